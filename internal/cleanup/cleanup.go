@@ -0,0 +1,532 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lu-zhengda/macbroom/internal/history"
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/trash"
+)
+
+// Methods recorded for each cleaned target.
+const (
+	MethodTrash     = "trash"
+	MethodPermanent = "permanent"
+	MethodCommand   = "command"
+)
+
+// DefaultConcurrency is how many cleanup jobs ExecuteAll runs at once.
+const DefaultConcurrency = 8
+
+// trashBatchSize is the most targets moved to Trash in one request when
+// the platform Trash supports batching.
+const trashBatchSize = 50
+
+// Result reports the outcome of cleaning a single target.
+type Result struct {
+	Target scanner.Target
+	Method string
+	Err    error
+
+	// Skipped is set when the target was never attempted because the
+	// context was cancelled first. Err is then the context's error.
+	Skipped bool
+
+	// TrashedPath is where a trashed target now lives inside the Trash.
+	// It is empty for other methods or when the location is unknown.
+	TrashedPath string
+}
+
+// HistoryItem returns the record of r kept in the cleanup history.
+func (r Result) HistoryItem() history.Item {
+	it := history.Item{
+		Path:     r.Target.Path,
+		Category: r.Target.Category,
+		Size:     r.Target.Size,
+		Method:   r.Method,
+		Trashed:  r.TrashedPath,
+	}
+	if r.Err != nil {
+		it.Error = r.Err.Error()
+	}
+	return it
+}
+
+// NestedError reports a target that was not cleaned because a target of
+// another category inside it, listed in Target.Contains, is not being
+// cleaned or failed to clean. Cleaning it would have removed that target
+// too. It matches trash.ErrProtected.
+type NestedError struct {
+	Path   string
+	Nested string
+	// Failed is set when Nested was selected but could not be cleaned.
+	Failed bool
+}
+
+func (e *NestedError) Error() string {
+	if e.Failed {
+		return fmt.Sprintf("refusing to delete %s: %s inside it could not be cleaned", e.Path, e.Nested)
+	}
+	return fmt.Sprintf("refusing to delete %s: it holds %s, which is not selected", e.Path, e.Nested)
+}
+
+func (e *NestedError) Is(target error) bool { return target == trash.ErrProtected }
+
+// Executor performs each target's cleanup action: filesystem targets are
+// moved to Trash or permanently deleted, command targets run their command.
+// Filesystem targets are checked by the deletion guard first, and refused
+// unless their scanner declared roots (see SetRoots); refusals are reported
+// as a *trash.ProtectedError.
+type Executor struct {
+	permanent bool
+
+	// concurrency bounds how many jobs ExecuteAll runs at once.
+	concurrency int
+
+	// batchSize is how many Trash targets ExecuteAll hands to
+	// moveAllToTrash at once; 1 when the platform Trash cannot batch.
+	batchSize int
+
+	// roots holds the declared roots of each scanner, keyed by category.
+	roots map[string][]string
+
+	// runCmd executes a command with stdin (which may be nil) and returns
+	// its combined output. Defaults to exec.CommandContext(...).CombinedOutput();
+	// override in tests.
+	runCmd func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error)
+
+	// moveToTrash and permanentDelete remove filesystem targets.
+	// Default to the trash package; override in tests.
+	moveToTrash     func(path string) (string, error)
+	moveAllToTrash  func(paths []string) ([]string, []error)
+	permanentDelete func(path string) error
+
+	// check is the deletion guard. Defaults to checkRooted; override in
+	// tests.
+	check func(path string, roots []string) error
+}
+
+// NewExecutor returns an Executor. When permanent is true, filesystem
+// targets are deleted instead of moved to Trash.
+func NewExecutor(permanent bool) *Executor {
+	batchSize := 1
+	if _, ok := trash.Default().(trash.BatchTrasher); ok {
+		batchSize = trashBatchSize
+	}
+	return &Executor{
+		permanent:   permanent,
+		concurrency: DefaultConcurrency,
+		batchSize:   batchSize,
+		runCmd: func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+			cmd := exec.CommandContext(ctx, name, args...)
+			if stdin != nil {
+				cmd.Stdin = bytes.NewReader(stdin)
+			}
+			return cmd.CombinedOutput()
+		},
+		moveToTrash:     trash.Move,
+		moveAllToTrash:  trash.MoveAll,
+		permanentDelete: trash.PermanentDelete,
+		check:           checkRooted,
+	}
+}
+
+// checkRooted is the default deletion guard: filesystem targets must come
+// from a scanner that declared its roots, and pass trash.Check against
+// them.
+func checkRooted(path string, roots []string) error {
+	if len(roots) == 0 {
+		return &trash.ProtectedError{Path: path, Resolved: path, Reason: trash.ReasonNoRoots}
+	}
+	return trash.Check(path, roots)
+}
+
+// SetRoots sets the directories each category's targets must stay under,
+// as returned by engine.Engine.Roots. Filesystem targets of categories
+// without roots are refused.
+func (e *Executor) SetRoots(roots map[string][]string) {
+	e.roots = roots
+}
+
+// SetConcurrency sets how many jobs ExecuteAll runs at once. Values below
+// 1 are treated as 1.
+func (e *Executor) SetConcurrency(n int) {
+	e.concurrency = max(n, 1)
+}
+
+// Method returns the method Execute uses for t. Commands marked
+// PermanentOnly are used only when cleaning permanently.
+func (e *Executor) Method(t scanner.Target) string {
+	if !t.IsFilesystem() {
+		return MethodCommand
+	}
+	if e.permanent {
+		if t.Action != nil && t.Action.PermanentOnly {
+			return MethodCommand
+		}
+		return MethodPermanent
+	}
+	return MethodTrash
+}
+
+// Describe returns a short, human-readable description of what Execute
+// would do for t. It is used for --dry-run listings and confirmations.
+func (e *Executor) Describe(t scanner.Target) string {
+	var d string
+	switch e.Method(t) {
+	case MethodCommand:
+		if t.IsFilesystem() {
+			return "permanently delete " + t.Path + " with " + t.Action.String()
+		}
+		return "run " + t.Action.String()
+	case MethodPermanent:
+		d = "permanently delete " + t.Path
+	default:
+		d = "move " + t.Path + " to Trash"
+	}
+	if n := len(t.Contains); n > 0 {
+		d += fmt.Sprintf(" (with the %d %s inside it)", n, plural(n, "item", "items"))
+	}
+	return d
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// Execute cleans a single target.
+func (e *Executor) Execute(ctx context.Context, t scanner.Target) Result {
+	r := Result{Target: t, Method: e.Method(t)}
+
+	if ctx.Err() != nil {
+		r.Err = ctx.Err()
+		r.Skipped = true
+		return r
+	}
+
+	if t.IsFilesystem() {
+		if err := e.check(t.Path, e.roots[t.Category]); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+
+	switch r.Method {
+	case MethodCommand:
+		r.Err = e.runAction(ctx, t.Action)
+	case MethodPermanent:
+		r.Err = e.permanentDelete(t.Path)
+	default:
+		r.TrashedPath, r.Err = e.moveToTrash(t.Path)
+	}
+	return r
+}
+
+// Progress is reported to the ExecuteAllWithProgress callback each time a
+// target has been dealt with.
+type Progress struct {
+	Result Result
+	// Done counts the targets dealt with so far, out of Total; Freed is
+	// the size of those cleaned successfully.
+	Done  int
+	Total int
+	Freed int64
+}
+
+// ExecuteAll cleans targets and returns one result per target, in the
+// order of targets.
+func (e *Executor) ExecuteAll(ctx context.Context, targets []scanner.Target) []Result {
+	return e.ExecuteAllWithProgress(ctx, targets, nil)
+}
+
+// ExecuteAllWithProgress is ExecuteAll with a callback invoked, one call at
+// a time, after each target. Targets are cleaned by a pool of workers, and
+// Trash targets are moved in batches when the platform allows it. Targets
+// whose paths are equal or nested are cleaned one after another by the same
+// worker, innermost first, so a directory is never removed while a target
+// inside it is still being cleaned.
+//
+// A target whose Contains lists paths that are not among targets, or that
+// failed to clean, is refused with a *NestedError.
+//
+// Cancelling ctx stops new work from starting: jobs already running are
+// finished, so each result says exactly whether its target was removed,
+// and the targets never attempted are returned with Skipped set.
+func (e *Executor) ExecuteAllWithProgress(ctx context.Context, targets []scanner.Target, onProgress func(Progress)) []Result {
+	results := make([]Result, len(targets))
+
+	var mu sync.Mutex
+	var done int
+	var freed int64
+	report := func(i int, r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[i] = r
+		done++
+		if r.Err == nil {
+			freed += r.Target.Size
+		}
+		if onProgress != nil {
+			onProgress(Progress{Result: r, Done: done, Total: len(targets), Freed: freed})
+		}
+	}
+
+	refused := unselectedNested(targets)
+	for i, err := range refused {
+		report(i, Result{Target: targets[i], Method: e.Method(targets[i]), Err: err})
+	}
+
+	jobs := e.jobs(targets, refused)
+	ch := make(chan job)
+	var wg sync.WaitGroup
+	for range min(e.concurrency, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				e.runJob(ctx, targets, j, report)
+			}
+		}()
+	}
+
+	sent := 0
+dispatch:
+	for _, j := range jobs {
+		select {
+		case ch <- j:
+			sent++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	for _, j := range jobs[sent:] {
+		for _, i := range j.idx {
+			report(i, e.Execute(ctx, targets[i]))
+		}
+	}
+	return results
+}
+
+// job is one unit of work for ExecuteAll's workers.
+type job struct {
+	// idx holds the indexes of the job's targets.
+	idx []int
+	// chain is set when the targets overlap. They are then cleaned one at
+	// a time, in order, instead of as one Trash batch.
+	chain bool
+}
+
+// unselectedNested returns, by index, a *NestedError for each target
+// holding a nested target that is not among targets.
+func unselectedNested(targets []scanner.Target) map[int]error {
+	selected := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		selected[filepath.Clean(t.Path)] = true
+	}
+	refused := make(map[int]error)
+	for i, t := range targets {
+		for _, p := range t.Contains {
+			if !selected[filepath.Clean(p)] {
+				refused[i] = &NestedError{Path: t.Path, Nested: p}
+				break
+			}
+		}
+	}
+	return refused
+}
+
+// jobs splits targets, by index, into the units of work ExecuteAll hands
+// to its workers: each chain of overlapping targets as one job, the other
+// Trash targets in batches of up to batchSize, every other target on its
+// own. Targets in skip are left out. Jobs are listed in the order of their
+// first target.
+func (e *Executor) jobs(targets []scanner.Target, skip map[int]error) []job {
+	chainOf := make(map[int][]int)
+	chained := make(map[int]bool)
+	for _, c := range overlapChains(targets) {
+		c = slices.DeleteFunc(c, func(i int) bool { return skip[i] != nil })
+		if len(c) == 0 {
+			continue
+		}
+		chainOf[slices.Min(c)] = c
+		for _, i := range c {
+			chained[i] = true
+		}
+	}
+
+	var jobs []job
+	var batch []int
+	for i, t := range targets {
+		if c, ok := chainOf[i]; ok {
+			jobs = append(jobs, job{idx: c, chain: true})
+			continue
+		}
+		if chained[i] || skip[i] != nil {
+			continue
+		}
+		if e.batchSize <= 1 || e.Method(t) != MethodTrash {
+			jobs = append(jobs, job{idx: []int{i}})
+			continue
+		}
+		batch = append(batch, i)
+		if len(batch) == e.batchSize {
+			jobs = append(jobs, job{idx: batch})
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		jobs = append(jobs, job{idx: batch})
+	}
+	return jobs
+}
+
+// overlapChains groups the filesystem targets whose paths are equal or
+// nested, by index. Each chain is ordered innermost first, so that a target
+// comes after every target inside it. Targets that overlap no other are
+// left out.
+func overlapChains(targets []scanner.Target) [][]int {
+	type entry struct {
+		idx int
+		key string // cleaned path with trailing separator
+	}
+	var entries []entry
+	for i, t := range targets {
+		if !t.IsFilesystem() {
+			continue
+		}
+		key := filepath.Clean(t.Path)
+		if !strings.HasSuffix(key, string(filepath.Separator)) {
+			key += string(filepath.Separator)
+		}
+		entries = append(entries, entry{idx: i, key: key})
+	}
+
+	// With a trailing separator on every key, lexical order places each
+	// path directly before its descendants.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	var chains [][]int
+	for start := 0; start < len(entries); {
+		end := start + 1
+		for end < len(entries) && strings.HasPrefix(entries[end].key, entries[start].key) {
+			end++
+		}
+		if end-start > 1 {
+			chain := slices.Clone(entries[start:end])
+			sort.SliceStable(chain, func(i, j int) bool { return len(chain[i].key) > len(chain[j].key) })
+			idx := make([]int, len(chain))
+			for k, c := range chain {
+				idx[k] = c.idx
+			}
+			chains = append(chains, idx)
+		}
+		start = end
+	}
+	return chains
+}
+
+// runJob cleans the targets of one job and reports each result.
+func (e *Executor) runJob(ctx context.Context, targets []scanner.Target, j job, report func(int, Result)) {
+	if ctx.Err() != nil {
+		for _, i := range j.idx {
+			report(i, e.Execute(ctx, targets[i]))
+		}
+		return
+	}
+
+	// Once started, a job runs to completion even if ctx is cancelled, so
+	// that a command is not killed half-way.
+	ctx = context.WithoutCancel(ctx)
+	if j.chain {
+		e.runChain(ctx, targets, j.idx, report)
+		return
+	}
+	if len(j.idx) == 1 {
+		report(j.idx[0], e.Execute(ctx, targets[j.idx[0]]))
+		return
+	}
+
+	var paths []string
+	var idx []int
+	for _, i := range j.idx {
+		t := targets[i]
+		if err := e.check(t.Path, e.roots[t.Category]); err != nil {
+			report(i, Result{Target: t, Method: MethodTrash, Err: err})
+			continue
+		}
+		paths = append(paths, t.Path)
+		idx = append(idx, i)
+	}
+	if len(paths) == 0 {
+		return
+	}
+	trashed, errs := e.moveAllToTrash(paths)
+	for j, i := range idx {
+		report(i, Result{Target: targets[i], Method: MethodTrash, TrashedPath: trashed[j], Err: errs[j]})
+	}
+}
+
+// runChain cleans overlapping targets one at a time, in order. A target
+// holding one that failed is refused rather than removed with it.
+func (e *Executor) runChain(ctx context.Context, targets []scanner.Target, idx []int, report func(int, Result)) {
+	failed := make(map[string]bool)
+	for _, i := range idx {
+		t := targets[i]
+		r := Result{Target: t, Method: e.Method(t)}
+		for _, p := range t.Contains {
+			if failed[filepath.Clean(p)] {
+				r.Err = &NestedError{Path: t.Path, Nested: p, Failed: true}
+				break
+			}
+		}
+		if r.Err == nil {
+			r = e.Execute(ctx, t)
+		}
+		if r.Err != nil {
+			failed[filepath.Clean(t.Path)] = true
+		}
+		report(i, r)
+	}
+}
+
+func (e *Executor) runAction(ctx context.Context, a *scanner.Action) error {
+	if len(a.Command) == 0 {
+		return fmt.Errorf("cleanup action has no command")
+	}
+	var stdin []byte
+	if a.Stdin != "" {
+		stdin = []byte(a.Stdin)
+	}
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+	out, err := e.runCmd(ctx, stdin, a.Command[0], a.Command[1:]...)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out after %s", a, a.Timeout)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("failed to run %s: %w", a, err)
+		}
+		return fmt.Errorf("failed to run %s: %w (%s)", a, err, msg)
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/trash"
+)
+
+// fakeExecutor returns an Executor that records calls instead of touching
+// the filesystem or running commands. It runs one job at a time without
+// batching, so calls are recorded in target order. Uncategorized targets
+// are rooted at /tmp.
+func fakeExecutor(permanent bool, calls *[]string) *Executor {
+	e := NewExecutor(permanent)
+	e.SetConcurrency(1)
+	e.batchSize = 1
+	e.SetRoots(map[string][]string{"": {"/tmp"}})
+	e.runCmd = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		*calls = append(*calls, "cmd:"+strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+	e.moveToTrash = func(path string) (string, error) {
+		*calls = append(*calls, "trash:"+path)
+		return "/Users/me/.Trash/" + filepath.Base(path), nil
+	}
+	e.moveAllToTrash = func(paths []string) ([]string, []error) {
+		*calls = append(*calls, "trashall:"+strings.Join(paths, " "))
+		trashed := make([]string, len(paths))
+		for i, p := range paths {
+			trashed[i] = "/Users/me/.Trash/" + filepath.Base(p)
+		}
+		return trashed, make([]error, len(paths))
+	}
+	e.permanentDelete = func(path string) error {
+		*calls = append(*calls, "delete:"+path)
+		return nil
+	}
+	return e
+}
+
+func TestExecute_DispatchesByAction(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/tmp/cache"},
+		{Path: "docker image abc", Action: scanner.CommandAction("docker", "rmi", "abc")},
+	}
+
+	tests := []struct {
+		name      string
+		permanent bool
+		want      []string
+		methods   []string
+	}{
+		{
+			name:    "trash",
+			want:    []string{"trash:/tmp/cache", "cmd:docker rmi abc"},
+			methods: []string{MethodTrash, MethodCommand},
+		},
+		{
+			name:      "permanent",
+			permanent: true,
+			want:      []string{"delete:/tmp/cache", "cmd:docker rmi abc"},
+			methods:   []string{MethodPermanent, MethodCommand},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			e := fakeExecutor(tt.permanent, &calls)
+			results := e.ExecuteAll(context.Background(), targets)
+
+			if strings.Join(calls, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("calls = %v, want %v", calls, tt.want)
+			}
+			for i, r := range results {
+				if r.Err != nil {
+					t.Errorf("result[%d]: unexpected error: %v", i, r.Err)
+				}
+				if r.Method != tt.methods[i] {
+					t.Errorf("result[%d]: method = %q, want %q", i, r.Method, tt.methods[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExecute_PermanentOnlyCommand(t *testing.T) {
+	action := scanner.CommandAction("go", "clean", "-modcache")
+	action.PermanentOnly = true
+	target := scanner.Target{Path: "/tmp/gomodcache", Action: action}
+
+	tests := []struct {
+		permanent bool
+		method    string
+		call      string
+		describe  string
+	}{
+		{false, MethodTrash, "trash:/tmp/gomodcache", "move /tmp/gomodcache to Trash"},
+		{true, MethodCommand, "cmd:go clean -modcache", "permanently delete /tmp/gomodcache with go clean -modcache"},
+	}
+	for _, tt := range tests {
+		var calls []string
+		e := fakeExecutor(tt.permanent, &calls)
+		var checked []string
+		e.check = func(path string, roots []string) error {
+			checked = append(checked, path)
+			return nil
+		}
+
+		if got := e.Describe(target); got != tt.describe {
+			t.Errorf("permanent=%v: Describe = %q, want %q", tt.permanent, got, tt.describe)
+		}
+		r := e.Execute(context.Background(), target)
+		if r.Err != nil || r.Method != tt.method {
+			t.Errorf("permanent=%v: unexpected result %+v", tt.permanent, r)
+		}
+		if strings.Join(calls, ",") != tt.call {
+			t.Errorf("permanent=%v: calls = %v, want %s", tt.permanent, calls, tt.call)
+		}
+		if len(checked) != 1 {
+			t.Errorf("permanent=%v: expected the guard to check the target, got %v", tt.permanent, checked)
+		}
+	}
+}
+
+func TestExecute_CommandFailureIncludesOutput(t *testing.T) {
+	e := NewExecutor(false)
+	e.runCmd = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return []byte("Error: No such image: abc\n"), errors.New("exit status 1")
+	}
+
+	r := e.Execute(context.Background(), scanner.Target{
+		Path:   "docker image abc",
+		Action: scanner.CommandAction("docker", "rmi", "abc"),
+	})
+	if r.Err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(r.Err.Error(), "No such image") {
+		t.Errorf("expected command output in error, got %v", r.Err)
+	}
+}
+
+func TestExecuteAll_CommandTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := NewExecutor(false)
+	e.runCmd = func(cmdCtx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		cancel()        // Ctrl+C does not stop a command that already started
+		<-cmdCtx.Done() // a plugin that hangs
+		return nil, cmdCtx.Err()
+	}
+	action := &scanner.Action{Kind: scanner.ActionCommand, Command: []string{"macbroom-scanner-acme"}, Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	results := e.ExecuteAllWithProgress(ctx, []scanner.Target{{Path: "acme://build/1", Action: action}}, nil)
+
+	if err := results[0].Err; err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("timeout was not enforced, took %s", time.Since(start))
+	}
+}
+
+func TestExecute_ContextCancelled(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(false, &calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := e.Execute(ctx, scanner.Target{Path: "/tmp/cache"})
+	if r.Err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", r.Err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected no calls after cancellation, got %v", calls)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	cmd := scanner.Target{Path: "docker build cache", Action: scanner.CommandAction("docker", "builder", "prune", "--force")}
+	file := scanner.Target{Path: "/tmp/cache"}
+
+	if got := NewExecutor(false).Describe(cmd); got != "run docker builder prune --force" {
+		t.Errorf("Describe(command) = %q", got)
+	}
+	if got := NewExecutor(false).Describe(file); got != "move /tmp/cache to Trash" {
+		t.Errorf("Describe(trash) = %q", got)
+	}
+	if got := NewExecutor(true).Describe(file); got != "permanently delete /tmp/cache" {
+		t.Errorf("Describe(permanent) = %q", got)
+	}
+}
+
+func TestExecute_GuardRefusesOutsideRoots(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(true, &calls)
+	var checked []string
+	e.check = func(path string, roots []string) error {
+		checked = append(checked, path+" in "+strings.Join(roots, ","))
+		if path == "/caches/escape" {
+			return &trash.ProtectedError{Path: path, Resolved: "/photos", Reason: trash.ReasonOutsideRoots, Rule: "/caches"}
+		}
+		return nil
+	}
+	e.SetRoots(map[string][]string{"System Junk": {"/caches"}})
+
+	results := e.ExecuteAll(context.Background(), []scanner.Target{
+		{Path: "/caches/ok", Category: "System Junk"},
+		{Path: "/caches/escape", Category: "System Junk"},
+		{Path: "docker image abc", Category: "Docker", Action: scanner.CommandAction("docker", "rmi", "abc")},
+	})
+
+	if results[0].Err != nil || !errors.Is(results[1].Err, trash.ErrProtected) || results[2].Err != nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if strings.Join(calls, ";") != "delete:/caches/ok;cmd:docker rmi abc" {
+		t.Errorf("refused target must not be deleted, calls: %v", calls)
+	}
+	if strings.Join(checked, ";") != "/caches/ok in /caches;/caches/escape in /caches" {
+		t.Errorf("expected only filesystem targets to be checked with their roots, got %v", checked)
+	}
+}
+
+func TestExecute_GuardRefusesUnrootedTargets(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(true, &calls)
+	e.SetRoots(map[string][]string{"System Junk": {"/tmp"}})
+
+	results := e.ExecuteAll(context.Background(), []scanner.Target{
+		{Path: "/tmp/ok", Category: "System Junk"},
+		{Path: "/tmp/plugin-cache", Category: "Plugin"},
+		{Path: "docker image abc", Category: "Docker", Action: scanner.CommandAction("docker", "rmi", "abc")},
+	})
+
+	var pe *trash.ProtectedError
+	if !errors.As(results[1].Err, &pe) || pe.Reason != trash.ReasonNoRoots {
+		t.Fatalf("expected a target from a scanner without roots to be refused, got %v", results[1].Err)
+	}
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if strings.Join(calls, ";") != "delete:/tmp/ok;cmd:docker rmi abc" {
+		t.Errorf("refused target must not be deleted, calls: %v", calls)
+	}
+}
+
+func TestExecuteAll_BatchesTrash(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(false, &calls)
+	e.batchSize = 2
+
+	var progress []Progress
+	results := e.ExecuteAllWithProgress(context.Background(), []scanner.Target{
+		{Path: "/tmp/a", Size: 1},
+		{Path: "/tmp/b", Size: 2},
+		{Path: "docker image abc", Size: 4, Action: scanner.CommandAction("docker", "rmi", "abc")},
+		{Path: "/tmp/c", Size: 8},
+	}, func(p Progress) { progress = append(progress, p) })
+
+	want := "trashall:/tmp/a /tmp/b;cmd:docker rmi abc;trash:/tmp/c"
+	if got := strings.Join(calls, ";"); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("result[%d]: unexpected error: %v", i, r.Err)
+		}
+	}
+	if results[1].TrashedPath != "/Users/me/.Trash/b" {
+		t.Errorf("expected batch result to carry its trashed path, got %q", results[1].TrashedPath)
+	}
+	if len(progress) != 4 {
+		t.Fatalf("expected 4 progress reports, got %d", len(progress))
+	}
+	if last := progress[3]; last.Done != 4 || last.Total != 4 || last.Freed != 15 {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
+
+func TestExecuteAll_Concurrent(t *testing.T) {
+	e := NewExecutor(true)
+	e.SetConcurrency(4)
+	e.check = func(string, []string) error { return nil }
+
+	var mu sync.Mutex
+	var inFlight, peak int
+	e.permanentDelete = func(string) error {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	targets := make([]scanner.Target, 8)
+	for i := range targets {
+		targets[i] = scanner.Target{Path: filepath.Join("/tmp", string(rune('a'+i)))}
+	}
+	results := e.ExecuteAll(context.Background(), targets)
+
+	for i, r := range results {
+		if r.Target.Path != targets[i].Path || r.Err != nil {
+			t.Errorf("result[%d] = %+v", i, r)
+		}
+	}
+	if peak < 2 || peak > 4 {
+		t.Errorf("expected 2-4 deletions in flight, peak was %d", peak)
+	}
+}
+
+func TestExecuteAll_CancelSkipsRemaining(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(true, &calls)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.permanentDelete = func(path string) error {
+		calls = append(calls, "delete:"+path)
+		cancel() // Ctrl+C while the first deletion runs
+		return nil
+	}
+
+	var last Progress
+	results := e.ExecuteAllWithProgress(ctx, []scanner.Target{
+		{Path: "/tmp/a"}, {Path: "/tmp/b"}, {Path: "/tmp/c"},
+	}, func(p Progress) { last = p })
+
+	if len(calls) != 1 {
+		t.Fatalf("expected one deletion before cancellation, got %v", calls)
+	}
+	if results[0].Err != nil || results[0].Skipped {
+		t.Errorf("the running deletion must finish, got %+v", results[0])
+	}
+	for _, r := range results[1:] {
+		if !r.Skipped || r.Err != context.Canceled {
+			t.Errorf("expected %s to be skipped, got %+v", r.Target.Path, r)
+		}
+	}
+	if last.Done != 3 || last.Total != 3 {
+		t.Errorf("expected every target to be reported, got %+v", last)
+	}
+}
+
+func TestExecuteAll_NestedTargetsCleanInnermostFirst(t *testing.T) {
+	e := NewExecutor(false)
+	e.SetConcurrency(8)
+	e.batchSize = 50
+	e.check = func(string, []string) error { return nil }
+
+	var mu sync.Mutex
+	var events []string
+	record := func(ev string) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}
+	e.moveToTrash = func(path string) (string, error) {
+		record("start:" + path)
+		time.Sleep(20 * time.Millisecond)
+		record("end:" + path)
+		return "/Users/me/.Trash/" + filepath.Base(path), nil
+	}
+
+	// The ancestor is listed before its descendants, so the order can only
+	// come from the paths.
+	results := e.ExecuteAll(context.Background(), []scanner.Target{
+		{Path: "/Users/me/Library/Caches/Google", Category: "System Junk"},
+		{Path: "/Users/me/Library/Caches/Other", Category: "System Junk"},
+		{Path: "/Users/me/Library/Caches/Google/Chrome", Category: "Browser Cache"},
+		{Path: "/Users/me/Library/Caches/Google/Chrome/Default", Category: "Browser Cache"},
+	})
+
+	for i, r := range results {
+		if r.Err != nil {
+			t.Errorf("result[%d]: unexpected error: %v", i, r.Err)
+		}
+	}
+	var chain []string
+	for _, ev := range events {
+		if strings.Contains(ev, "Google") {
+			chain = append(chain, ev)
+		}
+	}
+	want := []string{
+		"start:/Users/me/Library/Caches/Google/Chrome/Default",
+		"end:/Users/me/Library/Caches/Google/Chrome/Default",
+		"start:/Users/me/Library/Caches/Google/Chrome",
+		"end:/Users/me/Library/Caches/Google/Chrome",
+		"start:/Users/me/Library/Caches/Google",
+		"end:/Users/me/Library/Caches/Google",
+	}
+	if strings.Join(chain, ";") != strings.Join(want, ";") {
+		t.Errorf("nested targets must be cleaned one at a time, innermost first:\n got %v\nwant %v", chain, want)
+	}
+	if !slices.Contains(events, "end:/Users/me/Library/Caches/Other") {
+		t.Errorf("expected the unrelated target to be cleaned, got %v", events)
+	}
+}
+
+func TestExecuteAll_RefusesAncestorWithoutNestedTargets(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(true, &calls)
+	e.check = func(string, []string) error { return nil }
+
+	ancestor := scanner.Target{Path: "/L/Caches/Google", Category: "System Junk", Contains: []string{"/L/Caches/Google/Chrome"}}
+	results := e.ExecuteAll(context.Background(), []scanner.Target{ancestor, {Path: "/L/Caches/pip"}})
+
+	var ne *NestedError
+	if !errors.As(results[0].Err, &ne) || ne.Failed || !errors.Is(results[0].Err, trash.ErrProtected) {
+		t.Fatalf("expected the ancestor to be refused, got %+v", results[0])
+	}
+	if results[1].Err != nil {
+		t.Errorf("unrelated target: unexpected error %v", results[1].Err)
+	}
+	if strings.Join(calls, ";") != "delete:/L/Caches/pip" {
+		t.Errorf("refused ancestor must not be deleted, calls: %v", calls)
+	}
+}
+
+func TestExecuteAll_RefusesAncestorWhenNestedTargetFails(t *testing.T) {
+	var calls []string
+	e := fakeExecutor(true, &calls)
+	e.check = func(string, []string) error { return nil }
+	e.permanentDelete = func(path string) error {
+		calls = append(calls, "delete:"+path)
+		if path == "/L/Caches/Google/Chrome" {
+			return errors.New("permission denied")
+		}
+		return nil
+	}
+
+	results := e.ExecuteAll(context.Background(), []scanner.Target{
+		{Path: "/L/Caches/Google", Category: "System Junk", Contains: []string{"/L/Caches/Google/Chrome"}},
+		{Path: "/L/Caches/Google/Chrome", Category: "Browser Cache"},
+	})
+
+	var ne *NestedError
+	if !errors.As(results[0].Err, &ne) || !ne.Failed {
+		t.Fatalf("expected the ancestor to be refused after its descendant failed, got %+v", results[0])
+	}
+	if strings.Join(calls, ";") != "delete:/L/Caches/Google/Chrome" {
+		t.Errorf("expected only the descendant to be attempted, calls: %v", calls)
+	}
+}
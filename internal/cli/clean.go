@@ -1,11 +1,20 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"time"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
 
+	"github.com/lu-zhengda/macbroom/internal/cleanup"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/history"
+	"github.com/lu-zhengda/macbroom/internal/manifest"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
+	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/lu-zhengda/macbroom/internal/schedule"
 	"github.com/lu-zhengda/macbroom/internal/trash"
 	"github.com/lu-zhengda/macbroom/internal/utils"
@@ -19,6 +28,7 @@ var (
 	cleanQuiet     bool
 	cleanFilter    CategoryFilter
 	cleanExclude   []string
+	cleanDirty     bool
 )
 
 // cleanPrint prints to stdout only when --quiet and --json are not set.
@@ -45,46 +55,42 @@ var cleanCmd = &cobra.Command{
 			combined = append(combined, cleanExclude...)
 			appConfig.Exclude = combined
 		}
+		if cleanDirty {
+			appConfig.DevTools.IncludeDirty = true
+		}
 		e := buildEngine()
+		if err := resolveCustomFilter(&cleanFilter, e); err != nil {
+			return err
+		}
 		cats := selectedCategories(cleanFilter)
 
-		cleanPrintln("Scanning...")
-		targets, err := scanWithCategories(e, cats)
+		var onProgress func(engine.ScanProgress)
+		status := newScanStatusLine(os.Stdout)
+		if !cleanQuiet && !jsonFlag {
+			onProgress = status.update
+		}
+		targets, overlaps, failures, err := scanWithCategories(e, cats, onProgress)
+		status.clear()
 		if err != nil {
 			return fmt.Errorf("scan failed: %w", err)
 		}
 
 		if len(targets) == 0 {
 			if jsonFlag {
-				return printJSON(cleanJSON{
-					scanJSON: buildScanJSON(targets, nil),
-				})
+				sj := buildScanJSON(targets, nil)
+				sj.setFailures(failures)
+				return printJSON(cleanJSON{scanJSON: sj})
 			}
 			cleanPrintln("Nothing to clean!")
+			if !cleanQuiet {
+				printScanFailures(failures)
+			}
 			return nil
 		}
 
 		prev, prevErr := scancache.Load(scancache.DefaultPath())
 
-		grouped := make(map[string]*scancache.CategorySnapshot)
-		for _, t := range targets {
-			cs := grouped[t.Category]
-			if cs == nil {
-				cs = &scancache.CategorySnapshot{Name: t.Category}
-				grouped[t.Category] = cs
-			}
-			cs.Size += t.Size
-			cs.Items++
-		}
-		var snapCats []scancache.CategorySnapshot
-		for _, cs := range grouped {
-			snapCats = append(snapCats, *cs)
-		}
-		var snapTotal int64
-		for _, t := range targets {
-			snapTotal += t.Size
-		}
-		curr := scancache.Snapshot{Timestamp: time.Now().UTC(), Categories: snapCats, TotalSize: snapTotal}
+		curr := newSnapshot(targets, overlaps)
 
 		var diff *scancache.DiffResult
 		if prevErr == nil {
@@ -94,6 +100,8 @@ var cleanCmd = &cobra.Command{
 
 		if !cleanQuiet && !jsonFlag {
 			printScanResults(targets, diff)
+			printOverlapSummary(overlaps)
+			printScanFailures(failures)
 		}
 		_ = scancache.Save(scancache.DefaultPath(), curr)
 
@@ -101,13 +109,25 @@ var cleanCmd = &cobra.Command{
 		for _, t := range targets {
 			totalSize += t.Size
 		}
+		totalUsage := utils.Usage{
+			Apparent:  totalIn(targets, utils.SizeApparent),
+			Allocated: totalIn(targets, utils.SizeAllocated),
+		}
+
+		executor := cleanup.NewExecutor(cleanPermanent)
+		executor.SetRoots(e.Roots())
 
 		if cleanDryRun {
 			action := "move"
 			if cleanPermanent {
 				action = "permanently delete"
 			}
-			cleanPrint("\n[DRY RUN] Would %s %d items (%s).\n", action, len(targets), utils.FormatSize(totalSize))
+			cleanPrint("\n[DRY RUN] Would %s %d items (%s)%s.\n", action, len(targets), utils.FormatSize(totalSize), otherSize(totalUsage))
+			for _, t := range targets {
+				if executor.Method(t) == cleanup.MethodCommand || len(t.Contains) > 0 {
+					cleanPrint("[DRY RUN] Would %s\n", executor.Describe(t))
+				}
+			}
 			cleanPrintln("[DRY RUN] No files were deleted.")
 			return nil
 		}
@@ -132,72 +152,182 @@ var cleanCmd = &cobra.Command{
 		}
 
 		type catResult struct {
-			items int
-			bytes int64
+			items   int
+			bytes   int64
+			trashed int64
+		}
+		type catKey struct {
+			category string
+			method   string
+		}
+		byCategory := make(map[catKey]*catResult)
+		addCategory := func(key catKey, items int, bytes int64) {
+			cr := byCategory[key]
+			if cr == nil {
+				cr = &catResult{}
+				byCategory[key] = cr
+			}
+			cr.items += items
+			cr.bytes += bytes
+			if key.method == cleanup.MethodTrash {
+				cr.trashed += bytes
+			}
 		}
-		byCategory := make(map[string]*catResult)
 
-		var cleaned, failed int
-		var deletedSize int64
-		for _, t := range targets {
-			var err error
-			if cleanPermanent {
-				err = trash.PermanentDelete(t.Path)
-			} else {
-				err = trash.MoveToTrash(t.Path)
+		// Ctrl+C stops new deletions and reports what was left; a second
+		// one exits immediately.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		context.AfterFunc(ctx, stop)
+
+		var onClean func(cleanup.Progress)
+		cleanStatus := newCleanStatusLine(os.Stdout)
+		if !cleanQuiet && !jsonFlag {
+			onClean = cleanStatus.update
+		}
+		// Free space is measured on every volume a target is on, and on the
+		// home volume, where cleanup commands such as docker and brew work.
+		vols := utils.Volumes(append(filesystemPaths(targets), utils.HomeDir()))
+		freeBefore := utils.FreeOn(vols)
+		results := executor.ExecuteAllWithProgress(ctx, targets, onClean)
+		freeAfter := utils.FreeOn(vols)
+		measured := utils.Reclaimed(freeBefore, freeAfter)
+		cleanStatus.clear()
+
+		var cleaned, failed, refused, skipped int
+		var deletedSize, trashedSize int64
+		var deletedUsage utils.Usage
+		run := manifest.New("clean")
+		actions := make([]cleanActionJSON, 0, len(results))
+		var items []history.Item
+		for _, r := range results {
+			t := r.Target
+			if !r.Skipped {
+				items = append(items, r.HistoryItem())
+			}
+			aj := cleanActionJSON{Path: t.Path, Method: r.Method}
+			if r.Method == cleanup.MethodCommand {
+				aj.Command = t.Action.String()
 			}
-			if err != nil {
-				cleanPrint("  Failed: %s (%v)\n", t.Path, err)
+			if r.Skipped {
+				cleanPrint("  Skipped: %s\n", t.Path)
+				skipped++
+				aj.Error = r.Err.Error()
+				aj.Skipped = true
+			} else if errors.Is(r.Err, trash.ErrProtected) {
+				cleanPrint("  Refused: %v\n", r.Err)
+				refused++
+				aj.Error = r.Err.Error()
+				aj.Protected = true
+			} else if pe := (*trash.PartialError)(nil); errors.As(r.Err, &pe) {
+				freed := max(t.Size-pe.Remaining.In(sizeMode()), 0)
+				cleanPrint("  Partially deleted: %s (%s freed, %s left: %v)\n",
+					t.Path, utils.FormatSize(freed), utils.FormatSize(pe.Remaining.In(sizeMode())), pe.Err)
+				failed++
+				deletedSize += freed
+				addCategory(catKey{category: t.Category, method: r.Method}, 0, freed)
+				aj.Error = r.Err.Error()
+				aj.Partial = true
+				aj.FreedSize = freed
+			} else if r.Err != nil {
+				cleanPrint("  Failed: %s (%v)\n", t.Path, r.Err)
 				failed++
+				aj.Error = r.Err.Error()
 			} else {
+				if r.Method == cleanup.MethodCommand {
+					cleanPrint("  Ran: %s\n", t.Action)
+				}
+				if r.Method == cleanup.MethodTrash {
+					run.Add(t.Path, r.TrashedPath, t.Size, t.Category)
+					trashedSize += t.Size
+				}
 				cleaned++
 				deletedSize += t.Size
-				cr := byCategory[t.Category]
-				if cr == nil {
-					cr = &catResult{}
-					byCategory[t.Category] = cr
-				}
-				cr.items++
-				cr.bytes += t.Size
+				deletedUsage.Add(t.Usage)
+				addCategory(catKey{category: t.Category, method: r.Method}, 1, t.Size)
+				aj.OK = true
 			}
+			actions = append(actions, aj)
 		}
 
-		// Record cleanup history per category.
-		method := "trash"
-		if cleanPermanent {
-			method = "permanent"
-		}
-		h := history.New(history.DefaultPath())
-		now := time.Now()
-		for cat, cr := range byCategory {
-			_ = h.Record(history.Entry{
-				Timestamp:  now,
-				Category:   cat,
-				Items:      cr.items,
-				BytesFreed: cr.bytes,
-				Method:     method,
+		runID := manifest.SaveRun(run)
+
+		// Record the run in the cleanup history, with a summary per
+		// category and method.
+		hrun := history.Run{
+			ID:            runID,
+			Timestamp:     run.Timestamp,
+			Command:       "clean",
+			BytesMeasured: measured,
+			Items:         items,
+		}
+		for key, cr := range byCategory {
+			hrun.Entries = append(hrun.Entries, history.Entry{
+				Category:     key.category,
+				Items:        cr.items,
+				BytesFreed:   cr.bytes,
+				BytesTrashed: cr.trashed,
+				Method:       key.method,
 			})
 		}
+		sort.Slice(hrun.Entries, func(i, j int) bool {
+			a, b := hrun.Entries[i], hrun.Entries[j]
+			if a.Category != b.Category {
+				return a.Category < b.Category
+			}
+			return a.Method < b.Method
+		})
+		if len(items) > 0 {
+			_ = history.New(history.DefaultPath()).RecordRun(hrun)
+		}
 
 		if jsonFlag {
+			sj := buildScanJSON(targets, diff)
+			sj.setOverlaps(overlaps)
+			sj.setSizeCache(e.DirCacheStats())
+			sj.setFailures(failures)
 			result := cleanJSON{
-				scanJSON:     buildScanJSON(targets, diff),
+				scanJSON:     sj,
 				DeletedSize:  deletedSize,
+				TrashedSize:  trashedSize,
 				DeletedItems: cleaned,
 				Errors:       failed,
+				Refused:      refused,
+				Skipped:      skipped,
+				Actions:      actions,
+				RunID:        runID,
+			}
+			if len(freeAfter) > 0 {
+				result.MeasuredFreed = &measured
 			}
 			return printJSON(result)
 		}
 
-		cleanPrint("\nCleaned %d items (%s freed)", cleaned, utils.FormatSize(totalSize))
+		cleanPrint("\nCleaned %d items (%s)%s", cleaned, cleanedSizes(trashedSize, deletedSize-trashedSize), otherSize(deletedUsage))
 		if failed > 0 {
 			cleanPrint(", %d failed", failed)
 		}
+		if refused > 0 {
+			cleanPrint(", %d refused (protected)", refused)
+		}
+		if skipped > 0 {
+			cleanPrint(", %d not cleaned (interrupted)", skipped)
+		}
 		cleanPrintln()
+		if len(freeAfter) > 0 {
+			cleanPrint("Disk space reclaimed: %s measured, %s expected", utils.FormatSize(max(measured, 0)), utils.FormatSize(deletedSize-trashedSize))
+			if trashedSize > 0 {
+				cleanPrint(" (%s more once the Trash is emptied)", utils.FormatSize(trashedSize))
+			}
+			cleanPrintln()
+		}
+		if !cleanQuiet {
+			printUndoHint(runID)
+		}
 
 		// Send macOS notification when running in quiet mode with notify enabled.
 		if cleanQuiet && appConfig != nil && appConfig.Schedule.Notify && cleaned > 0 {
-			msg := fmt.Sprintf("Cleaned %d items, freed %s", cleaned, utils.FormatSize(totalSize))
+			msg := fmt.Sprintf("Cleaned %d items (%s)", cleaned, cleanedSizes(trashedSize, deletedSize-trashedSize))
 			_ = schedule.Notify("macbroom", msg)
 		}
 
@@ -211,23 +341,32 @@ func init() {
 	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Show what would be deleted without actually deleting")
 	cleanCmd.Flags().BoolVarP(&cleanQuiet, "quiet", "q", false, "Suppress all output (for scheduled runs)")
 	f := cleanCmd.Flags()
-	f.BoolVar(&cleanFilter.System, "system", false, "Clean system junk only")
-	f.BoolVar(&cleanFilter.Browser, "browser", false, "Clean browser caches only")
-	f.BoolVar(&cleanFilter.Xcode, "xcode", false, "Clean Xcode junk only")
-	f.BoolVar(&cleanFilter.Large, "large", false, "Clean large/old files only")
-	f.BoolVar(&cleanFilter.Docker, "docker", false, "Clean Docker junk only")
-	f.BoolVar(&cleanFilter.Node, "node", false, "Clean Node.js cache only")
-	f.BoolVar(&cleanFilter.Homebrew, "homebrew", false, "Clean Homebrew cache only")
-	f.BoolVar(&cleanFilter.Simulator, "simulator", false, "Clean iOS Simulator data only")
-	f.BoolVar(&cleanFilter.Python, "python", false, "Clean Python cache only")
-	f.BoolVar(&cleanFilter.Rust, "rust", false, "Clean Rust cache only")
-	f.BoolVar(&cleanFilter.Go, "go", false, "Clean Go cache only")
-	f.BoolVar(&cleanFilter.JetBrains, "jetbrains", false, "Clean JetBrains cache only")
-	f.BoolVar(&cleanFilter.Maven, "maven", false, "Clean Maven cache only")
-	f.BoolVar(&cleanFilter.Gradle, "gradle", false, "Clean Gradle cache only")
-	f.BoolVar(&cleanFilter.Ruby, "ruby", false, "Clean Ruby cache only")
-	f.BoolVar(&cleanFilter.Dev, "dev", false, "Clean all dev-tool caches")
-	f.BoolVar(&cleanFilter.Caches, "caches", false, "Clean all general caches")
-	f.BoolVar(&cleanFilter.All, "all", false, "Clean everything")
+	addCategoryFlags(f, &cleanFilter, "Clean")
+	f.StringSliceVar(&cleanFilter.Custom, "custom", nil, "Clean the named custom scanner or plugin (repeatable)")
 	f.StringSliceVar(&cleanExclude, "exclude", nil, "Exclude paths matching pattern (glob or dir/**)")
+	f.BoolVar(&cleanDirty, "include-dirty", false, "Include build artifacts of projects with uncommitted changes")
+}
+
+// cleanedSizes describes what a clean did with the space it cleaned, as in
+// "1.2 GB moved to Trash, 300.0 MB freed".
+func cleanedSizes(trashed, freed int64) string {
+	var parts []string
+	if trashed > 0 {
+		parts = append(parts, utils.FormatSize(trashed)+" moved to Trash")
+	}
+	if freed > 0 || trashed == 0 {
+		parts = append(parts, utils.FormatSize(freed)+" freed")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// filesystemPaths returns the paths of the targets that live on disk.
+func filesystemPaths(targets []scanner.Target) []string {
+	var paths []string
+	for _, t := range targets {
+		if t.IsFilesystem() {
+			paths = append(paths, t.Path)
+		}
+	}
+	return paths
 }
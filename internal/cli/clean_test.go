@@ -0,0 +1,20 @@
+package cli
+
+import "testing"
+
+func TestCleanedSizes(t *testing.T) {
+	tests := []struct {
+		trashed, freed int64
+		want           string
+	}{
+		{0, 0, "0 B freed"},
+		{2 << 30, 0, "2.0 GB moved to Trash"},
+		{0, 300 << 20, "300.0 MB freed"},
+		{2 << 30, 300 << 20, "2.0 GB moved to Trash, 300.0 MB freed"},
+	}
+	for _, tt := range tests {
+		if got := cleanedSizes(tt.trashed, tt.freed); got != tt.want {
+			t.Errorf("cleanedSizes(%d, %d) = %q, want %q", tt.trashed, tt.freed, got, tt.want)
+		}
+	}
+}
@@ -2,11 +2,16 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/lu-zhengda/macbroom/internal/dupes"
+	"github.com/lu-zhengda/macbroom/internal/manifest"
+	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/trash"
 	"github.com/lu-zhengda/macbroom/internal/utils"
 	"github.com/spf13/cobra"
@@ -16,13 +21,34 @@ var (
 	dupesMinSize int64
 	dupesYes     bool
 	dupesDryRun  bool
+	dupesPrune   bool
+	dupesWorkers int
+	dupesLink    bool
+	dupesLinkAs  string
+	dupesKeep    []string
+	dupesFiles   bool
 )
 
 var dupesCmd = &cobra.Command{
 	Use:   "dupes [dirs...]",
 	Short: "Find duplicate files",
-	Long:  "Scan directories for duplicate files using a three-pass algorithm:\n1. Group files by size\n2. Partial hash (first 4KB) for same-size files\n3. Full SHA256 only when partial hashes match\n\nDefaults to ~/Downloads, ~/Desktop, ~/Documents if no dirs given.",
+	Long:  "Scan directories for duplicate files using a three-pass algorithm:\n1. Group files by size\n2. Partial hash (4KB each from the start, middle and end) for same-size files\n3. Full SHA256 only when partial hashes match\n\nDirectories whose files are all duplicates of another directory's, with the\nsame names and layout, are reported as one directory group ahead of the\nfile groups (--files-only turns this off). A directory holding anything the\nsearch skips (hidden or small files, symlinks, git repositories) is never\nreported; .DS_Store files are ignored.\n\nFiles are hashed on several workers at once (--workers, or dupes.workers in\nthe config).\n\nDefaults to ~/Downloads, ~/Desktop, ~/Documents if no dirs given.\n\nThe copy kept in each group is chosen by keep rules (--keep, or dupes.keep\nin the config), applied in order until one copy is left:\n  path:DIR       prefer files under DIR; repeat for a priority list\n  glob:PATTERN   prefer files matching PATTERN\n  not-downloads  prefer files outside ~/Downloads\n  oldest/newest  prefer the earliest/latest modification time\n  shortest-path  prefer the shortest path\nWithout rules, the first file found is kept.\n\nHashes are cached in ~/.local/share/macbroom/dupes-hashes.json and reused\nfor files whose size, mtime and inode have not changed. --rescan ignores\nthe cache; --prune-cache drops entries for files that are gone or changed.",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dupesPrune {
+			return pruneHashCache()
+		}
+		linkType, err := dupes.ParseLinkType(dupesLinkAs)
+		if err != nil {
+			return err
+		}
+		// --keep replaces the dupes.keep rules from the config.
+		var keep []dupes.KeepRule
+		if cmd.Flags().Changed("keep") {
+			if keep, err = dupes.ParseKeepRules(dupesKeep); err != nil {
+				return err
+			}
+		}
+
 		dirs := args
 		if len(dirs) == 0 {
 			home := utils.HomeDir()
@@ -40,25 +66,36 @@ var dupesCmd = &cobra.Command{
 			fmt.Printf("Scanning for duplicates in: %s\n", strings.Join(dirs, ", "))
 		}
 
-		var fileCount int
-		progressFn := func(path string) {
-			fileCount++
-			if !jsonFlag && fileCount%500 == 0 {
-				fmt.Printf("\r  Scanned %d files...", fileCount)
+		var status dupesStatusLine
+		progressFn := func(p dupes.Progress) {
+			if !jsonFlag {
+				status.update(os.Stdout, p)
 			}
 		}
-		groups, err := dupes.FindWithProgress(context.Background(), dirs, dupesMinSize, progressFn)
+		cache, _ := dupes.LoadHashCache(scancache.HashCachePath())
+		cache.SetRefresh(rescanFlag)
+		ctx := dupes.WithHashCache(context.Background(), cache)
+		groups, err := dupes.FindWithOptions(ctx, dirs, dupes.Options{
+			MinSize:    dupesMinSize,
+			Workers:    dupesWorkers,
+			OnProgress: progressFn,
+			Keep:       keep,
+			// Links are made between files, so --link compares files only.
+			FilesOnly: dupesFiles || dupesLink,
+		})
+		status.clear(os.Stdout)
 		if err != nil {
 			return fmt.Errorf("failed to scan for duplicates: %w", err)
 		}
-
-		if !jsonFlag && fileCount >= 500 {
-			fmt.Println() // newline after progress
-		}
+		// A cache that cannot be written only costs the next run its
+		// speed-up, so errors are dropped.
+		_ = cache.Save()
 
 		// --json mode: output JSON and return (acts like --dry-run).
 		if jsonFlag {
-			return printJSON(buildDupesJSON(groups))
+			result := buildDupesJSON(groups)
+			result.setHashCache(cache.Stats())
+			return printJSON(result)
 		}
 
 		if len(groups) == 0 {
@@ -67,43 +104,71 @@ var dupesCmd = &cobra.Command{
 		}
 
 		var totalWasted int64
-		var totalFiles int
+		var totalFiles, totalDirs int
 		for _, g := range groups {
 			wasted := g.Size * int64(len(g.Files)-1)
 			totalWasted += wasted
-			totalFiles += len(g.Files)
+			if g.Dir {
+				totalDirs += len(g.Files)
+			} else {
+				totalFiles += len(g.Files)
+			}
 		}
 
-		fmt.Printf("\nFound %d duplicate groups (%d files, %s wasted)\n",
-			len(groups), totalFiles, utils.FormatSize(totalWasted))
+		if totalDirs > 0 {
+			fmt.Printf("\nFound %d duplicate groups (%d directories and %d files, %s wasted)\n",
+				len(groups), totalDirs, totalFiles, utils.FormatSize(totalWasted))
+		} else {
+			fmt.Printf("\nFound %d duplicate groups (%d files, %s wasted)\n",
+				len(groups), totalFiles, utils.FormatSize(totalWasted))
+		}
 		fmt.Println(strings.Repeat("-", 60))
 
 		for i, g := range groups {
 			wasted := g.Size * int64(len(g.Files)-1)
-			fmt.Printf("\nGroup %d: %s each, %d files (%s wasted)\n",
-				i+1, utils.FormatSize(g.Size), len(g.Files), utils.FormatSize(wasted))
+			if g.Dir {
+				fmt.Printf("\nGroup %d: %d directories of %d files, %s each (%s wasted)\n",
+					i+1, len(g.Files), g.FileCount, utils.FormatSize(g.Size), utils.FormatSize(wasted))
+			} else {
+				fmt.Printf("\nGroup %d: %s each, %d files (%s wasted)\n",
+					i+1, utils.FormatSize(g.Size), len(g.Files), utils.FormatSize(wasted))
+			}
 			fmt.Printf("  Hash: %s\n", g.Hash[:16]+"...")
 			for j, f := range g.Files {
 				label := "  "
 				if j == 0 {
-					label = "  [keep] "
+					label = "  [keep]   "
+				} else if dupesLink {
+					label = "  [link]   "
 				} else {
-					label = "  [copy] "
+					label = "  [remove] "
+				}
+				if g.Dir {
+					f += string(filepath.Separator)
+				}
+				if j == 0 && g.KeptBy == dupes.KeptWithDir {
+					f += dimStyle.Render("  (inside a kept directory)")
+				} else if j == 0 && g.KeptBy != "" {
+					f += dimStyle.Render("  (kept by " + g.KeptBy + ")")
 				}
 				fmt.Printf("%s%s\n", label, f)
 			}
 		}
 
+		if dupesLink {
+			return linkDuplicates(groups, linkType)
+		}
+
 		if dupesDryRun {
 			fmt.Printf("\n[DRY RUN] Would delete %d duplicate copies (%s).\n",
-				totalFiles-len(groups), utils.FormatSize(totalWasted))
+				totalFiles+totalDirs-len(groups), utils.FormatSize(totalWasted))
 			fmt.Println("[DRY RUN] No files were deleted.")
 			return nil
 		}
 
 		printYoloWarning()
 
-		deleteCount := totalFiles - len(groups)
+		deleteCount := totalFiles + totalDirs - len(groups)
 		if !shouldSkipConfirm(dupesYes) {
 			if !confirmAction(fmt.Sprintf("\nMove %d duplicate copies (%s) to Trash? (keeps 1 per group)",
 				deleteCount, utils.FormatSize(totalWasted))) {
@@ -114,13 +179,15 @@ var dupesCmd = &cobra.Command{
 
 		var deleted, failed int
 		var freedSize int64
+		run := manifest.New("dupes")
 		for _, g := range groups {
 			// Keep the first file, delete the rest.
 			for _, f := range g.Files[1:] {
-				if err := trash.MoveToTrash(f); err != nil {
+				if trashed, err := trash.Move(f); err != nil {
 					fmt.Printf("  Failed: %s (%v)\n", f, err)
 					failed++
 				} else {
+					run.Add(f, trashed, g.Size, "")
 					deleted++
 					freedSize += g.Size
 				}
@@ -132,6 +199,7 @@ var dupesCmd = &cobra.Command{
 			fmt.Printf(", %d failed", failed)
 		}
 		fmt.Println()
+		printUndoHint(manifest.SaveRun(run))
 
 		return nil
 	},
@@ -141,4 +209,137 @@ func init() {
 	dupesCmd.Flags().Int64Var(&dupesMinSize, "min-size", 0, "Minimum file size in bytes (0 = no minimum)")
 	dupesCmd.Flags().BoolVarP(&dupesYes, "yes", "y", false, "Skip confirmation prompt")
 	dupesCmd.Flags().BoolVar(&dupesDryRun, "dry-run", false, "Show duplicates without deleting")
+	dupesCmd.Flags().IntVar(&dupesWorkers, "workers", 0, "Files hashed at once (default: dupes.workers, or one per CPU up to 8)")
+	dupesCmd.Flags().StringArrayVar(&dupesKeep, "keep", nil, "Rule choosing the copy to keep, in priority order; repeatable (path:DIR, glob:PATTERN, not-downloads, oldest, newest, shortest-path)")
+	dupesCmd.Flags().BoolVar(&dupesFiles, "files-only", false, "Report duplicate files one by one instead of rolling up duplicate directories")
+	dupesCmd.Flags().BoolVar(&dupesLink, "link", false, "Replace duplicate copies with clones or hardlinks of the kept file instead of deleting them")
+	dupesCmd.Flags().StringVar(&dupesLinkAs, "link-type", "auto", "With --link: auto (clone where supported, else hardlink if modification times match), clone or hardlink")
+	dupesCmd.Flags().BoolVar(&dupesPrune, "prune-cache", false, "Remove cached hashes of files that are gone or changed, then exit")
+}
+
+// linkDuplicates replaces every copy but the first of each group with a
+// clone of, or hardlink to, the first.
+func linkDuplicates(groups []dupes.Group, linkType dupes.LinkType) error {
+	var copies int
+	var paths []string
+	var expected int64
+	for _, g := range groups {
+		copies += len(g.Files) - 1
+		expected += g.Size * int64(len(g.Files)-1)
+		paths = append(paths, g.Files...)
+	}
+
+	if dupesDryRun {
+		fmt.Printf("\n[DRY RUN] Would replace %d duplicate copies (%s) with links to the kept file.\n",
+			copies, utils.FormatSize(expected))
+		fmt.Println("[DRY RUN] No files were changed.")
+		return nil
+	}
+
+	printYoloWarning()
+
+	if !shouldSkipConfirm(dupesYes) {
+		if !confirmAction(fmt.Sprintf("\nReplace %d duplicate copies (%s) with links to the kept file? (all paths stay in place)",
+			copies, utils.FormatSize(expected))) {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	vols := utils.Volumes(paths)
+	freeBefore := utils.FreeOn(vols)
+
+	var clones, hardlinks, already, failed int
+	var reclaimed int64
+	for _, g := range groups {
+		for _, f := range g.Files[1:] {
+			if err := trash.Check(f, nil); err != nil {
+				fmt.Printf("  Failed: %s (%v)\n", f, err)
+				failed++
+				continue
+			}
+			res, err := dupes.Link(g.Files[0], f, linkType)
+			switch {
+			case errors.Is(err, dupes.ErrAlreadyLinked):
+				already++
+			case err != nil:
+				fmt.Printf("  Failed: %s (%v)\n", f, err)
+				failed++
+			case res.Type == dupes.LinkClone:
+				clones++
+				reclaimed += res.Reclaimed
+			default:
+				hardlinks++
+				reclaimed += res.Reclaimed
+			}
+		}
+	}
+
+	measured := utils.Reclaimed(freeBefore, utils.FreeOn(vols))
+
+	fmt.Printf("\nLinked %d duplicates (%d clones, %d hardlinks), %s reclaimed",
+		clones+hardlinks, clones, hardlinks, utils.FormatSize(reclaimed))
+	if already > 0 {
+		fmt.Printf(", %d already hardlinked", already)
+	}
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Println()
+	if clones+hardlinks > 0 {
+		fmt.Printf("Disk space reclaimed: %s measured, %s expected\n",
+			utils.FormatSize(max(measured, 0)), utils.FormatSize(reclaimed))
+	}
+	return nil
+}
+
+// dupesStatusLine redraws a one-line summary of a running duplicate search.
+type dupesStatusLine struct {
+	shown bool
+}
+
+// update redraws the line for p. The dupes package already limits how
+// often progress is reported.
+func (l *dupesStatusLine) update(w io.Writer, p dupes.Progress) {
+	fmt.Fprint(w, "\r\033[K"+dupesStatus(p))
+	l.shown = true
+}
+
+// clear erases the line so the results print from a clean row.
+func (l *dupesStatusLine) clear(w io.Writer) {
+	if l.shown {
+		fmt.Fprint(w, "\r\033[K")
+		l.shown = false
+	}
+}
+
+// dupesStatus describes duplicate search progress in one line.
+func dupesStatus(p dupes.Progress) string {
+	switch p.Phase {
+	case dupes.PhaseWalk:
+		return fmt.Sprintf("  Scanned %d files...", p.Files)
+	case dupes.PhasePartial:
+		return fmt.Sprintf("  Comparing samples: %d/%d files, %s/%s",
+			p.Files, p.TotalFiles, utils.FormatSize(p.Bytes), utils.FormatSize(p.TotalBytes))
+	default:
+		return fmt.Sprintf("  Hashing: %d/%d files, %s/%s",
+			p.Files, p.TotalFiles, utils.FormatSize(p.Bytes), utils.FormatSize(p.TotalBytes))
+	}
+}
+
+// pruneHashCache drops stale entries from the persistent hash cache.
+func pruneHashCache() error {
+	cache, err := dupes.LoadHashCache(scancache.HashCachePath())
+	if err != nil {
+		return err
+	}
+	removed := cache.Prune()
+	if err := cache.Save(); err != nil {
+		return err
+	}
+	if jsonFlag {
+		return printJSON(hashCachePruneJSON{Removed: removed, Remaining: cache.Len()})
+	}
+	fmt.Printf("Pruned %d stale entries from the hash cache (%d left).\n", removed, cache.Len())
+	return nil
 }
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/config"
+	"github.com/lu-zhengda/macbroom/internal/history"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince    string
+	historyUntil    string
+	historyCategory string
+	historyMethod   string
+	historyPath     string
+	historyMinSize  string
+	historyItems    bool
+	historyCSV      bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past cleanup runs and the items they removed",
+	Long: "List cleanup runs from the history, newest first, with the items each\n" +
+		"one cleaned. Filters narrow the runs and items shown; items are listed\n" +
+		"under their run whenever an item filter (--category, --method, --path,\n" +
+		"--min-size) or --items is given.\n\n" +
+		"--since and --until take a date (2026-02-14), a date and time\n" +
+		"(2026-02-14 10:30), or an age such as 7d or 12h.",
+	Example: "  macbroom history --path DerivedData\n" +
+		"  macbroom history --since 30d --method permanent --min-size 1G\n" +
+		"  macbroom history --category \"Xcode Junk\" --csv > xcode.csv",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if historyCSV && jsonFlag {
+			return fmt.Errorf("--csv and --json cannot be used together")
+		}
+		f, err := historyFilter(time.Now())
+		if err != nil {
+			return err
+		}
+
+		runs, err := history.New(history.DefaultPath()).Query(f)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case jsonFlag:
+			return printJSON(historyJSON{Runs: append([]history.Run{}, runs...)})
+		case historyCSV:
+			return writeHistoryCSV(os.Stdout, runs)
+		}
+
+		if len(runs) == 0 {
+			fmt.Println("No matching cleanup runs.")
+			return nil
+		}
+		printHistory(os.Stdout, runs, historyItems || f.Category != "" || f.Method != "" || f.Path != "" || f.MinSize > 0)
+		return nil
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only runs at or after this date, or within this age (e.g. 7d)")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "Only runs before the end of this date, or older than this age")
+	historyCmd.Flags().StringVar(&historyCategory, "category", "", "Only items in this category (e.g. \"Xcode Junk\")")
+	historyCmd.Flags().StringVar(&historyMethod, "method", "", "Only items cleaned this way: trash, permanent or command")
+	historyCmd.Flags().StringVar(&historyPath, "path", "", "Only items whose path contains this text")
+	historyCmd.Flags().StringVar(&historyMinSize, "min-size", "", "Only items at least this large (e.g. 100MB)")
+	historyCmd.Flags().BoolVar(&historyItems, "items", false, "List every item under its run")
+	historyCmd.Flags().BoolVar(&historyCSV, "csv", false, "Output one CSV row per item")
+}
+
+// historyFilter builds the query from the history flags.
+func historyFilter(now time.Time) (history.Filter, error) {
+	var f history.Filter
+	var err error
+	if historySince != "" {
+		if f.Since, err = parseHistoryTime(historySince, now, false); err != nil {
+			return f, fmt.Errorf("invalid --since value %q: %w", historySince, err)
+		}
+	}
+	if historyUntil != "" {
+		if f.Until, err = parseHistoryTime(historyUntil, now, true); err != nil {
+			return f, fmt.Errorf("invalid --until value %q: %w", historyUntil, err)
+		}
+	}
+	switch m := strings.ToLower(historyMethod); m {
+	case "", "trash", "permanent", "command":
+		f.Method = m
+	default:
+		return f, fmt.Errorf("invalid --method value %q: use trash, permanent or command", historyMethod)
+	}
+	if historyMinSize != "" {
+		if f.MinSize, err = config.ParseSize(historyMinSize); err != nil {
+			return f, fmt.Errorf("invalid --min-size value %q: %w", historyMinSize, err)
+		}
+	}
+	f.Category = historyCategory
+	f.Path = historyPath
+	return f, nil
+}
+
+// parseHistoryTime parses a --since or --until value: a local date, a
+// local date and time, or an age such as "7d" or "12h" counted back from
+// now. A bare date used as an upper bound means the end of that day.
+func parseHistoryTime(s string, now time.Time, endOfDay bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("expected a date such as 2026-02-14 or an age such as 7d")
+}
+
+// printHistory lists runs, and their items when withItems is set.
+func printHistory(w io.Writer, runs []history.Run, withItems bool) {
+	fmt.Fprintf(w, "%-20s %-17s %-8s %6s %10s\n", "RUN ID", "DATE", "COMMAND", "ITEMS", "SIZE")
+	for _, r := range runs {
+		items, size := runTotals(r)
+		fmt.Fprintf(w, "%-20s %-17s %-8s %6d %10s\n",
+			r.ID, r.Timestamp.Local().Format("2006-01-02 15:04"), r.Command, items, utils.FormatSize(size))
+		if !withItems {
+			continue
+		}
+		for _, it := range r.Items {
+			line := fmt.Sprintf("    %-9s %-20s %10s  %s", it.Method, it.Category, utils.FormatSize(it.Size), it.Path)
+			if it.Error != "" {
+				line += dimStyle.Render("  (failed: " + it.Error + ")")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// runTotals returns how many items a run cleaned and their size, counting
+// only the items that succeeded. Runs recorded without items are totalled
+// from their entries.
+func runTotals(r history.Run) (int, int64) {
+	var n int
+	var size int64
+	if len(r.Items) == 0 {
+		for _, e := range r.Entries {
+			n += e.Items
+			size += e.BytesFreed
+		}
+		return n, size
+	}
+	for _, it := range r.Items {
+		if it.Error == "" {
+			n++
+			size += it.Size
+		}
+	}
+	return n, size
+}
+
+// writeHistoryCSV writes one row per item of runs.
+func writeHistoryCSV(w io.Writer, runs []history.Run) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"run_id", "timestamp", "command", "category", "method", "path", "size", "trashed", "error"})
+	for _, r := range runs {
+		for _, it := range r.Items {
+			_ = cw.Write([]string{
+				r.ID,
+				r.Timestamp.Format(time.RFC3339),
+				r.Command,
+				it.Category,
+				it.Method,
+				it.Path,
+				strconv.FormatInt(it.Size, 10),
+				it.Trashed,
+				it.Error,
+			})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
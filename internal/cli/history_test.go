@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/history"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2026, 2, 14, 10, 30, 0, 0, time.Local)
+	tests := []struct {
+		in       string
+		endOfDay bool
+		want     time.Time
+	}{
+		{"2026-02-01", false, time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"2026-02-01", true, time.Date(2026, 2, 2, 0, 0, 0, 0, time.Local)},
+		{"2026-02-01 08:15", true, time.Date(2026, 2, 1, 8, 15, 0, 0, time.Local)},
+		{"7d", false, now.AddDate(0, 0, -7)},
+		{"12h", false, now.Add(-12 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.in, now, tt.endOfDay)
+		if err != nil {
+			t.Errorf("parseHistoryTime(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q, %v) = %v, want %v", tt.in, tt.endOfDay, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"yesterday", "-3d", "2026-13-01"} {
+		if _, err := parseHistoryTime(in, now, false); err == nil {
+			t.Errorf("parseHistoryTime(%q): expected an error", in)
+		}
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	runs := []history.Run{{
+		ID:        "20260214-103000",
+		Timestamp: time.Date(2026, 2, 14, 10, 30, 0, 0, time.UTC),
+		Command:   "clean",
+		Items: []history.Item{
+			{Path: "/tmp/a, b", Category: "System Junk", Size: 1024, Method: "trash", Trashed: "/Users/me/.Trash/a, b"},
+			{Path: "/tmp/c", Category: "System Junk", Size: 2048, Method: "permanent", Error: "permission denied"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := writeHistoryCSV(&buf, runs); err != nil {
+		t.Fatalf("writeHistoryCSV: %v", err)
+	}
+	want := "run_id,timestamp,command,category,method,path,size,trashed,error\n" +
+		"20260214-103000,2026-02-14T10:30:00Z,clean,System Junk,trash,\"/tmp/a, b\",1024,\"/Users/me/.Trash/a, b\",\n" +
+		"20260214-103000,2026-02-14T10:30:00Z,clean,System Junk,permanent,/tmp/c,2048,,permission denied\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected CSV:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunTotals(t *testing.T) {
+	r := history.Run{Items: []history.Item{{Size: 100}, {Size: 50, Error: "busy"}, {Size: 25}}}
+	if n, size := runTotals(r); n != 2 || size != 125 {
+		t.Errorf("runTotals = %d, %d; want 2, 125", n, size)
+	}
+	legacy := history.Run{Entries: []history.Entry{{Items: 3, BytesFreed: 300}, {Items: 1, BytesFreed: 10}}}
+	if n, size := runTotals(legacy); n != 4 || size != 310 {
+		t.Errorf("runTotals(legacy) = %d, %d; want 4, 310", n, size)
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	runs := []history.Run{{
+		ID:        "20260214-103000",
+		Timestamp: time.Date(2026, 2, 14, 10, 30, 0, 0, time.UTC),
+		Command:   "clean",
+		Items:     []history.Item{{Path: "/tmp/DerivedData", Category: "Xcode Junk", Size: 1024, Method: "trash"}},
+	}}
+	var buf bytes.Buffer
+	printHistory(&buf, runs, false)
+	if strings.Contains(buf.String(), "/tmp/DerivedData") {
+		t.Errorf("expected items to be hidden:\n%s", buf.String())
+	}
+	buf.Reset()
+	printHistory(&buf, runs, true)
+	if !strings.Contains(buf.String(), "/tmp/DerivedData") {
+		t.Errorf("expected items to be listed:\n%s", buf.String())
+	}
+}
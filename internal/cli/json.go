@@ -4,10 +4,13 @@ import (
 	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/dupes"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/history"
+	"github.com/lu-zhengda/macbroom/internal/manifest"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/lu-zhengda/macbroom/internal/trends"
+	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
 // ---------------------------------------------------------------------------
@@ -15,13 +18,74 @@ import (
 // ---------------------------------------------------------------------------
 
 type scanJSON struct {
-	Version     string             `json:"version"`
-	Timestamp   time.Time          `json:"timestamp"`
-	Categories  []scanCategoryJSON `json:"categories"`
-	TotalSize   int64              `json:"total_size"`
-	TotalItems  int                `json:"total_items"`
-	RiskSummary riskJSON           `json:"risk_summary"`
-	Diff        *diffJSON          `json:"diff,omitempty"`
+	Version      string             `json:"version"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Categories   []scanCategoryJSON `json:"categories"`
+	SizeMode     string             `json:"size_mode"`
+	TotalSize    int64              `json:"total_size"`
+	ApparentSize int64              `json:"apparent_size"`
+	DiskSize     int64              `json:"disk_size"`
+	TotalItems   int                `json:"total_items"`
+	RiskSummary  riskJSON           `json:"risk_summary"`
+	Diff         *diffJSON          `json:"diff,omitempty"`
+	OverlapSize  int64              `json:"overlap_size,omitempty"`
+	Overlaps     []engine.Overlap   `json:"overlaps,omitempty"`
+	SizeCache    *sizeCacheJSON     `json:"size_cache,omitempty"`
+	Failures     []scanFailureJSON  `json:"failures,omitempty"`
+}
+
+// scanFailureJSON reports a scanner that failed or timed out. The other
+// scanners' results are still included.
+type scanFailureJSON struct {
+	Scanner  string `json:"scanner"`
+	Phase    string `json:"phase"`
+	TimedOut bool   `json:"timed_out"`
+	Timeout  string `json:"timeout,omitempty"`
+	Error    string `json:"error"`
+}
+
+// setFailures records the scanners that did not finish.
+func (s *scanJSON) setFailures(failures engine.ScanErrors) {
+	for _, f := range failures {
+		fj := scanFailureJSON{
+			Scanner:  f.Scanner,
+			Phase:    string(f.Phase),
+			TimedOut: f.TimedOut(),
+			Error:    f.Err.Error(),
+		}
+		if fj.TimedOut {
+			fj.Timeout = f.Timeout.String()
+		}
+		s.Failures = append(s.Failures, fj)
+	}
+}
+
+// sizeCacheJSON reports how many directory sizes or file hashes came from
+// a persistent cache instead of being read from disk.
+type sizeCacheJSON struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// setSizeCache records directory cache statistics. Scans that measured no
+// directories leave it out.
+func (s *scanJSON) setSizeCache(stats utils.DirCacheStats) {
+	if stats.Hits+stats.Misses == 0 {
+		return
+	}
+	s.SizeCache = &sizeCacheJSON{
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		HitRate: stats.HitRate(),
+	}
+}
+
+// setOverlaps records paths that more than one scanner reported. Category
+// and total sizes already count those bytes once.
+func (s *scanJSON) setOverlaps(overlaps []engine.Overlap) {
+	s.Overlaps = overlaps
+	s.OverlapSize = engine.OverlapSize(overlaps)
 }
 
 type scanCategoryJSON struct {
@@ -33,9 +97,31 @@ type scanCategoryJSON struct {
 }
 
 type targetJSON struct {
-	Path string `json:"path"`
-	Size int64  `json:"size"`
-	Risk string `json:"risk"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	ApparentSize int64  `json:"apparent_size"`
+	DiskSize     int64  `json:"disk_size"`
+	Risk         string `json:"risk"`
+	Action       string `json:"action,omitempty"`
+	// Contains lists the targets nested inside this one that cleaning it
+	// also removes; it is only cleaned together with them.
+	Contains []string `json:"contains,omitempty"`
+}
+
+// newTargetJSON converts a target, including its cleanup command if any.
+func newTargetJSON(t scanner.Target) targetJSON {
+	tj := targetJSON{
+		Path:         t.Path,
+		Size:         t.Size,
+		ApparentSize: t.SizeIn(utils.SizeApparent),
+		DiskSize:     t.SizeIn(utils.SizeAllocated),
+		Risk:         t.Risk.String(),
+		Contains:     t.Contains,
+	}
+	if !t.IsFilesystem() {
+		tj.Action = t.Action.String()
+	}
+	return tj
 }
 
 type riskJSON struct {
@@ -45,8 +131,8 @@ type riskJSON struct {
 }
 
 type diffJSON struct {
-	PreviousTimestamp time.Time                       `json:"previous_timestamp"`
-	TotalDelta        int64                           `json:"total_delta"`
+	PreviousTimestamp time.Time                         `json:"previous_timestamp"`
+	TotalDelta        int64                             `json:"total_delta"`
 	Categories        map[string]scancache.CategoryDiff `json:"categories"`
 }
 
@@ -65,11 +151,7 @@ func buildScanJSON(targets []scanner.Target, diff *scancache.DiffResult) scanJSO
 		var maxRisk scanner.RiskLevel
 		for _, item := range items {
 			catSize += item.Size
-			catTargets = append(catTargets, targetJSON{
-				Path: item.Path,
-				Size: item.Size,
-				Risk: item.Risk.String(),
-			})
+			catTargets = append(catTargets, newTargetJSON(item))
 			if item.Risk > maxRisk {
 				maxRisk = item.Risk
 			}
@@ -86,11 +168,14 @@ func buildScanJSON(targets []scanner.Target, diff *scancache.DiffResult) scanJSO
 	rb := riskSummary(targets)
 
 	result := scanJSON{
-		Version:    version,
-		Timestamp:  time.Now().UTC(),
-		Categories: categories,
-		TotalSize:  rb.Total,
-		TotalItems: len(targets),
+		Version:      version,
+		Timestamp:    time.Now().UTC(),
+		Categories:   categories,
+		SizeMode:     sizeMode().String(),
+		TotalSize:    rb.Total,
+		ApparentSize: totalIn(targets, utils.SizeApparent),
+		DiskSize:     totalIn(targets, utils.SizeAllocated),
+		TotalItems:   len(targets),
 		RiskSummary: riskJSON{
 			Safe:     rb.Safe,
 			Moderate: rb.Moderate,
@@ -115,9 +200,36 @@ func buildScanJSON(targets []scanner.Target, diff *scancache.DiffResult) scanJSO
 
 type cleanJSON struct {
 	scanJSON
-	DeletedSize  int64 `json:"deleted_size"`
-	DeletedItems int   `json:"deleted_items"`
-	Errors       int   `json:"errors"`
+	DeletedSize  int64             `json:"deleted_size"`
+	DeletedItems int               `json:"deleted_items"`
+	Errors       int               `json:"errors"`
+	Refused      int               `json:"refused,omitempty"`
+	Skipped      int               `json:"skipped,omitempty"`
+	Actions      []cleanActionJSON `json:"actions,omitempty"`
+	RunID        string            `json:"run_id,omitempty"`
+	// TrashedSize is the part of DeletedSize moved to Trash, which is not
+	// freed until the Trash is emptied. MeasuredFreed is the growth in free
+	// disk space over the run, left out when it could not be measured.
+	TrashedSize   int64  `json:"trashed_size"`
+	MeasuredFreed *int64 `json:"measured_freed,omitempty"`
+}
+
+// cleanActionJSON reports the outcome of cleaning a single target.
+type cleanActionJSON struct {
+	Path    string `json:"path"`
+	Method  string `json:"method"`
+	Command string `json:"command,omitempty"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	// Protected is set when the deletion guard refused the target.
+	Protected bool `json:"protected,omitempty"`
+	// Partial is set when only part of the target could be deleted;
+	// FreedSize is then the space that was reclaimed.
+	Partial   bool  `json:"partial,omitempty"`
+	FreedSize int64 `json:"freed_size,omitempty"`
+	// Skipped is set when the run was interrupted before the target was
+	// attempted.
+	Skipped bool `json:"skipped,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -130,12 +242,39 @@ type dupesJSON struct {
 	Groups     []dupeGroupJSON `json:"groups"`
 	TotalFiles int             `json:"total_files"`
 	TotalWaste int64           `json:"total_waste"`
+	HashCache  *sizeCacheJSON  `json:"hash_cache,omitempty"`
+}
+
+// setHashCache records how many file hashes came from the persistent hash
+// cache. Runs that hashed nothing leave it out.
+func (d *dupesJSON) setHashCache(stats dupes.CacheStats) {
+	if stats.Hits+stats.Misses == 0 {
+		return
+	}
+	d.HashCache = &sizeCacheJSON{
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		HitRate: stats.HitRate(),
+	}
+}
+
+type hashCachePruneJSON struct {
+	Removed   int `json:"removed"`
+	Remaining int `json:"remaining"`
 }
 
 type dupeGroupJSON struct {
 	Size  int64    `json:"size"`
 	Hash  string   `json:"hash"`
 	Files []string `json:"files"`
+	// Dir is set when Files are directories with identical contents, each
+	// holding FileCount files.
+	Dir       bool `json:"dir,omitempty"`
+	FileCount int  `json:"file_count,omitempty"`
+	// Keep is the file kept when the group is cleaned, chosen by the keep
+	// rule KeptBy if any.
+	Keep   string `json:"keep"`
+	KeptBy string `json:"kept_by,omitempty"`
 }
 
 // buildDupesJSON converts duplicate groups into a JSON-serializable structure.
@@ -146,9 +285,13 @@ func buildDupesJSON(groups []dupes.Group) dupesJSON {
 
 	for _, g := range groups {
 		jsonGroups = append(jsonGroups, dupeGroupJSON{
-			Size:  g.Size,
-			Hash:  g.Hash,
-			Files: g.Files,
+			Size:      g.Size,
+			Hash:      g.Hash,
+			Files:     g.Files,
+			Dir:       g.Dir,
+			FileCount: g.FileCount,
+			Keep:      g.Files[0],
+			KeptBy:    g.KeptBy,
 		})
 		totalFiles += len(g.Files)
 		totalWaste += g.Size * int64(len(g.Files)-1)
@@ -168,11 +311,11 @@ func buildDupesJSON(groups []dupes.Group) dupesJSON {
 // ---------------------------------------------------------------------------
 
 type statsJSON struct {
-	Version       string                         `json:"version"`
-	TotalFreed    int64                          `json:"total_freed"`
-	TotalCleanups int                            `json:"total_cleanups"`
+	Version       string                           `json:"version"`
+	TotalFreed    int64                            `json:"total_freed"`
+	TotalCleanups int                              `json:"total_cleanups"`
 	ByCategory    map[string]history.CategoryStats `json:"by_category"`
-	Recent        []history.Entry                `json:"recent"`
+	Recent        []history.Entry                  `json:"recent"`
 }
 
 // buildStatsJSON converts history stats into a JSON-serializable structure.
@@ -191,9 +334,10 @@ func buildStatsJSON(stats history.Stats) statsJSON {
 // ---------------------------------------------------------------------------
 
 type spaceLensJSON struct {
-	Version   string                `json:"version"`
-	Timestamp time.Time             `json:"timestamp"`
-	Path      string                `json:"path"`
+	Version   string                  `json:"version"`
+	Timestamp time.Time               `json:"timestamp"`
+	Path      string                  `json:"path"`
+	SizeMode  string                  `json:"size_mode"`
 	Nodes     []scanner.SpaceLensNode `json:"nodes"`
 }
 
@@ -203,6 +347,7 @@ func buildSpaceLensJSON(path string, nodes []scanner.SpaceLensNode) spaceLensJSO
 		Version:   version,
 		Timestamp: time.Now().UTC(),
 		Path:      path,
+		SizeMode:  sizeMode().String(),
 		Nodes:     nodes,
 	}
 }
@@ -226,11 +371,7 @@ func buildUninstallJSON(appName string, targets []scanner.Target) uninstallJSON
 	jsonTargets := make([]targetJSON, 0, len(targets))
 	for _, t := range targets {
 		totalSize += t.Size
-		jsonTargets = append(jsonTargets, targetJSON{
-			Path: t.Path,
-			Size: t.Size,
-			Risk: t.Risk.String(),
-		})
+		jsonTargets = append(jsonTargets, newTargetJSON(t))
 	}
 	return uninstallJSON{
 		Version:   version,
@@ -283,3 +424,96 @@ type trendsRecordJSON struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Snapshot  trends.StorageSnapshot `json:"snapshot"`
 }
+
+// ---------------------------------------------------------------------------
+// Restore JSON types
+// ---------------------------------------------------------------------------
+
+type runJSON struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	Items     int       `json:"items"`
+	Pending   int       `json:"pending"`
+	TotalSize int64     `json:"total_size"`
+}
+
+type runsJSON struct {
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Runs      []runJSON `json:"runs"`
+}
+
+type restoreItemJSON struct {
+	Original string `json:"original"`
+	Trashed  string `json:"trashed"`
+	Size     int64  `json:"size"`
+	Status   string `json:"status"`
+	Dest     string `json:"dest,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type restoreJSON struct {
+	Version   string            `json:"version"`
+	Timestamp time.Time         `json:"timestamp"`
+	Run       runJSON           `json:"run"`
+	Items     []restoreItemJSON `json:"items"`
+}
+
+func newRunJSON(m *manifest.Manifest) runJSON {
+	return runJSON{
+		ID:        m.ID,
+		Timestamp: m.Timestamp,
+		Command:   m.Command,
+		Items:     len(m.Items),
+		Pending:   m.Pending(),
+		TotalSize: m.TotalSize(),
+	}
+}
+
+// buildRunsJSON lists restorable runs.
+func buildRunsJSON(runs []*manifest.Manifest) runsJSON {
+	out := make([]runJSON, 0, len(runs))
+	for _, m := range runs {
+		out = append(out, newRunJSON(m))
+	}
+	return runsJSON{Version: version, Timestamp: time.Now().UTC(), Runs: out}
+}
+
+// buildRestoreJSON reports a restore. When outcomes is nil (dry run or
+// nothing to do), each item reports "pending" or "already restored".
+func buildRestoreJSON(m *manifest.Manifest, outcomes []manifest.Outcome) restoreJSON {
+	items := make([]restoreItemJSON, 0, len(m.Items))
+	if outcomes == nil {
+		for _, it := range m.Items {
+			status := "pending"
+			if it.RestoredTo != "" {
+				status = manifest.AlreadyRestored.String()
+			}
+			items = append(items, restoreItemJSON{
+				Original: it.Original, Trashed: it.Trashed, Size: it.Size,
+				Status: status, Dest: it.RestoredTo,
+			})
+		}
+	}
+	for _, o := range outcomes {
+		ij := restoreItemJSON{
+			Original: o.Item.Original, Trashed: o.Item.Trashed, Size: o.Item.Size,
+			Status: o.Status.String(), Dest: o.Dest,
+		}
+		if o.Err != nil {
+			ij.Error = o.Err.Error()
+		}
+		items = append(items, ij)
+	}
+	return restoreJSON{
+		Version:   version,
+		Timestamp: time.Now().UTC(),
+		Run:       newRunJSON(m),
+		Items:     items,
+	}
+}
+
+type historyJSON struct {
+	Runs []history.Run `json:"runs"`
+}
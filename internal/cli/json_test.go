@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/dupes"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/history"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
@@ -56,6 +59,26 @@ func TestBuildScanJSON(t *testing.T) {
 	}
 }
 
+func TestScanJSON_SetFailures(t *testing.T) {
+	result := buildScanJSON(nil, nil)
+	result.setFailures(engine.ScanErrors{
+		{Scanner: "Homebrew", Phase: engine.PhaseScan, Err: context.DeadlineExceeded, Timeout: 30 * time.Second},
+		{Scanner: "macbroom-scanner-acme", Phase: engine.PhaseLoad, Err: errors.New("exit status 1")},
+	})
+
+	if len(result.Failures) != 2 {
+		t.Fatalf("len(Failures) = %d, want 2", len(result.Failures))
+	}
+	timedOut := result.Failures[0]
+	if timedOut.Scanner != "Homebrew" || !timedOut.TimedOut || timedOut.Timeout != "30s" || timedOut.Phase != "scan" {
+		t.Errorf("unexpected timeout failure %+v", timedOut)
+	}
+	failed := result.Failures[1]
+	if failed.TimedOut || failed.Phase != "load" || failed.Error != "exit status 1" || failed.Timeout != "" {
+		t.Errorf("unexpected load failure %+v", failed)
+	}
+}
+
 func TestBuildScanJSON_WithDiff(t *testing.T) {
 	targets := []scanner.Target{
 		{Path: "/a", Size: 1000, Category: "System Junk", Risk: scanner.Safe},
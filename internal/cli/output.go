@@ -3,11 +3,16 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/lu-zhengda/macbroom/internal/cleanup"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/lu-zhengda/macbroom/internal/tui"
@@ -99,11 +104,18 @@ func printScanResults(targets []scanner.Target, diff *scancache.DiffResult) {
 			}
 			padded := fmt.Sprintf("%10s", utils.FormatSize(item.Size))
 			sizeStr := boldStyle.Render(padded)
-			fmt.Printf("  %-40s %s%s\n", truncatePath(item.Path, 40), sizeStr, risk)
+			fmt.Printf("  %-40s %s%s%s\n", truncatePath(item.Path, 40), sizeStr, risk, otherSize(item.Usage))
+			for _, p := range item.Contains {
+				fmt.Printf("    %s\n", dimStyle.Render("also removes "+truncatePath(p, 50)+" (listed separately)"))
+			}
 		}
 	}
 
-	fmt.Printf("\n%s\n", totalStyle.Render(fmt.Sprintf("Total reclaimable: %s", utils.FormatSize(totalSize))))
+	fmt.Printf("\n%s%s\n", totalStyle.Render(fmt.Sprintf("Total reclaimable: %s", utils.FormatSize(totalSize))),
+		otherSize(utils.Usage{
+			Apparent:  totalIn(targets, utils.SizeApparent),
+			Allocated: totalIn(targets, utils.SizeAllocated),
+		}))
 
 	if line := riskSummaryLine(riskSummary(targets)); line != "" {
 		fmt.Printf("%s\n", line)
@@ -120,6 +132,182 @@ func printScanResults(targets []scanner.Target, diff *scancache.DiffResult) {
 	}
 }
 
+// totalIn sums the sizes of targets in mode.
+func totalIn(targets []scanner.Target, mode utils.SizeMode) int64 {
+	var total int64
+	for _, t := range targets {
+		total += t.SizeIn(mode)
+	}
+	return total
+}
+
+// otherSize renders the size not selected by the size mode, dimmed, when it
+// differs from the reported one by at least 10%. Sparse disk images and
+// hardlinked caches are where the two diverge; elsewhere it stays quiet.
+func otherSize(u utils.Usage) string {
+	if u.IsZero() {
+		return ""
+	}
+	mode := sizeMode()
+	shown, other := u.In(mode), u.Apparent
+	label := "apparent"
+	if mode == utils.SizeApparent {
+		other, label = u.Allocated, "on disk"
+	}
+	diff := shown - other
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff*10 < max(shown, other) {
+		return ""
+	}
+	return " " + dimStyle.Render(fmt.Sprintf("(%s %s)", utils.FormatSize(other), label))
+}
+
+// printOverlapSummary notes how many bytes were reported by more than one
+// scanner and which category ended up owning them.
+func printOverlapSummary(overlaps []engine.Overlap) {
+	if len(overlaps) == 0 {
+		return
+	}
+	owners := make(map[string]int64)
+	for _, o := range overlaps {
+		owners[o.Owner] += o.Bytes
+	}
+	names := make([]string, 0, len(owners))
+	for name := range owners {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return owners[names[i]] > owners[names[j]] })
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %s", name, utils.FormatSize(owners[name])))
+	}
+	fmt.Printf("  %s\n", dimStyle.Render(fmt.Sprintf("%s reported by more than one scanner, counted once (%s)",
+		utils.FormatSize(engine.OverlapSize(overlaps)), strings.Join(parts, ", "))))
+}
+
+// printScanFailures lists the scanners that failed or timed out, whose
+// results are missing from the output above.
+func printScanFailures(failures engine.ScanErrors) {
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Printf("\n%s\n", riskModerate.Render(fmt.Sprintf("%d scanner(s) did not finish; their results are not included:", len(failures))))
+	for _, f := range failures {
+		reason := fmt.Sprintf("%s failed: %v", f.Phase, f.Err)
+		if f.TimedOut() {
+			reason = fmt.Sprintf("timed out after %s", f.Timeout)
+		}
+		fmt.Printf("  %-20s %s\n", f.Scanner, dimStyle.Render(reason))
+	}
+}
+
+// statusLineInterval limits how often the live scan status is redrawn.
+const statusLineInterval = 100 * time.Millisecond
+
+// scanStatusLine redraws a one-line summary of a running scan: the items
+// and size found so far, and the largest categories.
+type scanStatusLine struct {
+	w io.Writer
+
+	mu    sync.Mutex
+	cats  map[string]engine.ScanProgress
+	last  time.Time
+	shown bool
+}
+
+func newScanStatusLine(w io.Writer) *scanStatusLine {
+	return &scanStatusLine{w: w, cats: make(map[string]engine.ScanProgress)}
+}
+
+// update records p and redraws the line if it is due. It may be called
+// from several scanners at once.
+func (l *scanStatusLine) update(p engine.ScanProgress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cats[p.Name] = p
+	if now := time.Now(); now.Sub(l.last) >= statusLineInterval {
+		l.last = now
+		fmt.Fprintf(l.w, "\r\033[K%s", l.render())
+		l.shown = true
+	}
+}
+
+// render formats the current totals, as in
+// "Scanning... 42 items, 3.1 GB (Xcode Junk 12 · 2.0 GB, ...)".
+func (l *scanStatusLine) render() string {
+	var items int
+	var size int64
+	cats := make([]engine.ScanProgress, 0, len(l.cats))
+	for _, p := range l.cats {
+		items += p.Items
+		size += p.Size
+		if p.Items > 0 {
+			cats = append(cats, p)
+		}
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		if cats[i].Size != cats[j].Size {
+			return cats[i].Size > cats[j].Size
+		}
+		return cats[i].Name < cats[j].Name
+	})
+
+	line := fmt.Sprintf("Scanning... %d items, %s", items, utils.FormatSize(size))
+	if len(cats) > 3 {
+		cats = cats[:3]
+	}
+	if len(cats) > 0 {
+		parts := make([]string, len(cats))
+		for i, p := range cats {
+			parts[i] = fmt.Sprintf("%s %d · %s", p.Name, p.Items, utils.FormatSize(p.Size))
+		}
+		line += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return line
+}
+
+// clear erases the line so the results print from a clean row.
+func (l *scanStatusLine) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.shown {
+		fmt.Fprint(l.w, "\r\033[K")
+		l.shown = false
+	}
+}
+
+// cleanStatusLine redraws a one-line summary of a running cleanup.
+type cleanStatusLine struct {
+	w     io.Writer
+	last  time.Time
+	shown bool
+}
+
+func newCleanStatusLine(w io.Writer) *cleanStatusLine {
+	return &cleanStatusLine{w: w}
+}
+
+// update redraws the line for p if it is due. The executor reports
+// progress one call at a time, so no locking is needed.
+func (l *cleanStatusLine) update(p cleanup.Progress) {
+	if now := time.Now(); now.Sub(l.last) >= statusLineInterval || p.Done == p.Total {
+		l.last = now
+		fmt.Fprintf(l.w, "\r\033[KCleaning... %d/%d items, %s freed", p.Done, p.Total, utils.FormatSize(p.Freed))
+		l.shown = true
+	}
+}
+
+// clear erases the line so the results print from a clean row.
+func (l *cleanStatusLine) clear() {
+	if l.shown {
+		fmt.Fprint(l.w, "\r\033[K")
+		l.shown = false
+	}
+}
+
 // diffIndicator returns a styled string showing how a category changed since the last scan.
 func diffIndicator(name string, diff *scancache.DiffResult) string {
 	if diff == nil {
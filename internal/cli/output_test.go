@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/lu-zhengda/macbroom/internal/cleanup"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 )
@@ -229,3 +231,54 @@ func TestDiffIndicator_NotFound(t *testing.T) {
 		t.Errorf("expected empty string for category not in diff, got %q", got)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// scanStatusLine
+// ---------------------------------------------------------------------------
+
+func TestScanStatusLine(t *testing.T) {
+	var buf strings.Builder
+	l := newScanStatusLine(&buf)
+	l.update(engine.ScanProgress{Name: "Xcode Junk", Status: engine.ScanFound, Items: 2, Size: 3 << 30})
+	for _, p := range []engine.ScanProgress{
+		{Name: "Node.js", Status: engine.ScanFound, Items: 5, Size: 1 << 30},
+		{Name: "Go", Status: engine.ScanDone, Items: 1, Size: 2 << 20},
+		{Name: "Docker", Status: engine.ScanStarted},
+		{Name: "Ruby", Status: engine.ScanDone, Items: 1, Size: 1 << 20},
+	} {
+		l.cats[p.Name] = p
+	}
+
+	got := l.render()
+	want := "Scanning... 9 items, 4.0 GB (Xcode Junk 2 · 3.0 GB, Node.js 5 · 1.0 GB, Go 1 · 2.0 MB)"
+	if got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(buf.String(), "\r\033[KScanning... 2 items") {
+		t.Errorf("expected the first update to draw the line, got %q", buf.String())
+	}
+
+	buf.Reset()
+	l.clear()
+	if buf.String() != "\r\033[K" {
+		t.Errorf("clear() wrote %q", buf.String())
+	}
+	buf.Reset()
+	l.clear()
+	if buf.Len() != 0 {
+		t.Errorf("second clear() wrote %q", buf.String())
+	}
+}
+
+func TestCleanStatusLine(t *testing.T) {
+	var buf strings.Builder
+	l := newCleanStatusLine(&buf)
+	l.update(cleanup.Progress{Done: 1, Total: 3, Freed: 1 << 20})
+	l.update(cleanup.Progress{Done: 2, Total: 3, Freed: 2 << 20}) // throttled
+	l.update(cleanup.Progress{Done: 3, Total: 3, Freed: 3 << 20}) // final, always drawn
+
+	want := "\r\033[KCleaning... 1/3 items, 1.0 MB freed\r\033[KCleaning... 3/3 items, 3.0 MB freed"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -1,8 +1,18 @@
 package cli
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"sort"
 	"testing"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/config"
+	"github.com/lu-zhengda/macbroom/internal/engine"
+	"github.com/lu-zhengda/macbroom/internal/plugin"
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/spf13/pflag"
 )
 
 func TestSelectedCategories_DevProfile(t *testing.T) {
@@ -41,7 +51,7 @@ func TestSelectedCategories_AllProfile(t *testing.T) {
 }
 
 func TestSelectedCategories_DevPlusDocker(t *testing.T) {
-	cats := selectedCategories(CategoryFilter{Dev: true, Docker: true})
+	cats := selectedCategories(CategoryFilter{Dev: true, Scanners: map[string]bool{"docker": true}})
 	sort.Strings(cats)
 	want := []string{"Docker", "Go", "Gradle", "JetBrains", "Maven", "Node.js", "Python", "Ruby", "Rust"}
 	if len(cats) != len(want) {
@@ -74,3 +84,136 @@ func TestSelectedCategories_NoneSelected(t *testing.T) {
 		t.Errorf("no flags should return nil, got %v", cats)
 	}
 }
+
+func TestSelectedCategories_Custom(t *testing.T) {
+	cats := selectedCategories(CategoryFilter{Scanners: map[string]bool{"system": true}, Custom: []string{"Unity"}})
+	sort.Strings(cats)
+	want := []string{"System Junk", "Unity"}
+	if len(cats) != len(want) || cats[0] != want[0] || cats[1] != want[1] {
+		t.Errorf("--system --custom Unity: got %v, want %v", cats, want)
+	}
+}
+
+func TestResolveCustomFilter(t *testing.T) {
+	saved := appConfig
+	defer func() { appConfig = saved }()
+	appConfig = config.Default()
+	appConfig.CustomScanners = []config.CustomScannerConfig{{Name: "Unity", Paths: []string{"/tmp/*"}}}
+
+	e := engine.New()
+	f := CategoryFilter{Custom: []string{"unity"}}
+	if err := resolveCustomFilter(&f, e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Custom[0] != "Unity" {
+		t.Errorf("expected name to resolve to %q, got %q", "Unity", f.Custom[0])
+	}
+
+	f = CategoryFilter{Custom: []string{"Unreal"}}
+	if err := resolveCustomFilter(&f, e); err == nil {
+		t.Error("expected an error for an unconfigured custom scanner")
+	}
+}
+
+func TestRegisterCustomScanners(t *testing.T) {
+	saved := appConfig
+	defer func() { appConfig = saved }()
+	appConfig = config.Default()
+	appConfig.CustomScanners = []config.CustomScannerConfig{
+		{Name: "Unity", Paths: []string{"/tmp/unity/*"}, Risk: "safe"},
+		{Name: "Broken", Paths: []string{"/tmp/*"}, Risk: "yolo"},
+		{Name: "Docker", Paths: []string{"/tmp/docker/*"}},
+		{Name: "unity", Paths: []string{"/tmp/other/*"}},
+	}
+
+	e := engine.New()
+	names := registerCustomScanners(e)
+	if len(names) != 1 || names[0] != "Unity" {
+		t.Errorf("expected registered names [Unity], got %v", names)
+	}
+	if len(e.Scanners()) != 1 || e.Scanners()[0].Name() != "Unity" || e.Scanners()[0].Risk() != scanner.Safe {
+		t.Errorf("expected only the valid custom scanner to be registered, got %v", e.Scanners())
+	}
+}
+
+// withPluginDir enables plugins and points discovery at a temporary
+// directory holding one script per entry of describes (file suffix ->
+// describe response).
+func withPluginDir(t *testing.T, describes map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for suffix, resp := range describes {
+		script := "#!/bin/sh\necho '" + resp + "'\n"
+		if err := os.WriteFile(filepath.Join(dir, plugin.Prefix+suffix), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	savedConfig, savedLoad := appConfig, loadPlugins
+	t.Cleanup(func() { appConfig, loadPlugins = savedConfig, savedLoad })
+	appConfig = config.Default()
+	appConfig.Plugins.Enabled = true
+	appConfig.Plugins.Dirs = []string{dir}
+	loadPlugins = func(dirs []string, timeout time.Duration) ([]*plugin.Scanner, []error) {
+		return plugin.LoadAll(context.Background(), plugin.Discover(dirs, ""), timeout)
+	}
+}
+
+func TestRegisterPlugins(t *testing.T) {
+	withPluginDir(t, map[string]string{
+		"acme":   `{"protocol": 1, "name": "Acme"}`,
+		"clash":  `{"protocol": 1, "name": "docker"}`,
+		"broken": `{"protocol": 99, "name": "Broken"}`,
+	})
+
+	e := engine.New()
+	names := registerPlugins(e)
+	if len(names) != 1 || names[0] != "Acme" || len(e.Scanners()) != 1 {
+		t.Fatalf("expected only the Acme plugin, got names %v, scanners %v", names, e.Scanners())
+	}
+
+	// The plugin that failed to load is reported by the scan.
+	_, _, failures, err := scanWithCategories(e, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Scanner != plugin.Prefix+"broken" || failures[0].Phase != engine.PhaseLoad {
+		t.Errorf("expected a load failure for the broken plugin, got %v", failures)
+	}
+
+	f := CategoryFilter{Custom: []string{"acme"}}
+	if err := resolveCustomFilter(&f, e); err != nil || f.Custom[0] != "Acme" {
+		t.Errorf("expected --custom to resolve the plugin, got %v, %v", f.Custom, err)
+	}
+}
+
+func TestRegisterPlugins_Disabled(t *testing.T) {
+	withPluginDir(t, map[string]string{"acme": `{"protocol": 1, "name": "Acme"}`})
+	appConfig.Plugins.Enabled = false
+
+	e := engine.New()
+	if names := registerPlugins(e); len(names) != 0 || len(e.Scanners()) != 0 {
+		t.Errorf("disabled plugins must not load, got %v", names)
+	}
+}
+
+func TestAddCategoryFlags(t *testing.T) {
+	var f CategoryFilter
+	fs := pflag.NewFlagSet("scan", pflag.ContinueOnError)
+	addCategoryFlags(fs, &f, "Scan")
+
+	if err := fs.Parse([]string{"--simulator", "--large=true", "--go=false", "--dev"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !f.Scanners["simulator"] || !f.Scanners["large_files"] || f.Scanners["go"] || !f.Dev {
+		t.Errorf("unexpected filter %+v", f)
+	}
+	for _, info := range scanner.Registry() {
+		if fs.Lookup(info.Flag) == nil {
+			t.Errorf("missing flag --%s for %s", info.Flag, info.Name)
+		}
+	}
+	if usage := fs.Lookup("simulator").Usage; usage != "Scan iOS Simulator data only" {
+		t.Errorf("unexpected usage %q", usage)
+	}
+}
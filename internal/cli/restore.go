@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/lu-zhengda/macbroom/internal/manifest"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreYes    bool
+	restoreDryRun bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [run-id]",
+	Short: "Put back items a previous run moved to Trash",
+	Long: "Restore items that clean, uninstall or dupes moved to Trash.\n\n" +
+		"Without a run ID, lists recent runs. Use \"last\" for the most recent run\n" +
+		"that still has items to restore. Items whose original path is taken are\n" +
+		"restored next to it as \"name (restored)\".",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := manifest.NewStore(manifest.DefaultDir())
+
+		if len(args) == 0 {
+			runs, err := store.List()
+			if err != nil {
+				return err
+			}
+			if jsonFlag {
+				return printJSON(buildRunsJSON(runs))
+			}
+			if len(runs) == 0 {
+				fmt.Println("No runs to restore.")
+				return nil
+			}
+			fmt.Printf("%-20s %-20s %-10s %8s %10s\n", "RUN ID", "DATE", "COMMAND", "PENDING", "SIZE")
+			for _, m := range runs {
+				fmt.Printf("%-20s %-20s %-10s %4d/%-3d %10s\n",
+					m.ID, m.Timestamp.Local().Format("2006-01-02 15:04"), m.Command,
+					m.Pending(), len(m.Items), utils.FormatSize(m.TotalSize()))
+			}
+			fmt.Println("\nRun 'macbroom restore <run-id>' to put a run's items back.")
+			return nil
+		}
+
+		var m *manifest.Manifest
+		var err error
+		if args[0] == "last" {
+			m, err = store.Latest()
+		} else {
+			m, err = store.Load(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		if m.Pending() == 0 {
+			if jsonFlag {
+				return printJSON(buildRestoreJSON(m, nil))
+			}
+			fmt.Printf("Everything from run %s has already been restored.\n", m.ID)
+			return nil
+		}
+
+		if !jsonFlag {
+			fmt.Printf("Run %s (%s, %s):\n", m.ID, m.Command, m.Timestamp.Local().Format("2006-01-02 15:04"))
+			for _, it := range m.Items {
+				if it.RestoredTo == "" {
+					fmt.Printf("  %s (%s)\n", it.Original, utils.FormatSize(it.Size))
+				}
+			}
+		}
+
+		if restoreDryRun {
+			if jsonFlag {
+				return printJSON(buildRestoreJSON(m, nil))
+			}
+			fmt.Printf("\n[DRY RUN] Would restore %d items.\n", m.Pending())
+			return nil
+		}
+
+		if !jsonFlag && !shouldSkipConfirm(restoreYes) {
+			if !confirmAction(fmt.Sprintf("\nRestore %d items from Trash?", m.Pending())) {
+				fmt.Println("Cancelled.")
+				return nil
+			}
+		}
+
+		outcomes := manifest.Restore(m)
+		if err := store.Save(m); err != nil {
+			return err
+		}
+
+		if jsonFlag {
+			return printJSON(buildRestoreJSON(m, outcomes))
+		}
+
+		var restored, missing, failed int
+		for _, o := range outcomes {
+			switch o.Status {
+			case manifest.Restored:
+				restored++
+			case manifest.Renamed:
+				restored++
+				fmt.Printf("  Restored as: %s (original path is in use)\n", o.Dest)
+			case manifest.Missing:
+				missing++
+				fmt.Printf("  Missing: %s (no longer in Trash)\n", o.Item.Original)
+			case manifest.Failed:
+				failed++
+				fmt.Printf("  Failed: %s (%v)\n", o.Item.Original, o.Err)
+			}
+		}
+
+		fmt.Printf("\nRestored %d items", restored)
+		if missing > 0 {
+			fmt.Printf(", %d missing", missing)
+		}
+		if failed > 0 {
+			fmt.Printf(", %d failed", failed)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "Skip confirmation prompt")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "Show what would be restored without moving anything")
+}
+
+// printUndoHint tells the user how to undo the run saved as runID.
+func printUndoHint(runID string) {
+	if runID != "" {
+		fmt.Printf("Undo with: macbroom restore %s\n", runID)
+	}
+}
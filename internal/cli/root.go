@@ -2,24 +2,35 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/lu-zhengda/macbroom/internal/config"
+	"github.com/lu-zhengda/macbroom/internal/dupes"
 	"github.com/lu-zhengda/macbroom/internal/engine"
+	"github.com/lu-zhengda/macbroom/internal/history"
+	"github.com/lu-zhengda/macbroom/internal/plugin"
+	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/trash"
 	"github.com/lu-zhengda/macbroom/internal/tui"
 	"github.com/lu-zhengda/macbroom/internal/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
 	yoloMode   bool
 	jsonFlag   bool
 	configPath string
+	sizeFlag   string
+	rescanFlag bool
 	appConfig  *config.Config
 
 	// Set via ldflags at build time.
@@ -43,9 +54,21 @@ var rootCmd = &cobra.Command{
 		}
 		appConfig = cfg
 
+		if cmd.Flags().Changed("size-mode") {
+			if _, err := utils.ParseSizeMode(sizeFlag); err != nil {
+				return err
+			}
+			appConfig.SizeMode = sizeFlag
+		}
+
 		for _, w := range appConfig.Validate() {
 			fmt.Fprintf(os.Stderr, "warning: %s\n", w.Message)
 		}
+		trash.SetProtected(expandPaths(appConfig.ProtectedPaths))
+		history.SetRetention(appConfig.HistoryRetention())
+		dupes.SetDefaultWorkers(appConfig.Dupes.Workers)
+		keepRules, _ := dupes.ParseKeepRules(appConfig.Dupes.Keep)
+		dupes.SetDefaultKeepRules(keepRules)
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -78,6 +101,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&yoloMode, "yolo", false, "Skip ALL confirmation prompts (dangerous!)")
 	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "Output in JSON format")
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to config file (default ~/.config/macbroom/config.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&rescanFlag, "rescan", false, "Ignore cached directory sizes and file hashes and measure everything again")
+	rootCmd.PersistentFlags().StringVar(&sizeFlag, "size-mode", "", "Report sizes as apparent (file length) or allocated (on disk); overrides size_mode in config")
 	rootCmd.Flags().String("generate-completion", "", "Generate shell completion (bash, zsh, fish)")
 	rootCmd.Flags().MarkHidden("generate-completion")
 	rootCmd.AddCommand(scanCmd)
@@ -86,12 +111,14 @@ func init() {
 	rootCmd.AddCommand(maintainCmd)
 	rootCmd.AddCommand(spacelensCmd)
 	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(historyCmd)
 	rootCmd.AddCommand(dupesCmd)
 	rootCmd.AddCommand(scheduleCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(reportCmd)
 	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(trendsCmd)
+	rootCmd.AddCommand(restoreCmd)
 }
 
 // shouldSkipConfirm returns true if the user wants to skip confirmation,
@@ -116,161 +143,265 @@ func buildEngine() *engine.Engine {
 	}
 
 	e := engine.New()
-	home := utils.HomeDir()
 
-	if appConfig.Scanners.System {
-		e.Register(scanner.NewSystemScanner(""))
+	// Node.js, Python and Rust share one traversal of the dev-tool search paths.
+	devPaths := expandPaths(appConfig.DevTools.SearchPaths)
+	walker := scanner.NewProjectWalker(devPaths)
+	walker.SetIncludeDirty(appConfig.DevTools.IncludeDirty)
+	opts := scanner.Options{
+		Home:             utils.HomeDir(),
+		DevPaths:         devPaths,
+		DevMinAge:        config.ParseDuration(appConfig.DevTools.MinAge),
+		Walker:           walker,
+		LargeFilePaths:   expandPaths(appConfig.LargeFiles.Paths),
+		LargeFileMinSize: appConfig.LargeFiles.MinSize,
+		LargeFileMinAge:  config.ParseDuration(appConfig.LargeFiles.MinAge),
+		DockerContainers: appConfig.Docker.StoppedContainers,
+		DockerVolumes:    appConfig.Docker.UnusedVolumes,
 	}
-	if appConfig.Scanners.Browser {
-		e.Register(scanner.NewBrowserScanner("", ""))
+	if appConfig.Docker.Socket != "" {
+		opts.DockerSocket = expandPaths([]string{appConfig.Docker.Socket})[0]
 	}
-	if appConfig.Scanners.Xcode {
-		e.Register(scanner.NewXcodeScanner(""))
+	for _, info := range scanner.Registry() {
+		if appConfig.Scanners.Enabled(info.ConfigKey) {
+			e.Register(info.New(opts))
+		}
 	}
-	if appConfig.Scanners.LargeFiles {
-		paths := expandPaths(appConfig.LargeFiles.Paths)
-		minAge := config.ParseDuration(appConfig.LargeFiles.MinAge)
-		e.Register(scanner.NewLargeFileScanner(paths, appConfig.LargeFiles.MinSize, minAge))
+
+	// Custom and plugin scanners describe specific locations, so they win
+	// overlaps with the built-ins.
+	custom := registerCustomScanners(e)
+	extra := append(custom, registerPlugins(e)...)
+	if len(extra) > 0 {
+		e.SetPrecedence(append(extra, engine.DefaultPrecedence...))
 	}
-	if appConfig.Scanners.Docker {
-		e.Register(scanner.NewDockerScanner())
+
+	e.SetTimeout(appConfig.DefaultTimeout())
+	for _, info := range scanner.Registry() {
+		if d, ok := appConfig.ScannerTimeout(info.ConfigKey); ok {
+			e.SetScannerTimeout(info.Name, d)
+		}
 	}
-	if appConfig.Scanners.Node {
-		paths := expandPaths(appConfig.DevTools.SearchPaths)
-		minAge := config.ParseDuration(appConfig.DevTools.MinAge)
-		e.Register(scanner.NewNodeScanner(home, paths, minAge))
+	for _, name := range custom {
+		if d, ok := appConfig.ScannerTimeout(name); ok {
+			e.SetScannerTimeout(name, d)
+		}
 	}
-	if appConfig.Scanners.Homebrew {
-		e.Register(scanner.NewHomebrewScanner())
+
+	e.SetExcludeFunc(appConfig.IsExcluded)
+	e.SetSizeMode(sizeMode())
+	dirCache, _ := utils.LoadDirCache(scancache.DirCachePath())
+	e.SetDirCache(dirCache)
+	e.SetForceRescan(rescanFlag)
+
+	return e
+}
+
+// registerCustomScanners adds a scanner for each custom_scanners entry and
+// returns their names. Entries the config validation warned about are
+// skipped, including those whose name is reserved by a built-in scanner,
+// taken by a registered one or repeated. Scanners are looked up by name,
+// so a second scanner with the same name would take over its roots,
+// timeout and category.
+func registerCustomScanners(e *engine.Engine) []string {
+	// Built-in names are reserved even when that scanner is disabled.
+	taken := make(map[string]bool)
+	for _, name := range engine.DefaultPrecedence {
+		taken[strings.ToLower(name)] = true
 	}
-	if appConfig.Scanners.IOSSimulators {
-		e.Register(scanner.NewSimulatorScanner(""))
+	for _, s := range e.Scanners() {
+		taken[strings.ToLower(s.Name())] = true
 	}
-	if appConfig.Scanners.Python {
-		paths := expandPaths(appConfig.DevTools.SearchPaths)
-		minAge := config.ParseDuration(appConfig.DevTools.MinAge)
-		e.Register(scanner.NewPythonScanner(home, paths, minAge))
+
+	var names []string
+	for _, cs := range appConfig.CustomScanners {
+		if cs.Name == "" || len(cs.Paths) == 0 {
+			continue
+		}
+		key := strings.ToLower(cs.Name)
+		if _, builtin := scanner.LookupName(cs.Name); builtin || scanner.IsCategoryFlag(key) || taken[key] {
+			continue
+		}
+		risk := scanner.Moderate
+		if cs.Risk != "" {
+			r, err := scanner.ParseRiskLevel(cs.Risk)
+			if err != nil {
+				continue
+			}
+			risk = r
+		}
+		var minAge time.Duration
+		if cs.MinAge != "" {
+			minAge = config.ParseDuration(cs.MinAge)
+		}
+		var minSize int64
+		if cs.MinSize != "" {
+			size, err := config.ParseSize(cs.MinSize)
+			if err != nil {
+				continue
+			}
+			minSize = size
+		}
+		taken[key] = true
+		e.Register(scanner.NewCustomScanner(cs.Name, cs.Description, expandPaths(cs.Paths), minAge, minSize, risk))
+		names = append(names, cs.Name)
 	}
-	if appConfig.Scanners.Rust {
-		paths := expandPaths(appConfig.DevTools.SearchPaths)
-		minAge := config.ParseDuration(appConfig.DevTools.MinAge)
-		e.Register(scanner.NewRustScanner(home, paths, minAge))
+	return names
+}
+
+// loadPlugins finds and loads scanner plugins. Overridden in tests.
+var loadPlugins = func(dirs []string, timeout time.Duration) ([]*plugin.Scanner, []error) {
+	paths := plugin.Discover(dirs, os.Getenv("PATH"))
+	return plugin.LoadAll(context.Background(), paths, timeout)
+}
+
+// registerPlugins loads the scanner plugins enabled in config, registers
+// them and returns their names. Plugins that fail to load are recorded as
+// scan failures; plugins whose name is already taken are reported on
+// stderr. Both are skipped.
+func registerPlugins(e *engine.Engine) []string {
+	if !appConfig.Plugins.Enabled {
+		return nil
 	}
-	if appConfig.Scanners.Go {
-		e.Register(scanner.NewGoScanner(home))
+	timeout, err := time.ParseDuration(appConfig.Plugins.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = time.Minute
 	}
-	if appConfig.Scanners.JetBrains {
-		e.Register(scanner.NewJetBrainsScanner(home))
+
+	// Built-in names are reserved even when that scanner is disabled.
+	taken := make(map[string]bool)
+	for _, name := range engine.DefaultPrecedence {
+		taken[strings.ToLower(name)] = true
 	}
-	if appConfig.Scanners.Maven {
-		e.Register(scanner.NewMavenScanner(home))
+	for _, s := range e.Scanners() {
+		taken[strings.ToLower(s.Name())] = true
 	}
-	if appConfig.Scanners.Gradle {
-		e.Register(scanner.NewGradleScanner(home))
+
+	plugins, errs := loadPlugins(expandPaths(appConfig.Plugins.Dirs), timeout)
+	for _, err := range errs {
+		var le *plugin.LoadError
+		if errors.As(err, &le) {
+			e.AddFailure(&engine.ScanError{Scanner: filepath.Base(le.Path), Phase: engine.PhaseLoad, Err: le.Err})
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 	}
-	if appConfig.Scanners.Ruby {
-		e.Register(scanner.NewRubyScanner(home))
+	var names []string
+	for _, p := range plugins {
+		key := strings.ToLower(p.Name())
+		if taken[key] {
+			fmt.Fprintf(os.Stderr, "warning: plugin %s: scanner name %q is already in use\n", filepath.Base(p.Path()), p.Name())
+			continue
+		}
+		taken[key] = true
+		e.Register(p)
+		names = append(names, p.Name())
 	}
+	return names
+}
 
-	e.SetExcludeFunc(appConfig.IsExcluded)
+// resolveCustomFilter replaces each --custom name with the exact name of
+// the custom scanner or plugin registered in e, failing on unknown names.
+func resolveCustomFilter(f *CategoryFilter, e *engine.Engine) error {
+	for i, name := range f.Custom {
+		if cs, ok := appConfig.CustomScanner(name); ok {
+			f.Custom[i] = cs.Name
+			continue
+		}
+		found := false
+		for _, s := range e.Scanners() {
+			if p, ok := s.(*plugin.Scanner); ok && strings.EqualFold(p.Name(), name) {
+				f.Custom[i] = p.Name()
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown custom scanner %q (define it under custom_scanners in config or install a plugin)", name)
+		}
+	}
+	return nil
+}
 
-	return e
+// sizeMode returns the configured size mode. An invalid size_mode has
+// already been reported as a config warning and falls back to apparent.
+func sizeMode() utils.SizeMode {
+	if appConfig == nil {
+		return utils.SizeApparent
+	}
+	mode, _ := utils.ParseSizeMode(appConfig.SizeMode)
+	return mode
 }
 
-// CategoryFilter holds the boolean flags for individual scanners and profiles.
+// CategoryFilter holds the scanner and profile selection flags.
 type CategoryFilter struct {
-	System    bool
-	Browser   bool
-	Xcode     bool
-	Large     bool
-	Docker    bool
-	Node      bool
-	Homebrew  bool
-	Simulator bool
-	Python    bool
-	Rust      bool
-	Go        bool
-	JetBrains bool
-	Maven     bool
-	Gradle    bool
-	Ruby      bool
-	Dev       bool
-	Caches    bool
-	All       bool
+	// Scanners holds the IDs of the built-in scanners selected by flag.
+	Scanners map[string]bool
+	Dev      bool
+	Caches   bool
+	All      bool
+	// Custom holds names of custom scanners from config.
+	Custom []string
 }
 
-func selectedCategories(f CategoryFilter) []string {
-	// --all overrides everything.
-	if f.All {
-		return nil
+// addCategoryFlags registers one flag per built-in scanner and profile on
+// fs, bound to f. verb starts each help text ("Scan", "Clean").
+func addCategoryFlags(fs *pflag.FlagSet, f *CategoryFilter, verb string) {
+	f.Scanners = make(map[string]bool)
+	for _, info := range scanner.Registry() {
+		flag := fs.VarPF(scannerFlag{set: f.Scanners, id: info.ID}, info.Flag, "", fmt.Sprintf("%s %s only", verb, info.Help))
+		flag.NoOptDefVal = "true"
 	}
+	fs.BoolVar(&f.Dev, string(scanner.ProfileDev), false, verb+" all dev-tool caches")
+	fs.BoolVar(&f.Caches, string(scanner.ProfileCaches), false, verb+" all general caches")
+	fs.BoolVar(&f.All, "all", false, verb+" everything")
+}
 
-	// Expand --dev profile.
-	if f.Dev {
-		f.Node = true
-		f.Python = true
-		f.Rust = true
-		f.Go = true
-		f.JetBrains = true
-		f.Maven = true
-		f.Gradle = true
-		f.Ruby = true
-	}
+// scannerFlag is a boolean flag that toggles one scanner ID in a set.
+type scannerFlag struct {
+	set map[string]bool
+	id  string
+}
 
-	// Expand --caches profile.
-	if f.Caches {
-		f.System = true
-		f.Browser = true
-		f.Homebrew = true
-	}
+func (s scannerFlag) String() string { return strconv.FormatBool(s.set[s.id]) }
+func (s scannerFlag) Type() string   { return "bool" }
 
-	type entry struct {
-		flag bool
-		name string
+func (s scannerFlag) Set(v string) error {
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
 	}
-	entries := []entry{
-		{f.System, "System Junk"},
-		{f.Browser, "Browser Cache"},
-		{f.Xcode, "Xcode Junk"},
-		{f.Large, "Large & Old Files"},
-		{f.Docker, "Docker"},
-		{f.Node, "Node.js"},
-		{f.Homebrew, "Homebrew"},
-		{f.Simulator, "iOS Simulators"},
-		{f.Python, "Python"},
-		{f.Rust, "Rust"},
-		{f.Go, "Go"},
-		{f.JetBrains, "JetBrains"},
-		{f.Maven, "Maven"},
-		{f.Gradle, "Gradle"},
-		{f.Ruby, "Ruby"},
+	s.set[s.id] = on
+	return nil
+}
+
+func selectedCategories(f CategoryFilter) []string {
+	// --all overrides everything.
+	if f.All {
+		return nil
 	}
 
 	var cats []string
-	for _, e := range entries {
-		if e.flag {
-			cats = append(cats, e.name)
+	for _, info := range scanner.Registry() {
+		if f.Scanners[info.ID] ||
+			(f.Dev && info.InProfile(scanner.ProfileDev)) ||
+			(f.Caches && info.InProfile(scanner.ProfileCaches)) {
+			cats = append(cats, info.Name)
 		}
 	}
+	cats = append(cats, f.Custom...)
 	return cats // nil when nothing selected
 }
 
-func scanWithCategories(e *engine.Engine, cats []string) ([]scanner.Target, error) {
-	ctx := context.Background()
-
-	if cats == nil {
-		return e.ScanAll(ctx)
-	}
-
-	var all []scanner.Target
-	for _, cat := range cats {
-		targets, err := e.ScanByCategory(ctx, cat)
-		if err != nil {
-			continue
-		}
-		all = append(all, targets...)
+// scanWithCategories scans the given categories, calling onProgress, when
+// set, as scanners report. Scanners that fail or time out are returned as
+// failures next to the targets of the others.
+func scanWithCategories(e *engine.Engine, cats []string, onProgress func(engine.ScanProgress)) ([]scanner.Target, []engine.Overlap, engine.ScanErrors, error) {
+	targets, overlaps, err := e.ScanTargetsWithProgress(context.Background(), cats, onProgress)
+	if failures := engine.AsScanErrors(err); failures != nil {
+		return targets, overlaps, failures, nil
 	}
-	return all, nil
+	return targets, overlaps, nil, err
 }
 
 // RootCmd returns the root cobra command for documentation generation.
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/config"
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/spf13/cobra"
@@ -13,6 +15,7 @@ import (
 var (
 	scanFilter    CategoryFilter
 	scanExclude   []string
+	scanDirty     bool
 	scanThreshold string
 )
 
@@ -27,6 +30,35 @@ func filterByThreshold(targets []scanner.Target, threshold int64) []scanner.Targ
 	return filtered
 }
 
+// newSnapshot summarizes targets per category for the scan cache. Sizes are
+// already de-duplicated; overlaps only record how much was double-reported.
+func newSnapshot(targets []scanner.Target, overlaps []engine.Overlap) scancache.Snapshot {
+	grouped := make(map[string]*scancache.CategorySnapshot)
+	var order []string
+	var totalSize int64
+	for _, t := range targets {
+		cs := grouped[t.Category]
+		if cs == nil {
+			cs = &scancache.CategorySnapshot{Name: t.Category}
+			grouped[t.Category] = cs
+			order = append(order, t.Category)
+		}
+		cs.Size += t.Size
+		cs.Items++
+		totalSize += t.Size
+	}
+	cats := make([]scancache.CategorySnapshot, 0, len(order))
+	for _, name := range order {
+		cats = append(cats, *grouped[name])
+	}
+	return scancache.Snapshot{
+		Timestamp:   time.Now().UTC(),
+		Categories:  cats,
+		TotalSize:   totalSize,
+		OverlapSize: engine.OverlapSize(overlaps),
+	}
+}
+
 var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Scan for junk files and reclaimable space",
@@ -37,13 +69,22 @@ var scanCmd = &cobra.Command{
 			combined = append(combined, scanExclude...)
 			appConfig.Exclude = combined
 		}
+		if scanDirty {
+			appConfig.DevTools.IncludeDirty = true
+		}
 		e := buildEngine()
+		if err := resolveCustomFilter(&scanFilter, e); err != nil {
+			return err
+		}
 		cats := selectedCategories(scanFilter)
 
+		var onProgress func(engine.ScanProgress)
+		status := newScanStatusLine(os.Stdout)
 		if !jsonFlag {
-			fmt.Println("Scanning...")
+			onProgress = status.update
 		}
-		targets, err := scanWithCategories(e, cats)
+		targets, overlaps, failures, err := scanWithCategories(e, cats, onProgress)
+		status.clear()
 		if err != nil {
 			return fmt.Errorf("scan failed: %w", err)
 		}
@@ -59,25 +100,7 @@ var scanCmd = &cobra.Command{
 
 		prev, prevErr := scancache.Load(scancache.DefaultPath())
 
-		grouped := make(map[string]*scancache.CategorySnapshot)
-		for _, t := range targets {
-			cs := grouped[t.Category]
-			if cs == nil {
-				cs = &scancache.CategorySnapshot{Name: t.Category}
-				grouped[t.Category] = cs
-			}
-			cs.Size += t.Size
-			cs.Items++
-		}
-		var cats2 []scancache.CategorySnapshot
-		for _, cs := range grouped {
-			cats2 = append(cats2, *cs)
-		}
-		var totalSize int64
-		for _, t := range targets {
-			totalSize += t.Size
-		}
-		curr := scancache.Snapshot{Timestamp: time.Now().UTC(), Categories: cats2, TotalSize: totalSize}
+		curr := newSnapshot(targets, overlaps)
 
 		var diff *scancache.DiffResult
 		if prevErr == nil {
@@ -88,10 +111,16 @@ var scanCmd = &cobra.Command{
 		_ = scancache.Save(scancache.DefaultPath(), curr)
 
 		if jsonFlag {
-			return printJSON(buildScanJSON(targets, diff))
+			result := buildScanJSON(targets, diff)
+			result.setOverlaps(overlaps)
+			result.setSizeCache(e.DirCacheStats())
+			result.setFailures(failures)
+			return printJSON(result)
 		}
 
 		printScanResults(targets, diff)
+		printOverlapSummary(overlaps)
+		printScanFailures(failures)
 		return nil
 	},
 }
@@ -99,23 +128,8 @@ var scanCmd = &cobra.Command{
 func init() {
 	f := scanCmd.Flags()
 	f.StringVar(&scanThreshold, "threshold", "", "Only show items above this size (e.g., 100M, 1G)")
-	f.BoolVar(&scanFilter.System, "system", false, "Scan system junk only")
-	f.BoolVar(&scanFilter.Browser, "browser", false, "Scan browser caches only")
-	f.BoolVar(&scanFilter.Xcode, "xcode", false, "Scan Xcode junk only")
-	f.BoolVar(&scanFilter.Large, "large", false, "Scan large/old files only")
-	f.BoolVar(&scanFilter.Docker, "docker", false, "Scan Docker junk only")
-	f.BoolVar(&scanFilter.Node, "node", false, "Scan Node.js cache only")
-	f.BoolVar(&scanFilter.Homebrew, "homebrew", false, "Scan Homebrew cache only")
-	f.BoolVar(&scanFilter.Simulator, "simulator", false, "Scan iOS Simulator data only")
-	f.BoolVar(&scanFilter.Python, "python", false, "Scan Python cache only")
-	f.BoolVar(&scanFilter.Rust, "rust", false, "Scan Rust cache only")
-	f.BoolVar(&scanFilter.Go, "go", false, "Scan Go cache only")
-	f.BoolVar(&scanFilter.JetBrains, "jetbrains", false, "Scan JetBrains cache only")
-	f.BoolVar(&scanFilter.Maven, "maven", false, "Scan Maven cache only")
-	f.BoolVar(&scanFilter.Gradle, "gradle", false, "Scan Gradle cache only")
-	f.BoolVar(&scanFilter.Ruby, "ruby", false, "Scan Ruby cache only")
-	f.BoolVar(&scanFilter.Dev, "dev", false, "Scan all dev-tool caches")
-	f.BoolVar(&scanFilter.Caches, "caches", false, "Scan all general caches")
-	f.BoolVar(&scanFilter.All, "all", false, "Scan everything")
+	addCategoryFlags(f, &scanFilter, "Scan")
+	f.StringSliceVar(&scanFilter.Custom, "custom", nil, "Scan the named custom scanner or plugin (repeatable)")
 	f.StringSliceVar(&scanExclude, "exclude", nil, "Exclude paths matching pattern (glob or dir/**)")
+	f.BoolVar(&scanDirty, "include-dirty", false, "Include build artifacts of projects with uncommitted changes")
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 )
 
@@ -93,3 +94,24 @@ func TestPrintJSON(t *testing.T) {
 		t.Error("expected indented JSON output")
 	}
 }
+
+func TestNewSnapshot_RecordsOverlap(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Caches/pip", Size: 50, Category: "Python"},
+		{Path: "/L/Caches/a", Size: 100, Category: "System Junk"},
+		{Path: "/L/Caches/b", Size: 25, Category: "System Junk"},
+	}
+	overlaps := []engine.Overlap{{Path: "/L/Caches/pip", Owner: "Python", Other: "System Junk", Bytes: 50}}
+
+	snap := newSnapshot(targets, overlaps)
+
+	if snap.TotalSize != 175 {
+		t.Errorf("TotalSize = %d, want 175", snap.TotalSize)
+	}
+	if snap.OverlapSize != 50 {
+		t.Errorf("OverlapSize = %d, want 50", snap.OverlapSize)
+	}
+	if len(snap.Categories) != 2 || snap.Categories[0].Name != "Python" || snap.Categories[1].Items != 2 {
+		t.Errorf("unexpected categories: %+v", snap.Categories)
+	}
+}
@@ -27,7 +27,7 @@ var enableCmd = &cobra.Command{
 
 		fmt.Printf("Installing LaunchAgent for %s cleanup at %s...\n", interval, timeStr)
 
-		if err := schedule.Install(path, timeStr, interval, appConfig.Schedule.Categories); err != nil {
+		if err := schedule.Install(path, timeStr, interval, scheduleCategories()); err != nil {
 			return fmt.Errorf("failed to install schedule: %w", err)
 		}
 
@@ -121,3 +121,16 @@ func init() {
 	scheduleCmd.AddCommand(disableCmd)
 	scheduleCmd.AddCommand(statusCmd)
 }
+
+// scheduleCategories returns the configured schedule categories, marking
+// names of custom scanners so the LaunchAgent passes them via --custom.
+func scheduleCategories() []string {
+	cats := make([]string, 0, len(appConfig.Schedule.Categories))
+	for _, cat := range appConfig.Schedule.Categories {
+		if cs, ok := appConfig.CustomScanner(cat); ok {
+			cat = schedule.CustomCategoryPrefix + cs.Name
+		}
+		cats = append(cats, cat)
+	}
+	return cats
+}
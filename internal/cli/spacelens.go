@@ -28,7 +28,7 @@ var spacelensCmd = &cobra.Command{
 		}
 
 		if spacelensInteractive {
-			p := tea.NewProgram(tui.NewSpaceLensModel(path), tea.WithAltScreen())
+			p := tea.NewProgram(tui.NewSpaceLensModel(path, sizeMode()), tea.WithAltScreen())
 			_, err := p.Run()
 			return err
 		}
@@ -37,6 +37,7 @@ var spacelensCmd = &cobra.Command{
 			fmt.Printf("Analyzing %s...\n\n", path)
 		}
 		sl := scanner.NewSpaceLens(path, spacelensDepth)
+		sl.SetSizeMode(sizeMode())
 		nodes, err := sl.Analyze(context.Background())
 		if err != nil {
 			return fmt.Errorf("failed to analyze: %w", err)
@@ -67,7 +68,7 @@ func printSpaceLensNodes(nodes []scanner.SpaceLensNode, indent int) {
 			icon = "D "
 		}
 		bar := sizeBar(node.Size, nodes[0].Size)
-		fmt.Printf("%s%s %-40s %10s %s\n", prefix, icon, node.Name, utils.FormatSize(node.Size), bar)
+		fmt.Printf("%s%s %-40s %10s %s%s\n", prefix, icon, node.Name, utils.FormatSize(node.Size), bar, otherSize(node.Usage))
 
 		if len(node.Children) > 0 {
 			printSpaceLensNodes(node.Children, indent+1)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/lu-zhengda/macbroom/internal/manifest"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/lu-zhengda/macbroom/internal/trash"
 	"github.com/lu-zhengda/macbroom/internal/utils"
@@ -77,12 +78,17 @@ var uninstallCmd = &cobra.Command{
 			}
 		}
 
+		run := manifest.New("uninstall")
 		for _, t := range targets {
 			var err error
 			if uninstallPermanent {
 				err = trash.PermanentDelete(t.Path)
 			} else {
-				err = trash.MoveToTrash(t.Path)
+				var trashed string
+				trashed, err = trash.Move(t.Path)
+				if err == nil {
+					run.Add(t.Path, trashed, t.Size, t.Category)
+				}
 			}
 			if err != nil {
 				fmt.Printf("  Failed: %s (%v)\n", t.Path, err)
@@ -90,6 +96,7 @@ var uninstallCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Uninstalled %q successfully.\n", appName)
+		printUndoHint(manifest.SaveRun(run))
 		return nil
 	},
 }
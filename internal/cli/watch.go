@@ -8,7 +8,6 @@ import (
 	"github.com/lu-zhengda/macbroom/internal/config"
 	"github.com/lu-zhengda/macbroom/internal/utils"
 	"github.com/spf13/cobra"
-	"golang.org/x/sys/unix"
 )
 
 var (
@@ -16,15 +15,6 @@ var (
 	watchInterval int
 )
 
-// diskFree returns the available disk space in bytes for the given path.
-func diskFree(path string) (int64, error) {
-	var stat unix.Statfs_t
-	if err := unix.Statfs(path, &stat); err != nil {
-		return 0, fmt.Errorf("failed to stat filesystem: %w", err)
-	}
-	return int64(stat.Bavail) * int64(stat.Bsize), nil
-}
-
 var watchCmd = &cobra.Command{
 	Use:   "watch",
 	Short: "Monitor disk free space",
@@ -47,7 +37,7 @@ var watchCmd = &cobra.Command{
 		}
 
 		for {
-			free, err := diskFree("/")
+			free, err := utils.DiskFree("/")
 			if err != nil {
 				return fmt.Errorf("failed to check disk space: %w", err)
 			}
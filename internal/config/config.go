@@ -8,6 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lu-zhengda/macbroom/internal/dupes"
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,6 +22,20 @@ type Config struct {
 	Scanners   ScannersConfig   `yaml:"scanners"`
 	SpaceLens  SpaceLensConfig  `yaml:"spacelens"`
 	Schedule   ScheduleConfig   `yaml:"schedule"`
+	Docker     DockerConfig     `yaml:"docker"`
+	// SizeMode is "apparent" (file length, as Finder shows) or
+	// "allocated" (blocks on disk, as df and du count).
+	SizeMode string `yaml:"size_mode"`
+	// CustomScanners declares extra scanners for cache locations macbroom
+	// has no built-in scanner for.
+	CustomScanners []CustomScannerConfig `yaml:"custom_scanners"`
+	Plugins        PluginsConfig         `yaml:"plugins"`
+	Timeouts       TimeoutsConfig        `yaml:"timeouts"`
+	// ProtectedPaths are never deleted, nor is anything inside them or
+	// any directory containing them.
+	ProtectedPaths []string      `yaml:"protected_paths"`
+	History        HistoryConfig `yaml:"history"`
+	Dupes          DupesConfig   `yaml:"dupes"`
 }
 
 // LargeFilesConfig controls the large/old file scanner.
@@ -31,29 +48,91 @@ type LargeFilesConfig struct {
 
 // DevToolsConfig controls search paths and staleness for dev-tool scanners
 // (Node.js, Python, Rust). These scanners walk directories looking for
-// stale build artifacts (node_modules, virtualenvs, target/).
+// stale build artifacts (node_modules, virtualenvs, target/). A project is
+// stale when its last commit, checkout or lockfile change is older than
+// MinAge; projects with uncommitted changes are skipped unless
+// IncludeDirty is set.
 type DevToolsConfig struct {
-	SearchPaths []string `yaml:"search_paths"`
+	SearchPaths  []string `yaml:"search_paths"`
+	MinAge       string   `yaml:"min_age"`
+	IncludeDirty bool     `yaml:"include_dirty"`
+}
+
+// ScannersConfig toggles built-in scanners on or off, keyed by
+// scanner.Info.ConfigKey. Scanners without an entry are enabled.
+type ScannersConfig map[string]bool
+
+// Enabled reports whether the scanner with the given config key is on.
+func (s ScannersConfig) Enabled(key string) bool {
+	on, ok := s[key]
+	return on || !ok
+}
+
+// CustomScannerConfig declares a scanner that reports every path matching
+// one of Paths. Paths are filepath.Glob patterns and may start with ~/.
+// MinAge and MinSize (e.g. "30d", "10MB") filter matches; empty means no
+// threshold. Risk is safe, moderate or risky and defaults to moderate.
+type CustomScannerConfig struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Paths       []string `yaml:"paths"`
 	MinAge      string   `yaml:"min_age"`
+	MinSize     string   `yaml:"min_size"`
+	Risk        string   `yaml:"risk"`
+}
+
+// CustomScanner returns the custom scanner named name, ignoring case.
+func (c *Config) CustomScanner(name string) (CustomScannerConfig, bool) {
+	for _, cs := range c.CustomScanners {
+		if strings.EqualFold(cs.Name, name) {
+			return cs, true
+		}
+	}
+	return CustomScannerConfig{}, false
+}
+
+// PluginsConfig controls external scanner plugins: executables named
+// macbroom-scanner-* in Dirs or on PATH. Plugins are opt-in, since any
+// matching executable on PATH would otherwise run on every scan. Timeout
+// bounds every call to a plugin, cleaning included (e.g. "60s").
+type PluginsConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Dirs    []string `yaml:"dirs"`
+	Timeout string   `yaml:"timeout"`
 }
 
-// ScannersConfig toggles individual scanners on or off.
-type ScannersConfig struct {
-	System        bool `yaml:"system"`
-	Browser       bool `yaml:"browser"`
-	Xcode         bool `yaml:"xcode"`
-	LargeFiles    bool `yaml:"large_files"`
-	Docker        bool `yaml:"docker"`
-	Node          bool `yaml:"node"`
-	Homebrew      bool `yaml:"homebrew"`
-	IOSSimulators bool `yaml:"ios_simulators"`
-	Python        bool `yaml:"python"`
-	Rust          bool `yaml:"rust"`
-	Go            bool `yaml:"go"`
-	JetBrains     bool `yaml:"jetbrains"`
-	Maven         bool `yaml:"maven"`
-	Gradle        bool `yaml:"gradle"`
-	Ruby          bool `yaml:"ruby"`
+// TimeoutsConfig bounds how long scanners may run. Default applies to every
+// scanner; Scanners overrides it per scanner, keyed by the scanner's key
+// under "scanners" or a custom scanner's name. "0" disables a timeout.
+type TimeoutsConfig struct {
+	Default  string            `yaml:"default"`
+	Scanners map[string]string `yaml:"scanners"`
+}
+
+// DockerConfig controls how the Docker scanner reaches the daemon.
+// Socket is the Engine API Unix socket; when empty, $DOCKER_HOST and the
+// usual Docker Desktop, Colima, OrbStack and Podman sockets are probed.
+// StoppedContainers and UnusedVolumes opt in to cleaning stopped
+// containers and unused volumes, which cannot be restored from the Trash.
+type DockerConfig struct {
+	Socket            string `yaml:"socket"`
+	StoppedContainers bool   `yaml:"stopped_containers"`
+	UnusedVolumes     bool   `yaml:"unused_volumes"`
+}
+
+// HistoryConfig controls the cleanup history log. Runs older than
+// Retention (e.g. "365d") are dropped; "0" keeps every run.
+type HistoryConfig struct {
+	Retention string `yaml:"retention"`
+}
+
+// DupesConfig controls the duplicate file finder. Workers is the number
+// of files hashed at once; 0 picks one per CPU, up to 8. Keep lists the
+// rules that choose which copy of each group to keep, in priority order
+// (e.g. "path:~/Pictures", "not-downloads", "oldest").
+type DupesConfig struct {
+	Workers int      `yaml:"workers"`
+	Keep    []string `yaml:"keep"`
 }
 
 // SpaceLensConfig controls the space-lens disk visualizer.
@@ -84,24 +163,8 @@ func Default() *Config {
 			SearchPaths: []string{"~/Documents", "~/Projects", "~/src", "~/code", "~/Developer"},
 			MinAge:      "30d",
 		},
-		Exclude: []string{},
-		Scanners: ScannersConfig{
-			System:        true,
-			Browser:       true,
-			Xcode:         true,
-			LargeFiles:    true,
-			Docker:        true,
-			Node:          true,
-			Homebrew:      true,
-			IOSSimulators: true,
-			Python:        true,
-			Rust:          true,
-			Go:            true,
-			JetBrains:     true,
-			Maven:         true,
-			Gradle:        true,
-			Ruby:          true,
-		},
+		Exclude:  []string{},
+		Scanners: defaultScanners(),
 		SpaceLens: SpaceLensConfig{
 			DefaultPath: "/",
 			Depth:       2,
@@ -113,9 +176,31 @@ func Default() *Config {
 			Notify:     true,
 			Categories: []string{},
 		},
+		SizeMode: "apparent",
+		Plugins: PluginsConfig{
+			Enabled: false,
+			Dirs:    []string{"~/.config/macbroom/plugins"},
+			Timeout: "60s",
+		},
+		Timeouts: TimeoutsConfig{
+			Default:  "5m",
+			Scanners: map[string]string{},
+		},
+		History: HistoryConfig{
+			Retention: "365d",
+		},
 	}
 }
 
+// defaultScanners enables every built-in scanner.
+func defaultScanners() ScannersConfig {
+	m := make(ScannersConfig)
+	for _, info := range scanner.Registry() {
+		m[info.ConfigKey] = true
+	}
+	return m
+}
+
 // Load loads config from the given path. If path is empty, it uses the
 // default location (~/.config/macbroom/config.yaml). If the file does not
 // exist, it creates it with default values.
@@ -280,27 +365,22 @@ type Warning struct {
 	Suggestion string
 }
 
-// validScheduleCategories lists category names accepted in schedule.categories.
-var validScheduleCategories = map[string]bool{
-	"system": true, "browser": true, "xcode": true, "large": true,
-	"docker": true, "node": true, "homebrew": true, "simulator": true,
-	"python": true, "rust": true, "go": true, "jetbrains": true,
-	"maven": true, "gradle": true, "ruby": true,
-	"dev": true, "caches": true, "all": true,
-}
-
 // knownTopLevelKeys lists the accepted top-level YAML keys.
 var knownTopLevelKeys = map[string]bool{
 	"large_files": true, "dev_tools": true, "exclude": true,
 	"scanners": true, "spacelens": true, "schedule": true,
+	"docker": true, "size_mode": true, "custom_scanners": true,
+	"plugins": true, "timeouts": true, "protected_paths": true,
+	"history": true, "dupes": true,
 }
 
-// knownScannerKeys lists the accepted keys under the "scanners" map.
-var knownScannerKeys = map[string]bool{
-	"system": true, "browser": true, "xcode": true, "large_files": true,
-	"docker": true, "node": true, "homebrew": true, "ios_simulators": true,
-	"python": true, "rust": true, "go": true, "jetbrains": true,
-	"maven": true, "gradle": true, "ruby": true,
+// scannerConfigKeys lists the accepted keys under the "scanners" map.
+func scannerConfigKeys() []string {
+	var keys []string
+	for _, info := range scanner.Registry() {
+		keys = append(keys, info.ConfigKey)
+	}
+	return keys
 }
 
 // Validate checks the config for common issues and returns warnings.
@@ -365,6 +445,46 @@ func (c *Config) Validate() []Warning {
 		}
 	}
 
+	// Validate protected_paths — relative paths cannot be matched.
+	for _, p := range c.ProtectedPaths {
+		if p != "~" && !strings.HasPrefix(p, "~/") && !filepath.IsAbs(p) {
+			warnings = append(warnings, Warning{
+				Field:      "protected_paths",
+				Message:    fmt.Sprintf("protected path %q is not absolute", p),
+				Suggestion: "Use an absolute path or one starting with ~/",
+			})
+		}
+	}
+
+	// Validate history.retention.
+	if r := c.History.Retention; r != "" && r != "0" && !validDuration(r) {
+		warnings = append(warnings, Warning{
+			Field:      "history.retention",
+			Message:    fmt.Sprintf("invalid history retention %q", r),
+			Suggestion: "Use a duration such as \"365d\", or \"0\" to keep every run",
+		})
+	}
+
+	// Validate dupes.workers.
+	if c.Dupes.Workers < 0 {
+		warnings = append(warnings, Warning{
+			Field:      "dupes.workers",
+			Message:    fmt.Sprintf("invalid worker count %d", c.Dupes.Workers),
+			Suggestion: "Use a positive number, or 0 for one per CPU",
+		})
+	}
+
+	// Validate dupes.keep.
+	for _, r := range c.Dupes.Keep {
+		if _, err := dupes.ParseKeepRule(r); err != nil {
+			warnings = append(warnings, Warning{
+				Field:      "dupes.keep",
+				Message:    err.Error(),
+				Suggestion: "Use path:DIR, glob:PATTERN, not-downloads, oldest, newest or shortest-path",
+			})
+		}
+	}
+
 	// Validate schedule.time.
 	if c.Schedule.Time != "" {
 		parts := strings.SplitN(c.Schedule.Time, ":", 2)
@@ -404,11 +524,35 @@ func (c *Config) Validate() []Warning {
 
 	// Validate schedule.categories.
 	for _, cat := range c.Schedule.Categories {
-		if !validScheduleCategories[cat] {
+		if _, custom := c.CustomScanner(cat); !scanner.IsCategoryFlag(cat) && !custom {
 			warnings = append(warnings, Warning{
 				Field:      "schedule.categories",
 				Message:    fmt.Sprintf("unknown schedule category %q", cat),
-				Suggestion: "Valid categories: system, browser, xcode, large, docker, node, homebrew, simulator, python, rust, go, jetbrains, maven, gradle, ruby, dev, caches, all",
+				Suggestion: "Valid categories: " + strings.Join(scanner.CategoryFlags(), ", "),
+			})
+		}
+	}
+
+	warnings = append(warnings, c.validateCustomScanners()...)
+
+	// Validate size_mode.
+	if _, err := utils.ParseSizeMode(c.SizeMode); err != nil {
+		warnings = append(warnings, Warning{
+			Field:      "size_mode",
+			Message:    fmt.Sprintf("invalid size mode %q", c.SizeMode),
+			Suggestion: "Use \"apparent\" or \"allocated\"",
+		})
+	}
+
+	warnings = append(warnings, c.validateTimeouts()...)
+
+	// Validate plugins.timeout.
+	if c.Plugins.Timeout != "" {
+		if d, err := time.ParseDuration(c.Plugins.Timeout); err != nil || d <= 0 {
+			warnings = append(warnings, Warning{
+				Field:      "plugins.timeout",
+				Message:    fmt.Sprintf("invalid plugin timeout %q", c.Plugins.Timeout),
+				Suggestion: "Use a positive duration such as \"60s\" or \"2m\"",
 			})
 		}
 	}
@@ -416,6 +560,135 @@ func (c *Config) Validate() []Warning {
 	return warnings
 }
 
+// validateCustomScanners checks each custom_scanners entry.
+func (c *Config) validateCustomScanners() []Warning {
+	var warnings []Warning
+	names := make(map[string]bool)
+	for i, cs := range c.CustomScanners {
+		field := fmt.Sprintf("custom_scanners[%d]", i)
+		warn := func(msg, suggestion string) {
+			warnings = append(warnings, Warning{Field: field, Message: msg, Suggestion: suggestion})
+		}
+
+		if strings.TrimSpace(cs.Name) == "" {
+			warn("custom scanner has no name", "Add a name; it is shown as the category and used with --custom")
+		} else {
+			key := strings.ToLower(cs.Name)
+			if _, builtin := scanner.LookupName(cs.Name); builtin || scanner.IsCategoryFlag(key) {
+				warn(fmt.Sprintf("custom scanner %q clashes with a built-in scanner", cs.Name), "Choose a different name")
+			}
+			if names[key] {
+				warn(fmt.Sprintf("duplicate custom scanner %q", cs.Name), "Give each custom scanner a unique name")
+			}
+			names[key] = true
+		}
+
+		if len(cs.Paths) == 0 {
+			warn(fmt.Sprintf("custom scanner %q has no paths", cs.Name), "Add one or more glob patterns under paths")
+		}
+		for _, p := range cs.Paths {
+			if _, err := filepath.Match(p, "test"); err != nil {
+				warn(fmt.Sprintf("invalid path pattern %q: %v", p, err), "Check glob syntax; avoid unmatched brackets")
+			}
+		}
+		if cs.MinAge != "" && !validDuration(cs.MinAge) {
+			warn(fmt.Sprintf("invalid min_age %q", cs.MinAge), "Use a duration such as \"30d\" or \"12h\"")
+		}
+		if cs.MinSize != "" {
+			if _, err := ParseSize(cs.MinSize); err != nil {
+				warn(fmt.Sprintf("invalid min_size %q", cs.MinSize), "Use a size such as \"10MB\" or \"1G\"")
+			}
+		}
+		switch strings.ToLower(cs.Risk) {
+		case "", "safe", "moderate", "risky":
+		default:
+			warn(fmt.Sprintf("invalid risk %q", cs.Risk), "Use \"safe\", \"moderate\" or \"risky\"")
+		}
+	}
+	return warnings
+}
+
+// validateTimeouts checks the timeouts section.
+func (c *Config) validateTimeouts() []Warning {
+	var warnings []Warning
+	check := func(field, value string) {
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			warnings = append(warnings, Warning{
+				Field:      field,
+				Message:    fmt.Sprintf("invalid timeout %q", value),
+				Suggestion: "Use a duration such as \"30s\" or \"5m\", or \"0\" for no limit",
+			})
+		}
+	}
+	if c.Timeouts.Default != "" {
+		check("timeouts.default", c.Timeouts.Default)
+	}
+	for key, value := range c.Timeouts.Scanners {
+		field := "timeouts.scanners." + key
+		_, builtin := scanner.LookupConfigKey(key)
+		if _, custom := c.CustomScanner(key); !builtin && !custom {
+			warnings = append(warnings, Warning{
+				Field:      field,
+				Message:    fmt.Sprintf("unknown scanner %q", key),
+				Suggestion: "Use a key from the scanners section or a custom scanner name",
+			})
+			continue
+		}
+		check(field, value)
+	}
+	return warnings
+}
+
+// ScannerTimeout returns the timeout for the scanner configured under key
+// (a "scanners" key or custom scanner name) and whether one is set.
+func (c *Config) ScannerTimeout(key string) (time.Duration, bool) {
+	value, ok := c.Timeouts.Scanners[key]
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// DefaultTimeout returns the timeout applied to every scanner, or zero for
+// no limit. An invalid value falls back to five minutes.
+func (c *Config) DefaultTimeout() time.Duration {
+	if c.Timeouts.Default == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.Timeouts.Default)
+	if err != nil || d < 0 {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// HistoryRetention returns how long cleanup history is kept, or zero to
+// keep it forever. An invalid value also keeps it forever.
+func (c *Config) HistoryRetention() time.Duration {
+	r := c.History.Retention
+	if r == "" || r == "0" || !validDuration(r) {
+		return 0
+	}
+	return ParseDuration(r)
+}
+
+// validDuration reports whether ParseDuration understands s rather than
+// falling back to its default.
+func validDuration(s string) bool {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if _, err := strconv.Atoi(days); err == nil {
+			return true
+		}
+	}
+	_, err := time.ParseDuration(s)
+	return err == nil
+}
+
 // LoadAndValidate unmarshals YAML data into a Config, detects unknown keys,
 // and runs structural validation. It returns the config and any warnings.
 func LoadAndValidate(data []byte) (*Config, []Warning) {
@@ -452,7 +725,7 @@ func LoadAndValidate(data []byte) (*Config, []Warning) {
 				warnings = append(warnings, Warning{
 					Field:      key,
 					Message:    fmt.Sprintf("unknown config key %q", key),
-					Suggestion: "Check spelling; valid keys: large_files, dev_tools, exclude, scanners, spacelens, schedule",
+					Suggestion: "Check spelling; valid keys: large_files, dev_tools, exclude, scanners, spacelens, schedule, docker, size_mode, custom_scanners, plugins, timeouts, protected_paths, history, dupes",
 				})
 			}
 		}
@@ -461,11 +734,11 @@ func LoadAndValidate(data []byte) (*Config, []Warning) {
 		if scannersRaw, ok := raw["scanners"]; ok {
 			if scannersMap, ok := scannersRaw.(map[string]interface{}); ok {
 				for key := range scannersMap {
-					if !knownScannerKeys[key] {
+					if _, ok := scanner.LookupConfigKey(key); !ok {
 						warnings = append(warnings, Warning{
 							Field:      "scanners." + key,
 							Message:    fmt.Sprintf("unknown scanner %q", key),
-							Suggestion: "Valid scanners: system, browser, xcode, large_files, docker, node, homebrew, ios_simulators, python, rust, go, jetbrains, maven, gradle, ruby",
+							Suggestion: "Valid scanners: " + strings.Join(scannerConfigKeys(), ", "),
 						})
 					}
 				}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -31,29 +32,29 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	// Scanners defaults — all enabled
-	if !cfg.Scanners.System {
-		t.Error("expected Scanners.System to be true")
+	if !cfg.Scanners.Enabled("system") {
+		t.Error("expected scanners.system to be true")
 	}
-	if !cfg.Scanners.Browser {
-		t.Error("expected Scanners.Browser to be true")
+	if !cfg.Scanners.Enabled("browser") {
+		t.Error("expected scanners.browser to be true")
 	}
-	if !cfg.Scanners.Xcode {
-		t.Error("expected Scanners.Xcode to be true")
+	if !cfg.Scanners.Enabled("xcode") {
+		t.Error("expected scanners.xcode to be true")
 	}
-	if !cfg.Scanners.LargeFiles {
-		t.Error("expected Scanners.LargeFiles to be true")
+	if !cfg.Scanners.Enabled("large_files") {
+		t.Error("expected scanners.large_files to be true")
 	}
-	if !cfg.Scanners.Docker {
-		t.Error("expected Scanners.Docker to be true")
+	if !cfg.Scanners.Enabled("docker") {
+		t.Error("expected scanners.docker to be true")
 	}
-	if !cfg.Scanners.Node {
-		t.Error("expected Scanners.Node to be true")
+	if !cfg.Scanners.Enabled("node") {
+		t.Error("expected scanners.node to be true")
 	}
-	if !cfg.Scanners.Homebrew {
-		t.Error("expected Scanners.Homebrew to be true")
+	if !cfg.Scanners.Enabled("homebrew") {
+		t.Error("expected scanners.homebrew to be true")
 	}
-	if !cfg.Scanners.IOSSimulators {
-		t.Error("expected Scanners.IOSSimulators to be true")
+	if !cfg.Scanners.Enabled("ios_simulators") {
+		t.Error("expected scanners.ios_simulators to be true")
 	}
 
 	// DevTools defaults
@@ -97,29 +98,29 @@ func TestDefaultConfig(t *testing.T) {
 
 func TestDefaultConfig_NewScanners(t *testing.T) {
 	cfg := Default()
-	if !cfg.Scanners.Python {
+	if !cfg.Scanners.Enabled("python") {
 		t.Error("expected Python scanner enabled by default")
 	}
-	if !cfg.Scanners.Rust {
+	if !cfg.Scanners.Enabled("rust") {
 		t.Error("expected Rust scanner enabled by default")
 	}
-	if !cfg.Scanners.Go {
+	if !cfg.Scanners.Enabled("go") {
 		t.Error("expected Go scanner enabled by default")
 	}
-	if !cfg.Scanners.JetBrains {
+	if !cfg.Scanners.Enabled("jetbrains") {
 		t.Error("expected JetBrains scanner enabled by default")
 	}
 }
 
 func TestDefaultConfig_V04Scanners(t *testing.T) {
 	cfg := Default()
-	if !cfg.Scanners.Maven {
+	if !cfg.Scanners.Enabled("maven") {
 		t.Error("expected Maven scanner enabled by default")
 	}
-	if !cfg.Scanners.Gradle {
+	if !cfg.Scanners.Enabled("gradle") {
 		t.Error("expected Gradle scanner enabled by default")
 	}
-	if !cfg.Scanners.Ruby {
+	if !cfg.Scanners.Enabled("ruby") {
 		t.Error("expected Ruby scanner enabled by default")
 	}
 }
@@ -181,17 +182,17 @@ schedule:
 		t.Fatalf("expected 2 exclude patterns, got %d", len(cfg.Exclude))
 	}
 
-	if cfg.Scanners.System {
-		t.Error("expected Scanners.System to be false")
+	if cfg.Scanners.Enabled("system") {
+		t.Error("expected scanners.system to be false")
 	}
-	if !cfg.Scanners.Browser {
-		t.Error("expected Scanners.Browser to be true")
+	if !cfg.Scanners.Enabled("browser") {
+		t.Error("expected scanners.browser to be true")
 	}
-	if cfg.Scanners.Xcode {
-		t.Error("expected Scanners.Xcode to be false")
+	if cfg.Scanners.Enabled("xcode") {
+		t.Error("expected scanners.xcode to be false")
 	}
-	if cfg.Scanners.Docker {
-		t.Error("expected Scanners.Docker to be false")
+	if cfg.Scanners.Enabled("docker") {
+		t.Error("expected scanners.docker to be false")
 	}
 
 	if cfg.SpaceLens.DefaultPath != "/Users" {
@@ -236,14 +237,32 @@ func TestLoadFromFilePartial(t *testing.T) {
 	if cfg.LargeFiles.MinAge != "90d" {
 		t.Errorf("expected MinAge '90d' (default), got %q", cfg.LargeFiles.MinAge)
 	}
-	if !cfg.Scanners.System {
-		t.Error("expected Scanners.System to keep default (true)")
+	if !cfg.Scanners.Enabled("system") {
+		t.Error("expected scanners.system to keep default (true)")
 	}
 	if cfg.SpaceLens.Depth != 2 {
 		t.Errorf("expected SpaceLens.Depth to keep default 2, got %d", cfg.SpaceLens.Depth)
 	}
 }
 
+func TestLoadFromFile_DevToolsIncludeDirty(t *testing.T) {
+	if Default().DevTools.IncludeDirty {
+		t.Error("expected dirty projects to be skipped by default")
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("dev_tools:\n  include_dirty: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadFrom(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	if !cfg.DevTools.IncludeDirty || cfg.DevTools.MinAge != "30d" {
+		t.Errorf("unexpected dev_tools %+v", cfg.DevTools)
+	}
+}
+
 func TestLoadCreatesDefault(t *testing.T) {
 	dir := t.TempDir()
 	cfgPath := filepath.Join(dir, "subdir", "config.yaml")
@@ -428,7 +447,7 @@ func TestSaveAndLoad(t *testing.T) {
 	cfg := Default()
 	cfg.LargeFiles.MinSizeStr = "250MB"
 	cfg.LargeFiles.MinSize = 250 * 1024 * 1024
-	cfg.Scanners.Docker = false
+	cfg.Scanners["docker"] = false
 	cfg.Exclude = []string{"*.tmp"}
 
 	if err := cfg.Save(cfgPath); err != nil {
@@ -446,7 +465,7 @@ func TestSaveAndLoad(t *testing.T) {
 	if loaded.LargeFiles.MinSize != 250*1024*1024 {
 		t.Errorf("expected MinSize 250MB, got %d", loaded.LargeFiles.MinSize)
 	}
-	if loaded.Scanners.Docker {
+	if loaded.Scanners.Enabled("docker") {
 		t.Error("expected Docker scanner to be disabled")
 	}
 	if len(loaded.Exclude) != 1 || loaded.Exclude[0] != "*.tmp" {
@@ -630,3 +649,269 @@ scanners:
 		t.Error("expected warning for unknown scanner key")
 	}
 }
+
+func TestLoadAndValidate_DockerSocket(t *testing.T) {
+	data := []byte(`
+docker:
+  socket: ~/.colima/default/docker.sock
+  unused_volumes: true
+`)
+	cfg, warnings := LoadAndValidate(data)
+	for _, w := range warnings {
+		if w.Field == "docker" {
+			t.Errorf("unexpected warning for docker key: %s", w.Message)
+		}
+	}
+	if cfg.Docker.Socket != "~/.colima/default/docker.sock" {
+		t.Errorf("expected docker socket to be loaded, got %q", cfg.Docker.Socket)
+	}
+	if cfg.Docker.StoppedContainers || !cfg.Docker.UnusedVolumes {
+		t.Errorf("expected only unused volumes to be enabled, got %+v", cfg.Docker)
+	}
+}
+
+func TestLoadAndValidate_SizeMode(t *testing.T) {
+	cfg, warnings := LoadAndValidate([]byte("size_mode: allocated\n"))
+	for _, w := range warnings {
+		if w.Field == "size_mode" {
+			t.Errorf("unexpected warning for size_mode: %s", w.Message)
+		}
+	}
+	if cfg.SizeMode != "allocated" {
+		t.Errorf("expected size_mode to be loaded, got %q", cfg.SizeMode)
+	}
+
+	_, warnings = LoadAndValidate([]byte("size_mode: blocks\n"))
+	found := false
+	for _, w := range warnings {
+		if w.Field == "size_mode" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected warning for invalid size_mode")
+	}
+}
+
+func TestLoadAndValidate_CustomScanners(t *testing.T) {
+	data := []byte(`
+custom_scanners:
+  - name: Unity
+    description: Unity asset caches
+    paths: ["~/Library/Unity/cache/*"]
+    min_age: 30d
+    min_size: 10MB
+    risk: safe
+schedule:
+  categories: [system, Unity]
+`)
+	cfg, warnings := LoadAndValidate(data)
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Field, "custom_scanners") || w.Field == "schedule.categories" {
+			t.Errorf("unexpected warning: field=%q message=%q", w.Field, w.Message)
+		}
+	}
+	cs, ok := cfg.CustomScanner("unity")
+	if !ok || cs.Name != "Unity" || cs.MinSize != "10MB" || len(cs.Paths) != 1 {
+		t.Errorf("custom scanner not loaded: %+v", cfg.CustomScanners)
+	}
+}
+
+func TestValidate_InvalidCustomScanners(t *testing.T) {
+	cfg := Default()
+	cfg.LargeFiles.Paths = nil
+	cfg.DevTools.SearchPaths = nil
+	cfg.CustomScanners = []CustomScannerConfig{
+		{Name: "", Paths: []string{"/tmp/*"}},
+		{Name: "Python", Paths: []string{"/tmp/*"}},
+		{Name: "dev", Paths: []string{"/tmp/*"}},
+		{Name: "Logs", Paths: []string{"[bad"}, MinAge: "soon", MinSize: "lots", Risk: "yolo"},
+		{Name: "logs"},
+	}
+
+	var got []string
+	for _, w := range cfg.Validate() {
+		got = append(got, w.Field+": "+w.Message)
+	}
+	want := []string{
+		"custom_scanners[0]: custom scanner has no name",
+		`custom_scanners[1]: custom scanner "Python" clashes with a built-in scanner`,
+		`custom_scanners[2]: custom scanner "dev" clashes with a built-in scanner`,
+		`custom_scanners[3]: invalid min_age "soon"`,
+		`custom_scanners[3]: invalid min_size "lots"`,
+		`custom_scanners[3]: invalid risk "yolo"`,
+		`custom_scanners[4]: duplicate custom scanner "logs"`,
+		`custom_scanners[4]: custom scanner "logs" has no paths`,
+	}
+	joined := strings.Join(got, "\n")
+	for _, w := range want {
+		if !strings.Contains(joined, w) {
+			t.Errorf("missing warning %q in:\n%s", w, joined)
+		}
+	}
+	if !strings.Contains(joined, `invalid path pattern "[bad"`) {
+		t.Errorf("expected a warning for the malformed path pattern, got:\n%s", joined)
+	}
+}
+
+func TestLoadAndValidate_Plugins(t *testing.T) {
+	if Default().Plugins.Enabled {
+		t.Error("expected plugins to be disabled by default")
+	}
+
+	cfg, warnings := LoadAndValidate([]byte("plugins:\n  enabled: true\n  dirs: [~/bin/macbroom]\n  timeout: 2m\n"))
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Field, "plugins") {
+			t.Errorf("unexpected warning: field=%q message=%q", w.Field, w.Message)
+		}
+	}
+	if !cfg.Plugins.Enabled || len(cfg.Plugins.Dirs) != 1 || cfg.Plugins.Timeout != "2m" {
+		t.Errorf("plugins not loaded: %+v", cfg.Plugins)
+	}
+
+	_, warnings = LoadAndValidate([]byte("plugins:\n  timeout: forever\n"))
+	found := false
+	for _, w := range warnings {
+		if w.Field == "plugins.timeout" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected warning for invalid plugins.timeout")
+	}
+}
+
+func TestLoadAndValidate_Timeouts(t *testing.T) {
+	data := []byte(`
+timeouts:
+  default: 2m
+  scanners:
+    homebrew: 30s
+    ios_simulators: "0"
+    Unity: 10s
+custom_scanners:
+  - name: Unity
+    paths: ["/tmp/unity/*"]
+`)
+	cfg, warnings := LoadAndValidate(data)
+	for _, w := range warnings {
+		if strings.HasPrefix(w.Field, "timeouts") {
+			t.Errorf("unexpected warning: field=%q message=%q", w.Field, w.Message)
+		}
+	}
+	if got := cfg.DefaultTimeout(); got != 2*time.Minute {
+		t.Errorf("DefaultTimeout = %s, want 2m", got)
+	}
+	if d, ok := cfg.ScannerTimeout("homebrew"); !ok || d != 30*time.Second {
+		t.Errorf("ScannerTimeout(homebrew) = %s, %v", d, ok)
+	}
+	if d, ok := cfg.ScannerTimeout("ios_simulators"); !ok || d != 0 {
+		t.Errorf("ScannerTimeout(ios_simulators) = %s, %v; want an explicit 0", d, ok)
+	}
+	if _, ok := cfg.ScannerTimeout("docker"); ok {
+		t.Error("docker has no timeout override")
+	}
+
+	_, warnings = LoadAndValidate([]byte("timeouts:\n  default: soon\n  scanners:\n    brew: 30s\n    docker: -1s\n"))
+	fields := make(map[string]bool)
+	for _, w := range warnings {
+		fields[w.Field] = true
+	}
+	for _, f := range []string{"timeouts.default", "timeouts.scanners.brew", "timeouts.scanners.docker"} {
+		if !fields[f] {
+			t.Errorf("expected a warning for %s, got %v", f, warnings)
+		}
+	}
+}
+
+func TestLoadAndValidate_ProtectedPaths(t *testing.T) {
+	data := []byte(`
+protected_paths:
+  - ~/Projects/thesis
+  - /Volumes/Archive
+  - relative/dir
+`)
+	cfg, warnings := LoadAndValidate(data)
+	if len(cfg.ProtectedPaths) != 3 {
+		t.Fatalf("expected 3 protected paths, got %v", cfg.ProtectedPaths)
+	}
+	var got []string
+	for _, w := range warnings {
+		if w.Field == "protected_paths" {
+			got = append(got, w.Message)
+		}
+		if w.Field == "protected_paths" && !strings.Contains(w.Message, "relative/dir") {
+			t.Errorf("unexpected warning %q", w.Message)
+		}
+	}
+	if len(got) != 1 {
+		t.Errorf("expected one warning for the relative path, got %v", got)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		warn  bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"forever", 0, true},
+	}
+	for _, tt := range tests {
+		cfg, warnings := LoadAndValidate([]byte("history:\n  retention: \"" + tt.value + "\"\n"))
+		if got := cfg.HistoryRetention(); got != tt.want {
+			t.Errorf("HistoryRetention(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		var warned bool
+		for _, w := range warnings {
+			warned = warned || w.Field == "history.retention"
+		}
+		if warned != tt.warn {
+			t.Errorf("retention %q: warned = %v, want %v", tt.value, warned, tt.warn)
+		}
+	}
+	if got := Default().HistoryRetention(); got != 365*24*time.Hour {
+		t.Errorf("default retention = %v, want 365 days", got)
+	}
+}
+
+func TestDupesWorkers(t *testing.T) {
+	cfg, warnings := LoadAndValidate([]byte("dupes:\n  workers: 4\n"))
+	if cfg.Dupes.Workers != 4 {
+		t.Errorf("Dupes.Workers = %d, want 4", cfg.Dupes.Workers)
+	}
+	for _, w := range warnings {
+		if w.Field == "dupes" || w.Field == "dupes.workers" {
+			t.Errorf("unexpected warning %+v", w)
+		}
+	}
+
+	_, warnings = LoadAndValidate([]byte("dupes:\n  workers: -1\n"))
+	var warned bool
+	for _, w := range warnings {
+		warned = warned || w.Field == "dupes.workers"
+	}
+	if !warned {
+		t.Error("expected a warning for a negative worker count")
+	}
+}
+
+func TestDupesKeep(t *testing.T) {
+	cfg, warnings := LoadAndValidate([]byte("dupes:\n  keep:\n    - path:~/Pictures\n    - oldest\n    - biggest\n"))
+	if len(cfg.Dupes.Keep) != 3 {
+		t.Fatalf("Dupes.Keep = %v, want 3 rules", cfg.Dupes.Keep)
+	}
+	var warned int
+	for _, w := range warnings {
+		if w.Field == "dupes.keep" {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Errorf("expected 1 dupes.keep warning, got %d", warned)
+	}
+}
@@ -0,0 +1,17 @@
+package dupes
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst as an APFS clone of src, sharing its blocks until
+// either is modified.
+func cloneFile(src, dst string) error {
+	err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW|unix.CLONE_NOOWNERCOPY)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EXDEV) {
+		return ErrCloneUnsupported
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package dupes
+
+// cloneFile reports that clones are not supported on this platform.
+func cloneFile(src, dst string) error {
+	return ErrCloneUnsupported
+}
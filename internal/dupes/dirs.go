@@ -0,0 +1,249 @@
+package dupes
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ignoredInDirs are files left out when comparing directories: Finder
+// writes them per folder, so otherwise identical copies rarely match.
+var ignoredInDirs = map[string]bool{".DS_Store": true}
+
+// tree records the directories walked by groupBySize, so that directories
+// whose entire contents are duplicated can be found once the files have
+// been hashed. A nil tree records nothing.
+type tree struct {
+	dirs map[string]*dirNode
+}
+
+// dirNode is one walked directory. A directory is incomplete when the walk
+// skipped anything in it (a small, hidden or unreadable file, a symlink, a
+// git repository): it could then differ from a copy in ways the search
+// cannot see, so it is never reported as a duplicate.
+type dirNode struct {
+	files      []string
+	subdirs    []string
+	incomplete bool
+	root       bool
+
+	hash  string
+	size  int64
+	count int
+}
+
+func newTree() *tree {
+	return &tree{dirs: make(map[string]*dirNode)}
+}
+
+// addDir records a walked directory. Directories the search started from
+// are never reported as duplicates themselves.
+func (t *tree) addDir(path string) {
+	if t == nil {
+		return
+	}
+	if t.dirs[path] != nil {
+		return
+	}
+	n := &dirNode{}
+	t.dirs[path] = n
+	if parent := t.dirs[filepath.Dir(path)]; parent != nil && filepath.Dir(path) != path {
+		parent.subdirs = append(parent.subdirs, path)
+	} else {
+		n.root = true
+	}
+}
+
+// addFile records a file the search compares.
+func (t *tree) addFile(path string) {
+	if t == nil {
+		return
+	}
+	if parent := t.dirs[filepath.Dir(path)]; parent != nil {
+		parent.files = append(parent.files, path)
+	}
+}
+
+// skip records that the walk left out path, making its directory
+// incomplete.
+func (t *tree) skip(path string) {
+	if t == nil || ignoredInDirs[filepath.Base(path)] {
+		return
+	}
+	if parent := t.dirs[filepath.Dir(path)]; parent != nil {
+		parent.incomplete = true
+	}
+}
+
+// markIncomplete records that the contents of dir could not all be listed.
+func (t *tree) markIncomplete(dir string) {
+	if t == nil {
+		return
+	}
+	if n := t.dirs[dir]; n != nil {
+		n.incomplete = true
+	}
+}
+
+// dirGroups hashes every complete directory from the content hashes of its
+// files and subdirectories, Merkle-style, and groups directories with equal
+// hashes. Files missing from fileHash have no duplicate, so directories
+// holding them cannot have one either. Directories without files are left
+// out.
+func (t *tree) dirGroups(fileHash map[string]string, sizes map[string]int64) []Group {
+	paths := make([]string, 0, len(t.dirs))
+	for p := range t.dirs {
+		paths = append(paths, p)
+	}
+	// Children before their parents.
+	sort.Slice(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+
+	byHash := make(map[string][]string)
+	for _, p := range paths {
+		n := t.dirs[p]
+		if !n.incomplete {
+			n.hash = n.merkle(fileHash, sizes, t.dirs)
+		}
+		if n.hash != "" && n.count > 0 && !n.root {
+			byHash[n.hash] = append(byHash[n.hash], p)
+		}
+	}
+
+	var groups []Group
+	for h, members := range byHash {
+		if len(members) < 2 {
+			continue
+		}
+		sort.Strings(members)
+		n := t.dirs[members[0]]
+		groups = append(groups, Group{Size: n.size, Hash: h, Files: members, Dir: true, FileCount: n.count})
+	}
+	return groups
+}
+
+// merkle returns the hash of the directory from the names and hashes of
+// its entries, and totals its size and file count, or returns "" if any
+// entry has no hash.
+func (n *dirNode) merkle(fileHash map[string]string, sizes map[string]int64, dirs map[string]*dirNode) string {
+	type entry struct{ name, kind, hash string }
+	entries := make([]entry, 0, len(n.files)+len(n.subdirs))
+	n.size, n.count = 0, 0
+	for _, f := range n.files {
+		h, ok := fileHash[f]
+		if !ok {
+			return ""
+		}
+		entries = append(entries, entry{filepath.Base(f), "f", h})
+		n.size += sizes[f]
+		n.count++
+	}
+	for _, d := range n.subdirs {
+		sub := dirs[d]
+		if sub.hash == "" {
+			return ""
+		}
+		entries = append(entries, entry{filepath.Base(d), "d", sub.hash})
+		n.size += sub.size
+		n.count += sub.count
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	h := sha256.New()
+	for _, e := range entries {
+		fmt.Fprintf(h, "%s %s %s\x00", e.kind, e.hash, e.name)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// rollUp chooses the copy to keep in each group and leaves out whatever
+// removing a larger duplicate directory already takes care of. Directory
+// groups are settled largest first, outermost first on ties, so a group
+// nested in one already reported only lists the members outside the
+// directories it removes, and is dropped if fewer than two are left. File
+// groups are then trimmed the same way. A group with a member inside a
+// directory already kept keeps that member (see pinKept).
+func rollUp(dirGroups, fileGroups []Group, keep []KeepRule) []Group {
+	sort.Slice(dirGroups, func(i, j int) bool {
+		if dirGroups[i].Size != dirGroups[j].Size {
+			return dirGroups[i].Size > dirGroups[j].Size
+		}
+		return len(dirGroups[i].Files[0]) < len(dirGroups[j].Files[0])
+	})
+
+	removed := make(map[string]bool)
+	keptDirs := make(map[string]bool)
+	var dirs []Group
+	for _, g := range dirGroups {
+		if g.Files = outside(g.Files, removed); len(g.Files) < 2 {
+			continue
+		}
+		kept := []Group{g}
+		ApplyKeep(kept, keep)
+		g = kept[0]
+		pinKept(&g, keptDirs)
+		keptDirs[g.Files[0]] = true
+		for _, p := range g.Files[1:] {
+			removed[p] = true
+		}
+		dirs = append(dirs, g)
+	}
+
+	var files []Group
+	for _, g := range fileGroups {
+		if g.Files = outside(g.Files, removed); len(g.Files) >= 2 {
+			files = append(files, g)
+		}
+	}
+	ApplyKeep(files, keep)
+	for i := range files {
+		pinKept(&files[i], keptDirs)
+	}
+
+	sortGroups(dirs)
+	sortGroups(files)
+	return append(dirs, files...)
+}
+
+// pinKept keeps a copy inside the directories kept by larger groups in
+// favour of one outside them, whatever the keep rules chose, so a kept
+// directory is not emptied out for a loose copy elsewhere.
+func pinKept(g *Group, keptDirs map[string]bool) {
+	if len(keptDirs) == 0 || insideAny(g.Files[0], keptDirs) {
+		return
+	}
+	for i, p := range g.Files[1:] {
+		if insideAny(p, keptDirs) {
+			copy(g.Files[1:i+2], g.Files[:i+1])
+			g.Files[0] = p
+			g.KeptBy = KeptWithDir
+			return
+		}
+	}
+}
+
+// outside returns the paths that are not inside any of dirs, keeping
+// their order.
+func outside(paths []string, dirs map[string]bool) []string {
+	if len(dirs) == 0 {
+		return paths
+	}
+	var out []string
+	for _, p := range paths {
+		if !insideAny(p, dirs) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func insideAny(path string, dirs map[string]bool) bool {
+	for d := filepath.Dir(path); ; d = filepath.Dir(d) {
+		if dirs[d] {
+			return true
+		}
+		if d == filepath.Dir(d) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package dupes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates files under root, given as relative path to content.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var photoImport = map[string]string{
+	"IMG_0001.jpg":      "first photo, long enough to matter",
+	"IMG_0002.jpg":      "second photo, different from the first",
+	"raw/IMG_0001.dng":  "raw data for the first photo",
+	"raw/IMG_0002.dng":  "raw data for the second photo!!",
+	"edits/export.tiff": "an exported edit of a photo",
+}
+
+func TestFind_RollsUpIdenticalDirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "Import"), photoImport)
+	writeTree(t, filepath.Join(dir, "Import copy"), photoImport)
+	// Finder metadata does not stop directories from matching.
+	writeTree(t, filepath.Join(dir, "Import copy"), map[string]string{".DS_Store": "finder state"})
+	// A loose duplicate of one photo is still reported on its own.
+	writeTree(t, dir, map[string]string{"IMG_0001.jpg": photoImport["IMG_0001.jpg"]})
+
+	groups, err := Find(context.Background(), []string{dir}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected a directory group and a file group, got %+v", groups)
+	}
+
+	d := groups[0]
+	if !d.Dir {
+		t.Fatalf("expected the directory group first, got %+v", d)
+	}
+	want := []string{filepath.Join(dir, "Import"), filepath.Join(dir, "Import copy")}
+	if len(d.Files) != 2 || d.Files[0] != want[0] || d.Files[1] != want[1] {
+		t.Errorf("expected directories %v, got %v", want, d.Files)
+	}
+	if d.FileCount != len(photoImport) {
+		t.Errorf("expected %d files per directory, got %d", len(photoImport), d.FileCount)
+	}
+	var size int64
+	for _, content := range photoImport {
+		size += int64(len(content))
+	}
+	if d.Size != size {
+		t.Errorf("expected directory size %d, got %d", size, d.Size)
+	}
+
+	// Files inside the copy being removed are not listed again, and the
+	// copy inside the kept directory is kept.
+	f := groups[1]
+	if f.Dir {
+		t.Fatalf("expected a file group, got %+v", f)
+	}
+	wantFiles := []string{filepath.Join(dir, "Import", "IMG_0001.jpg"), filepath.Join(dir, "IMG_0001.jpg")}
+	if len(f.Files) != 2 || f.Files[0] != wantFiles[0] || f.Files[1] != wantFiles[1] {
+		t.Errorf("expected files %v, got %v", wantFiles, f.Files)
+	}
+}
+
+func TestFind_DirectoriesThatDiffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  map[string]string
+		remove string
+	}{
+		{"extra file", map[string]string{"notes.txt": "only in the copy"}, ""},
+		{"renamed file", map[string]string{"IMG_0002 renamed.jpg": photoImport["IMG_0002.jpg"]}, "IMG_0002.jpg"},
+		{"hidden file", map[string]string{".xmp": "sidecar only in the copy"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, filepath.Join(dir, "a"), photoImport)
+			writeTree(t, filepath.Join(dir, "b"), photoImport)
+			writeTree(t, filepath.Join(dir, "b"), tt.extra)
+			if tt.remove != "" {
+				if err := os.Remove(filepath.Join(dir, "b", tt.remove)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			groups, err := Find(context.Background(), []string{dir}, 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, g := range groups {
+				if g.Dir && g.Files[0] == filepath.Join(dir, "a") {
+					t.Errorf("expected a and b not to be grouped, got %v", g.Files)
+				}
+			}
+			// Subdirectories that still match are reported instead.
+			if len(groups) == 0 || !groups[0].Dir || filepath.Base(groups[0].Files[0]) != "raw" {
+				t.Errorf("expected the raw directories to be grouped first, got %+v", groups)
+			}
+		})
+	}
+}
+
+func TestFind_NestedDirectoryGroups(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		// Each copy holds the same album twice.
+		writeTree(t, filepath.Join(dir, name, "album"), photoImport)
+		writeTree(t, filepath.Join(dir, name, "album 2"), photoImport)
+	}
+
+	groups, err := Find(context.Background(), []string{dir}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 2 || !groups[0].Dir || !groups[1].Dir {
+		t.Fatalf("expected two directory groups, got %+v", groups)
+	}
+	if groups[0].Files[0] != filepath.Join(dir, "a") || groups[0].Files[1] != filepath.Join(dir, "b") {
+		t.Errorf("expected a and b grouped first, got %v", groups[0].Files)
+	}
+	// Only the albums in the copy that is kept are left to compare.
+	want := []string{filepath.Join(dir, "a", "album"), filepath.Join(dir, "a", "album 2")}
+	if len(groups[1].Files) != 2 || groups[1].Files[0] != want[0] || groups[1].Files[1] != want[1] {
+		t.Errorf("expected albums %v, got %v", want, groups[1].Files)
+	}
+}
+
+func TestFind_ScanRootsAreNotGrouped(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+	writeTree(t, a, photoImport)
+	writeTree(t, b, photoImport)
+
+	groups, err := Find(context.Background(), []string{a, b}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, g := range groups {
+		for _, f := range g.Files {
+			if f == a || f == b {
+				t.Errorf("expected scanned directories not to be grouped, got %v", g.Files)
+			}
+		}
+	}
+	if len(groups) == 0 || !groups[0].Dir {
+		t.Errorf("expected their subdirectories to be grouped, got %+v", groups)
+	}
+}
+
+func TestFindWithOptions_FilesOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "a"), photoImport)
+	writeTree(t, filepath.Join(dir, "b"), photoImport)
+
+	groups, err := FindWithOptions(context.Background(), []string{dir}, Options{FilesOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != len(photoImport) {
+		t.Fatalf("expected %d file groups, got %d", len(photoImport), len(groups))
+	}
+	for _, g := range groups {
+		if g.Dir {
+			t.Errorf("expected no directory groups, got %v", g.Files)
+		}
+	}
+}
+
+func TestFind_KeepRulesDoNotReachIntoKeptDirectories(t *testing.T) {
+	dir := t.TempDir()
+	downloads := filepath.Join(dir, "Downloads")
+	documents := filepath.Join(dir, "Documents")
+	writeTree(t, filepath.Join(downloads, "export"), photoImport)
+	writeTree(t, filepath.Join(downloads, "export 2"), photoImport)
+	// A loose copy of one photo, in the folder the rules prefer.
+	writeTree(t, documents, map[string]string{"IMG_0001.jpg": photoImport["IMG_0001.jpg"]})
+
+	rule, err := ParseKeepRule("path:" + documents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := FindWithOptions(context.Background(), []string{dir}, Options{Keep: []KeepRule{rule}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 2 || !groups[0].Dir || groups[1].Dir {
+		t.Fatalf("expected a directory group and a file group, got %+v", groups)
+	}
+	keptDir := groups[0].Files[0]
+
+	f := groups[1]
+	want := []string{filepath.Join(keptDir, "IMG_0001.jpg"), filepath.Join(documents, "IMG_0001.jpg")}
+	if len(f.Files) != 2 || f.Files[0] != want[0] || f.Files[1] != want[1] {
+		t.Errorf("expected the copy in the kept directory to be kept, got %v", f.Files)
+	}
+	if f.KeptBy != KeptWithDir {
+		t.Errorf("expected KeptBy %q, got %q", KeptWithDir, f.KeptBy)
+	}
+}
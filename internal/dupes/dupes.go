@@ -8,44 +8,119 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
-// partialHashSize is the number of bytes read for the partial hash pass.
-const partialHashSize = 4096
+// sampleSize is the number of bytes read from each of the head, middle and
+// tail of a file for the partial hash pass.
+const sampleSize = 4096
+
+// partialSpan is the largest file the partial hash reads in full. Its
+// partial hash is then its full hash, so the full pass skips it.
+const partialSpan = 3 * sampleSize
+
+// defaultWorkers is the number of files hashed at once when
+// Options.Workers is not set. See SetDefaultWorkers.
+var defaultWorkers atomic.Int64
+
+// SetDefaultWorkers sets the number of files hashed at once when
+// Options.Workers is not set. Zero or less restores the default of one per
+// CPU, up to 8.
+func SetDefaultWorkers(n int) {
+	defaultWorkers.Store(int64(n))
+}
+
+// workerCount resolves the number of hashing workers for opts.
+func (o Options) workerCount() int {
+	if o.Workers > 0 {
+		return o.Workers
+	}
+	if n := int(defaultWorkers.Load()); n > 0 {
+		return n
+	}
+	return min(runtime.NumCPU(), 8)
+}
 
 // Group represents a set of duplicate files sharing the same content.
+// Files[0] is the copy to keep.
+//
+// When Dir is set, Files are directories with identical contents and Size
+// is the size of each directory's files.
 type Group struct {
 	Size  int64    `json:"size"`
 	Hash  string   `json:"hash"`
 	Files []string `json:"files"`
+	Dir   bool     `json:"dir,omitempty"`
+	// FileCount is the number of files in each directory of a Dir group.
+	FileCount int `json:"file_count,omitempty"`
+	// KeptBy is the keep rule that chose Files[0], KeptWithDir if Files[0]
+	// lies in a directory kept by another group, or "" if the first file
+	// found was kept.
+	KeptBy string `json:"kept_by,omitempty"`
+}
+
+// KeptWithDir is the KeptBy of a group whose copy was kept because it lies
+// in a directory that another group keeps.
+const KeptWithDir = "kept-dir"
+
+// Options configures FindWithOptions.
+type Options struct {
+	// MinSize skips files smaller than this many bytes.
+	MinSize int64
+	// Workers is the number of files hashed at once; zero means the
+	// default set by SetDefaultWorkers.
+	Workers int
+	// OnProgress, if set, receives progress reports. Calls are serialized.
+	OnProgress ProgressFunc
+	// Keep chooses the copy to keep in each group; nil means the rules set
+	// by SetDefaultKeepRules.
+	Keep []KeepRule
+	// FilesOnly reports every duplicate file on its own instead of rolling
+	// directories whose entire contents are duplicated up into Dir groups.
+	FilesOnly bool
 }
 
-// ProgressFunc is called with each file path as it is visited during scanning.
-type ProgressFunc func(path string)
+// ProgressFunc is called as files are visited and hashed.
+type ProgressFunc func(Progress)
 
 // Find scans dirs for duplicate files whose size is at least minSize bytes.
 // It uses a three-pass algorithm: group by size, partial hash, then full hash.
 func Find(ctx context.Context, dirs []string, minSize int64) ([]Group, error) {
-	return FindWithProgress(ctx, dirs, minSize, nil)
+	return FindWithOptions(ctx, dirs, Options{MinSize: minSize})
 }
 
-// FindWithProgress is like Find but calls onProgress for each file visited.
+// FindWithProgress is like Find but reports progress to onProgress.
 func FindWithProgress(ctx context.Context, dirs []string, minSize int64, onProgress ProgressFunc) ([]Group, error) {
+	return FindWithOptions(ctx, dirs, Options{MinSize: minSize, OnProgress: onProgress})
+}
+
+// FindWithOptions is like Find, configured by opts.
+func FindWithOptions(ctx context.Context, dirs []string, opts Options) ([]Group, error) {
+	workers := opts.workerCount()
+	m := newMeter(opts.OnProgress)
+
+	var t *tree
+	if !opts.FilesOnly {
+		t = newTree()
+	}
+
 	// Pass 1: group files by size.
-	sizeGroups, err := groupBySize(ctx, dirs, minSize, onProgress)
+	sizeGroups, err := groupBySize(ctx, dirs, opts.MinSize, t, m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to group files by size: %w", err)
 	}
 
-	// Pass 2: partial hash (first 4KB SHA256) for same-size files.
-	candidates, err := refineByHash(ctx, sizeGroups, true)
+	// Pass 2: partial hash (head, middle and tail samples) for same-size files.
+	candidates, err := refineByHash(ctx, sizeGroups, true, workers, m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute partial hashes: %w", err)
 	}
 
 	// Pass 3: full SHA256 only for partial-hash matches.
-	confirmed, err := refineByHash(ctx, candidates, false)
+	confirmed, err := refineByHash(ctx, candidates, false, workers, m)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute full hashes: %w", err)
 	}
@@ -60,14 +135,38 @@ func FindWithProgress(ctx context.Context, dirs []string, minSize int64, onProgr
 		})
 	}
 
-	// Sort groups by total wasted size descending.
+	keep := opts.Keep
+	if keep == nil {
+		keep = defaultKeepRules()
+	}
+	if t == nil {
+		ApplyKeep(groups, keep)
+		sortGroups(groups)
+		return groups, nil
+	}
+
+	// Roll up directories whose files all have duplicates.
+	fileHash := make(map[string]string)
+	sizes := make(map[string]int64)
+	for _, c := range confirmed {
+		for _, f := range c.files {
+			fileHash[f] = c.hash
+			sizes[f] = c.size
+		}
+	}
+	return rollUp(t.dirGroups(fileHash, sizes), groups, keep), nil
+}
+
+// sortGroups sorts groups by total wasted size descending.
+func sortGroups(groups []Group) {
 	sort.Slice(groups, func(i, j int) bool {
 		wastedI := groups[i].Size * int64(len(groups[i].Files)-1)
 		wastedJ := groups[j].Size * int64(len(groups[j].Files)-1)
-		return wastedI > wastedJ
+		if wastedI != wastedJ {
+			return wastedI > wastedJ
+		}
+		return groups[i].Files[0] < groups[j].Files[0]
 	})
-
-	return groups, nil
 }
 
 // candidate holds a group of files that match on some criterion.
@@ -78,13 +177,17 @@ type candidate struct {
 }
 
 // groupBySize walks all dirs and groups regular files by size, skipping
-// files smaller than minSize. Returns only groups with 2+ files.
-func groupBySize(ctx context.Context, dirs []string, minSize int64, onProgress ProgressFunc) ([]candidate, error) {
+// files smaller than minSize. Returns only groups with 2+ files. If t is
+// not nil, the walked directories and what they hold are recorded in it.
+func groupBySize(ctx context.Context, dirs []string, minSize int64, t *tree, m *meter) ([]candidate, error) {
 	sizeMap := make(map[int64][]string)
+	m.start(PhaseWalk, 0, 0)
 
 	for _, dir := range dirs {
 		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
+				t.markIncomplete(path)
+				t.skip(path)
 				return nil // skip unreadable entries
 			}
 
@@ -97,43 +200,50 @@ func groupBySize(ctx context.Context, dirs []string, minSize int64, onProgress P
 			if d.IsDir() {
 				// Skip .git directories entirely.
 				if d.Name() == ".git" {
+					t.skip(path)
 					return fs.SkipDir
 				}
 				// Skip git repository roots (directories containing .git).
 				if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+					t.skip(path)
 					return fs.SkipDir
 				}
+				t.addDir(path)
 				return nil
 			}
 
 			// Skip hidden files (dotfiles).
 			if d.Name()[0] == '.' {
+				t.skip(path)
 				return nil
 			}
 
 			// Skip symlinks.
 			if d.Type()&os.ModeSymlink != 0 {
+				t.skip(path)
 				return nil
 			}
 
 			info, err := d.Info()
 			if err != nil {
+				t.skip(path)
 				return nil
 			}
 
 			// Skip non-regular files.
 			if !info.Mode().IsRegular() {
+				t.skip(path)
 				return nil
 			}
 
 			size := info.Size()
 			if size < minSize {
+				t.skip(path)
 				return nil
 			}
 
-			if onProgress != nil {
-				onProgress(path)
-			}
+			m.fileDone(path)
+			t.addFile(path)
 
 			sizeMap[size] = append(sizeMap[size], path)
 			return nil
@@ -142,6 +252,7 @@ func groupBySize(ctx context.Context, dirs []string, minSize int64, onProgress P
 			return nil, err
 		}
 	}
+	m.flush()
 
 	// Keep only sizes with 2+ files.
 	var candidates []candidate
@@ -154,36 +265,106 @@ func groupBySize(ctx context.Context, dirs []string, minSize int64, onProgress P
 	return candidates, nil
 }
 
-// refineByHash takes candidate groups and sub-groups them by hash.
-// If partial is true, only the first partialHashSize bytes are hashed.
-// Returns only sub-groups with 2+ matching files.
-func refineByHash(ctx context.Context, candidates []candidate, partial bool) ([]candidate, error) {
-	var refined []candidate
+// hashJob is one file of candidate group group to hash.
+type hashJob struct {
+	group int
+	file  int
+}
 
-	for _, c := range candidates {
+// refineByHash takes candidate groups and sub-groups them by hash, hashing
+// up to workers files at once. If partial is true, only samples from the
+// head, middle and tail of each file are hashed. Hashes of unchanged files
+// come from the HashCache in ctx, if any. Sub-groups keep the order of
+// their files. Returns only sub-groups with 2+ matching files.
+func refineByHash(ctx context.Context, candidates []candidate, partial bool, workers int, m *meter) ([]candidate, error) {
+	cache := hashCacheFrom(ctx)
+
+	// The full pass reuses the hash of files the partial pass read in full.
+	var jobs []hashJob
+	var totalBytes int64
+	hashes := make([][]string, len(candidates))
+	for gi, c := range candidates {
+		hashes[gi] = make([]string, len(c.files))
+		if !partial && c.size <= partialSpan {
+			for fi := range c.files {
+				hashes[gi][fi] = c.hash
+			}
+			continue
+		}
+		for fi := range c.files {
+			jobs = append(jobs, hashJob{group: gi, file: fi})
+			totalBytes += readCost(c.size, partial)
+		}
+	}
+
+	phase := PhaseFull
+	if partial {
+		phase = PhasePartial
+	}
+	if len(jobs) > 0 {
+		m.start(phase, len(jobs), totalBytes)
+	}
+
+	jobCh := make(chan hashJob)
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				path := candidates[j.group].files[j.file]
+				cost := readCost(candidates[j.group].size, partial)
+				var read int64
+				h, err := cache.hash(path, partial, func(n int64) {
+					read += n
+					m.addBytes(n)
+				})
+				// Cache hits and short or failed reads still count in full.
+				if read < cost {
+					m.addBytes(cost - read)
+				}
+				m.fileDone(path)
+				if err != nil {
+					continue // skip unreadable files
+				}
+				hashes[j.group][j.file] = h
+			}
+		}()
+	}
+
+dispatch:
+	for _, j := range jobs {
 		select {
+		case jobCh <- j:
 		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+			break dispatch
 		}
+	}
+	close(jobCh)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(jobs) > 0 {
+		m.flush()
+	}
 
-		hashMap := make(map[string][]string)
-		for _, f := range c.files {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
+	var refined []candidate
+	for gi, c := range candidates {
+		byHash := make(map[string][]string)
+		var order []string
+		for fi, f := range c.files {
+			h := hashes[gi][fi]
+			if h == "" {
+				continue
 			}
-
-			h, err := hashFile(f, partial)
-			if err != nil {
-				continue // skip unreadable files
+			if _, ok := byHash[h]; !ok {
+				order = append(order, h)
 			}
-			hashMap[h] = append(hashMap[h], f)
+			byHash[h] = append(byHash[h], f)
 		}
-
-		for h, matched := range hashMap {
-			if len(matched) >= 2 {
+		for _, h := range order {
+			if matched := byHash[h]; len(matched) >= 2 {
 				refined = append(refined, candidate{
 					size:  c.size,
 					hash:  h,
@@ -196,28 +377,62 @@ func refineByHash(ctx context.Context, candidates []candidate, partial bool) ([]
 	return refined, nil
 }
 
-// hashFile computes the SHA256 hash of a file. If partial is true, only
-// the first partialHashSize bytes are read.
-func hashFile(path string, partial bool) (string, error) {
+// readCost returns how many bytes hashing a file of size bytes reads.
+func readCost(size int64, partial bool) int64 {
+	if partial {
+		return min(size, partialSpan)
+	}
+	return size
+}
+
+// hashFile computes the SHA256 hash of a file, calling onRead with the
+// number of bytes read as it goes. If partial is true, only sampleSize
+// bytes from each of the head, middle and tail are read; files of up to
+// partialSpan bytes are read in full, so their partial hash equals their
+// full hash.
+func hashFile(path string, partial bool, onRead func(int64)) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 	defer f.Close()
 
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+
 	h := sha256.New()
+	r := &countingReader{r: f, onRead: onRead}
 
-	if partial {
-		_, err = io.CopyN(h, f, partialHashSize)
-		if err != nil && err != io.EOF {
-			return "", fmt.Errorf("failed to read file: %w", err)
+	if partial && size > partialSpan {
+		for _, off := range []int64{0, size/2 - sampleSize/2, size - sampleSize} {
+			r.r = io.NewSectionReader(f, off, sampleSize)
+			if _, err := io.Copy(h, r); err != nil {
+				return "", fmt.Errorf("failed to read file: %w", err)
+			}
 		}
 	} else {
-		_, err = io.Copy(h, f)
-		if err != nil {
+		buf := make([]byte, 256*1024)
+		if _, err := io.CopyBuffer(h, r, buf); err != nil {
 			return "", fmt.Errorf("failed to read file: %w", err)
 		}
 	}
 
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+// countingReader reports the bytes read through it to onRead.
+type countingReader struct {
+	r      io.Reader
+	onRead func(int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 && c.onRead != nil {
+		c.onRead(int64(n))
+	}
+	return n, err
+}
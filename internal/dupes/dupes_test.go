@@ -126,8 +126,14 @@ func TestFindWithProgress(t *testing.T) {
 	}
 
 	var paths []string
-	groups, err := dupes.FindWithProgress(context.Background(), []string{dir}, 0, func(path string) {
-		paths = append(paths, path)
+	var partial dupes.Progress
+	groups, err := dupes.FindWithProgress(context.Background(), []string{dir}, 0, func(p dupes.Progress) {
+		if p.Path != "" {
+			paths = append(paths, p.Path)
+		}
+		if p.Phase == dupes.PhasePartial {
+			partial = p
+		}
 	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -139,6 +145,52 @@ func TestFindWithProgress(t *testing.T) {
 	if len(paths) == 0 {
 		t.Error("expected progress callback to be called at least once")
 	}
+	if want := int64(2 * len(content)); partial.TotalBytes != want || partial.Bytes != want || partial.Files != 2 {
+		t.Errorf("expected the partial pass to end with 2 files and %d bytes hashed, got %+v", want, partial)
+	}
+}
+
+func TestFindWithOptions_LargeFilesManyWorkers(t *testing.T) {
+	dir := t.TempDir()
+
+	// Same head and tail, differing only in the middle, like disk images
+	// with identical headers.
+	base := make([]byte, 1<<20)
+	for i := range base {
+		base[i] = byte(i % 251)
+	}
+	other := append([]byte(nil), base...)
+	other[len(other)/2] ^= 0xff
+
+	for name, data := range map[string][]byte{"a.img": base, "b.img": base, "c.img": other, "d.img": base} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var maxBytes int64
+	groups, err := dupes.FindWithOptions(context.Background(), []string{dir}, dupes.Options{
+		Workers: 4,
+		OnProgress: func(p dupes.Progress) {
+			if p.Phase == dupes.PhaseFull && p.Bytes > maxBytes {
+				maxBytes = p.Bytes
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group, got %d", len(groups))
+	}
+	want := []string{filepath.Join(dir, "a.img"), filepath.Join(dir, "b.img"), filepath.Join(dir, "d.img")}
+	if got := groups[0].Files; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("expected files %v in walk order, got %v", want, got)
+	}
+	// c.img differs in the middle sample, so only three files are hashed in full.
+	if maxBytes != 3<<20 {
+		t.Errorf("expected 3 MB hashed in full, got %d", maxBytes)
+	}
 }
 
 func TestSkipsGitDirs(t *testing.T) {
@@ -0,0 +1,42 @@
+package dupes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashFile_PartialSamplesMiddle(t *testing.T) {
+	dir := t.TempDir()
+	data := make([]byte, 64*1024)
+	changed := append([]byte(nil), data...)
+	changed[len(changed)/2] = 1
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.WriteFile(a, data, 0o644)
+	os.WriteFile(b, changed, 0o644)
+
+	var read int64
+	ha, err := hashFile(a, true, func(n int64) { read += n })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != partialSpan {
+		t.Errorf("partial hash read %d bytes, want %d", read, partialSpan)
+	}
+	hb, _ := hashFile(b, true, nil)
+	if ha == hb {
+		t.Error("expected files differing in the middle to have different partial hashes")
+	}
+}
+
+func TestHashFile_SmallFilePartialIsFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small")
+	os.WriteFile(path, make([]byte, partialSpan), 0o644)
+
+	partial, _ := hashFile(path, true, nil)
+	full, _ := hashFile(path, false, nil)
+	if partial != full {
+		t.Errorf("partial hash %s != full hash %s for a file within partialSpan", partial, full)
+	}
+}
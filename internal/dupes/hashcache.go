@@ -0,0 +1,311 @@
+package dupes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// hashCacheVersion is bumped whenever the on-disk record layout or the way
+// hashes are computed changes; files with another version are discarded.
+const hashCacheVersion = 2
+
+// hashCacheTTL drops records for files that no scan has looked at for this
+// long, so the cache does not grow without bound as files come and go.
+const hashCacheTTL = 90 * 24 * time.Hour
+
+// hashCacheSettle skips caching files modified this recently, since a
+// write landing within the same mtime tick would go unnoticed.
+const hashCacheSettle = 2 * time.Second
+
+// HashCache persists the partial and full content hashes of files between
+// runs, keyed by path and validated against the file's device, inode, size
+// and mtime. A file that changed in any of these is hashed again.
+//
+// A nil *HashCache is valid and hashes everything from scratch. HashCache
+// is safe for concurrent use.
+type HashCache struct {
+	path string
+	now  func() time.Time
+
+	refresh atomic.Bool
+	hits    atomic.Int64
+	misses  atomic.Int64
+
+	mu      sync.Mutex
+	records map[string]hashRecord
+	dirty   bool
+}
+
+type hashRecord struct {
+	Dev     uint64 `json:"dev"`
+	Ino     uint64 `json:"ino"`
+	Size    int64  `json:"size"`
+	MTime   int64  `json:"mtime"`
+	Partial string `json:"partial,omitempty"`
+	Full    string `json:"full,omitempty"`
+	// Used is when a scan last looked the record up or stored it.
+	Used int64 `json:"used"`
+}
+
+type hashCacheFile struct {
+	Version int                   `json:"version"`
+	Records map[string]hashRecord `json:"records"`
+}
+
+// CacheStats counts hashes served from the cache (Hits) and computed by
+// reading the file (Misses).
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// HitRate returns the fraction of hashes served from the cache.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewHashCache returns an empty cache that Save writes to path.
+func NewHashCache(path string) *HashCache {
+	return &HashCache{path: path, now: time.Now, records: make(map[string]hashRecord)}
+}
+
+// LoadHashCache reads the cache stored at path. A missing file yields an
+// empty cache. An unreadable or outdated file also yields an empty cache,
+// along with the error, so callers can carry on without it.
+func LoadHashCache(path string) (*HashCache, error) {
+	c := NewHashCache(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read hash cache: %w", err)
+	}
+	var f hashCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return c, fmt.Errorf("failed to parse hash cache: %w", err)
+	}
+	if f.Version != hashCacheVersion {
+		return c, nil
+	}
+	if f.Records != nil {
+		c.records = f.Records
+	}
+	return c, nil
+}
+
+// Save writes the cache back to its path if anything changed, dropping
+// records that have not been used within hashCacheTTL. The file is
+// replaced atomically.
+func (c *HashCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := c.now().Add(-hashCacheTTL).UnixNano()
+	for p, r := range c.records {
+		if r.Used < cutoff {
+			delete(c.records, p)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(hashCacheFile{Version: hashCacheVersion, Records: c.records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create hash cache directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Prune removes the records of files that no longer exist or have changed
+// since they were hashed, and returns how many it removed. Call Save to
+// persist the result.
+func (c *HashCache) Prune() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	paths := make([]string, 0, len(c.records))
+	for p := range c.records {
+		paths = append(paths, p)
+	}
+	c.mu.Unlock()
+
+	var removed int
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		c.mu.Lock()
+		if rec, ok := c.records[p]; ok && (err != nil || !rec.matches(info)) {
+			delete(c.records, p)
+			c.dirty = true
+			removed++
+		}
+		c.mu.Unlock()
+	}
+	return removed
+}
+
+// Len returns the number of files with cached hashes.
+func (c *HashCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.records)
+}
+
+// SetRefresh makes the cache ignore stored hashes, so every file is read
+// again. Fresh hashes are still stored for the next run.
+func (c *HashCache) SetRefresh(refresh bool) {
+	if c != nil {
+		c.refresh.Store(refresh)
+	}
+}
+
+// Stats returns the hit and miss counts since the cache was loaded.
+func (c *HashCache) Stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// hash returns the partial or full hash of path, from the cache when the
+// file is unchanged since it was last hashed. onRead is passed to hashFile.
+func (c *HashCache) hash(path string, partial bool, onRead func(int64)) (string, error) {
+	if c == nil {
+		return hashFile(path, partial, onRead)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if h, ok := c.lookup(path, info, partial); ok {
+		return h, nil
+	}
+	h, err := hashFile(path, partial, onRead)
+	if err != nil {
+		return "", err
+	}
+	// Only trust the hash if the file did not change while it was read.
+	if after, err := os.Lstat(path); err == nil && sameFile(info, after) {
+		c.store(path, info, partial, h)
+	}
+	return h, nil
+}
+
+// lookup returns the cached hash of path if its record is still valid for
+// info.
+func (c *HashCache) lookup(path string, info fs.FileInfo, partial bool) (string, bool) {
+	if c.refresh.Load() {
+		c.misses.Add(1)
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec, ok := c.records[path]
+	h := rec.Full
+	if partial {
+		h = rec.Partial
+	}
+	if !ok || h == "" || !rec.matches(info) {
+		c.misses.Add(1)
+		return "", false
+	}
+	rec.Used = c.now().UnixNano()
+	c.records[path] = rec
+	c.dirty = true
+	c.hits.Add(1)
+	return h, true
+}
+
+// store records h for path. A record whose file has changed is replaced,
+// dropping the other hash it held.
+func (c *HashCache) store(path string, info fs.FileInfo, partial bool, h string) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(info.ModTime()) < hashCacheSettle {
+		if _, ok := c.records[path]; ok {
+			delete(c.records, path)
+			c.dirty = true
+		}
+		return
+	}
+	rec, ok := c.records[path]
+	if !ok || !rec.matches(info) {
+		rec = hashRecord{Size: info.Size(), MTime: info.ModTime().UnixNano()}
+		rec.Dev, rec.Ino = devIno(info)
+	}
+	if partial {
+		rec.Partial = h
+	} else {
+		rec.Full = h
+	}
+	rec.Used = now.UnixNano()
+	c.records[path] = rec
+	c.dirty = true
+}
+
+// matches reports whether r was recorded for the file described by info.
+func (r hashRecord) matches(info fs.FileInfo) bool {
+	dev, ino := devIno(info)
+	return info.Mode().IsRegular() && r.Dev == dev && r.Ino == ino &&
+		r.Size == info.Size() && r.MTime == info.ModTime().UnixNano()
+}
+
+// sameFile reports whether a and b describe the same, unchanged file.
+func sameFile(a, b fs.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func devIno(info fs.FileInfo) (uint64, uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
+
+type hashCacheKey struct{}
+
+// WithHashCache returns a context under which Find and FindWithProgress
+// take hashes from cache and store the ones they compute.
+func WithHashCache(ctx context.Context, cache *HashCache) context.Context {
+	return context.WithValue(ctx, hashCacheKey{}, cache)
+}
+
+// hashCacheFrom returns the cache stored by WithHashCache, or nil.
+func hashCacheFrom(ctx context.Context) *HashCache {
+	c, _ := ctx.Value(hashCacheKey{}).(*HashCache)
+	return c
+}
@@ -0,0 +1,139 @@
+package dupes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// settledHashCache returns a cache whose clock runs ahead of the filesystem
+// so freshly written test files are old enough to be cached.
+func settledHashCache(t *testing.T) *HashCache {
+	t.Helper()
+	c := NewHashCache(filepath.Join(t.TempDir(), "dupes-hashes.json"))
+	c.now = func() time.Time { return time.Now().Add(time.Minute) }
+	return c
+}
+
+func writeDupes(t *testing.T, dir string, content string, names ...string) {
+	t.Helper()
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHashCache_ReusesUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeDupes(t, dir, "same content in both files", "a.txt", "b.txt")
+
+	c := settledHashCache(t)
+	ctx := WithHashCache(context.Background(), c)
+	if groups, err := Find(ctx, []string{dir}, 0); err != nil || len(groups) != 1 {
+		t.Fatalf("first run: %d groups, err %v", len(groups), err)
+	}
+	// One hash per file: the partial pass reads small files in full, so
+	// the full pass reuses it.
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 2 {
+		t.Errorf("first run stats = %+v, want 0 hits, 2 misses", s)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadHashCache(c.path)
+	if err != nil {
+		t.Fatalf("LoadHashCache: %v", err)
+	}
+	loaded.now = c.now
+	groups, err := Find(WithHashCache(context.Background(), loaded), []string{dir}, 0)
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("second run: %d groups, err %v", len(groups), err)
+	}
+	if s := loaded.Stats(); s.Hits != 2 || s.Misses != 0 {
+		t.Errorf("second run stats = %+v, want 2 hits, 0 misses", s)
+	}
+}
+
+func TestHashCache_ChangedFileIsRehashed(t *testing.T) {
+	dir := t.TempDir()
+	writeDupes(t, dir, "same content in both files", "a.txt", "b.txt")
+
+	c := settledHashCache(t)
+	ctx := WithHashCache(context.Background(), c)
+	Find(ctx, []string{dir}, 0)
+
+	// Same size, different content, and an mtime that clearly moved.
+	writeDupes(t, dir, "SAME CONTENT IN BOTH FILES", "b.txt")
+	later := time.Now().Add(-10 * time.Second)
+	os.Chtimes(filepath.Join(dir, "b.txt"), later, later)
+
+	groups, err := Find(ctx, []string{dir}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("expected the changed file to be rehashed, got %d groups", len(groups))
+	}
+}
+
+func TestHashCache_SkipsRecentlyModified(t *testing.T) {
+	dir := t.TempDir()
+	writeDupes(t, dir, "same content in both files", "a.txt", "b.txt")
+
+	c := NewHashCache(filepath.Join(t.TempDir(), "dupes-hashes.json"))
+	Find(WithHashCache(context.Background(), c), []string{dir}, 0)
+	if n := c.Len(); n != 0 {
+		t.Errorf("expected files written just now not to be cached, got %d records", n)
+	}
+}
+
+func TestHashCache_Prune(t *testing.T) {
+	dir := t.TempDir()
+	writeDupes(t, dir, "same content in every file", "a.txt", "b.txt", "c.txt")
+
+	c := settledHashCache(t)
+	Find(WithHashCache(context.Background(), c), []string{dir}, 0)
+	if n := c.Len(); n != 3 {
+		t.Fatalf("expected 3 records, got %d", n)
+	}
+
+	os.Remove(filepath.Join(dir, "a.txt"))
+	writeDupes(t, dir, "longer content than before, so the size changes", "b.txt")
+
+	if removed := c.Prune(); removed != 2 {
+		t.Errorf("Prune removed %d, want 2", removed)
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("expected 1 record left, got %d", n)
+	}
+}
+
+func TestHashCache_SaveDropsUnused(t *testing.T) {
+	dir := t.TempDir()
+	writeDupes(t, dir, "same content in both files", "a.txt", "b.txt")
+
+	c := settledHashCache(t)
+	Find(WithHashCache(context.Background(), c), []string{dir}, 0)
+
+	c.now = func() time.Time { return time.Now().Add(hashCacheTTL + time.Hour) }
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("expected unused records to be dropped, got %d", n)
+	}
+}
+
+func TestHashCache_Nil(t *testing.T) {
+	var c *HashCache
+	if err := c.Save(); err != nil {
+		t.Errorf("Save on nil cache: %v", err)
+	}
+	if c.Prune() != 0 || c.Len() != 0 || c.Stats() != (CacheStats{}) {
+		t.Error("expected nil cache to be empty")
+	}
+}
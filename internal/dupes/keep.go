@@ -0,0 +1,214 @@
+package dupes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// KeepRule ranks the files of a group; the file ranked best is kept. Rules
+// are applied in order, each one breaking the ties left by the ones before
+// it, and files still tied after the last rule are kept in walk order.
+type KeepRule struct {
+	name      string
+	needMTime bool
+	rank      func(f keepFile) int64 // lower is better
+}
+
+// String returns the rule as written, e.g. "path:~/Pictures".
+func (r KeepRule) String() string { return r.name }
+
+// keepFile is what rules rank a file by.
+type keepFile struct {
+	path  string
+	mtime int64
+}
+
+// ParseKeepRule parses one rule:
+//
+//	path:DIR       prefer files under DIR; list several for a priority order
+//	glob:PATTERN   prefer files matching PATTERN (full path, base name, or DIR/**)
+//	not-downloads  prefer files outside ~/Downloads
+//	oldest         prefer the earliest modification time
+//	newest         prefer the latest modification time
+//	shortest-path  prefer the shortest path
+func ParseKeepRule(s string) (KeepRule, error) {
+	s = strings.TrimSpace(s)
+	kind, arg, hasArg := strings.Cut(s, ":")
+	switch kind {
+	case "path":
+		if arg == "" {
+			return KeepRule{}, fmt.Errorf("invalid keep rule %q: missing directory", s)
+		}
+		dir := filepath.Clean(expandHome(arg))
+		return KeepRule{name: s, rank: func(f keepFile) int64 {
+			return boolRank(!under(f.path, dir))
+		}}, nil
+	case "glob":
+		if arg == "" {
+			return KeepRule{}, fmt.Errorf("invalid keep rule %q: missing pattern", s)
+		}
+		pattern := expandHome(arg)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return KeepRule{}, fmt.Errorf("invalid keep rule %q: %w", s, err)
+		}
+		return KeepRule{name: s, rank: func(f keepFile) int64 {
+			return boolRank(!matchGlob(pattern, f.path))
+		}}, nil
+	}
+	if hasArg {
+		return KeepRule{}, fmt.Errorf("unknown keep rule %q", s)
+	}
+	switch kind {
+	case "not-downloads":
+		downloads := filepath.Join(utils.HomeDir(), "Downloads")
+		return KeepRule{name: s, rank: func(f keepFile) int64 {
+			return boolRank(under(f.path, downloads))
+		}}, nil
+	case "oldest":
+		return KeepRule{name: s, needMTime: true, rank: func(f keepFile) int64 { return f.mtime }}, nil
+	case "newest":
+		return KeepRule{name: s, needMTime: true, rank: func(f keepFile) int64 { return -f.mtime }}, nil
+	case "shortest-path":
+		return KeepRule{name: s, rank: func(f keepFile) int64 { return int64(len(f.path)) }}, nil
+	}
+	return KeepRule{}, fmt.Errorf("unknown keep rule %q: use path:DIR, glob:PATTERN, not-downloads, oldest, newest or shortest-path", s)
+}
+
+// ParseKeepRules parses each of rules. Rules that do not parse are left
+// out, and their errors returned together, so callers can carry on with
+// the rest.
+func ParseKeepRules(rules []string) ([]KeepRule, error) {
+	var parsed []KeepRule
+	var errs []error
+	for _, s := range rules {
+		r, err := ParseKeepRule(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, errors.Join(errs...)
+}
+
+var (
+	keepMu      sync.RWMutex
+	defaultKeep []KeepRule
+)
+
+// SetDefaultKeepRules sets the rules used when Options.Keep is nil.
+func SetDefaultKeepRules(rules []KeepRule) {
+	keepMu.Lock()
+	defaultKeep = rules
+	keepMu.Unlock()
+}
+
+func defaultKeepRules() []KeepRule {
+	keepMu.RLock()
+	defer keepMu.RUnlock()
+	return defaultKeep
+}
+
+// ApplyKeep moves the file that rules keep to the front of each group and
+// records the rule that chose it in KeptBy. The other files keep their
+// order. With no rules, groups are left as they are.
+func ApplyKeep(groups []Group, rules []KeepRule) {
+	if len(rules) == 0 {
+		return
+	}
+	var needMTime bool
+	for _, r := range rules {
+		needMTime = needMTime || r.needMTime
+	}
+	for gi := range groups {
+		g := &groups[gi]
+		files := make([]keepFile, len(g.Files))
+		for i, p := range g.Files {
+			files[i].path = p
+			if needMTime {
+				if info, err := os.Lstat(p); err == nil {
+					files[i].mtime = info.ModTime().UnixNano()
+				}
+			}
+		}
+		keep, by := chooseKeep(files, rules)
+		if keep > 0 {
+			kept := g.Files[keep]
+			copy(g.Files[1:keep+1], g.Files[:keep])
+			g.Files[0] = kept
+		}
+		g.KeptBy = by
+	}
+}
+
+// chooseKeep returns the index of the file to keep and the name of the
+// last rule that narrowed the choice, or "" if none did.
+func chooseKeep(files []keepFile, rules []KeepRule) (int, string) {
+	candidates := make([]int, len(files))
+	for i := range files {
+		candidates[i] = i
+	}
+	var by string
+	for _, r := range rules {
+		if len(candidates) == 1 {
+			break
+		}
+		best := r.rank(files[candidates[0]])
+		for _, i := range candidates[1:] {
+			best = min(best, r.rank(files[i]))
+		}
+		var next []int
+		for _, i := range candidates {
+			if r.rank(files[i]) == best {
+				next = append(next, i)
+			}
+		}
+		if len(next) < len(candidates) {
+			by = r.name
+		}
+		candidates = next
+	}
+	return candidates[0], by
+}
+
+func boolRank(worse bool) int64 {
+	if worse {
+		return 1
+	}
+	return 0
+}
+
+// under reports whether path is dir or inside it.
+func under(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// matchGlob matches path like the exclude patterns in the config: "DIR/**"
+// matches everything under DIR, other patterns the full path or the base
+// name.
+func matchGlob(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return under(path, prefix)
+	}
+	if ok, _ := filepath.Match(pattern, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.Base(path))
+	return ok
+}
+
+func expandHome(p string) string {
+	if p == "~" {
+		return utils.HomeDir()
+	}
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return filepath.Join(utils.HomeDir(), rest)
+	}
+	return p
+}
@@ -0,0 +1,95 @@
+package dupes
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+func mustRules(t *testing.T, rules ...string) []KeepRule {
+	t.Helper()
+	parsed, err := ParseKeepRules(rules)
+	if err != nil {
+		t.Fatalf("ParseKeepRules(%v): %v", rules, err)
+	}
+	return parsed
+}
+
+func TestApplyKeep(t *testing.T) {
+	home := utils.HomeDir()
+	dl := filepath.Join(home, "Downloads", "photo.jpg")
+	docs := filepath.Join(home, "Documents", "trip", "photo.jpg")
+	pics := filepath.Join(home, "Pictures", "2024", "holiday", "photo.jpg")
+
+	tests := []struct {
+		name   string
+		rules  []string
+		files  []string
+		want   []string
+		keptBy string
+	}{
+		{"no rules", nil, []string{dl, docs, pics}, []string{dl, docs, pics}, ""},
+		{"path priority", []string{"path:~/Pictures", "path:~/Documents"}, []string{dl, docs, pics}, []string{pics, dl, docs}, "path:~/Pictures"},
+		{"path priority falls through", []string{"path:~/Pictures", "path:~/Documents"}, []string{dl, docs}, []string{docs, dl}, "path:~/Documents"},
+		{"not downloads", []string{"not-downloads"}, []string{dl, docs}, []string{docs, dl}, "not-downloads"},
+		{"shortest path", []string{"shortest-path"}, []string{pics, docs, dl}, []string{dl, pics, docs}, "shortest-path"},
+		{"glob", []string{"glob:~/Documents/*/photo.jpg"}, []string{dl, pics, docs}, []string{docs, dl, pics}, "glob:~/Documents/*/photo.jpg"},
+		{"glob dir", []string{"glob:~/Pictures/**"}, []string{dl, pics}, []string{pics, dl}, "glob:~/Pictures/**"},
+		{"tie broken by later rule", []string{"not-downloads", "shortest-path"}, []string{dl, pics, docs}, []string{docs, dl, pics}, "shortest-path"},
+		{"rule that cannot decide", []string{"path:/nowhere"}, []string{docs, pics}, []string{docs, pics}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := []Group{{Files: append([]string{}, tt.files...)}}
+			ApplyKeep(groups, mustRules(t, tt.rules...))
+			if !reflect.DeepEqual(groups[0].Files, tt.want) {
+				t.Errorf("Files = %v, want %v", groups[0].Files, tt.want)
+			}
+			if groups[0].KeptBy != tt.keptBy {
+				t.Errorf("KeptBy = %q, want %q", groups[0].KeptBy, tt.keptBy)
+			}
+		})
+	}
+}
+
+func TestApplyKeep_ModTime(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, age := range []time.Duration{time.Hour, 3 * time.Hour, 2 * time.Hour} {
+		p := filepath.Join(dir, string(rune('a'+i)))
+		os.WriteFile(p, []byte("same"), 0o644)
+		mtime := time.Now().Add(-age)
+		os.Chtimes(p, mtime, mtime)
+		files = append(files, p)
+	}
+
+	groups := []Group{{Files: append([]string{}, files...)}}
+	ApplyKeep(groups, mustRules(t, "oldest"))
+	if groups[0].Files[0] != files[1] {
+		t.Errorf("oldest kept %s, want %s", groups[0].Files[0], files[1])
+	}
+
+	groups = []Group{{Files: append([]string{}, files...)}}
+	ApplyKeep(groups, mustRules(t, "newest"))
+	if groups[0].Files[0] != files[0] {
+		t.Errorf("newest kept %s, want %s", groups[0].Files[0], files[0])
+	}
+}
+
+func TestParseKeepRules(t *testing.T) {
+	rules, err := ParseKeepRules([]string{"oldest", "largest", "path:", "glob:[", "newest:x", "shortest-path"})
+	if err == nil {
+		t.Fatal("expected errors for invalid rules")
+	}
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.String())
+	}
+	if want := []string{"oldest", "shortest-path"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("valid rules = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,219 @@
+package dupes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// LinkType selects how Link replaces a duplicate.
+type LinkType int
+
+const (
+	// LinkAuto clones where the filesystem supports it and hardlinks
+	// otherwise, but only files whose modification times already match.
+	LinkAuto LinkType = iota
+	// LinkClone makes a copy-on-write clone (clonefile on APFS, FICLONE on
+	// btrfs and XFS). The copy keeps its own permissions and mtime.
+	LinkClone
+	// LinkHard makes a hardlink, which shares the kept file's inode and so
+	// also its permissions and mtime.
+	LinkHard
+)
+
+func (t LinkType) String() string {
+	switch t {
+	case LinkClone:
+		return "clone"
+	case LinkHard:
+		return "hardlink"
+	}
+	return "auto"
+}
+
+// ParseLinkType parses "auto", "clone" or "hardlink".
+func ParseLinkType(s string) (LinkType, error) {
+	switch s {
+	case "", "auto":
+		return LinkAuto, nil
+	case "clone", "reflink":
+		return LinkClone, nil
+	case "hardlink", "hard":
+		return LinkHard, nil
+	}
+	return LinkAuto, fmt.Errorf("invalid link type %q: use auto, clone or hardlink", s)
+}
+
+var (
+	// ErrAlreadyLinked is returned when the duplicate is already a hardlink
+	// of the kept file.
+	ErrAlreadyLinked = errors.New("already hardlinked")
+	// ErrContentDiffers is returned when the files no longer have the same
+	// content, for instance because one was edited after the scan.
+	ErrContentDiffers = errors.New("content differs")
+	// ErrCloneUnsupported is wrapped in the error for LinkClone when the
+	// filesystem cannot clone files.
+	ErrCloneUnsupported = errors.New("filesystem does not support clones")
+)
+
+// Linked describes a duplicate that Link replaced.
+type Linked struct {
+	// Type is LinkClone or LinkHard, whichever was made.
+	Type LinkType
+	// Reclaimed is the space the replaced copy took on disk, or zero if
+	// other hardlinks still hold its data.
+	Reclaimed int64
+}
+
+// Link replaces dup with a clone of, or a hardlink to, keep. Both must be
+// regular files on the same filesystem with the same content, which is
+// compared byte for byte first. The replacement is built next to dup and
+// renamed over it, so dup is never missing or partial; if dup changes
+// while that happens, it is left alone.
+//
+// A clone keeps dup's permissions and modification time. A hardlink
+// cannot, so dups whose permissions or owner differ from keep's are not
+// hardlinked, and LinkAuto does not hardlink dups whose modification time
+// differs either.
+func Link(keep, dup string, t LinkType) (Linked, error) {
+	ki, err := os.Lstat(keep)
+	if err != nil {
+		return Linked{}, fmt.Errorf("failed to stat %s: %w", keep, err)
+	}
+	di, err := os.Lstat(dup)
+	if err != nil {
+		return Linked{}, fmt.Errorf("failed to stat %s: %w", dup, err)
+	}
+	if !ki.Mode().IsRegular() || !di.Mode().IsRegular() {
+		return Linked{}, fmt.Errorf("failed to link %s: not a regular file", dup)
+	}
+	if os.SameFile(ki, di) {
+		return Linked{}, ErrAlreadyLinked
+	}
+	kDev, _ := devIno(ki)
+	dDev, _ := devIno(di)
+	if kDev != dDev {
+		return Linked{}, fmt.Errorf("failed to link %s: on a different filesystem from %s", dup, keep)
+	}
+	if err := sameContent(keep, dup); err != nil {
+		return Linked{}, err
+	}
+
+	tmp := filepath.Join(filepath.Dir(dup), "."+filepath.Base(dup)+".macbroom-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	made, err := makeLink(keep, tmp, ki, di, t)
+	if err != nil {
+		return Linked{}, fmt.Errorf("failed to link %s: %w", dup, err)
+	}
+
+	// Only replace dup if both files are still the ones that were verified.
+	if now, err := os.Lstat(keep); err != nil || !sameFile(ki, now) {
+		os.Remove(tmp)
+		return Linked{}, fmt.Errorf("failed to link %s: %s changed while linking", dup, keep)
+	}
+	if now, err := os.Lstat(dup); err != nil || !sameFile(di, now) {
+		os.Remove(tmp)
+		return Linked{}, fmt.Errorf("failed to link %s: file changed while linking", dup)
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return Linked{}, fmt.Errorf("failed to replace %s: %w", dup, err)
+	}
+
+	res := Linked{Type: made}
+	if st, ok := di.Sys().(*syscall.Stat_t); !ok || st.Nlink <= 1 {
+		res.Reclaimed = utils.FileUsage(dup, di, nil).Allocated
+	}
+	return res, nil
+}
+
+// makeLink creates tmp as a clone of or hardlink to keep, as t asks, and
+// returns which it made.
+func makeLink(keep, tmp string, ki, di fs.FileInfo, t LinkType) (LinkType, error) {
+	if t != LinkHard {
+		err := cloneFile(keep, tmp)
+		if err == nil {
+			if err := copyMetadata(tmp, di); err != nil {
+				os.Remove(tmp)
+				return 0, fmt.Errorf("failed to preserve metadata: %w", err)
+			}
+			return LinkClone, nil
+		}
+		os.Remove(tmp)
+		if t == LinkClone || !errors.Is(err, ErrCloneUnsupported) {
+			return 0, fmt.Errorf("failed to clone: %w", err)
+		}
+	}
+
+	if ki.Mode().Perm() != di.Mode().Perm() || !sameOwner(ki, di) {
+		return 0, fmt.Errorf("cannot hardlink: permissions or owner differ from %s", keep)
+	}
+	if t == LinkAuto && !ki.ModTime().Equal(di.ModTime()) {
+		return 0, fmt.Errorf("cannot hardlink: modification time differs from %s", keep)
+	}
+	if err := os.Link(keep, tmp); err != nil {
+		return 0, fmt.Errorf("failed to hardlink: %w", err)
+	}
+	return LinkHard, nil
+}
+
+// copyMetadata gives path the permissions, owner and modification time of
+// info. The owner is only changed where permitted.
+func copyMetadata(path string, info fs.FileInfo) error {
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+	return os.Chtimes(path, time.Time{}, info.ModTime())
+}
+
+func sameOwner(a, b fs.FileInfo) bool {
+	sa, okA := a.Sys().(*syscall.Stat_t)
+	sb, okB := b.Sys().(*syscall.Stat_t)
+	return !okA || !okB || (sa.Uid == sb.Uid && sa.Gid == sb.Gid)
+}
+
+// sameContent compares the files at a and b byte for byte.
+func sameContent(a, b string) error {
+	fa, err := os.Open(a)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", a, err)
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", b, err)
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 256*1024)
+	bufB := make([]byte, 256*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		doneA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		doneB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !doneA {
+			return fmt.Errorf("failed to read %s: %w", a, errA)
+		}
+		if errB != nil && !doneB {
+			return fmt.Errorf("failed to read %s: %w", b, errB)
+		}
+		if na != nb || doneA != doneB || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return fmt.Errorf("failed to link %s: %w", b, ErrContentDiffers)
+		}
+		if doneA {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package dupes
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, data []byte, perm os.FileMode, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertOnlyFiles fails if dir holds anything but names, such as a
+// leftover temporary file.
+func assertOnlyFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(names) {
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name())
+		}
+		t.Errorf("expected only %v in %s, got %v", names, dir, got)
+	}
+}
+
+func TestLink_Hardlink(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("x"), 64*1024)
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, data, 0o644, time.Now().Add(-time.Hour))
+	writeFile(t, dup, data, 0o644, time.Now().Add(-2*time.Hour))
+
+	res, err := Link(keep, dup, LinkHard)
+	if err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if res.Type != LinkHard {
+		t.Errorf("Type = %v, want hardlink", res.Type)
+	}
+	if res.Reclaimed < int64(len(data)) {
+		t.Errorf("Reclaimed = %d, want at least %d", res.Reclaimed, len(data))
+	}
+	ki, _ := os.Stat(keep)
+	di, _ := os.Stat(dup)
+	if !os.SameFile(ki, di) {
+		t.Error("expected dup to be a hardlink of keep")
+	}
+	assertOnlyFiles(t, dir, "dup", "keep")
+
+	if _, err := Link(keep, dup, LinkHard); !errors.Is(err, ErrAlreadyLinked) {
+		t.Errorf("second Link: expected ErrAlreadyLinked, got %v", err)
+	}
+}
+
+func TestLink_Auto(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("y"), 64*1024)
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	writeFile(t, keep, data, 0o644, time.Now())
+	writeFile(t, dup, data, 0o644, mtime)
+
+	// Clones keep their own mtime. A hardlink would take keep's, so without
+	// clone support the copy is left alone.
+	res, err := Link(keep, dup, LinkAuto)
+	if err == nil && res.Type != LinkClone {
+		t.Fatalf("Type = %v, want clone or an error", res.Type)
+	}
+	got, _ := os.ReadFile(dup)
+	if !bytes.Equal(got, data) {
+		t.Error("dup content changed")
+	}
+	if di, _ := os.Stat(dup); !di.ModTime().Equal(mtime) {
+		t.Errorf("dup mtime = %v, want %v", di.ModTime(), mtime)
+	}
+	assertOnlyFiles(t, dir, "dup", "keep")
+}
+
+func TestLink_AutoMatchingModTime(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("z"), 64*1024)
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	mtime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	writeFile(t, keep, data, 0o644, mtime)
+	writeFile(t, dup, data, 0o644, mtime)
+
+	if _, err := Link(keep, dup, LinkAuto); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if di, _ := os.Stat(dup); !di.ModTime().Equal(mtime) {
+		t.Errorf("dup mtime = %v, want %v", di.ModTime(), mtime)
+	}
+	assertOnlyFiles(t, dir, "dup", "keep")
+}
+
+func TestLink_ContentDiffers(t *testing.T) {
+	dir := t.TempDir()
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, []byte("same size, first"), 0o644, time.Now())
+	writeFile(t, dup, []byte("same size, other"), 0o644, time.Now())
+
+	if _, err := Link(keep, dup, LinkAuto); !errors.Is(err, ErrContentDiffers) {
+		t.Fatalf("expected ErrContentDiffers, got %v", err)
+	}
+	if got, _ := os.ReadFile(dup); string(got) != "same size, other" {
+		t.Errorf("dup was modified: %q", got)
+	}
+	assertOnlyFiles(t, dir, "dup", "keep")
+}
+
+func TestLink_HardlinkKeepsDifferentPermissions(t *testing.T) {
+	dir := t.TempDir()
+	keep, dup := filepath.Join(dir, "keep"), filepath.Join(dir, "dup")
+	writeFile(t, keep, []byte("content"), 0o644, time.Now())
+	writeFile(t, dup, []byte("content"), 0o600, time.Now())
+
+	if _, err := Link(keep, dup, LinkHard); err == nil {
+		t.Fatal("expected hardlinking files with different permissions to fail")
+	}
+	if di, _ := os.Stat(dup); di.Mode().Perm() != 0o600 {
+		t.Errorf("dup permissions changed to %v", di.Mode().Perm())
+	}
+	assertOnlyFiles(t, dir, "dup", "keep")
+}
+
+func TestParseLinkType(t *testing.T) {
+	for in, want := range map[string]LinkType{"": LinkAuto, "auto": LinkAuto, "clone": LinkClone, "reflink": LinkClone, "hardlink": LinkHard} {
+		if got, err := ParseLinkType(in); err != nil || got != want {
+			t.Errorf("ParseLinkType(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLinkType("symlink"); err == nil {
+		t.Error("expected an error for an unknown link type")
+	}
+}
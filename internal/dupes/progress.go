@@ -0,0 +1,119 @@
+package dupes
+
+import (
+	"sync"
+	"time"
+)
+
+// Phase is a stage of the duplicate search.
+type Phase int
+
+const (
+	// PhaseWalk lists files and groups them by size.
+	PhaseWalk Phase = iota
+	// PhasePartial hashes samples of same-size files.
+	PhasePartial
+	// PhaseFull hashes files whose samples matched in full.
+	PhaseFull
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseWalk:
+		return "walk"
+	case PhasePartial:
+		return "partial"
+	case PhaseFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// progressInterval limits how often progress is reported.
+const progressInterval = 100 * time.Millisecond
+
+// Progress describes how far the current phase has got. While walking,
+// Files counts the files visited and the totals are zero; while hashing,
+// Files and Bytes count the files and bytes hashed so far out of
+// TotalFiles and TotalBytes. Bytes served from the hash cache count as
+// hashed.
+type Progress struct {
+	Phase      Phase
+	Path       string
+	Files      int
+	TotalFiles int
+	Bytes      int64
+	TotalBytes int64
+}
+
+// meter collects progress from the walk and the hashing workers and
+// reports it at most every progressInterval, plus once at the end of each
+// phase. A nil meter, used when there is no callback, discards everything.
+type meter struct {
+	fn func(Progress)
+
+	mu       sync.Mutex
+	p        Progress
+	lastSent time.Time
+}
+
+func newMeter(fn ProgressFunc) *meter {
+	if fn == nil {
+		return nil
+	}
+	return &meter{fn: fn}
+}
+
+// start begins phase, which has totalFiles files and totalBytes bytes to
+// hash.
+func (m *meter) start(phase Phase, totalFiles int, totalBytes int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.p = Progress{Phase: phase, TotalFiles: totalFiles, TotalBytes: totalBytes}
+	m.sendLocked()
+}
+
+func (m *meter) addBytes(n int64) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.p.Bytes += n
+	m.maybeSendLocked()
+}
+
+func (m *meter) fileDone(path string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.p.Files++
+	m.p.Path = path
+	m.maybeSendLocked()
+}
+
+// flush reports the final state of the phase.
+func (m *meter) flush() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sendLocked()
+}
+
+func (m *meter) maybeSendLocked() {
+	if time.Since(m.lastSent) >= progressInterval {
+		m.sendLocked()
+	}
+}
+
+func (m *meter) sendLocked() {
+	m.lastSent = time.Now()
+	m.fn(m.p)
+}
@@ -2,10 +2,15 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
 type ScanResult struct {
@@ -17,6 +22,13 @@ type ScanResult struct {
 type Engine struct {
 	scanners    []scanner.Scanner
 	excludeFunc func(string) bool
+	precedence  []string
+	sizeMode    utils.SizeMode
+	dirCache    *utils.DirCache
+	forceRescan bool
+	timeout     time.Duration
+	timeouts    map[string]time.Duration
+	failures    ScanErrors
 }
 
 func New() *Engine {
@@ -31,10 +43,152 @@ func (e *Engine) SetExcludeFunc(fn func(string) bool) {
 	e.excludeFunc = fn
 }
 
+// SetSizeMode selects whether target sizes are apparent (the default) or
+// allocated on disk.
+func (e *Engine) SetSizeMode(mode utils.SizeMode) {
+	e.sizeMode = mode
+}
+
+// SizeMode returns the size mode set with SetSizeMode.
+func (e *Engine) SizeMode() utils.SizeMode {
+	return e.sizeMode
+}
+
+// SetDirCache makes scanners reuse the sizes of directories that have not
+// changed since cache was last saved. The cache is saved after every scan.
+func (e *Engine) SetDirCache(cache *utils.DirCache) {
+	e.dirCache = cache
+	cache.SetRefresh(e.forceRescan)
+}
+
+// SetForceRescan makes scans ignore the directory cache and measure every
+// directory again. The fresh sizes are still written back to the cache.
+func (e *Engine) SetForceRescan(force bool) {
+	e.forceRescan = force
+	e.dirCache.SetRefresh(force)
+}
+
+// SetTimeout bounds how long each scanner may run. Zero, the default,
+// means no limit.
+func (e *Engine) SetTimeout(d time.Duration) {
+	e.timeout = d
+}
+
+// SetScannerTimeout overrides the timeout of the scanner named name.
+func (e *Engine) SetScannerTimeout(name string, d time.Duration) {
+	if e.timeouts == nil {
+		e.timeouts = make(map[string]time.Duration)
+	}
+	e.timeouts[name] = d
+}
+
+// AddFailure records a scanner that failed before it could be registered,
+// such as a plugin that did not load. Every scan of all categories reports
+// it alongside the scanners that failed while scanning.
+func (e *Engine) AddFailure(err *ScanError) {
+	e.failures = append(e.failures, err)
+}
+
+// timeoutFor returns the timeout that applies to the scanner named name.
+func (e *Engine) timeoutFor(name string) time.Duration {
+	if d, ok := e.timeouts[name]; ok {
+		return d
+	}
+	return e.timeout
+}
+
+// DirCacheStats returns the directory cache hit and miss counts, which are
+// zero when no cache is set.
+func (e *Engine) DirCacheStats() utils.DirCacheStats {
+	return e.dirCache.Stats()
+}
+
+// scanOne runs s with the engine's size mode, directory cache and timeout,
+// reporting its incremental progress to sink when set.
+// Every scanner of one scan shares seen, so an inode hardlinked into the
+// targets of several scanners is counted once. A link reached through
+// nested targets counts in both, like any other file, and is settled by
+// ResolveOverlaps.
+//
+// A scanner that ignores its context is abandoned when the timeout expires
+// so it cannot hold up the scan. Errors are returned as *ScanError, along
+// with whatever targets the scanner still reported. Only the scanner's own
+// timeout is reported as one; a cancelled or expired ctx is a plain error.
+func (e *Engine) scanOne(ctx context.Context, s scanner.Scanner, seen *utils.InodeSet, sink *progressSink) ([]scanner.Target, error) {
+	ctx = utils.WithSizing(ctx, e.sizeMode, seen)
+	ctx = utils.WithDirCache(ctx, e.dirCache)
+	if sink != nil {
+		ctx = scanner.WithProgress(ctx, sink)
+		// An abandoned scanner must not report after its Done event.
+		defer sink.close()
+	}
+	parent := ctx
+	timeout := e.timeoutFor(s.Name())
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		targets []scanner.Target
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		targets, err := s.Scan(ctx)
+		done <- result{targets, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		// A result that arrived together with the deadline still counts.
+		select {
+		case r = <-done:
+		default:
+			r.err = ctx.Err()
+		}
+	}
+	if r.err == nil {
+		return r.targets, nil
+	}
+	if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+		return r.targets, &ScanError{Scanner: s.Name(), Phase: PhaseScan, Err: context.DeadlineExceeded, Timeout: timeout}
+	}
+	return r.targets, &ScanError{Scanner: s.Name(), Phase: PhaseScan, Err: r.err}
+}
+
+// saveDirCache persists the directory cache. A cache that cannot be
+// written only costs the next scan its speed-up, so errors are dropped.
+func (e *Engine) saveDirCache() {
+	_ = e.dirCache.Save()
+}
+
 func (e *Engine) Scanners() []scanner.Scanner {
 	return e.scanners
 }
 
+// Roots returns the declared roots of every registered scanner that
+// implements scanner.Rooted, keyed by scanner name. Call it after scanning:
+// some scanners only learn their roots while they scan. Cleanup refuses the
+// filesystem targets of scanners missing from the result.
+func (e *Engine) Roots() map[string][]string {
+	roots := make(map[string][]string)
+	for _, s := range e.scanners {
+		if r, ok := s.(scanner.Rooted); ok {
+			if dirs := r.Roots(); len(dirs) > 0 {
+				roots[s.Name()] = dirs
+			}
+		}
+	}
+	return roots
+}
+
+// filterExcluded drops excluded targets. Scans apply it after resolving
+// overlaps, so that a target holding an excluded one lists it in Contains
+// and is not cleaned without it.
 func (e *Engine) filterExcluded(targets []scanner.Target) []scanner.Target {
 	if e.excludeFunc == nil {
 		return targets
@@ -48,45 +202,95 @@ func (e *Engine) filterExcluded(targets []scanner.Target) []scanner.Target {
 	return filtered
 }
 
+// ScanAll runs every scanner concurrently and returns their targets with
+// overlapping paths resolved.
 func (e *Engine) ScanAll(ctx context.Context) ([]scanner.Target, error) {
-	if len(e.scanners) == 0 {
-		return nil, nil
+	targets, _, err := e.ScanTargets(ctx, nil)
+	return targets, err
+}
+
+// ScanTargets runs the scanners for the given categories (all scanners when
+// categories is nil) concurrently. Targets claimed by more than one scanner
+// are resolved with ResolveOverlaps and the overlaps are returned alongside.
+// When some scanners fail or time out, the targets of the others are still
+// returned, together with a ScanErrors listing the failures.
+func (e *Engine) ScanTargets(ctx context.Context, categories []string) ([]scanner.Target, []Overlap, error) {
+	return e.ScanTargetsWithProgress(ctx, categories, nil)
+}
+
+// ScanTargetsWithProgress is ScanTargets that also calls onProgress for
+// every scanner event, including targets and bytes reported while the
+// scanners run. onProgress may be called from several goroutines at once.
+func (e *Engine) ScanTargetsWithProgress(ctx context.Context, categories []string, onProgress func(ScanProgress)) ([]scanner.Target, []Overlap, error) {
+	selected := e.scanners
+	if categories != nil {
+		want := make(map[string]bool, len(categories))
+		for _, c := range categories {
+			want[c] = true
+		}
+		selected = nil
+		for _, s := range e.scanners {
+			if want[s.Name()] {
+				selected = append(selected, s)
+			}
+		}
+	}
+	var errs ScanErrors
+	if categories == nil {
+		errs = append(errs, e.failures...)
+	}
+	if len(selected) == 0 {
+		if len(errs) > 0 {
+			return nil, nil, errs
+		}
+		return nil, nil, nil
 	}
 
 	var (
 		mu      sync.Mutex
 		wg      sync.WaitGroup
 		targets []scanner.Target
-		errs    []error
+		seen    = utils.NewInodeSet()
 	)
 
-	for _, s := range e.scanners {
+	for _, s := range selected {
 		wg.Add(1)
 		go func(s scanner.Scanner) {
 			defer wg.Done()
-			t, err := s.Scan(ctx)
+			sink := e.newProgressSink(s.Name(), onProgress)
+			sink.send(ScanProgress{Status: ScanStarted})
+			t, err := e.scanOne(ctx, s, seen, sink)
+			sink.done(e.filterExcluded(t), err)
 			mu.Lock()
 			defer mu.Unlock()
-			if err != nil {
-				errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
-				return
+			var se *ScanError
+			if errors.As(err, &se) {
+				errs = append(errs, se)
 			}
 			targets = append(targets, t...)
 		}(s)
 	}
 
 	wg.Wait()
+	e.saveDirCache()
 
+	resolved, overlaps := e.ResolveOverlaps(targets)
+	resolved = e.filterExcluded(resolved)
+	if e.excludeFunc != nil {
+		overlaps = slices.DeleteFunc(overlaps, func(o Overlap) bool { return e.excludeFunc(o.Path) })
+	}
 	if len(errs) > 0 {
-		return e.filterExcluded(targets), fmt.Errorf("scan errors: %v", errs)
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Scanner < errs[j].Scanner })
+		return resolved, overlaps, errs
 	}
-	return e.filterExcluded(targets), nil
+	return resolved, overlaps, nil
 }
 
 func (e *Engine) ScanByCategory(ctx context.Context, category string) ([]scanner.Target, error) {
 	for _, s := range e.scanners {
 		if s.Name() == category {
-			targets, err := s.Scan(ctx)
+			targets, err := e.scanOne(ctx, s, utils.NewInodeSet(), nil)
+			e.saveDirCache()
 			return e.filterExcluded(targets), err
 		}
 	}
@@ -98,14 +302,14 @@ func (e *Engine) ScanGrouped(ctx context.Context) []ScanResult {
 		mu      sync.Mutex
 		wg      sync.WaitGroup
 		results []ScanResult
+		seen    = utils.NewInodeSet()
 	)
 
 	for _, s := range e.scanners {
 		wg.Add(1)
 		go func(s scanner.Scanner) {
 			defer wg.Done()
-			targets, err := s.Scan(ctx)
-			targets = e.filterExcluded(targets)
+			targets, err := e.scanOne(ctx, s, seen, nil)
 			mu.Lock()
 			defer mu.Unlock()
 			results = append(results, ScanResult{
@@ -117,28 +321,56 @@ func (e *Engine) ScanGrouped(ctx context.Context) []ScanResult {
 	}
 
 	wg.Wait()
-	return results
+	e.saveDirCache()
+	return e.resolveGrouped(e.withFailures(results))
 }
 
-// ScanStatus represents the state of a scanner in the progress callback.
-type ScanStatus int
+// sendResolved reports the final targets of each category to onProgress.
+func sendResolved(results []ScanResult, onProgress func(ScanProgress)) {
+	for _, r := range results {
+		p := ScanProgress{Name: r.Category, Status: ScanResolved, Items: len(r.Targets), Targets: r.Targets, Error: r.Error}
+		for _, t := range r.Targets {
+			p.Size += t.Size
+		}
+		onProgress(p)
+	}
+}
 
-const (
-	ScanWaiting ScanStatus = iota
-	ScanStarted
-	ScanDone
-)
+// withFailures appends a result for each failure recorded with AddFailure.
+func (e *Engine) withFailures(results []ScanResult) []ScanResult {
+	for _, f := range e.failures {
+		results = append(results, ScanResult{Category: f.Scanner, Error: f})
+	}
+	return results
+}
+
+// resolveGrouped resolves overlaps across all categories of a grouped scan,
+// drops excluded targets and redistributes the rest to their categories.
+func (e *Engine) resolveGrouped(results []ScanResult) []ScanResult {
+	var all []scanner.Target
+	origin := make(map[string]int) // target category -> result index
+	for i, r := range results {
+		all = append(all, r.Targets...)
+		for _, t := range r.Targets {
+			origin[t.Category] = i
+		}
+	}
+	resolved, _ := e.ResolveOverlaps(all)
 
-// ScanProgress is sent to the progress callback for each scanner event.
-type ScanProgress struct {
-	Name    string
-	Status  ScanStatus
-	Targets []scanner.Target
-	Error   error
+	for i := range results {
+		results[i].Targets = nil
+	}
+	for _, t := range e.filterExcluded(resolved) {
+		i := origin[t.Category]
+		results[i].Targets = append(results[i].Targets, t)
+	}
+	return results
 }
 
 // ScanGroupedWithProgress runs scanners with a concurrency limit and calls
-// onProgress for each scanner event (started, done).
+// onProgress for each scanner event: started, each target found and bytes
+// measured while it runs, and done. Once all are done and overlaps are
+// resolved, a ScanResolved event per category reports the final totals.
 func (e *Engine) ScanGroupedWithProgress(ctx context.Context, concurrency int, onProgress func(ScanProgress)) []ScanResult {
 	if concurrency < 1 {
 		concurrency = 1
@@ -149,6 +381,7 @@ func (e *Engine) ScanGroupedWithProgress(ctx context.Context, concurrency int, o
 		wg      sync.WaitGroup
 		results []ScanResult
 		sem     = make(chan struct{}, concurrency)
+		seen    = utils.NewInodeSet()
 	)
 
 	for _, s := range e.scanners {
@@ -157,21 +390,11 @@ func (e *Engine) ScanGroupedWithProgress(ctx context.Context, concurrency int, o
 			defer wg.Done()
 
 			sem <- struct{}{} // acquire
-			if onProgress != nil {
-				onProgress(ScanProgress{Name: s.Name(), Status: ScanStarted})
-			}
+			sink := e.newProgressSink(s.Name(), onProgress)
+			sink.send(ScanProgress{Status: ScanStarted})
 
-			targets, err := s.Scan(ctx)
-			targets = e.filterExcluded(targets)
-
-			if onProgress != nil {
-				onProgress(ScanProgress{
-					Name:    s.Name(),
-					Status:  ScanDone,
-					Targets: targets,
-					Error:   err,
-				})
-			}
+			targets, err := e.scanOne(ctx, s, seen, sink)
+			sink.done(e.filterExcluded(targets), err)
 
 			<-sem // release after Done callback to keep concurrency tracking consistent
 
@@ -186,5 +409,10 @@ func (e *Engine) ScanGroupedWithProgress(ctx context.Context, concurrency int, o
 	}
 
 	wg.Wait()
-	return results
+	e.saveDirCache()
+	resolved := e.resolveGrouped(e.withFailures(results))
+	if onProgress != nil {
+		sendResolved(resolved, onProgress)
+	}
+	return resolved
 }
@@ -2,11 +2,15 @@ package engine
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
 type mockScanner struct {
@@ -122,6 +126,40 @@ func TestScanGroupedWithProgress_Basic(t *testing.T) {
 	}
 }
 
+func TestScanGroupedWithProgress_ResolvedTotals(t *testing.T) {
+	e := New()
+	e.Register(&mockScanner{name: "System Junk", targets: []scanner.Target{{Path: "/lib/caches", Size: 100, Category: "System Junk"}}})
+	e.Register(&mockScanner{name: "Browser Cache", targets: []scanner.Target{{Path: "/lib/caches/chrome", Size: 40, Category: "Browser Cache"}}})
+
+	var mu sync.Mutex
+	resolved := make(map[string]ScanProgress)
+	doneSeen := false
+	e.ScanGroupedWithProgress(context.Background(), 2, func(p ScanProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch p.Status {
+		case ScanDone:
+			if len(resolved) > 0 {
+				t.Error("Done event after ScanResolved")
+			}
+			doneSeen = true
+		case ScanResolved:
+			resolved[p.Name] = p
+		}
+	})
+
+	if !doneSeen {
+		t.Fatal("expected Done events")
+	}
+	// The broader target keeps only the bytes the nested one does not own.
+	if p := resolved["System Junk"]; p.Items != 1 || p.Size != 60 {
+		t.Errorf("System Junk resolved to %d items, %d bytes; want 1, 60", p.Items, p.Size)
+	}
+	if p := resolved["Browser Cache"]; p.Items != 1 || p.Size != 40 {
+		t.Errorf("Browser Cache resolved to %d items, %d bytes; want 1, 40", p.Items, p.Size)
+	}
+}
+
 func TestScanGroupedWithProgress_ConcurrencyLimit(t *testing.T) {
 	e := New()
 	var mu sync.Mutex
@@ -306,3 +344,342 @@ func TestScanGrouped_WithExcludeFunc(t *testing.T) {
 		}
 	}
 }
+
+// sizingScanner reports the size mode and InodeSet it was scanned with.
+type sizingScanner struct {
+	mockScanner
+	mode  utils.SizeMode
+	seen  *utils.InodeSet
+	cache *utils.DirCache
+}
+
+func (s *sizingScanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	s.mode, s.seen = utils.SizingFrom(ctx)
+	s.cache = utils.DirCacheFrom(ctx)
+	return nil, nil
+}
+
+func TestSetSizeMode_PassedToScanners(t *testing.T) {
+	a := &sizingScanner{mockScanner: mockScanner{name: "a"}}
+	b := &sizingScanner{mockScanner: mockScanner{name: "b"}}
+	e := New()
+	e.Register(a)
+	e.Register(b)
+	e.SetSizeMode(utils.SizeAllocated)
+
+	if _, err := e.ScanAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if a.mode != utils.SizeAllocated || b.mode != utils.SizeAllocated {
+		t.Errorf("scanners saw modes %v and %v, want allocated", a.mode, b.mode)
+	}
+	if a.seen == nil || a.seen != b.seen {
+		t.Error("expected the scanners of one scan to share an InodeSet")
+	}
+}
+
+func TestSetDirCache_PassedToScannersAndSaved(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "dir-sizes.json")
+	measured := filepath.Join(dir, "tree")
+	os.Mkdir(measured, 0o755)
+	os.WriteFile(filepath.Join(measured, "a.txt"), make([]byte, 10), 0o644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(measured, old, old)
+
+	cache := utils.NewDirCache(cachePath)
+	s := &sizingScanner{mockScanner: mockScanner{name: "a"}}
+	e := New()
+	e.Register(s)
+	e.SetDirCache(cache)
+	e.SetForceRescan(true)
+
+	cache.DirUsage(context.Background(), measured, nil)
+	cache.DirUsage(context.Background(), measured, nil)
+	if _, err := e.ScanAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.cache != cache {
+		t.Error("expected scanner to receive the engine's dir cache")
+	}
+	if st := e.DirCacheStats(); st.Hits != 0 || st.Misses != 2 {
+		t.Errorf("force rescan must bypass cached records, stats = %+v", st)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Errorf("expected cache to be saved after the scan: %v", err)
+	}
+}
+
+// hungScanner blocks until released, ignoring its context, like an external
+// command that never returns.
+type hungScanner struct {
+	name    string
+	release chan struct{}
+}
+
+func (h *hungScanner) Name() string            { return h.name }
+func (h *hungScanner) Description() string     { return "hung scanner" }
+func (h *hungScanner) Risk() scanner.RiskLevel { return scanner.Safe }
+func (h *hungScanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	<-h.release
+	return nil, nil
+}
+
+func TestScanTargets_TimeoutKeepsOtherResults(t *testing.T) {
+	hung := &hungScanner{name: "Homebrew", release: make(chan struct{})}
+	defer close(hung.release)
+
+	e := New()
+	e.SetTimeout(time.Minute)
+	e.SetScannerTimeout("Homebrew", 50*time.Millisecond)
+	e.Register(hung)
+	e.Register(&mockScanner{name: "Go", targets: []scanner.Target{{Path: "/go/cache", Size: 10, Category: "Go"}}})
+
+	start := time.Now()
+	targets, _, err := e.ScanTargets(context.Background(), nil)
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("scan waited for the hung scanner (%s)", time.Since(start))
+	}
+	if len(targets) != 1 || targets[0].Path != "/go/cache" {
+		t.Errorf("expected the other scanner's targets, got %+v", targets)
+	}
+
+	errs := AsScanErrors(err)
+	if len(errs) != 1 {
+		t.Fatalf("expected one scan error, got %v", err)
+	}
+	if errs[0].Scanner != "Homebrew" || errs[0].Phase != PhaseScan || !errs[0].TimedOut() || errs[0].Timeout != 50*time.Millisecond {
+		t.Errorf("unexpected scan error %+v", errs[0])
+	}
+	if got := errs[0].Error(); got != "Homebrew: timed out after 50ms" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+// partialScanner reports one target when its context ends, like a walk
+// cut short by a timeout.
+type partialScanner struct {
+	name string
+}
+
+func (p *partialScanner) Name() string            { return p.name }
+func (p *partialScanner) Description() string     { return "partial scanner" }
+func (p *partialScanner) Risk() scanner.RiskLevel { return scanner.Safe }
+func (p *partialScanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	<-ctx.Done()
+	return []scanner.Target{{Path: "/partial", Size: 1, Category: p.name}}, ctx.Err()
+}
+
+func TestScanByCategory_TimeoutKeepsReportedTargets(t *testing.T) {
+	e := New()
+	e.SetTimeout(20 * time.Millisecond)
+	e.Register(&partialScanner{name: "Node.js"})
+
+	targets, err := e.ScanByCategory(context.Background(), "Node.js")
+	var se *ScanError
+	if !errors.As(err, &se) || !se.TimedOut() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	// The result can race the deadline; either way nothing is dropped that
+	// the scanner returned.
+	if len(targets) > 1 || (len(targets) == 1 && targets[0].Path != "/partial") {
+		t.Errorf("unexpected targets %+v", targets)
+	}
+}
+
+func TestScanByCategory_ParentDeadlineIsNotATimeout(t *testing.T) {
+	e := New()
+	e.Register(&partialScanner{name: "Node.js"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := e.ScanByCategory(ctx, "Node.js")
+	var se *ScanError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a scan error, got %v", err)
+	}
+	if se.TimedOut() || se.Timeout != 0 {
+		t.Errorf("parent deadline reported as the scanner's timeout: %+v", se)
+	}
+	if got := se.Error(); got != "Node.js: scan failed: context deadline exceeded" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestScanTargets_FailuresAreTyped(t *testing.T) {
+	cause := errors.New("daemon not running")
+	e := New()
+	e.Register(&mockScanner{name: "Docker", err: cause})
+	e.Register(&mockScanner{name: "Browser Cache", err: errors.New("permission denied")})
+
+	_, _, err := e.ScanTargets(context.Background(), nil)
+	errs := AsScanErrors(err)
+	if len(errs) != 2 || errs[0].Scanner != "Browser Cache" || errs[1].Scanner != "Docker" {
+		t.Fatalf("expected both failures sorted by scanner, got %v", err)
+	}
+	if errs[1].TimedOut() || !errors.Is(err, cause) {
+		t.Errorf("expected the cause to be preserved, got %+v", errs[1])
+	}
+}
+
+func TestScanGroupedWithProgress_Timeout(t *testing.T) {
+	hung := &hungScanner{name: "Docker", release: make(chan struct{})}
+	defer close(hung.release)
+
+	e := New()
+	e.SetTimeout(50 * time.Millisecond)
+	e.Register(hung)
+
+	results := e.ScanGroupedWithProgress(context.Background(), 1, nil)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if errs := AsScanErrors(results[0].Error); len(errs) != 1 || !errs[0].TimedOut() {
+		t.Errorf("expected a timeout error, got %v", results[0].Error)
+	}
+}
+
+// streamingScanner reports its targets and some measured bytes through the
+// context's progress before returning them.
+type streamingScanner struct {
+	name    string
+	targets []scanner.Target
+}
+
+func (s *streamingScanner) Name() string            { return s.name }
+func (s *streamingScanner) Description() string     { return "streaming scanner" }
+func (s *streamingScanner) Risk() scanner.RiskLevel { return scanner.Safe }
+func (s *streamingScanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	p := scanner.ProgressFrom(ctx)
+	for _, t := range s.targets {
+		p.Measured(t.Size)
+		p.Found(t)
+	}
+	return s.targets, nil
+}
+
+func TestScanTargetsWithProgress_StreamsBeforeDone(t *testing.T) {
+	e := New()
+	e.SetExcludeFunc(func(path string) bool { return path == "/excluded" })
+	e.Register(&streamingScanner{name: "Xcode Junk", targets: []scanner.Target{
+		{Path: "/a", Size: 100, Category: "Xcode Junk"},
+		{Path: "/excluded", Size: 50, Category: "Xcode Junk"},
+		{Path: "/b", Size: 200, Category: "Xcode Junk"},
+	}})
+
+	var events []ScanProgress
+	targets, _, err := e.ScanTargetsWithProgress(context.Background(), nil, func(p ScanProgress) {
+		events = append(events, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %+v", targets)
+	}
+
+	var found []string
+	for i, ev := range events {
+		if ev.Name != "Xcode Junk" {
+			t.Errorf("event %d has name %q", i, ev.Name)
+		}
+		if ev.Status == ScanFound {
+			found = append(found, ev.Target.Path)
+		}
+		if ev.Status == ScanDone && i != len(events)-1 {
+			t.Errorf("events after ScanDone: %+v", events[i+1:])
+		}
+	}
+	if events[0].Status != ScanStarted {
+		t.Errorf("expected ScanStarted first, got %v", events[0].Status)
+	}
+	if len(found) != 2 || found[0] != "/a" || found[1] != "/b" {
+		t.Errorf("expected excluded targets to be skipped, found %v", found)
+	}
+
+	last := events[len(events)-1]
+	if last.Status != ScanDone || last.Items != 2 || last.Size != 300 || last.Bytes != 350 || len(last.Targets) != 2 {
+		t.Errorf("unexpected ScanDone event %+v", last)
+	}
+}
+
+func TestStream(t *testing.T) {
+	e := New()
+	e.Register(&streamingScanner{name: "Node.js", targets: []scanner.Target{{Path: "/n", Size: 10, Category: "Node.js"}}})
+	e.Register(&mockScanner{name: "Go", targets: []scanner.Target{{Path: "/g", Size: 20, Category: "Go"}}})
+
+	st := e.Stream(context.Background(), 2)
+	counts := make(map[ScanStatus]int)
+	for ev := range st.Events {
+		counts[ev.Status]++
+	}
+	if counts[ScanStarted] != 2 || counts[ScanDone] != 2 || counts[ScanFound] != 1 {
+		t.Errorf("unexpected event counts %v", counts)
+	}
+	if results := st.Results(); len(results) != 2 {
+		t.Errorf("expected 2 results, got %+v", results)
+	}
+}
+
+// lateScanner reports a target after the engine has given up on it.
+type lateScanner struct {
+	release, reported chan struct{}
+}
+
+func (l *lateScanner) Name() string            { return "Late" }
+func (l *lateScanner) Description() string     { return "late scanner" }
+func (l *lateScanner) Risk() scanner.RiskLevel { return scanner.Safe }
+func (l *lateScanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	<-l.release
+	scanner.ProgressFrom(ctx).Found(scanner.Target{Path: "/late", Size: 1})
+	close(l.reported)
+	return nil, nil
+}
+
+func TestScanTargetsWithProgress_NoEventsAfterTimeout(t *testing.T) {
+	late := &lateScanner{release: make(chan struct{}), reported: make(chan struct{})}
+	e := New()
+	e.SetTimeout(20 * time.Millisecond)
+	e.Register(late)
+
+	var mu sync.Mutex
+	var events []ScanProgress
+	e.ScanTargetsWithProgress(context.Background(), nil, func(p ScanProgress) {
+		mu.Lock()
+		events = append(events, p)
+		mu.Unlock()
+	})
+	close(late.release)
+	<-late.reported
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, ev := range events {
+		if ev.Status == ScanFound {
+			t.Errorf("abandoned scanner reported %+v", ev)
+		}
+	}
+	if last := events[len(events)-1]; last.Status != ScanDone || last.Error == nil {
+		t.Errorf("expected a failed ScanDone last, got %+v", last)
+	}
+}
+
+type rootedScanner struct {
+	mockScanner
+	roots []string
+}
+
+func (r *rootedScanner) Roots() []string { return r.roots }
+
+func TestRoots(t *testing.T) {
+	e := New()
+	e.Register(&rootedScanner{mockScanner: mockScanner{name: "Go"}, roots: []string{"/home/go/pkg/mod"}})
+	e.Register(&rootedScanner{mockScanner: mockScanner{name: "Homebrew"}})
+	e.Register(&mockScanner{name: "Docker"})
+
+	roots := e.Roots()
+	if len(roots) != 1 || len(roots["Go"]) != 1 || roots["Go"][0] != "/home/go/pkg/mod" {
+		t.Errorf("unexpected roots %v", roots)
+	}
+}
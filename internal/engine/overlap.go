@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// DefaultPrecedence lists categories from most to least specific. When two
+// scanners report the same bytes, the category listed first owns them.
+// Broad scanners that sweep whole directories (~/Library/Caches, Xcode's
+// Developer folder, large files) come last so that a dedicated scanner's
+// description and risk level win. Unlisted categories rank after all listed
+// ones.
+var DefaultPrecedence = []string{
+	"iOS Simulators",
+	"Browser Cache",
+	"JetBrains",
+	"Python",
+	"Node.js",
+	"Rust",
+	"Go",
+	"Maven",
+	"Gradle",
+	"Ruby",
+	"Homebrew",
+	"Docker",
+	"Xcode Junk",
+	"System Junk",
+	"Large & Old Files",
+}
+
+// Overlap records bytes that more than one scanner reported. Path is the
+// duplicated or nested path, Owner is the category that keeps the bytes
+// and Other is the category whose target was dropped or shrunk.
+type Overlap struct {
+	Path  string `json:"path"`
+	Owner string `json:"owner"`
+	Other string `json:"other"`
+	Bytes int64  `json:"bytes"`
+}
+
+// OverlapSize returns the total number of bytes removed from the scan
+// totals because they were reported more than once.
+func OverlapSize(overlaps []Overlap) int64 {
+	var total int64
+	for _, o := range overlaps {
+		total += o.Bytes
+	}
+	return total
+}
+
+// SetPrecedence overrides DefaultPrecedence for overlap resolution.
+func (e *Engine) SetPrecedence(categories []string) {
+	e.precedence = categories
+}
+
+// ResolveOverlaps removes double-counted bytes from targets using the
+// engine's precedence. See ResolveOverlaps.
+func (e *Engine) ResolveOverlaps(targets []scanner.Target) ([]scanner.Target, []Overlap) {
+	precedence := e.precedence
+	if precedence == nil {
+		precedence = DefaultPrecedence
+	}
+	return ResolveOverlaps(targets, precedence)
+}
+
+// ResolveOverlaps finds filesystem targets from different scanners whose
+// paths are equal or nested and keeps exactly one owner for each byte:
+//
+//   - Identical paths keep the target of the higher-precedence category.
+//   - A descendant of an equal- or higher-precedence target is dropped; its
+//     bytes are already counted by the ancestor.
+//   - A descendant with higher precedence than its ancestor is kept and its
+//     size is subtracted from the ancestor. Ancestors left with no bytes of
+//     their own are dropped; the rest list the descendant in Contains and
+//     are moved after their descendants.
+//
+// Command targets are never compared. The input slice is not modified.
+func ResolveOverlaps(targets []scanner.Target, precedence []string) ([]scanner.Target, []Overlap) {
+	rankOf := make(map[string]int, len(precedence))
+	for i, c := range precedence {
+		rankOf[c] = i
+	}
+	rank := func(category string) int {
+		if r, ok := rankOf[category]; ok {
+			return r
+		}
+		return len(precedence)
+	}
+
+	type node struct {
+		idx   int    // index into targets
+		key   string // cleaned path with trailing separator
+		size  int64  // remaining bytes after subtracting kept descendants
+		usage utils.Usage
+		drop  bool
+		split bool // lost bytes to a kept descendant
+
+		contains []string // kept descendants, at any depth
+	}
+
+	var nodes []*node
+	for i, t := range targets {
+		if !t.IsFilesystem() {
+			continue
+		}
+		key := filepath.Clean(t.Path)
+		if !strings.HasSuffix(key, string(filepath.Separator)) {
+			key += string(filepath.Separator)
+		}
+		nodes = append(nodes, &node{idx: i, key: key, size: t.Size, usage: t.Usage})
+	}
+
+	// With a trailing separator on every key, lexical order places each
+	// path directly before its descendants. Ties on identical paths put
+	// the owner first.
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].key != nodes[j].key {
+			return nodes[i].key < nodes[j].key
+		}
+		return rank(targets[nodes[i].idx].Category) < rank(targets[nodes[j].idx].Category)
+	})
+
+	var overlaps []Overlap
+	var stack []*node // kept ancestors of the current node, outermost first
+	for _, n := range nodes {
+		for len(stack) > 0 && !strings.HasPrefix(n.key, stack[len(stack)-1].key) {
+			stack = stack[:len(stack)-1]
+		}
+		t := targets[n.idx]
+		if len(stack) == 0 {
+			stack = append(stack, n)
+			continue
+		}
+
+		// The target owning n's bytes is the highest-precedence ancestor,
+		// unless n itself outranks all of them.
+		owner := stack[0]
+		for _, a := range stack[1:] {
+			if rank(targets[a.idx].Category) < rank(targets[owner.idx].Category) {
+				owner = a
+			}
+		}
+		ownerTarget := targets[owner.idx]
+
+		if rank(ownerTarget.Category) <= rank(t.Category) {
+			n.drop = true
+			overlaps = append(overlaps, Overlap{
+				Path:  t.Path,
+				Owner: ownerTarget.Category,
+				Other: t.Category,
+				Bytes: t.Size,
+			})
+			continue
+		}
+
+		parent := stack[len(stack)-1]
+		parent.size -= t.Size
+		parent.usage.Apparent -= t.Usage.Apparent
+		parent.usage.Allocated -= t.Usage.Allocated
+		parent.split = true
+		for _, a := range stack {
+			a.contains = append(a.contains, t.Path)
+		}
+		overlaps = append(overlaps, Overlap{
+			Path:  t.Path,
+			Owner: t.Category,
+			Other: targets[parent.idx].Category,
+			Bytes: t.Size,
+		})
+		stack = append(stack, n)
+	}
+
+	byIdx := make(map[int]*node, len(nodes))
+	kept := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		byIdx[n.idx] = n
+		if !n.drop && (!n.split || n.size > 0) {
+			kept[targets[n.idx].Path] = true
+		}
+	}
+
+	resolved := make([]scanner.Target, 0, len(targets))
+	var shrunk []scanner.Target
+	for i, t := range targets {
+		n, ok := byIdx[i]
+		if !ok {
+			resolved = append(resolved, t)
+			continue
+		}
+		switch {
+		case n.drop:
+		case n.split && n.size <= 0:
+		case n.split:
+			t.Size = n.size
+			t.Usage = n.usage
+			t.Contains = nil
+			for _, p := range n.contains {
+				// Descendants dropped for having no bytes of their own
+				// are still covered by their own kept descendants.
+				if kept[p] {
+					t.Contains = append(t.Contains, p)
+				}
+			}
+			shrunk = append(shrunk, t)
+		default:
+			resolved = append(resolved, t)
+		}
+	}
+	return append(resolved, shrunk...), overlaps
+}
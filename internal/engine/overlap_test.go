@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+func sizesByPath(targets []scanner.Target) map[string]int64 {
+	m := make(map[string]int64, len(targets))
+	for _, t := range targets {
+		m[t.Category+":"+t.Path] = t.Size
+	}
+	return m
+}
+
+func TestResolveOverlaps_IdenticalPaths(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Developer/CoreSimulator/Devices/A", Size: 100, Category: "Xcode Junk"},
+		{Path: "/L/Developer/CoreSimulator/Devices/A", Size: 100, Category: "iOS Simulators"},
+	}
+
+	got, overlaps := ResolveOverlaps(targets, DefaultPrecedence)
+
+	if len(got) != 1 || got[0].Category != "iOS Simulators" {
+		t.Fatalf("expected only the iOS Simulators target, got %+v", got)
+	}
+	if len(overlaps) != 1 || overlaps[0].Owner != "iOS Simulators" || overlaps[0].Other != "Xcode Junk" || overlaps[0].Bytes != 100 {
+		t.Errorf("unexpected overlaps: %+v", overlaps)
+	}
+}
+
+func TestResolveOverlaps_SpecificDescendantShrinksAncestor(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Caches/JetBrains", Size: 1000, Category: "System Junk"},
+		{Path: "/L/Caches/JetBrains/IntelliJIdea2024.1", Size: 600, Category: "JetBrains"},
+		{Path: "/L/Caches/JetBrains/GoLand2024.1", Size: 300, Category: "JetBrains"},
+		{Path: "/L/Caches/pip", Size: 50, Category: "System Junk"},
+		{Path: "/L/Caches/pip", Size: 50, Category: "Python"},
+	}
+
+	got, overlaps := ResolveOverlaps(targets, DefaultPrecedence)
+	sizes := sizesByPath(got)
+
+	want := map[string]int64{
+		"JetBrains:/L/Caches/JetBrains/IntelliJIdea2024.1": 600,
+		"JetBrains:/L/Caches/JetBrains/GoLand2024.1":       300,
+		"System Junk:/L/Caches/JetBrains":                  100,
+		"Python:/L/Caches/pip":                             50,
+	}
+	if len(sizes) != len(want) {
+		t.Fatalf("got %v, want %v", sizes, want)
+	}
+	for k, v := range want {
+		if sizes[k] != v {
+			t.Errorf("%s: size = %d, want %d", k, sizes[k], v)
+		}
+	}
+
+	if got[len(got)-1].Path != "/L/Caches/JetBrains" {
+		t.Errorf("expected shrunk ancestor after its descendants, got order %v", got)
+	}
+	wantContains := "/L/Caches/JetBrains/GoLand2024.1,/L/Caches/JetBrains/IntelliJIdea2024.1"
+	if c := strings.Join(got[len(got)-1].Contains, ","); c != wantContains {
+		t.Errorf("expected the shrunk ancestor to list its kept descendants, got %q", c)
+	}
+	for _, tgt := range got[:len(got)-1] {
+		if len(tgt.Contains) != 0 {
+			t.Errorf("%s: unexpected Contains %v", tgt.Path, tgt.Contains)
+		}
+	}
+	if OverlapSize(overlaps) != 950 {
+		t.Errorf("overlap size = %d, want 950", OverlapSize(overlaps))
+	}
+
+	var total int64
+	for _, tgt := range got {
+		total += tgt.Size
+	}
+	if total != 1050 {
+		t.Errorf("de-duplicated total = %d, want 1050", total)
+	}
+}
+
+func TestResolveOverlaps_FullyCoveredAncestorDropped(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Caches/go-build", Size: 500, Category: "System Junk"},
+		{Path: "/L/Caches/go-build/", Size: 500, Category: "Go"},
+	}
+
+	got, _ := ResolveOverlaps(targets, DefaultPrecedence)
+	if len(got) != 1 || got[0].Category != "Go" {
+		t.Errorf("expected only the Go target, got %+v", got)
+	}
+}
+
+func TestResolveOverlaps_GenericDescendantDropped(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/home/p/node_modules", Size: 400, Category: "Node.js"},
+		{Path: "/home/p/node_modules/big.tar", Size: 300, Category: "Large & Old Files"},
+		{Path: "/home/p/node_modules-backup", Size: 10, Category: "Large & Old Files"},
+	}
+
+	got, overlaps := ResolveOverlaps(targets, DefaultPrecedence)
+	sizes := sizesByPath(got)
+
+	if _, ok := sizes["Large & Old Files:/home/p/node_modules/big.tar"]; ok {
+		t.Error("expected descendant of a higher-precedence target to be dropped")
+	}
+	if sizes["Node.js:/home/p/node_modules"] != 400 {
+		t.Error("ancestor should keep its full size")
+	}
+	if sizes["Large & Old Files:/home/p/node_modules-backup"] != 10 {
+		t.Error("sibling with a shared name prefix is not nested and must be kept")
+	}
+	if len(overlaps) != 1 || overlaps[0].Owner != "Node.js" {
+		t.Errorf("unexpected overlaps: %+v", overlaps)
+	}
+}
+
+func TestResolveOverlaps_CommandTargetsUntouched(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "docker build cache", Size: 10, Category: "Docker", Action: scanner.CommandAction("docker", "builder", "prune")},
+		{Path: "docker build cache", Size: 10, Category: "Docker", Action: scanner.CommandAction("docker", "builder", "prune")},
+	}
+	got, overlaps := ResolveOverlaps(targets, DefaultPrecedence)
+	if len(got) != 2 || len(overlaps) != 0 {
+		t.Errorf("command targets must not be compared, got %d targets, %d overlaps", len(got), len(overlaps))
+	}
+}
+
+func TestScanGrouped_ResolvesAcrossCategories(t *testing.T) {
+	e := New()
+	e.Register(&mockScanner{name: "Xcode Junk", targets: []scanner.Target{
+		{Path: "/D/A", Size: 100, Category: "Xcode Junk"},
+		{Path: "/X/DerivedData", Size: 50, Category: "Xcode Junk"},
+	}})
+	e.Register(&mockScanner{name: "iOS Simulators", targets: []scanner.Target{
+		{Path: "/D/A", Size: 100, Category: "iOS Simulators"},
+	}})
+
+	results := e.ScanGrouped(context.Background())
+
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Category] = len(r.Targets)
+	}
+	if counts["Xcode Junk"] != 1 || counts["iOS Simulators"] != 1 {
+		t.Errorf("unexpected grouping after overlap resolution: %v", counts)
+	}
+}
+
+func TestScanTargets_SelectedCategories(t *testing.T) {
+	e := New()
+	e.Register(&mockScanner{name: "System Junk", targets: []scanner.Target{
+		{Path: "/L/Caches/pip", Size: 50, Category: "System Junk"},
+	}})
+	e.Register(&mockScanner{name: "Python", targets: []scanner.Target{
+		{Path: "/L/Caches/pip", Size: 50, Category: "Python"},
+	}})
+
+	got, overlaps, err := e.ScanTargets(context.Background(), []string{"System Junk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(overlaps) != 0 {
+		t.Errorf("scanning one category should not resolve against unscanned ones: %+v %+v", got, overlaps)
+	}
+
+	got, overlaps, err = e.ScanTargets(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Category != "Python" || len(overlaps) != 1 {
+		t.Errorf("expected Python to own the pip cache, got %+v", got)
+	}
+}
+
+func TestSetPrecedence(t *testing.T) {
+	e := New()
+	e.SetPrecedence([]string{"System Junk", "Python"})
+	got, _ := e.ResolveOverlaps([]scanner.Target{
+		{Path: "/L/Caches/pip", Size: 50, Category: "Python"},
+		{Path: "/L/Caches/pip", Size: 50, Category: "System Junk"},
+	})
+	if len(got) != 1 || got[0].Category != "System Junk" {
+		t.Errorf("expected custom precedence to win, got %+v", got)
+	}
+}
+
+func TestResolveOverlaps_ShrinksUsage(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Caches", Size: 1000, Category: "System Junk",
+			Usage: utils.Usage{Apparent: 1000, Allocated: 400}},
+		{Path: "/L/Caches/pip", Size: 600, Category: "Python",
+			Usage: utils.Usage{Apparent: 600, Allocated: 100}},
+	}
+
+	got, _ := ResolveOverlaps(targets, DefaultPrecedence)
+	anc := got[len(got)-1]
+	if anc.Path != "/L/Caches" {
+		t.Fatalf("expected shrunk ancestor last, got %+v", got)
+	}
+	if anc.Usage.Apparent != 400 || anc.Usage.Allocated != 300 {
+		t.Errorf("ancestor usage = %+v, want {400 300}", anc.Usage)
+	}
+}
+
+func TestDefaultPrecedence_CoversRegistry(t *testing.T) {
+	ranked := make(map[string]bool)
+	for _, name := range DefaultPrecedence {
+		ranked[name] = true
+	}
+	for _, info := range scanner.Registry() {
+		if !ranked[info.Name] {
+			t.Errorf("built-in scanner %q is missing from DefaultPrecedence", info.Name)
+		}
+	}
+}
+
+func TestResolveOverlaps_ContainsSkipsDroppedDescendants(t *testing.T) {
+	targets := []scanner.Target{
+		{Path: "/L/Caches", Size: 1000, Category: "Large & Old Files"},
+		{Path: "/L/Caches/Google", Size: 400, Category: "System Junk"},
+		{Path: "/L/Caches/Google/Chrome", Size: 400, Category: "Browser Cache"},
+	}
+
+	got, _ := ResolveOverlaps(targets, DefaultPrecedence)
+	sizes := sizesByPath(got)
+
+	if _, ok := sizes["System Junk:/L/Caches/Google"]; ok {
+		t.Errorf("expected the fully covered System Junk target to be dropped, got %v", sizes)
+	}
+	for _, tgt := range got {
+		if tgt.Path == "/L/Caches" && strings.Join(tgt.Contains, ",") != "/L/Caches/Google/Chrome" {
+			t.Errorf("expected only the kept descendant in Contains, got %v", tgt.Contains)
+		}
+	}
+}
+
+func TestScanTargets_ExcludedDescendantStaysInContains(t *testing.T) {
+	e := New()
+	e.Register(&mockScanner{name: "System Junk", targets: []scanner.Target{
+		{Path: "/L/Caches/Google", Size: 1000, Category: "System Junk"},
+	}})
+	e.Register(&mockScanner{name: "Browser Cache", targets: []scanner.Target{
+		{Path: "/L/Caches/Google/Chrome", Size: 600, Category: "Browser Cache"},
+	}})
+	e.SetExcludeFunc(func(path string) bool { return path == "/L/Caches/Google/Chrome" })
+
+	got, overlaps, err := e.ScanTargets(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != "/L/Caches/Google" {
+		t.Fatalf("expected only the System Junk target, got %+v", got)
+	}
+	if got[0].Size != 400 || strings.Join(got[0].Contains, ",") != "/L/Caches/Google/Chrome" {
+		t.Errorf("expected the ancestor to shrink and list the excluded path, got %+v", got[0])
+	}
+	if len(overlaps) != 0 {
+		t.Errorf("expected no overlaps reported for excluded paths, got %+v", overlaps)
+	}
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+)
+
+// ScanStatus represents the state of a scanner in the progress callback.
+type ScanStatus int
+
+const (
+	ScanWaiting ScanStatus = iota
+	ScanStarted
+	ScanDone
+	// ScanFound reports a target the scanner found while still running.
+	ScanFound
+	// ScanMeasured reports bytes the scanner has sized or walked so far.
+	ScanMeasured
+	// ScanResolved reports a category's final targets once overlaps
+	// between scanners are resolved, after every scanner is done.
+	ScanResolved
+)
+
+// measureInterval limits how often ScanMeasured is sent per scanner.
+const measureInterval = 100 * time.Millisecond
+
+// ScanProgress is sent to the progress callback for each scanner event.
+// Items, Size and Bytes are the scanner's running totals: targets found,
+// their size, and bytes measured. On ScanDone they describe the scanner's
+// Targets, which supersede the targets reported along the way but may
+// still count bytes another scanner also claims. On ScanResolved they
+// describe the category's targets as the scan returns them.
+type ScanProgress struct {
+	Name    string
+	Status  ScanStatus
+	Target  *scanner.Target
+	Items   int
+	Size    int64
+	Bytes   int64
+	Targets []scanner.Target
+	Error   error
+}
+
+// progressSink turns one scanner's incremental reports into ScanProgress
+// events. A nil sink discards everything.
+type progressSink struct {
+	name    string
+	fn      func(ScanProgress)
+	exclude func(string) bool
+
+	mu       sync.Mutex
+	closed   bool
+	items    int
+	size     int64
+	bytes    int64
+	lastSent time.Time
+}
+
+// newProgressSink returns a sink for the scanner named name, or nil when
+// fn is nil.
+func (e *Engine) newProgressSink(name string, fn func(ScanProgress)) *progressSink {
+	if fn == nil {
+		return nil
+	}
+	return &progressSink{name: name, fn: fn, exclude: e.excludeFunc}
+}
+
+func (p *progressSink) Found(t scanner.Target) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || (p.exclude != nil && p.exclude(t.Path)) {
+		return
+	}
+	p.items++
+	p.size += t.Size
+	p.sendLocked(ScanProgress{Status: ScanFound, Target: &t})
+}
+
+func (p *progressSink) Measured(bytes int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.bytes += bytes
+	if now := time.Now(); now.Sub(p.lastSent) >= measureInterval {
+		p.lastSent = now
+		p.sendLocked(ScanProgress{Status: ScanMeasured})
+	}
+}
+
+// send delivers ev with the sink's running totals.
+func (p *progressSink) send(ev ScanProgress) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sendLocked(ev)
+}
+
+func (p *progressSink) sendLocked(ev ScanProgress) {
+	ev.Name = p.name
+	ev.Items, ev.Size, ev.Bytes = p.items, p.size, p.bytes
+	p.fn(ev)
+}
+
+// close stops further reports, such as those of a scanner that was
+// abandoned after its timeout and is still running.
+func (p *progressSink) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+}
+
+// done sends the ScanDone event with the scanner's final targets.
+func (p *progressSink) done(targets []scanner.Target, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.items, p.size = len(targets), 0
+	for _, t := range targets {
+		p.size += t.Size
+	}
+	p.sendLocked(ScanProgress{Status: ScanDone, Targets: targets, Error: err})
+}
+
+// ScanStream is a scan running in the background. Read Events until it is
+// closed, then call Results.
+type ScanStream struct {
+	Events <-chan ScanProgress
+
+	done    chan struct{}
+	results []ScanResult
+}
+
+// Results waits for the scan to finish and returns what
+// ScanGroupedWithProgress would have.
+func (s *ScanStream) Results() []ScanResult {
+	<-s.done
+	return s.results
+}
+
+// Stream starts scanning with every registered scanner, at most
+// concurrency at a time, and delivers each progress event on the returned
+// stream's Events channel. The channel is closed when the scan completes.
+// The caller must keep reading it, or the scanners stall.
+func (e *Engine) Stream(ctx context.Context, concurrency int) *ScanStream {
+	events := make(chan ScanProgress, 64)
+	st := &ScanStream{Events: events, done: make(chan struct{})}
+	go func() {
+		st.results = e.ScanGroupedWithProgress(ctx, concurrency, func(p ScanProgress) {
+			events <- p
+		})
+		close(events)
+		close(st.done)
+	}()
+	return st
+}
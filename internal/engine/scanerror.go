@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Phase names the step of a scan that failed.
+type Phase string
+
+const (
+	// PhaseScan is the scanner's own Scan call.
+	PhaseScan Phase = "scan"
+	// PhaseLoad is loading a scanner before the scan, such as starting a
+	// plugin.
+	PhaseLoad Phase = "load"
+)
+
+// ScanError reports a scanner that failed or ran out of time. The targets
+// of the other scanners are unaffected.
+type ScanError struct {
+	Scanner string
+	Phase   Phase
+	Err     error
+	// Timeout is the deadline the scanner was given, set when it expired.
+	Timeout time.Duration
+}
+
+func (e *ScanError) Error() string {
+	if e.TimedOut() {
+		return fmt.Sprintf("%s: timed out after %s", e.Scanner, e.Timeout)
+	}
+	return fmt.Sprintf("%s: %s failed: %v", e.Scanner, e.Phase, e.Err)
+}
+
+func (e *ScanError) Unwrap() error { return e.Err }
+
+// TimedOut reports whether the scanner was stopped by its own timeout.
+func (e *ScanError) TimedOut() bool {
+	return e.Timeout > 0 && errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// ScanErrors collects the scanners that failed during one scan.
+type ScanErrors []*ScanError
+
+func (es ScanErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d scanner(s) failed: %s", len(es), strings.Join(msgs, "; "))
+}
+
+func (es ScanErrors) Unwrap() []error {
+	errs := make([]error, len(es))
+	for i, e := range es {
+		errs[i] = e
+	}
+	return errs
+}
+
+// AsScanErrors returns the scanner failures wrapped in err, or nil when err
+// carries none.
+func AsScanErrors(err error) ScanErrors {
+	var es ScanErrors
+	if errors.As(err, &es) {
+		return es
+	}
+	var se *ScanError
+	if errors.As(err, &se) {
+		return ScanErrors{se}
+	}
+	return nil
+}
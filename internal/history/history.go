@@ -1,21 +1,60 @@
 package history
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 // Entry represents a single cleanup operation recorded in the history.
 type Entry struct {
-	Timestamp  time.Time `json:"timestamp"`
-	Category   string    `json:"category"`
-	Items      int       `json:"items"`
-	BytesFreed int64     `json:"bytes_freed"`
-	Method     string    `json:"method"` // "trash" or "permanent"
+	Timestamp time.Time `json:"timestamp"`
+	Category  string    `json:"category"`
+	Items     int       `json:"items"`
+	// BytesFreed is the estimated size of what was cleaned, whether it was
+	// moved to Trash or removed for good.
+	BytesFreed int64 `json:"bytes_freed"`
+	// BytesTrashed is the part of BytesFreed that was moved to Trash and
+	// only frees space once the Trash is emptied.
+	BytesTrashed int64  `json:"bytes_trashed,omitempty"`
+	Method       string `json:"method"` // "trash", "permanent" or "command"
+}
+
+// Item records what happened to a single path during a run.
+type Item struct {
+	Path     string `json:"path"`
+	Category string `json:"category,omitempty"`
+	Size     int64  `json:"size"`
+	Method   string `json:"method"`
+	// Trashed is where a trashed item went inside the Trash.
+	Trashed string `json:"trashed,omitempty"`
+	// Error is set when the item could not be cleaned.
+	Error string `json:"error,omitempty"`
+}
+
+// Run is one cleanup run and one line of the history log: a summary entry
+// per category and method, and a record of every item it touched.
+type Run struct {
+	// ID is the run's Trash manifest ID when it has one, so that it can be
+	// restored; otherwise it is derived from Timestamp.
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command,omitempty"`
+	// BytesMeasured is how much free disk space grew over the run,
+	// measured with statfs.
+	BytesMeasured int64   `json:"bytes_measured,omitempty"`
+	Entries       []Entry `json:"entries"`
+	Items         []Item  `json:"items,omitempty"`
 }
 
 // CategoryStats holds aggregate statistics for a single category.
@@ -32,70 +71,335 @@ type Stats struct {
 	Recent        []Entry                  `json:"recent"`
 }
 
-// History manages the cleanup history file.
+// idLayout derives run IDs from timestamps, as manifest run IDs are.
+const idLayout = "20060102-150405"
+
+var (
+	retentionMu sync.RWMutex
+	retention   time.Duration
+)
+
+// SetRetention sets how long histories created afterwards keep runs. Older
+// runs are dropped the next time a run is recorded. Zero, the default,
+// keeps every run.
+func SetRetention(d time.Duration) {
+	retentionMu.Lock()
+	retention = d
+	retentionMu.Unlock()
+}
+
+// History manages the cleanup history: an append-only log with one JSON
+// run per line. Writers take an advisory lock on a sidecar file, so runs
+// recorded by concurrent processes, such as a scheduled clean and an
+// interactive one, are never lost.
 type History struct {
 	path string
+	// legacy is the JSON array file used before the log; it is migrated
+	// into the log the first time the history is used.
+	legacy    string
+	retention time.Duration
+	now       func() time.Time
 }
 
 // New creates a new History that reads/writes the given file path.
 func New(path string) *History {
-	return &History{path: path}
+	retentionMu.RLock()
+	defer retentionMu.RUnlock()
+	h := &History{path: path, retention: retention, now: time.Now}
+	if legacy := filepath.Join(filepath.Dir(path), "history.json"); legacy != path {
+		h.legacy = legacy
+	}
+	return h
 }
 
 // DefaultPath returns the default history file location:
-// ~/.local/share/macbroom/history.json
+// ~/.local/share/macbroom/history.jsonl
 func DefaultPath() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
-		return "history.json"
+		return "history.jsonl"
 	}
-	return filepath.Join(home, ".local", "share", "macbroom", "history.json")
+	return filepath.Join(home, ".local", "share", "macbroom", "history.jsonl")
 }
 
-// Record appends a cleanup entry to the history file.
+// Record appends a run consisting of the single entry e.
 func (h *History) Record(e Entry) error {
-	entries, err := h.Load()
-	if err != nil && !os.IsNotExist(err) {
-		// If the file simply doesn't exist, start fresh.
-		// For other errors (e.g., corrupt JSON), still start fresh to avoid blocking.
-		entries = nil
-	}
-
-	entries = append(entries, e)
+	return h.RecordRun(Run{Timestamp: e.Timestamp, Entries: []Entry{e}})
+}
 
-	data, err := json.MarshalIndent(entries, "", "  ")
+// RecordRun appends r to the log, first dropping runs older than the
+// retention period. A zero Timestamp is set to now and entries without a
+// Timestamp take the run's.
+func (h *History) RecordRun(r Run) error {
+	if r.Timestamp.IsZero() {
+		r.Timestamp = h.now()
+	}
+	if r.ID == "" {
+		r.ID = r.Timestamp.Format(idLayout)
+	}
+	for i := range r.Entries {
+		if r.Entries[i].Timestamp.IsZero() {
+			r.Entries[i].Timestamp = r.Timestamp
+		}
+	}
+	line, err := json.Marshal(r)
 	if err != nil {
 		return fmt.Errorf("failed to marshal history: %w", err)
 	}
 
-	dir := filepath.Dir(h.path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
 		return fmt.Errorf("failed to create history directory: %w", err)
 	}
+	unlock, err := h.lock(unix.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	if err := os.WriteFile(h.path, data, 0o644); err != nil {
-		return fmt.Errorf("failed to write history file: %w", err)
+	if err := h.migrate(); err != nil {
+		return err
+	}
+	if err := h.prune(); err != nil {
+		return err
 	}
 
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	// A single write of the whole line keeps it intact even for readers
+	// that do not take the lock.
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
 	return nil
 }
 
-// Load reads all entries from the history file.
+// Load reads all entries from the history, oldest run first.
 func (h *History) Load() ([]Entry, error) {
-	data, err := os.ReadFile(h.path)
+	runs, err := h.LoadRuns()
 	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, r := range runs {
+		for _, e := range r.Entries {
+			if e.Timestamp.IsZero() {
+				e.Timestamp = r.Timestamp
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// LoadRuns reads every run from the history, oldest first. Malformed lines,
+// such as one cut short by a crash, are skipped; a file without a single
+// readable run is reported as corrupt. When there is no history yet the
+// error satisfies os.IsNotExist.
+func (h *History) LoadRuns() ([]Run, error) {
+	if h.needsMigration() {
+		if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %w", err)
+		}
+		unlock, err := h.lock(unix.LOCK_EX)
+		if err != nil {
+			return nil, err
+		}
+		err = h.migrate()
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := os.Stat(h.path); err != nil {
 		if os.IsNotExist(err) {
 			return nil, err
 		}
 		return nil, fmt.Errorf("failed to read history file: %w", err)
 	}
+	unlock, err := h.lock(unix.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return readRuns(h.path)
+}
 
+// readRuns parses the log at path.
+func readRuns(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	defer f.Close()
+
+	var runs []Run
+	var bad int
+	var parseErr error
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var run Run
+			if jerr := json.Unmarshal(line, &run); jerr != nil {
+				bad++
+				parseErr = jerr
+			} else {
+				runs = append(runs, run)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+	}
+	if len(runs) == 0 && bad > 0 {
+		return nil, fmt.Errorf("failed to parse history file: %w", parseErr)
+	}
+	return runs, nil
+}
+
+// lock takes an advisory lock of kind how (unix.LOCK_SH or LOCK_EX) on
+// the sidecar lock file and returns the function that releases it. The
+// log itself is not locked because pruning replaces it.
+func (h *History) lock(how int) (func(), error) {
+	f, err := os.OpenFile(h.path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history lock: %w", err)
+	}
+	if err := unix.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock history: %w", err)
+	}
+	return func() {
+		_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// needsMigration reports whether a legacy history exists and the log
+// does not.
+func (h *History) needsMigration() bool {
+	if h.legacy == "" {
+		return false
+	}
+	if _, err := os.Stat(h.path); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	_, err := os.Stat(h.legacy)
+	return err == nil
+}
+
+// migrate converts the legacy JSON array history into the log and renames
+// it to history.json.migrated. Entries recorded by the same clean share a
+// timestamp and become one run. The caller must hold the exclusive lock.
+func (h *History) migrate() error {
+	if !h.needsMigration() {
+		return nil
+	}
+	data, err := os.ReadFile(h.legacy)
+	if err != nil {
+		return fmt.Errorf("failed to read legacy history: %w", err)
+	}
 	var entries []Entry
 	if err := json.Unmarshal(data, &entries); err != nil {
-		return nil, fmt.Errorf("failed to parse history file: %w", err)
+		// Record used to start over on a corrupt file; do the same, but
+		// keep the file aside.
+		entries = nil
 	}
 
-	return entries, nil
+	var runs []Run
+	for _, e := range entries {
+		if n := len(runs); n > 0 && runs[n-1].Timestamp.Equal(e.Timestamp) {
+			runs[n-1].Entries = append(runs[n-1].Entries, e)
+			continue
+		}
+		runs = append(runs, Run{ID: e.Timestamp.Format(idLayout), Timestamp: e.Timestamp, Entries: []Entry{e}})
+	}
+	if err := writeRuns(h.path, runs); err != nil {
+		return err
+	}
+	if err := os.Rename(h.legacy, h.legacy+".migrated"); err != nil {
+		return fmt.Errorf("failed to rename legacy history: %w", err)
+	}
+	return nil
+}
+
+// prune drops runs older than the retention period. It only rewrites the
+// log when its first run has expired. The caller must hold the exclusive
+// lock.
+func (h *History) prune() error {
+	if h.retention <= 0 {
+		return nil
+	}
+	cutoff := h.now().Add(-h.retention)
+	first, ok := firstRun(h.path)
+	if !ok || !first.Timestamp.Before(cutoff) {
+		return nil
+	}
+	runs, err := readRuns(h.path)
+	if err != nil {
+		return err
+	}
+	kept := runs[:0]
+	for _, r := range runs {
+		if !r.Timestamp.Before(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	return writeRuns(h.path, kept)
+}
+
+// firstRun returns the first readable run in the log at path.
+func firstRun(path string) (Run, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Run{}, false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var r Run
+		if json.Unmarshal(sc.Bytes(), &r) == nil {
+			return r, true
+		}
+	}
+	return Run{}, false
+}
+
+// writeRuns replaces the log at path with runs, via a temporary file so
+// that a crash leaves either the old or the new log.
+func writeRuns(path string, runs []Run) error {
+	var buf bytes.Buffer
+	for _, r := range runs {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
 }
 
 // Stats computes aggregate statistics from the history.
@@ -3,6 +3,7 @@ package history
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -250,7 +251,7 @@ func TestDefaultPath(t *testing.T) {
 		t.Fatalf("failed to get home dir: %v", err)
 	}
 
-	expected := filepath.Join(home, ".local", "share", "macbroom", "history.json")
+	expected := filepath.Join(home, ".local", "share", "macbroom", "history.jsonl")
 	if path != expected {
 		t.Errorf("expected default path %q, got %q", expected, path)
 	}
@@ -298,3 +299,168 @@ func TestRecordCreatesParentDir(t *testing.T) {
 		t.Fatalf("expected 1 entry, got %d", len(entries))
 	}
 }
+
+func TestRecordRun(t *testing.T) {
+	h := New(filepath.Join(t.TempDir(), "history.jsonl"))
+	ts := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	run := Run{
+		ID:            "20260301-090000",
+		Timestamp:     ts,
+		Command:       "clean",
+		BytesMeasured: 1 << 20,
+		Entries: []Entry{
+			{Category: "Homebrew", Items: 2, BytesFreed: 3 << 20, BytesTrashed: 3 << 20, Method: "trash"},
+		},
+		Items: []Item{
+			{Path: "/cache/a", Category: "Homebrew", Size: 1 << 20, Method: "trash", Trashed: "/Users/me/.Trash/a"},
+			{Path: "/cache/b", Category: "Homebrew", Size: 2 << 20, Method: "trash", Trashed: "/Users/me/.Trash/b"},
+			{Path: "/cache/c", Category: "Homebrew", Size: 5, Method: "trash", Error: "permission denied"},
+		},
+	}
+	if err := h.RecordRun(run); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Record(Entry{Timestamp: ts.Add(time.Hour), Category: "Go", Items: 1, BytesFreed: 10, Method: "command"}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := h.LoadRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	got := runs[0]
+	if got.ID != run.ID || got.BytesMeasured != 1<<20 || len(got.Items) != 3 || got.Items[2].Error == "" {
+		t.Errorf("run did not round-trip: %+v", got)
+	}
+	if got.Entries[0].BytesTrashed != 3<<20 || !got.Entries[0].Timestamp.Equal(ts) {
+		t.Errorf("expected entry to keep trashed bytes and take the run's timestamp, got %+v", got.Entries[0])
+	}
+	if runs[1].ID != "20260301-100000" {
+		t.Errorf("expected an ID derived from the timestamp, got %q", runs[1].ID)
+	}
+
+	entries, err := h.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected one entry per run, got %d", len(entries))
+	}
+}
+
+func TestRecordRun_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Separate History values, as separate processes would have.
+			if err := New(path).Record(Entry{Category: "System Junk", Items: i, Method: "trash"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := New(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		t.Errorf("expected %d entries, got %d", n, len(entries))
+	}
+}
+
+func TestLoadRuns_SkipsTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := New(path)
+	if err := h.Record(Entry{Category: "Go", Items: 1, Method: "trash"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"20260301-0900`) // cut short by a crash
+	f.Close()
+
+	runs, err := h.LoadRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("expected the torn line to be skipped, got %d runs", len(runs))
+	}
+}
+
+func TestMigrateLegacyHistory(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "history.json")
+	ts := time.Date(2024, 2, 14, 10, 30, 0, 0, time.UTC)
+	data := `[
+  {"timestamp": "2024-02-14T10:30:00Z", "category": "System Junk", "items": 5, "bytes_freed": 100, "method": "trash"},
+  {"timestamp": "2024-02-14T10:30:00Z", "category": "Homebrew", "items": 2, "bytes_freed": 50, "method": "trash"},
+  {"timestamp": "2024-02-15T08:00:00Z", "category": "Go", "items": 1, "bytes_freed": 10, "method": "command"}
+]`
+	if err := os.WriteFile(legacy, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(filepath.Join(dir, "history.jsonl"))
+	runs, err := h.LoadRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected entries sharing a timestamp to form one run, got %d runs", len(runs))
+	}
+	if len(runs[0].Entries) != 2 || !runs[0].Timestamp.Equal(ts) {
+		t.Errorf("unexpected first run %+v", runs[0])
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected legacy history to be moved aside, got %v", err)
+	}
+	if _, err := os.Stat(legacy + ".migrated"); err != nil {
+		t.Errorf("expected legacy history to be kept as .migrated: %v", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	h := New(path)
+	h.now = func() time.Time { return now }
+	for _, age := range []time.Duration{400 * 24 * time.Hour, 200 * 24 * time.Hour} {
+		if err := h.Record(Entry{Timestamp: now.Add(-age), Category: "Go", Method: "trash"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	SetRetention(365 * 24 * time.Hour)
+	defer SetRetention(0)
+	h = New(path)
+	h.now = func() time.Time { return now }
+	if err := h.Record(Entry{Timestamp: now, Category: "Go", Method: "trash"}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := h.LoadRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("expected the 400-day-old run to be dropped, got %d runs", len(runs))
+	}
+	for _, r := range runs {
+		if now.Sub(r.Timestamp) > 365*24*time.Hour {
+			t.Errorf("run %s is past retention", r.ID)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package history
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter selects runs and items from the history. Zero fields match
+// everything.
+type Filter struct {
+	// Since and Until bound the run's timestamp: Since is inclusive,
+	// Until exclusive.
+	Since time.Time
+	Until time.Time
+	// Category and Method match exactly, ignoring case.
+	Category string
+	Method   string
+	// Path matches items whose path contains it, ignoring case.
+	Path string
+	// MinSize matches items of at least this many bytes.
+	MinSize int64
+}
+
+// itemFilter reports whether f selects on item fields.
+func (f Filter) itemFilter() bool {
+	return f.Category != "" || f.Method != "" || f.Path != "" || f.MinSize > 0
+}
+
+// matchItem reports whether it passes the item filters of f.
+func (f Filter) matchItem(it Item) bool {
+	return (f.Category == "" || strings.EqualFold(it.Category, f.Category)) &&
+		(f.Method == "" || strings.EqualFold(it.Method, f.Method)) &&
+		(f.Path == "" || strings.Contains(strings.ToLower(it.Path), strings.ToLower(f.Path))) &&
+		it.Size >= f.MinSize
+}
+
+// matchEntry reports whether e passes the category and method filters.
+func (f Filter) matchEntry(e Entry) bool {
+	return (f.Category == "" || strings.EqualFold(e.Category, f.Category)) &&
+		(f.Method == "" || strings.EqualFold(e.Method, f.Method))
+}
+
+// Query returns the runs matching f, newest first. Each run keeps only the
+// items and entries that match. When f filters on item fields, runs
+// without a matching item are left out; runs recorded before items were
+// kept are matched on their entries instead, unless f filters on path or
+// size, which entries do not record.
+func (h *History) Query(f Filter) ([]Run, error) {
+	runs, err := h.LoadRuns()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []Run
+	for _, r := range runs {
+		if !f.Since.IsZero() && r.Timestamp.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && !r.Timestamp.Before(f.Until) {
+			continue
+		}
+
+		var items []Item
+		for _, it := range r.Items {
+			if f.matchItem(it) {
+				items = append(items, it)
+			}
+		}
+		var entries []Entry
+		for _, e := range r.Entries {
+			if f.matchEntry(e) {
+				entries = append(entries, e)
+			}
+		}
+
+		if f.itemFilter() {
+			legacy := len(r.Items) == 0 && f.Path == "" && f.MinSize == 0
+			if len(items) == 0 && !(legacy && len(entries) > 0) {
+				continue
+			}
+		}
+		r.Items, r.Entries = items, entries
+		out = append(out, r)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Timestamp.After(out[j].Timestamp)
+	})
+	return out, nil
+}
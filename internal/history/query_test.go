@@ -0,0 +1,77 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	h := New(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	runs := []Run{
+		{Timestamp: day(1), Command: "clean", Items: []Item{
+			{Path: "/Users/me/Library/Developer/Xcode/DerivedData/App-abc", Category: "Xcode Junk", Size: 2 << 30, Method: "trash"},
+			{Path: "/Users/me/Library/Caches/com.example", Category: "System Junk", Size: 10 << 20, Method: "permanent"},
+		}},
+		{Timestamp: day(5), Command: "clean", Items: []Item{
+			{Path: "/Users/me/project/node_modules", Category: "Node.js", Size: 500 << 20, Method: "trash"},
+		}},
+		// Recorded before items were kept.
+		{Timestamp: day(9), Entries: []Entry{{Category: "Xcode Junk", Items: 3, BytesFreed: 1 << 30, Method: "trash"}}},
+	}
+	for _, r := range runs {
+		if err := h.RecordRun(r); err != nil {
+			t.Fatalf("RecordRun: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		f     Filter
+		runs  []time.Time
+		items int
+	}{
+		{"all", Filter{}, []time.Time{day(9), day(5), day(1)}, 3},
+		{"since", Filter{Since: day(5)}, []time.Time{day(9), day(5)}, 1},
+		{"until", Filter{Until: day(5)}, []time.Time{day(1)}, 2},
+		{"category", Filter{Category: "xcode junk"}, []time.Time{day(9), day(1)}, 1},
+		{"method", Filter{Method: "permanent"}, []time.Time{day(1)}, 1},
+		{"path", Filter{Path: "deriveddata"}, []time.Time{day(1)}, 1},
+		{"min size", Filter{MinSize: 100 << 20}, []time.Time{day(5), day(1)}, 2},
+		{"no match", Filter{Path: "nowhere"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.Query(tt.f)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if len(got) != len(tt.runs) {
+				t.Fatalf("expected %d runs, got %d", len(tt.runs), len(got))
+			}
+			items := 0
+			for i, r := range got {
+				if !r.Timestamp.Equal(tt.runs[i]) {
+					t.Errorf("run %d: expected %v, got %v", i, tt.runs[i], r.Timestamp)
+				}
+				items += len(r.Items)
+			}
+			if items != tt.items {
+				t.Errorf("expected %d items, got %d", tt.items, items)
+			}
+		})
+	}
+}
+
+func TestQuery_NoHistory(t *testing.T) {
+	h := New(filepath.Join(t.TempDir(), "history.jsonl"))
+	runs, err := h.Query(Filter{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("expected no runs, got %d", len(runs))
+	}
+}
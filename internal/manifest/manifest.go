@@ -0,0 +1,208 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// idLayout formats run IDs; IDs sort chronologically as strings.
+const idLayout = "20060102-150405"
+
+// Item records where a single trashed path went.
+type Item struct {
+	Original   string `json:"original"`
+	Trashed    string `json:"trashed"`
+	Size       int64  `json:"size"`
+	Category   string `json:"category,omitempty"`
+	RestoredTo string `json:"restored_to,omitempty"`
+}
+
+// Manifest records every item moved to Trash by a single clean, uninstall
+// or dupes run so the run can be undone later.
+type Manifest struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	Items     []Item    `json:"items"`
+}
+
+// New creates an empty manifest for a run of command started now.
+func New(command string) *Manifest {
+	now := time.Now()
+	return &Manifest{
+		ID:        now.Format(idLayout),
+		Timestamp: now,
+		Command:   command,
+	}
+}
+
+// Add records that original was moved to trashed. Items without a known
+// Trash location cannot be restored and are ignored.
+func (m *Manifest) Add(original, trashed string, size int64, category string) {
+	if trashed == "" {
+		return
+	}
+	m.Items = append(m.Items, Item{
+		Original: original,
+		Trashed:  trashed,
+		Size:     size,
+		Category: category,
+	})
+}
+
+// Pending returns the number of items that have not been restored yet.
+func (m *Manifest) Pending() int {
+	var n int
+	for _, it := range m.Items {
+		if it.RestoredTo == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// TotalSize returns the combined size of all items in the manifest.
+func (m *Manifest) TotalSize() int64 {
+	var total int64
+	for _, it := range m.Items {
+		total += it.Size
+	}
+	return total
+}
+
+// Store reads and writes manifests as one JSON file per run.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a Store that keeps manifests in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultDir returns the default manifest directory:
+// ~/.local/share/macbroom/runs
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "runs"
+	}
+	return filepath.Join(home, ".local", "share", "macbroom", "runs")
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes m to the store. A new manifest whose ID is already taken by
+// another run is given a numeric suffix. Manifests without items are not
+// written.
+func (s *Store) Save(m *Manifest) error {
+	if len(m.Items) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	if existing, err := s.Load(m.ID); err == nil && !existing.Timestamp.Equal(m.Timestamp) {
+		base := m.ID
+		for i := 2; ; i++ {
+			m.ID = fmt.Sprintf("%s-%d", base, i)
+			if _, err := os.Stat(s.path(m.ID)); os.IsNotExist(err) {
+				break
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(s.path(m.ID), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest file: %w", err)
+	}
+	return nil
+}
+
+// Load reads the manifest for run id.
+func (s *Store) Load(id string) (*Manifest, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid run ID %q", id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no run with ID %q", id)
+		}
+		return nil, fmt.Errorf("failed to read manifest file: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// List returns all stored manifests, newest first. Unreadable files are
+// skipped.
+func (s *Store) List() ([]*Manifest, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest directory: %w", err)
+	}
+
+	var manifests []*Manifest
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		m, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Timestamp.After(manifests[j].Timestamp)
+	})
+	return manifests, nil
+}
+
+// Latest returns the most recent manifest that still has items to restore.
+func (s *Store) Latest() (*Manifest, error) {
+	manifests, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range manifests {
+		if m.Pending() > 0 {
+			return m, nil
+		}
+	}
+	return nil, errors.New("no runs to restore")
+}
+
+// SaveRun writes m to the default store and returns its run ID, or "" when
+// the run moved nothing to Trash or the manifest could not be written.
+func SaveRun(m *Manifest) string {
+	if len(m.Items) == 0 {
+		return ""
+	}
+	if err := NewStore(DefaultDir()).Save(m); err != nil {
+		return ""
+	}
+	return m.ID
+}
@@ -0,0 +1,203 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// trashFile creates original, moves it into trashDir and returns the
+// trashed location, mimicking what Finder does.
+func trashFile(t *testing.T, original, trashDir, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(original), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(original, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(trashDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	trashed := filepath.Join(trashDir, filepath.Base(original))
+	if err := os.Rename(original, trashed); err != nil {
+		t.Fatal(err)
+	}
+	return trashed
+}
+
+func TestStore_SaveLoadList(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	older := &Manifest{ID: "20260101-100000", Timestamp: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC), Command: "clean"}
+	older.Add("/a", "/trash/a", 10, "System Junk")
+	newer := &Manifest{ID: "20260102-100000", Timestamp: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC), Command: "dupes"}
+	newer.Add("/b", "/trash/b", 20, "")
+
+	for _, m := range []*Manifest{older, newer} {
+		if err := s.Save(m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	got, err := s.Load(older.ID)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got.Command != "clean" || len(got.Items) != 1 || got.Items[0].Category != "System Junk" {
+		t.Errorf("unexpected manifest: %+v", got)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != newer.ID {
+		t.Errorf("expected newest first, got %+v", list)
+	}
+}
+
+func TestStore_SaveSkipsEmptyAndUnknownLocations(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+
+	m := New("clean")
+	m.Add("/a", "", 10, "")
+	if err := s.Save(m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected no manifest for a run without restorable items, got %d files", len(entries))
+	}
+}
+
+func TestStore_SaveAvoidsIDCollision(t *testing.T) {
+	s := NewStore(t.TempDir())
+	ts := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	first := &Manifest{ID: "20260101-100000", Timestamp: ts, Command: "clean"}
+	first.Add("/a", "/trash/a", 1, "")
+	second := &Manifest{ID: "20260101-100000", Timestamp: ts.Add(time.Millisecond), Command: "dupes"}
+	second.Add("/b", "/trash/b", 1, "")
+
+	if err := s.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != "20260101-100000-2" {
+		t.Errorf("expected suffixed ID, got %q", second.ID)
+	}
+
+	// Re-saving the same run keeps its ID.
+	if err := s.Save(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != "20260101-100000" {
+		t.Errorf("re-save changed ID to %q", first.ID)
+	}
+}
+
+func TestStore_LoadRejectsPaths(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, err := s.Load("../history"); err == nil {
+		t.Error("expected error for run ID containing a path separator")
+	}
+}
+
+func TestStore_LatestSkipsFullyRestored(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	pending := &Manifest{ID: "1", Timestamp: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	pending.Add("/a", "/trash/a", 1, "")
+	done := &Manifest{ID: "2", Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}
+	done.Add("/b", "/trash/b", 1, "")
+	done.Items[0].RestoredTo = "/b"
+
+	_ = s.Save(pending)
+	_ = s.Save(done)
+
+	got, err := s.Latest()
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if got.ID != "1" {
+		t.Errorf("expected run 1, got %s", got.ID)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	root := t.TempDir()
+	trashDir := filepath.Join(root, "Trash")
+
+	plain := filepath.Join(root, "cache", "data.bin")
+	collide := filepath.Join(root, "docs", "report.pdf")
+	gone := filepath.Join(root, "old.log")
+
+	m := New("clean")
+	m.Add(plain, trashFile(t, plain, trashDir, "plain"), 5, "")
+	m.Add(collide, trashFile(t, collide, filepath.Join(trashDir, "x"), "trashed"), 7, "")
+	m.Add(gone, filepath.Join(trashDir, "old.log"), 3, "")
+
+	// Parent directory was removed after the run, and a new file now
+	// occupies the second item's original path.
+	if err := os.RemoveAll(filepath.Join(root, "cache")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(collide, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes := Restore(m)
+
+	want := []Status{Restored, Renamed, Missing}
+	for i, o := range outcomes {
+		if o.Status != want[i] {
+			t.Errorf("item %d: status = %s, want %s (err %v)", i, o.Status, want[i], o.Err)
+		}
+	}
+
+	if data, err := os.ReadFile(plain); err != nil || string(data) != "plain" {
+		t.Errorf("expected %s restored, got %q, %v", plain, data, err)
+	}
+	renamed := filepath.Join(root, "docs", "report (restored).pdf")
+	if outcomes[1].Dest != renamed {
+		t.Errorf("renamed dest = %q, want %q", outcomes[1].Dest, renamed)
+	}
+	if data, _ := os.ReadFile(renamed); string(data) != "trashed" {
+		t.Errorf("expected trashed content at %s, got %q", renamed, data)
+	}
+	if data, _ := os.ReadFile(collide); string(data) != "new" {
+		t.Error("existing file at original path was overwritten")
+	}
+
+	if m.Pending() != 1 {
+		t.Errorf("expected 1 pending item after restore, got %d", m.Pending())
+	}
+
+	// A second restore leaves already-restored items alone.
+	again := Restore(m)
+	if again[0].Status != AlreadyRestored || again[1].Status != AlreadyRestored {
+		t.Errorf("expected already restored on second run, got %s, %s", again[0].Status, again[1].Status)
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	dir := t.TempDir()
+	venv := filepath.Join(dir, ".venv")
+	if got := freeName(venv); got != filepath.Join(dir, ".venv (restored)") {
+		t.Errorf("freeName(.venv) = %q", got)
+	}
+
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(filepath.Join(dir, "a (restored).txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := freeName(file); got != filepath.Join(dir, "a (restored 2).txt") {
+		t.Errorf("freeName(a.txt) = %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lu-zhengda/macbroom/internal/trash"
+)
+
+// Status describes what happened to a single item during a restore.
+type Status int
+
+const (
+	// Restored means the item was moved back to its original path.
+	Restored Status = iota
+	// Renamed means the original path was taken, so the item was restored
+	// next to it under a new name.
+	Renamed
+	// Missing means the item is no longer in the Trash, usually because
+	// the Trash was emptied.
+	Missing
+	// AlreadyRestored means an earlier restore already put the item back.
+	AlreadyRestored
+	// Failed means the item could not be moved back.
+	Failed
+)
+
+func (s Status) String() string {
+	switch s {
+	case Restored:
+		return "restored"
+	case Renamed:
+		return "renamed"
+	case Missing:
+		return "missing"
+	case AlreadyRestored:
+		return "already restored"
+	default:
+		return "failed"
+	}
+}
+
+// Outcome reports the result of restoring a single item.
+type Outcome struct {
+	Item   Item
+	Dest   string
+	Status Status
+	Err    error
+}
+
+// Restore moves every pending item in m from the Trash back to where it came
+// from and marks it restored. When the original path is occupied the item is
+// restored alongside it as "name (restored)". The caller should save m
+// afterwards so that restored items are not restored twice.
+func Restore(m *Manifest) []Outcome {
+	outcomes := make([]Outcome, 0, len(m.Items))
+	for i := range m.Items {
+		it := &m.Items[i]
+		o := Outcome{Item: *it}
+
+		switch {
+		case it.RestoredTo != "":
+			o.Status = AlreadyRestored
+			o.Dest = it.RestoredTo
+		case !exists(it.Trashed):
+			o.Status = Missing
+		default:
+			o.Dest, o.Status, o.Err = restoreItem(it.Original, it.Trashed)
+			if o.Err == nil {
+				it.RestoredTo = o.Dest
+			}
+		}
+		outcomes = append(outcomes, o)
+	}
+	return outcomes
+}
+
+func restoreItem(original, trashed string) (string, Status, error) {
+	if err := os.MkdirAll(filepath.Dir(original), 0o755); err != nil {
+		return "", Failed, fmt.Errorf("failed to recreate %s: %w", filepath.Dir(original), err)
+	}
+
+	dest, status := original, Restored
+	if exists(original) {
+		dest, status = freeName(original), Renamed
+	}
+
+	if err := os.Rename(trashed, dest); err != nil {
+		return "", Failed, fmt.Errorf("failed to restore %s: %w", original, err)
+	}
+	// The item is back; a leftover .trashinfo would only confuse the
+	// desktop's own Trash view.
+	_ = trash.RemoveInfo(trashed)
+	return dest, status, nil
+}
+
+// freeName returns the first unused "name (restored)" or "name (restored N)"
+// path next to path, keeping its extension.
+func freeName(path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// Dotfiles such as ".venv" have no extension to preserve.
+		stem, ext = base, ""
+	}
+
+	for i := 1; ; i++ {
+		suffix := " (restored)"
+		if i > 1 {
+			suffix = fmt.Sprintf(" (restored %d)", i)
+		}
+		candidate := filepath.Join(dir, stem+suffix+ext)
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,420 @@
+// Package plugin runs external scanner programs. A plugin is any executable
+// named macbroom-scanner-* found in a plugins directory or on PATH. macbroom
+// talks to it by starting it once per request, writing one JSON Request to
+// its stdin and reading one JSON Response from its stdout:
+//
+//	{"protocol": 1, "command": "describe"}
+//	-> {"protocol": 1, "name": "Acme Build Cache", "description": "...", "risk": "safe", "clean": true}
+//
+//	{"protocol": 1, "command": "scan", "home": "/Users/me"}
+//	-> {"protocol": 1, "roots": ["/Users/me/.acme"], "targets": [{"path": "...", "id": "...", "size": 123}]}
+//
+//	{"protocol": 1, "command": "clean", "targets": [{"path": "...", "id": "..."}]}
+//	-> exit status 0 on success
+//
+// A plugin that reports "clean": false only returns absolute filesystem
+// paths, which macbroom moves to Trash itself. Its scan response must list
+// the directories those paths lie under as "roots"; macbroom refuses to
+// delete anything outside them. A plugin that reports
+// "clean": true is handed each selected target back with the clean command
+// and may use any string as a path. Errors are reported with a non-zero exit
+// status or an "error" field; anything written to stderr is included in the
+// message.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/trash"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// ProtocolVersion is the protocol version macbroom speaks. Responses must
+// carry the same version.
+const ProtocolVersion = 1
+
+// Prefix is the file name prefix that marks an executable as a plugin.
+const Prefix = "macbroom-scanner-"
+
+// maxOutput caps how much a plugin may write to stdout or stderr.
+const maxOutput = 32 << 20
+
+// Request is sent to a plugin on stdin.
+type Request struct {
+	Protocol int         `json:"protocol"`
+	Command  string      `json:"command"`
+	Home     string      `json:"home,omitempty"`
+	Targets  []TargetRef `json:"targets,omitempty"`
+}
+
+// TargetRef identifies a target in a clean request.
+type TargetRef struct {
+	Path string `json:"path"`
+	ID   string `json:"id,omitempty"`
+}
+
+// Response is read from a plugin's stdout. Describe responses fill the
+// name, description, risk and clean fields; scan responses fill Targets
+// and, for filesystem targets, Roots.
+type Response struct {
+	Protocol    int      `json:"protocol"`
+	Error       string   `json:"error,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Risk        string   `json:"risk,omitempty"`
+	Clean       bool     `json:"clean,omitempty"`
+	Targets     []Target `json:"targets,omitempty"`
+	Roots       []string `json:"roots,omitempty"`
+}
+
+// Target is a single reclaimable item reported by a plugin. DiskSize is the
+// allocated size and defaults to Size. Risk defaults to the plugin's risk.
+type Target struct {
+	Path        string    `json:"path"`
+	ID          string    `json:"id,omitempty"`
+	Size        int64     `json:"size"`
+	DiskSize    int64     `json:"disk_size,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Risk        string    `json:"risk,omitempty"`
+	ModTime     time.Time `json:"mod_time,omitempty"`
+	IsDir       bool      `json:"is_dir,omitempty"`
+}
+
+// Scanner adapts a plugin executable to scanner.Scanner.
+type Scanner struct {
+	path        string
+	name        string
+	description string
+	risk        scanner.RiskLevel
+	clean       bool
+	timeout     time.Duration
+	home        string
+
+	mu    sync.Mutex
+	roots []string
+
+	// run starts the plugin with stdin and returns its stdout. Defaults to
+	// runPlugin; override in tests.
+	run func(ctx context.Context, path string, stdin []byte) ([]byte, error)
+}
+
+// Load asks the plugin at path to describe itself. timeout bounds this and
+// every later scan and clean.
+func Load(ctx context.Context, path string, timeout time.Duration) (*Scanner, error) {
+	s := &Scanner{path: path, timeout: timeout, home: utils.HomeDir(), run: runPlugin}
+	if err := s.describe(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scanner) Name() string            { return s.name }
+func (s *Scanner) Description() string     { return s.description }
+func (s *Scanner) Risk() scanner.RiskLevel { return s.risk }
+func (s *Scanner) Path() string            { return s.path }
+
+// Roots implements scanner.Rooted with the roots declared in the last scan
+// response.
+func (s *Scanner) Roots() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.roots
+}
+
+func (s *Scanner) describe(ctx context.Context) error {
+	resp, err := s.call(ctx, Request{Command: "describe"})
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(resp.Name) == "" {
+		return fmt.Errorf("plugin %s did not report a name", filepath.Base(s.path))
+	}
+	s.name = resp.Name
+	s.description = resp.Description
+	s.clean = resp.Clean
+	s.risk = scanner.Moderate
+	if resp.Risk != "" {
+		risk, err := scanner.ParseRiskLevel(resp.Risk)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", filepath.Base(s.path), err)
+		}
+		s.risk = risk
+	}
+	return nil
+}
+
+// Scan runs the plugin's scan command. Targets that are malformed, or that
+// macbroom would have to delete but are not absolute paths under the
+// declared roots, are dropped. Returning such paths without declaring any
+// roots, or declaring a protected directory as a root, is an error.
+func (s *Scanner) Scan(ctx context.Context) ([]scanner.Target, error) {
+	resp, err := s.call(ctx, Request{Command: "scan", Home: s.home})
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	if !s.clean {
+		if roots, err = s.checkRoots(resp); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	s.roots = roots
+	s.mu.Unlock()
+
+	mode, _ := utils.SizingFrom(ctx)
+	targets := make([]scanner.Target, 0, len(resp.Targets))
+	for _, pt := range resp.Targets {
+		if pt.Path == "" || pt.Size < 0 || pt.DiskSize < 0 {
+			continue
+		}
+		risk := s.risk
+		if pt.Risk != "" {
+			r, err := scanner.ParseRiskLevel(pt.Risk)
+			if err != nil {
+				continue
+			}
+			risk = r
+		}
+		usage := utils.Usage{Apparent: pt.Size, Allocated: pt.DiskSize}
+		if pt.DiskSize == 0 {
+			usage.Allocated = pt.Size
+		}
+		desc := pt.Description
+		if desc == "" {
+			desc = s.description
+		}
+
+		t := scanner.Target{
+			Path:        pt.Path,
+			Size:        usage.In(mode),
+			Usage:       usage,
+			Category:    s.name,
+			Description: desc,
+			Risk:        risk,
+			ModTime:     pt.ModTime,
+			IsDir:       pt.IsDir,
+		}
+		if s.clean {
+			action, err := s.cleanAction(pt)
+			if err != nil {
+				return nil, err
+			}
+			t.Action = action
+		} else if !filepath.IsAbs(pt.Path) || !underAny(filepath.Clean(pt.Path), roots) {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// checkRoots validates the roots in a scan response. Each must be an
+// absolute directory the deletion guard accepts, which rules out / and the
+// home folder, and at least one is needed for any filesystem target.
+func (s *Scanner) checkRoots(resp Response) ([]string, error) {
+	name := filepath.Base(s.path)
+	roots := make([]string, 0, len(resp.Roots))
+	for _, r := range resp.Roots {
+		if !filepath.IsAbs(r) {
+			return nil, fmt.Errorf("plugin %s declared root %q, which is not an absolute path", name, r)
+		}
+		r = filepath.Clean(r)
+		if err := trash.Check(r, nil); err != nil {
+			return nil, fmt.Errorf("plugin %s declared an unsafe root: %w", name, err)
+		}
+		roots = append(roots, r)
+	}
+	if len(roots) == 0 && len(resp.Targets) > 0 {
+		return nil, fmt.Errorf("plugin %s returned paths without declaring their roots", name)
+	}
+	return roots, nil
+}
+
+// underAny reports whether path is one of roots or inside one of them.
+func underAny(path string, roots []string) bool {
+	for _, r := range roots {
+		if path == r || strings.HasPrefix(path, r+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanAction returns the command action that asks the plugin to clean pt.
+func (s *Scanner) cleanAction(pt Target) (*scanner.Action, error) {
+	req, err := json.Marshal(Request{
+		Protocol: ProtocolVersion,
+		Command:  "clean",
+		Targets:  []TargetRef{{Path: pt.Path, ID: pt.ID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode clean request: %w", err)
+	}
+	return &scanner.Action{Kind: scanner.ActionCommand, Command: []string{s.path}, Stdin: string(req), Timeout: s.timeout}, nil
+}
+
+// call sends req to the plugin within the scanner's timeout and decodes
+// its response.
+func (s *Scanner) call(ctx context.Context, req Request) (Response, error) {
+	name := filepath.Base(s.path)
+	req.Protocol = ProtocolVersion
+	in, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to encode %s request: %w", req.Command, err)
+	}
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	out, err := s.run(ctx, s.path, in)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return Response{}, fmt.Errorf("plugin %s timed out after %s", name, s.timeout)
+	}
+	if err != nil {
+		return Response{}, fmt.Errorf("plugin %s failed: %w", name, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return Response{}, fmt.Errorf("plugin %s returned invalid JSON: %w", name, err)
+	}
+	if resp.Protocol != ProtocolVersion {
+		return Response{}, fmt.Errorf("plugin %s speaks protocol %d, want %d", name, resp.Protocol, ProtocolVersion)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("plugin %s: %s", name, resp.Error)
+	}
+	return resp, nil
+}
+
+// runPlugin starts the executable at path, feeds it stdin and returns its
+// stdout. Output beyond maxOutput is an error, and stderr is folded into
+// the error when the plugin fails.
+func runPlugin(ctx context.Context, path string, stdin []byte) ([]byte, error) {
+	var stdout, stderr cappedBuffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait forever for grandchildren that inherited the pipes.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if stdout.overflow {
+		return nil, fmt.Errorf("output exceeds %d bytes", maxOutput)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w (%s)", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// cappedBuffer is a bytes.Buffer that stops growing at maxOutput.
+type cappedBuffer struct {
+	bytes.Buffer
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxOutput {
+		b.overflow = true
+		return 0, errors.New("plugin output too large")
+	}
+	return b.Buffer.Write(p)
+}
+
+// Discover returns the plugin executables in dirs followed by those on
+// pathEnv (a PATH-style list). When several share a file name, the first
+// one found wins, as with PATH lookup. Results are sorted by file name.
+func Discover(dirs []string, pathEnv string) []string {
+	seen := make(map[string]string)
+	search := append(append([]string(nil), dirs...), filepath.SplitList(pathEnv)...)
+	for _, dir := range search {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, Prefix) || len(name) == len(Prefix) {
+				continue
+			}
+			if _, dup := seen[name]; dup {
+				continue
+			}
+			full := filepath.Join(dir, name)
+			info, err := os.Stat(full)
+			if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			seen[name] = full
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, seen[name])
+	}
+	return paths
+}
+
+// LoadError reports a plugin that could not be loaded.
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e *LoadError) Error() string { return e.Err.Error() }
+func (e *LoadError) Unwrap() error { return e.Err }
+
+// LoadAll loads the plugins at paths concurrently, so startup waits at most
+// one timeout. Plugins that fail to load are reported in errs as
+// *LoadError and left out; the rest keep the order of paths.
+func LoadAll(ctx context.Context, paths []string, timeout time.Duration) (scanners []*Scanner, errs []error) {
+	loaded := make([]*Scanner, len(paths))
+	failed := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			loaded[i], failed[i] = Load(ctx, p, timeout)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for i := range paths {
+		if failed[i] != nil {
+			errs = append(errs, &LoadError{Path: paths[i], Err: failed[i]})
+			continue
+		}
+		scanners = append(scanners, loaded[i])
+	}
+	return scanners, errs
+}
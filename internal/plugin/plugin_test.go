@@ -0,0 +1,248 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// writePlugin writes an executable shell script named macbroom-scanner-name
+// into dir and returns its path.
+func writePlugin(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, Prefix+name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// fakeScanner returns a Scanner whose plugin answers each command with the
+// given response and records the requests it received.
+func fakeScanner(responses map[string]string, requests *[]Request) *Scanner {
+	return &Scanner{
+		path: "/opt/plugins/" + Prefix + "fake",
+		run: func(ctx context.Context, path string, stdin []byte) ([]byte, error) {
+			var req Request
+			if err := json.Unmarshal(stdin, &req); err != nil {
+				return nil, err
+			}
+			if requests != nil {
+				*requests = append(*requests, req)
+			}
+			return []byte(responses[req.Command]), nil
+		},
+	}
+}
+
+const describeJSON = `{"protocol": 1, "name": "Acme Cache", "description": "Acme build cache", "risk": "safe"}`
+
+func TestDiscover(t *testing.T) {
+	plugins := t.TempDir()
+	onPath := t.TempDir()
+
+	first := writePlugin(t, plugins, "acme", "")
+	writePlugin(t, onPath, "acme", "") // shadowed by the plugins dir
+	other := writePlugin(t, onPath, "zeta", "")
+	os.WriteFile(filepath.Join(onPath, Prefix+"noexec"), nil, 0o644)
+	os.WriteFile(filepath.Join(onPath, "unrelated"), nil, 0o755)
+	os.Mkdir(filepath.Join(onPath, Prefix+"dir"), 0o755)
+
+	got := Discover([]string{plugins}, onPath+string(os.PathListSeparator)+"/does/not/exist")
+	want := []string{first, other}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Discover = %v, want %v", got, want)
+	}
+}
+
+func TestLoadAndScan_RealExecutable(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	os.Mkdir(cache, 0o755)
+	path := writePlugin(t, dir, "acme", `
+req=$(cat)
+case "$req" in
+  *describe*) echo '`+describeJSON+`' ;;
+  *scan*) echo '{"protocol": 1, "roots": ["`+dir+`"], "targets": [{"path": "`+cache+`", "size": 4096, "disk_size": 1024, "is_dir": true}]}' ;;
+esac
+`)
+
+	s, err := Load(context.Background(), path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.Name() != "Acme Cache" || s.Risk() != scanner.Safe || s.Description() != "Acme build cache" {
+		t.Errorf("unexpected description: %q %v %q", s.Name(), s.Risk(), s.Description())
+	}
+
+	ctx := utils.WithSizing(context.Background(), utils.SizeAllocated, nil)
+	targets, err := s.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target, got %+v", targets)
+	}
+	got := targets[0]
+	if got.Path != cache || got.Category != "Acme Cache" || got.Size != 1024 || got.Usage.Apparent != 4096 || !got.IsFilesystem() {
+		t.Errorf("unexpected target %+v", got)
+	}
+	if roots := s.Roots(); len(roots) != 1 || roots[0] != dir {
+		t.Errorf("expected the declared roots, got %v", roots)
+	}
+}
+
+func TestScan_Timeout(t *testing.T) {
+	path := writePlugin(t, t.TempDir(), "slow", "exec sleep 10\n")
+	s := &Scanner{path: path, name: "Slow", timeout: 100 * time.Millisecond, run: runPlugin}
+
+	start := time.Now()
+	_, err := s.Scan(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("timeout was not enforced, took %s", time.Since(start))
+	}
+}
+
+func TestScan_FailureIncludesStderr(t *testing.T) {
+	path := writePlugin(t, t.TempDir(), "broken", "echo 'token expired' >&2\nexit 3\n")
+	s := &Scanner{path: path, name: "Broken", run: runPlugin}
+
+	_, err := s.Scan(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("expected stderr in error, got %v", err)
+	}
+}
+
+func TestLoad_ProtocolErrors(t *testing.T) {
+	tests := map[string]string{
+		"wrong version": `{"protocol": 2, "name": "X"}`,
+		"invalid json":  `not json`,
+		"no name":       `{"protocol": 1}`,
+		"bad risk":      `{"protocol": 1, "name": "X", "risk": "yolo"}`,
+		"error field":   `{"protocol": 1, "error": "not configured"}`,
+	}
+	for name, resp := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := fakeScanner(map[string]string{"describe": resp}, nil)
+			if err := s.describe(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestScan_FiltersInvalidTargets(t *testing.T) {
+	s := fakeScanner(map[string]string{
+		"describe": describeJSON,
+		"scan": `{"protocol": 1, "roots": ["/abs"], "targets": [
+			{"path": "/abs/ok", "size": 10},
+			{"path": "/elsewhere/outside", "size": 10},
+			{"path": "/abs/../escape", "size": 10},
+			{"path": "relative/path", "size": 10},
+			{"path": "", "size": 10},
+			{"path": "/abs/negative", "size": -1},
+			{"path": "/abs/badrisk", "size": 10, "risk": "yolo"},
+			{"path": "/abs/risky", "size": 10, "risk": "risky"}
+		]}`,
+	}, nil)
+	if err := s.describe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Path != "/abs/ok" || targets[1].Risk != scanner.Risky {
+		t.Errorf("unexpected targets %+v", targets)
+	}
+}
+
+func TestScan_RootErrors(t *testing.T) {
+	tests := map[string]string{
+		"no roots":      `{"protocol": 1, "targets": [{"path": "/abs/ok", "size": 10}]}`,
+		"relative root": `{"protocol": 1, "roots": ["abs"], "targets": [{"path": "/abs/ok", "size": 10}]}`,
+		"root of disk":  `{"protocol": 1, "roots": ["/"], "targets": [{"path": "/abs/ok", "size": 10}]}`,
+		"system root":   `{"protocol": 1, "roots": ["/usr/local"], "targets": [{"path": "/usr/local/ok", "size": 10}]}`,
+	}
+	for name, resp := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := fakeScanner(map[string]string{"describe": describeJSON, "scan": resp}, nil)
+			if err := s.describe(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Scan(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestScan_CleaningPluginGetsCommandActions(t *testing.T) {
+	var requests []Request
+	s := fakeScanner(map[string]string{
+		"describe": `{"protocol": 1, "name": "Artifacts", "clean": true}`,
+		"scan":     `{"protocol": 1, "targets": [{"path": "store://builds/42", "id": "b42", "size": 100}]}`,
+	}, &requests)
+	if err := s.describe(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].IsFilesystem() {
+		t.Fatalf("expected one command target, got %+v", targets)
+	}
+	if targets[0].Risk != scanner.Moderate {
+		t.Errorf("expected default risk Moderate, got %v", targets[0].Risk)
+	}
+
+	a := targets[0].Action
+	if len(a.Command) != 1 || a.Command[0] != s.path {
+		t.Errorf("expected the plugin itself as command, got %v", a.Command)
+	}
+	var req Request
+	if err := json.Unmarshal([]byte(a.Stdin), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Protocol != ProtocolVersion || req.Command != "clean" || len(req.Targets) != 1 || req.Targets[0].ID != "b42" {
+		t.Errorf("unexpected clean request %+v", req)
+	}
+
+	for _, r := range requests {
+		if r.Protocol != ProtocolVersion {
+			t.Errorf("request %q sent without protocol version", r.Command)
+		}
+	}
+}
+
+func TestLoadAll_IsolatesFailures(t *testing.T) {
+	dir := t.TempDir()
+	good := writePlugin(t, dir, "good", "echo '"+describeJSON+"'\n")
+	bad := writePlugin(t, dir, "bad", "exit 1\n")
+
+	scanners, errs := LoadAll(context.Background(), []string{bad, good}, 5*time.Second)
+	if len(scanners) != 1 || scanners[0].Path() != good {
+		t.Errorf("expected only the good plugin, got %v", scanners)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected one load error, got %v", errs)
+	}
+	var le *LoadError
+	if !errors.As(errs[0], &le) || le.Path != bad {
+		t.Errorf("expected a LoadError for %s, got %v", bad, errs[0])
+	}
+}
@@ -13,6 +13,9 @@ type Snapshot struct {
 	Timestamp  time.Time          `json:"timestamp"`
 	Categories []CategorySnapshot `json:"categories"`
 	TotalSize  int64              `json:"total_size"`
+	// OverlapSize is the number of bytes reported by more than one scanner.
+	// They are counted once in TotalSize and the category sizes.
+	OverlapSize int64 `json:"overlap_size,omitempty"`
 }
 
 // CategorySnapshot captures the size and item count for a single category.
@@ -47,6 +50,18 @@ func DefaultPath() string {
 	return filepath.Join(home, ".local", "share", "macbroom", "last-scan.json")
 }
 
+// DirCachePath returns the location of the persistent directory size
+// cache, next to the scan snapshot: ~/.local/share/macbroom/dir-sizes.json
+func DirCachePath() string {
+	return filepath.Join(filepath.Dir(DefaultPath()), "dir-sizes.json")
+}
+
+// HashCachePath returns the location of the persistent file hash cache
+// used by the duplicate finder: ~/.local/share/macbroom/dupes-hashes.json
+func HashCachePath() string {
+	return filepath.Join(filepath.Dir(DefaultPath()), "dupes-hashes.json")
+}
+
 // Save writes a snapshot to the given path as indented JSON.
 // It creates parent directories if they don't exist.
 func Save(path string, snap Snapshot) error {
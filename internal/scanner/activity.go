@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// Lockfiles whose modification time shows that a project's dependencies
+// were recently installed or changed.
+var (
+	nodeLockfiles   = []string{"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lockb", "bun.lock"}
+	pythonLockfiles = []string{"poetry.lock", "Pipfile.lock", "uv.lock", "pdm.lock"}
+	rustLockfiles   = []string{"Cargo.lock"}
+)
+
+// projectActivity returns when the project in dir was last worked on: the
+// latest of the last commit touching dir, as reported by lastCommit for
+// its repository, and the modification time of its lockfiles. fallback,
+// usually the artifact's own mtime, is returned when neither is available.
+// lastCommit may be nil.
+func projectActivity(dir string, lockfiles []string, fallback time.Time, lastCommit func(root, dir string) (time.Time, bool)) time.Time {
+	var last time.Time
+	if root := findGitRoot(dir); root != "" && lastCommit != nil {
+		if t, ok := lastCommit(root, dir); ok {
+			last = t
+		}
+	}
+	for _, name := range lockfiles {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	if last.IsZero() {
+		return fallback
+	}
+	return last
+}
+
+// findGitRoot returns the nearest directory at or above dir that contains
+// a .git entry, or "" if there is none. The home directory itself is never
+// returned, so a dotfiles repository in ~ does not swallow every project.
+func findGitRoot(dir string) string {
+	home := utils.HomeDir()
+	for {
+		if dir == home {
+			return ""
+		}
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProjectActivity(t *testing.T) {
+	fallback := time.Now().Add(-100 * 24 * time.Hour).Truncate(time.Second)
+	commit := time.Now().Add(-3 * 24 * time.Hour).Truncate(time.Second)
+
+	t.Run("no signals", func(t *testing.T) {
+		if got := projectActivity(t.TempDir(), nodeLockfiles, fallback, nil); !got.Equal(fallback) {
+			t.Errorf("expected fallback %v, got %v", fallback, got)
+		}
+	})
+
+	t.Run("last commit touching the project", func(t *testing.T) {
+		repo := t.TempDir()
+		if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		project := filepath.Join(repo, "packages", "web")
+		if err := os.MkdirAll(project, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		lastCommit := func(root, dir string) (time.Time, bool) {
+			if root != repo || dir != project {
+				t.Errorf("lastCommit(%s, %s), want (%s, %s)", root, dir, repo, project)
+			}
+			return commit, true
+		}
+		if got := projectActivity(project, nodeLockfiles, fallback, lastCommit); !got.Equal(commit) {
+			t.Errorf("expected last commit %v, got %v", commit, got)
+		}
+	})
+
+	t.Run("newer lockfile wins", func(t *testing.T) {
+		repo := t.TempDir()
+		if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		lock := filepath.Join(repo, "Cargo.lock")
+		if err := os.WriteFile(lock, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		recent := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if err := os.Chtimes(lock, recent, recent); err != nil {
+			t.Fatal(err)
+		}
+		lastCommit := func(string, string) (time.Time, bool) { return commit, true }
+		if got := projectActivity(repo, rustLockfiles, fallback, lastCommit); !got.Equal(recent) {
+			t.Errorf("expected lockfile mtime %v, got %v", recent, got)
+		}
+	})
+}
+
+func TestFindGitRoot_Worktree(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".git"), []byte("gitdir: ../main/.git/worktrees/feature\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := findGitRoot(filepath.Join(root, "src")); got != root {
+		t.Errorf("findGitRoot = %q, want %q", got, root)
+	}
+}
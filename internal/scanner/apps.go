@@ -109,37 +109,29 @@ func (a *AppScanner) FindRelatedFiles(ctx context.Context, appName string) ([]Ta
 				continue
 			}
 
-			var size int64
-			if info.IsDir() {
-				size, _ = utils.DirSize(entryPath)
-			} else {
-				size = info.Size()
-			}
+			usage := measure(ctx, entryPath, info)
 
-			targets = append(targets, Target{
+			targets = append(targets, withUsage(ctx, Target{
 				Path:        entryPath,
-				Size:        size,
 				Category:    "App Uninstaller",
 				Description: dir + " (" + appName + ")",
 				Risk:        Moderate,
 				ModTime:     info.ModTime(),
 				IsDir:       info.IsDir(),
-			})
+			}, usage))
 		}
 	}
 
 	appBundle := filepath.Join(a.apps(), appName+".app")
 	if info, err := os.Stat(appBundle); err == nil {
-		size, _ := utils.DirSize(appBundle)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        appBundle,
-			Size:        size,
 			Category:    "App Uninstaller",
 			Description: "Application bundle",
 			Risk:        Moderate,
 			ModTime:     info.ModTime(),
 			IsDir:       true,
-		})
+		}, measureDir(ctx, appBundle)))
 	}
 
 	return targets, nil
@@ -196,15 +188,14 @@ func (a *AppScanner) FindOrphans(ctx context.Context) ([]Target, error) {
 			continue
 		}
 
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        plistPath,
-			Size:        info.Size(),
 			Category:    "Orphaned Preferences",
 			Description: fmt.Sprintf("Orphaned plist (%s)", appName),
 			Risk:        Safe,
 			ModTime:     info.ModTime(),
 			IsDir:       false,
-		})
+		}, measure(ctx, plistPath, info)))
 
 		// Also check Caches and Application Support for matching remnants.
 		bundleID := strings.TrimSuffix(name, ".plist")
@@ -235,22 +226,16 @@ func (a *AppScanner) FindOrphans(ctx context.Context) ([]Target, error) {
 					continue
 				}
 
-				var size int64
-				if reInfo.IsDir() {
-					size, _ = utils.DirSize(rePath)
-				} else {
-					size = reInfo.Size()
-				}
+				usage := measure(ctx, rePath, reInfo)
 
-				targets = append(targets, Target{
+				targets = append(targets, withUsage(ctx, Target{
 					Path:        rePath,
-					Size:        size,
 					Category:    "Orphaned Preferences",
 					Description: fmt.Sprintf("Orphaned %s (%s)", dir, appName),
 					Risk:        Safe,
 					ModTime:     reInfo.ModTime(),
 					IsDir:       reInfo.IsDir(),
-				})
+				}, usage))
 			}
 		}
 	}
@@ -27,6 +27,11 @@ func (b *BrowserScanner) Name() string        { return "Browser Cache" }
 func (b *BrowserScanner) Description() string { return "Browser caches, cookies, and local storage" }
 func (b *BrowserScanner) Risk() RiskLevel     { return Moderate }
 
+// Roots implements Rooted.
+func (b *BrowserScanner) Roots() []string {
+	return []string{b.caches(), filepath.Join(b.library(), "Safari")}
+}
+
 func (b *BrowserScanner) caches() string {
 	if b.cachesBase != "" {
 		return b.cachesBase
@@ -73,26 +78,19 @@ func (b *BrowserScanner) Scan(ctx context.Context) ([]Target, error) {
 				continue
 			}
 
-			var size int64
-			if info.IsDir() {
-				size, _ = utils.DirSize(fullPath)
-			} else {
-				size = info.Size()
-			}
-
-			if size == 0 {
+			usage := measure(ctx, fullPath, info)
+			if usage.IsZero() {
 				continue
 			}
 
-			targets = append(targets, Target{
+			targets = append(targets, withUsage(ctx, Target{
 				Path:        fullPath,
-				Size:        size,
 				Category:    "Browser Cache",
 				Description: profile.name + " cache",
 				Risk:        Moderate,
 				ModTime:     info.ModTime(),
 				IsDir:       info.IsDir(),
-			})
+			}, usage))
 		}
 	}
 
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CustomScanner reports paths matching user-declared glob patterns. It backs
+// the custom_scanners config section, so new cache locations can be added
+// without writing Go code.
+type CustomScanner struct {
+	name        string
+	description string
+	patterns    []string
+	minAge      time.Duration
+	minSize     int64
+	risk        RiskLevel
+}
+
+// NewCustomScanner returns a scanner named name that reports every file or
+// directory matching one of patterns (filepath.Glob syntax, already
+// ~-expanded) that is at least minAge old and minSize large. Zero disables
+// either threshold.
+func NewCustomScanner(name, description string, patterns []string, minAge time.Duration, minSize int64, risk RiskLevel) *CustomScanner {
+	return &CustomScanner{
+		name:        name,
+		description: description,
+		patterns:    patterns,
+		minAge:      minAge,
+		minSize:     minSize,
+		risk:        risk,
+	}
+}
+
+func (s *CustomScanner) Name() string { return s.name }
+func (s *CustomScanner) Description() string {
+	if s.description != "" {
+		return s.description
+	}
+	return "Custom scanner"
+}
+func (s *CustomScanner) Risk() RiskLevel { return s.risk }
+
+// Roots implements Rooted. Each pattern contributes the directory before
+// its first wildcard.
+func (s *CustomScanner) Roots() []string {
+	roots := make([]string, 0, len(s.patterns))
+	for _, p := range s.patterns {
+		roots = append(roots, globRoot(p))
+	}
+	return roots
+}
+
+// globRoot returns the longest leading part of pattern without glob
+// metacharacters.
+func globRoot(pattern string) string {
+	parts := strings.Split(filepath.Clean(pattern), string(filepath.Separator))
+	for i, part := range parts {
+		if strings.ContainsAny(part, `*?[\`) {
+			switch root := strings.Join(parts[:i], string(filepath.Separator)); {
+			case i == 0:
+				return "."
+			case root == "":
+				return string(filepath.Separator)
+			default:
+				return root
+			}
+		}
+	}
+	return filepath.Clean(pattern)
+}
+
+func (s *CustomScanner) Scan(ctx context.Context) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+	now := time.Now()
+
+	for _, pattern := range s.patterns {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			if s.minAge > 0 && now.Sub(info.ModTime()) < s.minAge {
+				continue
+			}
+
+			usage := measure(ctx, path, info)
+			t := withUsage(ctx, Target{
+				Path:        path,
+				Category:    s.name,
+				Description: s.Description(),
+				Risk:        s.risk,
+				ModTime:     info.ModTime(),
+				IsDir:       info.IsDir(),
+			}, usage)
+			if usage.IsZero() || t.Size < s.minSize {
+				continue
+			}
+			targets = append(targets, emit(ctx, t))
+		}
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCustomScanner_ImplementsScanner(t *testing.T) {
+	var _ Scanner = NewCustomScanner("Unity", "", nil, 0, 0, Safe)
+}
+
+func TestCustomScanner_MatchesGlobs(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"a/cache", "b/cache", "c/other"} {
+		dir := filepath.Join(root, p)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 2048), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewCustomScanner("Unity", "Unity caches", []string{
+		filepath.Join(root, "*", "cache"),
+		filepath.Join(root, "a", "cache"), // overlapping pattern, reported once
+	}, 0, 0, Moderate)
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d: %+v", len(targets), targets)
+	}
+	for _, tgt := range targets {
+		if tgt.Category != "Unity" || tgt.Risk != Moderate || tgt.Description != "Unity caches" {
+			t.Errorf("unexpected target %+v", tgt)
+		}
+		if tgt.Size != 2048 || !tgt.IsDir {
+			t.Errorf("expected a 2048-byte directory, got %+v", tgt)
+		}
+	}
+}
+
+func TestCustomScanner_Thresholds(t *testing.T) {
+	root := t.TempDir()
+	small := filepath.Join(root, "small.log")
+	big := filepath.Join(root, "big.log")
+	fresh := filepath.Join(root, "fresh.log")
+	os.WriteFile(small, make([]byte, 10), 0o644)
+	os.WriteFile(big, make([]byte, 5000), 0o644)
+	os.WriteFile(fresh, make([]byte, 5000), 0o644)
+
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(small, old, old)
+	os.Chtimes(big, old, old)
+
+	s := NewCustomScanner("Logs", "", []string{filepath.Join(root, "*.log")}, 24*time.Hour, 1000, Safe)
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Path != big {
+		t.Errorf("expected only %s, got %+v", big, targets)
+	}
+}
+
+func TestCustomScanner_BadPattern(t *testing.T) {
+	s := NewCustomScanner("Bad", "", []string{"[unclosed"}, 0, 0, Safe)
+	if _, err := s.Scan(context.Background()); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestParseRiskLevel(t *testing.T) {
+	for in, want := range map[string]RiskLevel{"safe": Safe, "Moderate": Moderate, " RISKY ": Risky} {
+		got, err := ParseRiskLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRiskLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseRiskLevel("dangerous"); err == nil {
+		t.Error("expected an error for an unknown risk level")
+	}
+}
+
+// recordProgress collects what a scanner reports through its context.
+type recordProgress struct {
+	found []Target
+	bytes int64
+}
+
+func (r *recordProgress) Found(t Target)       { r.found = append(r.found, t) }
+func (r *recordProgress) Measured(bytes int64) { r.bytes += bytes }
+
+func TestCustomScanner_ReportsProgress(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "cache")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recordProgress{}
+	ctx := WithProgress(context.Background(), rec)
+	targets, err := NewCustomScanner("Unity", "", []string{dir}, 0, 0, Safe).Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || len(rec.found) != 1 || rec.found[0].Path != targets[0].Path {
+		t.Errorf("expected the target to be reported as found, got %+v", rec.found)
+	}
+	if rec.bytes < 4096 {
+		t.Errorf("expected at least 4096 bytes measured, got %d", rec.bytes)
+	}
+}
+
+func TestCustomScanner_Roots(t *testing.T) {
+	s := NewCustomScanner("Unity", "", []string{
+		"/Users/me/Library/Unity/cache",
+		"/Users/me/Projects/*/Library/Cache",
+		"/Users/me/Library/Caches/com.unity?.*",
+		"*.tmp",
+	}, 0, 0, Safe)
+	want := []string{
+		"/Users/me/Library/Unity/cache",
+		"/Users/me/Projects",
+		"/Users/me/Library/Caches",
+		".",
+	}
+	got := s.Roots()
+	if len(got) != len(want) {
+		t.Fatalf("Roots() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Roots()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -3,13 +3,28 @@ package scanner
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // DockerScanner detects Docker cleanup opportunities including
-// dangling images and build cache.
+// dangling images, build cache and, when enabled, stopped containers and
+// unused volumes. It prefers the Engine API over a Unix socket, which
+// reports exact byte sizes, and falls back to the docker CLI when no
+// socket answers.
 type DockerScanner struct {
+	// sockets are the Unix sockets probed for the Engine API, in order.
+	sockets []string
+
+	// containers and volumes enable stopped containers and unused volumes
+	// as targets. Removing them cannot be undone from the Trash, so both
+	// are off by default.
+	containers bool
+	volumes    bool
+
 	// lookPath is used to check if docker is installed.
 	// Defaults to exec.LookPath; override in tests.
 	lookPath func(file string) (string, error)
@@ -22,6 +37,7 @@ type DockerScanner struct {
 // NewDockerScanner returns a new DockerScanner with default command execution.
 func NewDockerScanner() *DockerScanner {
 	return &DockerScanner{
+		sockets:  DefaultDockerSockets(),
 		lookPath: exec.LookPath,
 		runCmd: func(ctx context.Context, name string, args ...string) ([]byte, error) {
 			return exec.CommandContext(ctx, name, args...).Output()
@@ -29,11 +45,140 @@ func NewDockerScanner() *DockerScanner {
 	}
 }
 
+// SetSocket makes the scanner query only the given Engine API socket, for
+// daemons such as Colima, Podman or OrbStack that live at custom paths.
+func (s *DockerScanner) SetSocket(path string) {
+	s.sockets = []string{path}
+}
+
+// SetIncludeContainers makes the scanner report stopped containers.
+func (s *DockerScanner) SetIncludeContainers(include bool) {
+	s.containers = include
+}
+
+// SetIncludeVolumes makes the scanner report volumes no container uses.
+func (s *DockerScanner) SetIncludeVolumes(include bool) {
+	s.volumes = include
+}
+
 func (s *DockerScanner) Name() string        { return "Docker" }
 func (s *DockerScanner) Description() string { return "Docker images, containers, and build cache" }
 func (s *DockerScanner) Risk() RiskLevel     { return Moderate }
 
 func (s *DockerScanner) Scan(ctx context.Context) ([]Target, error) {
+	for _, socket := range s.sockets {
+		if _, err := os.Stat(socket); err != nil {
+			continue
+		}
+		du, err := newDockerAPI(socket).diskUsage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // stale socket or daemon not running; try the next one
+		}
+		return s.apiTargets(du, socket), nil
+	}
+	return s.scanCLI(ctx)
+}
+
+// apiTargets converts Engine API disk usage into cleanup targets with
+// exact sizes. Their commands are sent to socket, the daemon the sizes
+// came from, rather than the docker CLI's current context.
+func (s *DockerScanner) apiTargets(du dockerDiskUsage, socket string) []Target {
+	var targets []Target
+	docker := func(args ...string) *Action {
+		return CommandAction("docker", append([]string{"-H", "unix://" + socket}, args...)...)
+	}
+
+	for _, img := range du.Images {
+		// Images still referenced by a container cannot be removed with rmi.
+		if !isDanglingImage(img.RepoTags) || img.Containers > 0 {
+			continue
+		}
+		id := dockerShortID(img.ID)
+		targets = append(targets, Target{
+			Path:        "docker image " + id,
+			Size:        img.Size,
+			Category:    "Docker",
+			Description: "Dangling image",
+			Risk:        Moderate,
+			ModTime:     time.Unix(img.Created, 0),
+			Action:      docker("rmi", id),
+		})
+	}
+
+	if s.containers {
+		for _, c := range du.Containers {
+			if c.State == "running" || c.State == "paused" || c.State == "restarting" {
+				continue
+			}
+			id := dockerShortID(c.ID)
+			name := id
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			targets = append(targets, Target{
+				Path:        "docker container " + name,
+				Size:        c.SizeRw,
+				Category:    "Docker",
+				Description: fmt.Sprintf("Stopped container (%s, %s)", c.Image, c.State),
+				Risk:        Moderate,
+				ModTime:     time.Unix(c.Created, 0),
+				Action:      docker("rm", id),
+			})
+		}
+	}
+
+	if s.volumes {
+		for _, v := range du.Volumes {
+			if v.UsageData == nil || v.UsageData.RefCount != 0 {
+				continue
+			}
+			size := v.UsageData.Size
+			if size < 0 {
+				size = 0 // -1 means the daemon did not compute the size
+			}
+			targets = append(targets, Target{
+				Path:        "docker volume " + v.Name,
+				Size:        size,
+				Category:    "Docker",
+				Description: "Unused volume",
+				Risk:        Risky,
+				Action:      docker("volume", "rm", v.Name),
+			})
+		}
+	}
+
+	// Like docker system df's reclaimable figure, the size counts every
+	// unused record. Plain "builder prune" only removes dangling ones, so
+	// the action prunes with --all to free what is reported.
+	var cacheSize int64
+	var cacheRecords int
+	for _, bc := range du.BuildCache {
+		if bc.InUse || bc.Shared {
+			continue
+		}
+		cacheSize += bc.Size
+		cacheRecords++
+	}
+	if cacheRecords > 0 {
+		targets = append(targets, Target{
+			Path:        "docker build cache",
+			Size:        cacheSize,
+			Category:    "Docker",
+			Description: fmt.Sprintf("Build cache (%d unused records)", cacheRecords),
+			Risk:        Safe,
+			Action:      docker("builder", "prune", "--all", "--force"),
+		})
+	}
+
+	return targets
+}
+
+// scanCLI detects dangling images and build cache through the docker CLI.
+// The CLI only reports human-readable sizes, so targets carry no Size.
+func (s *DockerScanner) scanCLI(ctx context.Context) ([]Target, error) {
 	if _, err := s.lookPath("docker"); err != nil {
 		return nil, nil
 	}
@@ -63,6 +208,7 @@ func (s *DockerScanner) Scan(ctx context.Context) ([]Target, error) {
 			Description: desc,
 			Category:    "Docker",
 			Risk:        Moderate,
+			Action:      CommandAction("docker", "rmi", parts[0]),
 		})
 	}
 
@@ -89,6 +235,7 @@ func (s *DockerScanner) Scan(ctx context.Context) ([]Target, error) {
 					Description: "Build cache (" + df.Size + ", " + df.Reclaimable + " reclaimable)",
 					Category:    "Docker",
 					Risk:        Safe,
+					Action:      CommandAction("docker", "builder", "prune", "--all", "--force"),
 				})
 			}
 		}
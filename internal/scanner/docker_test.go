@@ -33,7 +33,7 @@ func TestDockerScanner_ImplementsScanner(t *testing.T) {
 }
 
 func TestDockerScanner_SkipsIfNotInstalled(t *testing.T) {
-	s := NewDockerScanner()
+	s := newCLIDockerScanner()
 	// Override lookPath to simulate docker not being installed
 	s.lookPath = func(file string) (string, error) {
 		return "", exec.ErrNotFound
@@ -49,7 +49,7 @@ func TestDockerScanner_SkipsIfNotInstalled(t *testing.T) {
 }
 
 func TestDockerScanner_SkipsIfDaemonNotRunning(t *testing.T) {
-	s := NewDockerScanner()
+	s := newCLIDockerScanner()
 	// Docker is "installed" but commands fail (daemon not running)
 	s.lookPath = func(file string) (string, error) {
 		return "/usr/local/bin/docker", nil
@@ -68,7 +68,7 @@ func TestDockerScanner_SkipsIfDaemonNotRunning(t *testing.T) {
 }
 
 func TestDockerScanner_ParsesDanglingImages(t *testing.T) {
-	s := NewDockerScanner()
+	s := newCLIDockerScanner()
 	s.lookPath = func(file string) (string, error) {
 		return "/usr/local/bin/docker", nil
 	}
@@ -123,10 +123,21 @@ func TestDockerScanner_ParsesDanglingImages(t *testing.T) {
 	if targets[2].Risk != Safe {
 		t.Errorf("target[2]: expected risk Safe, got %s", targets[2].Risk)
 	}
+
+	// Docker targets are cleaned by running docker, never by deleting Path.
+	wantActions := []string{"docker rmi abc123", "docker rmi def456", "docker builder prune --all --force"}
+	for i, want := range wantActions {
+		if targets[i].IsFilesystem() {
+			t.Errorf("target[%d]: expected a command action, got filesystem delete", i)
+		}
+		if got := targets[i].Action.String(); got != want {
+			t.Errorf("target[%d]: expected action %q, got %q", i, want, got)
+		}
+	}
 }
 
 func TestDockerScanner_NoDanglingImages(t *testing.T) {
-	s := NewDockerScanner()
+	s := newCLIDockerScanner()
 	s.lookPath = func(file string) (string, error) {
 		return "/usr/local/bin/docker", nil
 	}
@@ -155,7 +166,7 @@ func TestDockerScanner_ContextCancelled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
 
-	s := NewDockerScanner()
+	s := newCLIDockerScanner()
 	s.lookPath = func(file string) (string, error) {
 		return "/usr/local/bin/docker", nil
 	}
@@ -172,6 +183,14 @@ func TestDockerScanner_ContextCancelled(t *testing.T) {
 	}
 }
 
+// newCLIDockerScanner returns a DockerScanner that never probes Engine API
+// sockets, so tests exercise the CLI fallback regardless of the host.
+func newCLIDockerScanner() *DockerScanner {
+	s := NewDockerScanner()
+	s.sockets = nil
+	return s
+}
+
 // exitError simulates an exec.ExitError for testing.
 type exitError struct {
 	msg string
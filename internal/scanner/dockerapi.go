@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// DefaultDockerSockets returns the Unix sockets probed for a Docker-compatible
+// daemon, in order: $DOCKER_HOST (when it is a unix:// URL), the system
+// socket, and the per-user sockets of Docker Desktop, Colima, OrbStack and
+// Podman machine.
+func DefaultDockerSockets() []string {
+	var sockets []string
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		sockets = append(sockets, strings.TrimPrefix(host, "unix://"))
+	}
+	sockets = append(sockets, "/var/run/docker.sock")
+	if home := utils.HomeDir(); home != "" {
+		sockets = append(sockets,
+			filepath.Join(home, ".docker", "run", "docker.sock"),
+			filepath.Join(home, ".colima", "default", "docker.sock"),
+			filepath.Join(home, ".orbstack", "run", "docker.sock"),
+			filepath.Join(home, ".local", "share", "containers", "podman", "machine", "podman.sock"),
+		)
+	}
+	return sockets
+}
+
+// dockerAPI is a minimal Docker Engine API client that talks to the daemon
+// over its Unix socket.
+type dockerAPI struct {
+	socket string
+	client *http.Client
+}
+
+func newDockerAPI(socket string) *dockerAPI {
+	return &dockerAPI{
+		socket: socket,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// dockerDiskUsage mirrors the subset of GET /system/df used by macbroom.
+type dockerDiskUsage struct {
+	Images []struct {
+		ID         string   `json:"Id"`
+		RepoTags   []string `json:"RepoTags"`
+		Size       int64    `json:"Size"`
+		Containers int64    `json:"Containers"`
+		Created    int64    `json:"Created"`
+	} `json:"Images"`
+	Containers []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		Image   string   `json:"Image"`
+		State   string   `json:"State"`
+		SizeRw  int64    `json:"SizeRw"`
+		Created int64    `json:"Created"`
+	} `json:"Containers"`
+	Volumes []struct {
+		Name      string `json:"Name"`
+		UsageData *struct {
+			Size     int64 `json:"Size"`
+			RefCount int64 `json:"RefCount"`
+		} `json:"UsageData"`
+	} `json:"Volumes"`
+	BuildCache []struct {
+		ID     string `json:"ID"`
+		Size   int64  `json:"Size"`
+		InUse  bool   `json:"InUse"`
+		Shared bool   `json:"Shared"`
+	} `json:"BuildCache"`
+}
+
+// diskUsage calls GET /system/df, which reports per-object sizes for
+// images, containers, volumes and build cache in a single request.
+func (c *dockerAPI) diskUsage(ctx context.Context) (dockerDiskUsage, error) {
+	var du dockerDiskUsage
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/system/df", nil)
+	if err != nil {
+		return du, fmt.Errorf("failed to build docker request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return du, fmt.Errorf("failed to query docker at %s: %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return du, fmt.Errorf("docker at %s returned %s", c.socket, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&du); err != nil {
+		return du, fmt.Errorf("failed to parse docker disk usage: %w", err)
+	}
+	return du, nil
+}
+
+// dockerShortID trims the digest prefix and shortens an object ID the way
+// the docker CLI displays it.
+func dockerShortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return id
+}
+
+// isDanglingImage reports whether an image has no tags.
+func isDanglingImage(tags []string) bool {
+	for _, tag := range tags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
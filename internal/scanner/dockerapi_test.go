@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fakeSystemDF = `{
+  "LayersSize": 4000000000,
+  "Images": [
+    {"Id": "sha256:aaaaaaaaaaaa1111", "RepoTags": ["<none>:<none>"], "Size": 1200000000, "Containers": 0, "Created": 1700000000},
+    {"Id": "sha256:bbbbbbbbbbbb2222", "RepoTags": [], "Size": 300000000, "Containers": 1, "Created": 1700000000},
+    {"Id": "sha256:cccccccccccc3333", "RepoTags": ["postgres:16"], "Size": 450000000, "Containers": 2, "Created": 1700000000}
+  ],
+  "Containers": [
+    {"Id": "dddddddddddd4444", "Names": ["/old-db"], "Image": "postgres:16", "State": "exited", "SizeRw": 52428800, "Created": 1700000000},
+    {"Id": "eeeeeeeeeeee5555", "Names": ["/web"], "Image": "postgres:16", "State": "running", "SizeRw": 1024, "Created": 1700000000}
+  ],
+  "Volumes": [
+    {"Name": "orphan", "UsageData": {"Size": 734003200, "RefCount": 0}},
+    {"Name": "pgdata", "UsageData": {"Size": 999, "RefCount": 1}}
+  ],
+  "BuildCache": [
+    {"ID": "x1", "Size": 1000000, "InUse": false, "Shared": false},
+    {"ID": "x2", "Size": 2000000, "InUse": false, "Shared": false},
+    {"ID": "x3", "Size": 4000000, "InUse": true, "Shared": false}
+  ]
+}`
+
+// startFakeDocker serves handler over HTTP on a Unix socket and returns
+// the socket path.
+func startFakeDocker(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	// Unix socket paths are limited to ~104 bytes on macOS, so avoid the
+	// long per-test temp directory.
+	dir, err := os.MkdirTemp("", "mbdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func TestDockerScanner_EngineAPISizes(t *testing.T) {
+	socket := startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/system/df" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fakeSystemDF))
+	}))
+
+	s := NewDockerScanner()
+	s.SetSocket(socket)
+	s.SetIncludeContainers(true)
+	s.SetIncludeVolumes(true)
+	s.lookPath = func(file string) (string, error) {
+		t.Fatal("CLI fallback should not be used when the socket answers")
+		return "", nil
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	host := "docker -H unix://" + socket + " "
+	want := map[string]struct {
+		size   int64
+		risk   RiskLevel
+		action string
+	}{
+		"docker image aaaaaaaaaaaa": {1200000000, Moderate, host + "rmi aaaaaaaaaaaa"},
+		"docker container old-db":   {52428800, Moderate, host + "rm dddddddddddd"},
+		"docker volume orphan":      {734003200, Risky, host + "volume rm orphan"},
+		"docker build cache":        {3000000, Safe, host + "builder prune --all --force"},
+	}
+
+	if len(targets) != len(want) {
+		t.Fatalf("expected %d targets, got %d: %+v", len(want), len(targets), targets)
+	}
+	for _, tgt := range targets {
+		w, ok := want[tgt.Path]
+		if !ok {
+			t.Errorf("unexpected target %q", tgt.Path)
+			continue
+		}
+		if tgt.Size != w.size {
+			t.Errorf("%s: size = %d, want %d", tgt.Path, tgt.Size, w.size)
+		}
+		if tgt.Risk != w.risk {
+			t.Errorf("%s: risk = %s, want %s", tgt.Path, tgt.Risk, w.risk)
+		}
+		if got := tgt.Action.String(); got != w.action {
+			t.Errorf("%s: action = %q, want %q", tgt.Path, got, w.action)
+		}
+		if tgt.Category != "Docker" {
+			t.Errorf("%s: category = %q, want Docker", tgt.Path, tgt.Category)
+		}
+	}
+}
+
+func TestDockerScanner_ContainersAndVolumesAreOptIn(t *testing.T) {
+	socket := startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fakeSystemDF))
+	}))
+
+	s := NewDockerScanner()
+	s.SetSocket(socket)
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tgt := range targets {
+		if strings.HasPrefix(tgt.Path, "docker container ") || strings.HasPrefix(tgt.Path, "docker volume ") {
+			t.Errorf("unexpected target %q without opting in", tgt.Path)
+		}
+	}
+	if len(targets) != 2 {
+		t.Errorf("expected the dangling image and build cache, got %+v", targets)
+	}
+
+	s.SetIncludeVolumes(true)
+	targets, _ = s.Scan(context.Background())
+	var volumes, containers int
+	for _, tgt := range targets {
+		switch {
+		case strings.HasPrefix(tgt.Path, "docker volume "):
+			volumes++
+		case strings.HasPrefix(tgt.Path, "docker container "):
+			containers++
+		}
+	}
+	if volumes != 1 || containers != 0 {
+		t.Errorf("with volumes only: %d volumes, %d containers; want 1 and 0", volumes, containers)
+	}
+}
+
+func TestDockerScanner_FallsBackToCLIOnAPIError(t *testing.T) {
+	socket := startFakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "daemon starting", http.StatusServiceUnavailable)
+	}))
+
+	s := NewDockerScanner()
+	s.SetSocket(socket)
+	s.lookPath = func(file string) (string, error) {
+		return "/usr/local/bin/docker", nil
+	}
+	s.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		for _, arg := range args {
+			if arg == "dangling=true" {
+				return []byte("abc123\t1.2GB\n"), nil
+			}
+		}
+		return nil, nil
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Path != "docker image abc123" {
+		t.Errorf("expected CLI fallback target, got %+v", targets)
+	}
+}
+
+func TestDockerScanner_MissingSocketFallsBack(t *testing.T) {
+	s := NewDockerScanner()
+	s.SetSocket(filepath.Join(t.TempDir(), "missing.sock"))
+	var usedCLI bool
+	s.lookPath = func(file string) (string, error) {
+		usedCLI = true
+		return "", os.ErrNotExist
+	}
+
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !usedCLI {
+		t.Error("expected CLI fallback when the socket does not exist")
+	}
+}
+
+func TestDockerShortID(t *testing.T) {
+	tests := map[string]string{
+		"sha256:0123456789abcdef": "0123456789ab",
+		"0123456789abcdef":        "0123456789ab",
+		"abc":                     "abc",
+	}
+	for in, want := range tests {
+		if got := dockerShortID(in); got != want {
+			t.Errorf("dockerShortID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,27 +2,65 @@ package scanner
 
 import (
 	"context"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
-// GoScanner detects Go module cache and build cache.
+// GoScanner detects Go module cache and build cache. When the go tool is
+// installed and cleaning is permanent, the module cache is removed with
+// `go clean -modcache`; otherwise it is moved to Trash like any directory.
 type GoScanner struct {
 	home string
+
+	mu       sync.Mutex
+	modCache string
+
+	// lookPath checks whether go is installed.
+	// Defaults to exec.LookPath; override in tests.
+	lookPath func(file string) (string, error)
+
+	// runCmd executes a command and returns its stdout.
+	// Defaults to exec.CommandContext(...).Output(); override in tests.
+	runCmd func(ctx context.Context, name string, args ...string) ([]byte, error)
 }
 
 // NewGoScanner returns a new GoScanner.
-//   - home: user home directory (module cache at home/go/pkg/mod/cache,
-//     build cache at home/Library/Caches/go-build)
+//   - home: user home directory (module cache at home/go/pkg/mod unless
+//     go env GOMODCACHE says otherwise, build cache at
+//     home/Library/Caches/go-build)
 func NewGoScanner(home string) *GoScanner {
-	return &GoScanner{home: home}
+	return &GoScanner{
+		home:     home,
+		lookPath: exec.LookPath,
+		runCmd: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).Output()
+		},
+	}
 }
 
 func (s *GoScanner) Name() string        { return "Go" }
 func (s *GoScanner) Description() string { return "Go module cache and build cache" }
 func (s *GoScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted. A module cache moved with GOMODCACHE is added
+// once Scan has asked go for it.
+func (s *GoScanner) Roots() []string {
+	roots := []string{
+		filepath.Join(s.home, "go", "pkg", "mod"),
+		filepath.Join(s.home, "Library", "Caches", "go-build"),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.modCache != "" && s.modCache != roots[0] {
+		roots = append(roots, s.modCache)
+	}
+	return roots
+}
+
 func (s *GoScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -30,17 +68,19 @@ func (s *GoScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	var targets []Target
 
-	modCache := filepath.Join(s.home, "go", "pkg", "mod", "cache")
+	modCache, action := s.findModCache(ctx)
+	s.mu.Lock()
+	s.modCache = modCache
+	s.mu.Unlock()
 	if utils.DirExists(modCache) {
-		size, _ := utils.DirSize(modCache)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        modCache,
-			Size:        size,
 			Category:    "Go",
 			Description: "Go module cache",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+			Action:      action,
+		}, measureDir(ctx, modCache)))
 	}
 
 	if ctx.Err() != nil {
@@ -49,16 +89,34 @@ func (s *GoScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	buildCache := filepath.Join(s.home, "Library", "Caches", "go-build")
 	if utils.DirExists(buildCache) {
-		size, _ := utils.DirSize(buildCache)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        buildCache,
-			Size:        size,
 			Category:    "Go",
 			Description: "Go build cache",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, buildCache)))
 	}
 
 	return targets, nil
 }
+
+// findModCache returns the module cache directory and, when the go tool
+// can clean it, the command to use for permanent cleaning. Without go the
+// cache is only ever deleted as a regular directory.
+func (s *GoScanner) findModCache(ctx context.Context) (string, *Action) {
+	dir := filepath.Join(s.home, "go", "pkg", "mod")
+	if _, err := s.lookPath("go"); err != nil {
+		return dir, nil
+	}
+	out, err := s.runCmd(ctx, "go", "env", "GOMODCACHE")
+	if err != nil {
+		return dir, nil
+	}
+	if d := strings.TrimSpace(string(out)); d != "" {
+		dir = d
+	}
+	action := CommandAction("go", "clean", "-modcache")
+	action.PermanentOnly = true
+	return dir, action
+}
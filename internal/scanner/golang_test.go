@@ -2,8 +2,11 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -18,17 +21,23 @@ func TestGoScanner_ImplementsScanner(t *testing.T) {
 	var _ Scanner = NewGoScanner("")
 }
 
+// withoutGo makes s behave as if the go tool were not installed.
+func withoutGo(s *GoScanner) *GoScanner {
+	s.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	return s
+}
+
 func TestGoScanner_FindsModCache(t *testing.T) {
 	home := t.TempDir()
-	modCache := filepath.Join(home, "go", "pkg", "mod", "cache")
-	if err := os.MkdirAll(modCache, 0o755); err != nil {
+	modCache := filepath.Join(home, "go", "pkg", "mod")
+	if err := os.MkdirAll(filepath.Join(modCache, "cache"), 0o755); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile(filepath.Join(modCache, "module.zip"), make([]byte, 4096), 0o644); err != nil {
+	if err := os.WriteFile(filepath.Join(modCache, "cache", "module.zip"), make([]byte, 4096), 0o644); err != nil {
 		t.Fatal(err)
 	}
 
-	s := NewGoScanner(home)
+	s := withoutGo(NewGoScanner(home))
 	targets, err := s.Scan(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -41,6 +50,9 @@ func TestGoScanner_FindsModCache(t *testing.T) {
 			if tgt.Risk != Safe {
 				t.Errorf("expected risk Safe, got %s", tgt.Risk)
 			}
+			if !tgt.IsFilesystem() {
+				t.Errorf("expected a filesystem target without go, got %s", tgt.Action)
+			}
 		}
 	}
 	if !found {
@@ -58,7 +70,7 @@ func TestGoScanner_FindsBuildCache(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	s := NewGoScanner(home)
+	s := withoutGo(NewGoScanner(home))
 	targets, err := s.Scan(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -77,7 +89,7 @@ func TestGoScanner_FindsBuildCache(t *testing.T) {
 
 func TestGoScanner_NoGoDir(t *testing.T) {
 	home := t.TempDir()
-	s := NewGoScanner(home)
+	s := withoutGo(NewGoScanner(home))
 	targets, err := s.Scan(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -91,9 +103,43 @@ func TestGoScanner_ContextCancelled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	s := NewGoScanner(t.TempDir())
+	s := withoutGo(NewGoScanner(t.TempDir()))
 	_, err := s.Scan(ctx)
 	if err != context.Canceled {
 		t.Fatalf("expected context.Canceled, got %v", err)
 	}
 }
+
+func TestGoScanner_ModCacheUsesGoClean(t *testing.T) {
+	home := t.TempDir()
+	gomodcache := filepath.Join(t.TempDir(), "gomodcache")
+	if err := os.MkdirAll(filepath.Join(gomodcache, "example.com", "m@v1.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewGoScanner(home)
+	s.lookPath = func(string) (string, error) { return "/usr/local/go/bin/go", nil }
+	s.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name != "go" || strings.Join(args, " ") != "env GOMODCACHE" {
+			t.Errorf("unexpected command %s %v", name, args)
+		}
+		return []byte(gomodcache + "\n"), nil
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Path != gomodcache {
+		t.Fatalf("expected the GOMODCACHE target, got %+v", targets)
+	}
+	if got := targets[0].Action.String(); got != "go clean -modcache" {
+		t.Errorf("expected go clean -modcache, got %q", got)
+	}
+	if !targets[0].Action.PermanentOnly || !targets[0].IsFilesystem() {
+		t.Errorf("expected go clean to be used only for permanent cleaning, got %+v", targets[0].Action)
+	}
+	if roots := s.Roots(); !slices.Contains(roots, gomodcache) {
+		t.Errorf("expected GOMODCACHE among the roots, got %v", roots)
+	}
+}
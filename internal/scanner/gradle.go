@@ -21,6 +21,9 @@ func (s *GradleScanner) Name() string        { return "Gradle" }
 func (s *GradleScanner) Description() string { return "Gradle caches and wrapper distributions" }
 func (s *GradleScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *GradleScanner) Roots() []string { return []string{filepath.Join(s.home, ".gradle")} }
+
 func (s *GradleScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -30,15 +33,13 @@ func (s *GradleScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	caches := filepath.Join(s.home, ".gradle", "caches")
 	if utils.DirExists(caches) {
-		size, _ := utils.DirSize(caches)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        caches,
-			Size:        size,
 			Category:    "Gradle",
 			Description: "Gradle build caches",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, caches)))
 	}
 
 	if ctx.Err() != nil {
@@ -47,15 +48,13 @@ func (s *GradleScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	dists := filepath.Join(s.home, ".gradle", "wrapper", "dists")
 	if utils.DirExists(dists) {
-		size, _ := utils.DirSize(dists)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        dists,
-			Size:        size,
 			Category:    "Gradle",
 			Description: "Gradle wrapper distributions",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, dists)))
 	}
 
 	return targets, nil
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 // HomebrewScanner detects Homebrew download cache files that can be
@@ -19,6 +20,9 @@ type HomebrewScanner struct {
 	// runCmd executes a command and returns its stdout.
 	// Defaults to exec.CommandContext(...).Output(); override in tests.
 	runCmd func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+	mu       sync.Mutex
+	cacheDir string // set by Scan
 }
 
 // NewHomebrewScanner returns a new HomebrewScanner with default command execution.
@@ -35,6 +39,17 @@ func (s *HomebrewScanner) Name() string        { return "Homebrew" }
 func (s *HomebrewScanner) Description() string { return "Homebrew download cache" }
 func (s *HomebrewScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted. The cache directory is known once Scan has
+// asked brew for it.
+func (s *HomebrewScanner) Roots() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cacheDir == "" {
+		return nil
+	}
+	return []string{s.cacheDir}
+}
+
 func (s *HomebrewScanner) Scan(ctx context.Context) ([]Target, error) {
 	if _, err := s.lookPath("brew"); err != nil {
 		return nil, nil
@@ -52,6 +67,9 @@ func (s *HomebrewScanner) Scan(ctx context.Context) ([]Target, error) {
 	if cacheDir == "" {
 		return nil, nil
 	}
+	s.mu.Lock()
+	s.cacheDir = cacheDir
+	s.mu.Unlock()
 
 	var targets []Target
 
@@ -77,14 +95,13 @@ func (s *HomebrewScanner) Scan(ctx context.Context) ([]Target, error) {
 			return nil // skip files we cannot stat
 		}
 
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        path,
-			Size:        info.Size(),
 			Category:    "Homebrew",
 			Description: "Cached download: " + d.Name(),
 			Risk:        Safe,
 			ModTime:     info.ModTime(),
-		})
+		}, measure(ctx, path, info)))
 
 		return nil
 	})
@@ -25,6 +25,14 @@ func (s *JetBrainsScanner) Name() string        { return "JetBrains" }
 func (s *JetBrainsScanner) Description() string { return "JetBrains IDE caches and logs" }
 func (s *JetBrainsScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *JetBrainsScanner) Roots() []string {
+	return []string{
+		filepath.Join(s.home, "Library", "Caches", "JetBrains"),
+		filepath.Join(s.home, "Library", "Logs", "JetBrains"),
+	}
+}
+
 func (s *JetBrainsScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -56,15 +64,13 @@ func (s *JetBrainsScanner) Scan(ctx context.Context) ([]Target, error) {
 				continue
 			}
 			ideDir := filepath.Join(d.base, entry.Name())
-			size, _ := utils.DirSize(ideDir)
-			targets = append(targets, Target{
+			targets = append(targets, emit(ctx, withUsage(ctx, Target{
 				Path:        ideDir,
-				Size:        size,
 				Category:    "JetBrains",
 				Description: fmt.Sprintf("%s %s", entry.Name(), d.desc),
 				Risk:        Safe,
 				IsDir:       true,
-			})
+			}, measureDir(ctx, ideDir))))
 		}
 	}
 
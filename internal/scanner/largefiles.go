@@ -21,6 +21,9 @@ func (l *LargeFileScanner) Name() string        { return "Large & Old Files" }
 func (l *LargeFileScanner) Description() string { return "Files exceeding size or age thresholds" }
 func (l *LargeFileScanner) Risk() RiskLevel     { return Risky }
 
+// Roots implements Rooted.
+func (l *LargeFileScanner) Roots() []string { return l.searchDirs }
+
 func (l *LargeFileScanner) Scan(ctx context.Context) ([]Target, error) {
 	var targets []Target
 	now := time.Now()
@@ -41,6 +44,8 @@ func (l *LargeFileScanner) Scan(ctx context.Context) ([]Target, error) {
 			matchesAge := l.minAge > 0 && now.Sub(info.ModTime()) >= l.minAge
 
 			if !matchesSize && !matchesAge {
+				// Matches are counted when they are measured below.
+				ProgressFrom(ctx).Measured(info.Size())
 				return nil
 			}
 
@@ -56,15 +61,14 @@ func (l *LargeFileScanner) Scan(ctx context.Context) ([]Target, error) {
 				desc = "Old file (not modified recently)"
 			}
 
-			targets = append(targets, Target{
+			targets = append(targets, emit(ctx, withUsage(ctx, Target{
 				Path:        path,
-				Size:        info.Size(),
 				Category:    "Large & Old Files",
 				Description: desc,
 				Risk:        risk,
 				ModTime:     info.ModTime(),
 				IsDir:       false,
-			})
+			}, measure(ctx, path, info))))
 
 			return nil
 		})
@@ -21,6 +21,9 @@ func (s *MavenScanner) Name() string        { return "Maven" }
 func (s *MavenScanner) Description() string { return "Maven local repository" }
 func (s *MavenScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *MavenScanner) Roots() []string { return []string{filepath.Join(s.home, ".m2")} }
+
 func (s *MavenScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -30,15 +33,13 @@ func (s *MavenScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	repo := filepath.Join(s.home, ".m2", "repository")
 	if utils.DirExists(repo) {
-		size, _ := utils.DirSize(repo)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        repo,
-			Size:        size,
 			Category:    "Maven",
 			Description: "Maven local repository",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, repo)))
 	}
 
 	return targets, nil
@@ -2,11 +2,11 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/lu-zhengda/macbroom/internal/utils"
@@ -14,9 +14,9 @@ import (
 
 // NodeScanner detects npm cache and stale node_modules directories.
 type NodeScanner struct {
-	home        string
-	searchPaths []string
-	maxAge      time.Duration
+	home   string
+	maxAge time.Duration
+	walker *ProjectWalker
 }
 
 // NewNodeScanner returns a new NodeScanner.
@@ -24,17 +24,27 @@ type NodeScanner struct {
 //   - searchPaths: directories to walk looking for stale node_modules
 //   - maxAge: threshold after which node_modules is considered stale
 func NewNodeScanner(home string, searchPaths []string, maxAge time.Duration) *NodeScanner {
-	return &NodeScanner{
-		home:        home,
-		searchPaths: searchPaths,
-		maxAge:      maxAge,
-	}
+	s := &NodeScanner{home: home, maxAge: maxAge}
+	s.SetWalker(NewProjectWalker(searchPaths))
+	return s
+}
+
+// SetWalker makes the scanner collect stale node_modules from a shared
+// ProjectWalker instead of walking its own search paths.
+func (s *NodeScanner) SetWalker(w *ProjectWalker) {
+	w.Register(nodeModulesDetector{maxAge: s.maxAge})
+	s.walker = w
 }
 
 func (s *NodeScanner) Name() string        { return "Node.js" }
 func (s *NodeScanner) Description() string { return "npm cache and stale node_modules" }
 func (s *NodeScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *NodeScanner) Roots() []string {
+	return append([]string{filepath.Join(s.home, ".npm")}, s.walker.searchPaths...)
+}
+
 func (s *NodeScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -45,18 +55,18 @@ func (s *NodeScanner) Scan(ctx context.Context) ([]Target, error) {
 	// --- npm cache ---
 	npmCache := filepath.Join(s.home, ".npm", "_cacache")
 	if utils.DirExists(npmCache) {
-		size, err := utils.DirSize(npmCache)
+		_, seen := utils.SizingFrom(ctx)
+		usage, err := utils.DirCacheFrom(ctx).DirUsage(ctx, npmCache, seen)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compute npm cache size: %w", err)
 		}
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        npmCache,
-			Size:        size,
 			Category:    "Node.js",
 			Description: "npm cache (_cacache)",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, usage))
 	}
 
 	if ctx.Err() != nil {
@@ -64,72 +74,46 @@ func (s *NodeScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	// --- stale node_modules ---
-	now := time.Now()
-	for _, searchPath := range s.searchPaths {
-		if !utils.DirExists(searchPath) {
-			continue
-		}
+	stale, err := s.walker.Collect(ctx, s.Name())
+	if gse := (*GitStatusError)(nil); err != nil && !errors.As(err, &gse) {
+		return nil, err
+	}
+	// Projects skipped for their git status are reported with the rest.
+	return append(targets, stale...), err
+}
 
-		err := filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			if err != nil {
-				return nil // skip inaccessible entries
-			}
-			if !d.IsDir() {
-				return nil
-			}
-			if d.Name() != "node_modules" {
-				return nil
-			}
-
-			// Skip nested node_modules (those inside another node_modules)
-			rel, relErr := filepath.Rel(searchPath, path)
-			if relErr == nil {
-				parts := strings.Split(rel, string(filepath.Separator))
-				nested := false
-				for i, p := range parts {
-					if p == "node_modules" && i < len(parts)-1 {
-						nested = true
-						break
-					}
-				}
-				if nested {
-					return fs.SkipDir
-				}
-			}
-
-			info, infoErr := os.Stat(path)
-			if infoErr != nil {
-				return nil
-			}
-
-			age := now.Sub(info.ModTime())
-			if age >= s.maxAge {
-				size, _ := utils.DirSize(path)
-				targets = append(targets, Target{
-					Path:        path,
-					Size:        size,
-					Category:    "Node.js",
-					Description: fmt.Sprintf("stale node_modules (unused for %d days)", int(age.Hours()/24)),
-					Risk:        Moderate,
-					ModTime:     info.ModTime(),
-					IsDir:       true,
-				})
-			}
-
-			// Don't descend into node_modules regardless of staleness
-			return fs.SkipDir
-		})
+// nodeModulesDetector reports node_modules directories whose project has
+// had no activity (see projectActivity) within maxAge. Nested node_modules
+// are never visited because the walker does not descend into matched
+// directories.
+type nodeModulesDetector struct {
+	maxAge time.Duration
+}
 
-		if err != nil {
-			if ctx.Err() != nil {
-				return nil, ctx.Err()
-			}
-			// Non-context errors during walk are non-fatal; skip this search path
-		}
+func (nodeModulesDetector) Category() string { return "Node.js" }
+
+func (d nodeModulesDetector) Detect(path string, entry fs.DirEntry, now time.Time, activity ActivityFunc) (*Target, bool) {
+	if entry.Name() != "node_modules" {
+		return nil, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, true
+	}
+
+	active := activity(filepath.Dir(path), nodeLockfiles, info.ModTime())
+	age := now.Sub(active)
+	if age < d.maxAge {
+		return nil, true
 	}
 
-	return targets, nil
+	return &Target{
+		Path:        path,
+		Category:    "Node.js",
+		Description: fmt.Sprintf("stale node_modules (project inactive for %d days)", int(age.Hours()/24)),
+		Risk:        Moderate,
+		ModTime:     active,
+		IsDir:       true,
+	}, true
 }
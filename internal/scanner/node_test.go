@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -228,3 +229,43 @@ func TestNodeScanner_SkipsNestedNodeModules(t *testing.T) {
 		t.Errorf("expected 1 stale node_modules target, got %d: %+v", count, targets)
 	}
 }
+
+func TestNodeScanner_RecentGitActivityIsNotStale(t *testing.T) {
+	searchDir := t.TempDir()
+	repo := filepath.Join(searchDir, "mono")
+	oldTime := time.Now().Add(-90 * 24 * time.Hour)
+	for _, app := range []string{"web", "admin"} {
+		nmDir := filepath.Join(repo, app, "node_modules")
+		if err := os.MkdirAll(nmDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(nmDir, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only web was committed to recently; the monorepo as a whole was.
+	s := NewNodeScanner(t.TempDir(), []string{searchDir}, 30*24*time.Hour)
+	s.walker.lookPath = func(string) (string, error) { return "/usr/bin/git", nil }
+	s.walker.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if args[2] == "log" {
+			when := oldTime
+			if args[len(args)-1] == "web" {
+				when = time.Now().Add(-2 * 24 * time.Hour)
+			}
+			return []byte(fmt.Sprintf("%d\n", when.Unix())), nil
+		}
+		return nil, nil
+	}
+
+	targets, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Path != filepath.Join(repo, "admin", "node_modules") {
+		t.Errorf("expected only the idle project to be stale, got %+v", targets)
+	}
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"context"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// Progress receives updates from a running scanner, so that long scans can
+// be shown while they happen. The engine installs one per scanner; the
+// targets a scanner returns remain the authoritative result.
+type Progress interface {
+	// Found is called with each target as soon as the scanner knows it
+	// will report it.
+	Found(t Target)
+	// Measured is called with the number of bytes the scanner has just
+	// sized or walked past.
+	Measured(bytes int64)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context that delivers scanner updates to p.
+func WithProgress(ctx context.Context, p Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFrom returns the Progress set with WithProgress, or one that
+// discards updates.
+func ProgressFrom(ctx context.Context) Progress {
+	if p, ok := ctx.Value(progressKey{}).(Progress); ok && p != nil {
+		return p
+	}
+	return nopProgress{}
+}
+
+type nopProgress struct{}
+
+func (nopProgress) Found(Target)   {}
+func (nopProgress) Measured(int64) {}
+
+// emit reports t to the progress in ctx and returns it, so it can wrap
+// the value being appended to a scanner's results.
+func emit(ctx context.Context, t Target) Target {
+	ProgressFrom(ctx).Found(t)
+	return t
+}
+
+// measured reports u, in ctx's size mode, to the progress in ctx.
+func measured(ctx context.Context, u utils.Usage) {
+	mode, _ := utils.SizingFrom(ctx)
+	ProgressFrom(ctx).Measured(u.In(mode))
+}
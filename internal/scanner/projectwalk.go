@@ -0,0 +1,409 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// walkReuseWindow bounds how long a finished walk may be handed to a
+// detector that has not yet collected it. Older results are discarded and
+// the search paths are walked again.
+const walkReuseWindow = 5 * time.Minute
+
+// gitTimeout bounds each git call made for a project.
+const gitTimeout = 30 * time.Second
+
+// ArtifactDetector recognizes one kind of project build artifact (for
+// example node_modules or a Cargo target/ directory) while a ProjectWalker
+// traverses the dev-tool search paths.
+type ArtifactDetector interface {
+	// Category is the scanner category the detector reports under.
+	Category() string
+
+	// Detect inspects a directory visited by the walker. matched reports
+	// whether the directory belongs to this detector; the walker never
+	// descends into matched directories. t is non-nil when the directory
+	// should be reported as a cleanup target. Sizes are filled in later
+	// by the walker, so detectors should leave Target.Size at zero.
+	// activity tells when a project was last worked on.
+	Detect(path string, d fs.DirEntry, now time.Time, activity ActivityFunc) (t *Target, matched bool)
+}
+
+// ActivityFunc returns when the project in dir was last worked on, judged
+// by the project's own git history and lockfiles, or fallback when neither
+// tells (see projectActivity).
+type ActivityFunc func(dir string, lockfiles []string, fallback time.Time) time.Time
+
+// GitStatusError reports artifacts that were left out because the git
+// status of their project could not be read, so uncommitted work in it
+// could not be ruled out.
+type GitStatusError struct {
+	Paths []string
+	Err   error
+}
+
+func (e *GitStatusError) Error() string {
+	if len(e.Paths) == 1 {
+		return fmt.Sprintf("skipped %s: %v", e.Paths[0], e.Err)
+	}
+	return fmt.Sprintf("skipped %d artifacts whose git status could not be read: %v", len(e.Paths), e.Err)
+}
+
+func (e *GitStatusError) Unwrap() error { return e.Err }
+
+// ProjectWalker traverses the dev-tool search paths once and dispatches
+// every directory to the registered detectors. Scanners that share a
+// walker collect their own category from the same traversal instead of
+// walking the search paths themselves.
+//
+// Artifacts of projects with uncommitted changes in their git repository
+// are not reported unless SetIncludeDirty(true) is called. Artifacts whose
+// project status cannot be read are left out and reported by Collect as a
+// *GitStatusError.
+type ProjectWalker struct {
+	searchPaths  []string
+	includeDirty bool
+
+	// lookPath is used to check if git is installed.
+	// Defaults to exec.LookPath; override in tests.
+	lookPath func(file string) (string, error)
+
+	// runCmd executes a command and returns its stdout.
+	// Defaults to exec.CommandContext(...).Output(); override in tests.
+	runCmd func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+	mu        sync.Mutex
+	detectors []ArtifactDetector
+	current   *walkRun
+}
+
+// walkRun is a single traversal shared by all detectors. It runs on its
+// own context, so that one caller giving up does not fail the others, and
+// is cancelled once every caller waiting for it has given up. Targets are
+// reported to the caller collecting their category as soon as they are
+// found. Until done is closed, fields other than done are guarded by the
+// walker lock; err and finished are only set before done is closed.
+type walkRun struct {
+	done      chan struct{}
+	cancel    context.CancelFunc
+	waiting   int
+	abandoned bool
+	finished  time.Time
+	found     map[string][]Target
+	skipped   map[string]*GitStatusError
+	listeners map[string]context.Context
+	claimed   map[string]bool
+	err       error
+}
+
+// NewProjectWalker returns a walker over the given search paths.
+func NewProjectWalker(searchPaths []string) *ProjectWalker {
+	return &ProjectWalker{
+		searchPaths: searchPaths,
+		lookPath:    exec.LookPath,
+		runCmd: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).Output()
+		},
+	}
+}
+
+// SetIncludeDirty controls whether artifacts of projects with uncommitted
+// changes are reported. It must be called before the first Collect.
+func (w *ProjectWalker) SetIncludeDirty(include bool) {
+	w.includeDirty = include
+}
+
+// Register adds a detector to the walker. Detectors registered after a
+// walk has started take part in the next walk.
+func (w *ProjectWalker) Register(d ArtifactDetector) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.detectors = append(w.detectors, d)
+}
+
+// Collect returns the sized targets found for category. Concurrent
+// callers share one traversal; a category that has already collected the
+// current traversal triggers a fresh one, so repeated scans never see
+// stale results. A caller whose ctx ends stops waiting, and the traversal
+// stops once no caller is left waiting for it. Targets are reported to the
+// progress in ctx as the traversal finds them, and each directory's size
+// as soon as it is measured. Artifacts left out for an unreadable git
+// status are reported as a *GitStatusError along with the targets.
+func (w *ProjectWalker) Collect(ctx context.Context, category string) ([]Target, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	w.mu.Lock()
+	run := w.current
+	if run == nil || run.claimed[category] || run.expired() {
+		run = w.start(ctx)
+	}
+	run.claimed[category] = true
+	run.waiting++
+	run.listeners[category] = ctx
+	early := append([]Target(nil), run.found[category]...)
+	w.mu.Unlock()
+	for _, t := range early {
+		emit(ctx, t)
+	}
+
+	select {
+	case <-run.done:
+	case <-ctx.Done():
+		w.mu.Lock()
+		delete(run.listeners, category)
+		if run.waiting--; run.waiting == 0 {
+			run.abandoned = true
+			run.cancel()
+		}
+		w.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	if run.err != nil {
+		return nil, run.err
+	}
+
+	found := run.found[category]
+	targets := make([]Target, len(found))
+	copy(targets, found)
+
+	var dirs []string
+	for _, t := range targets {
+		if t.IsDir {
+			dirs = append(dirs, t.Path)
+		}
+	}
+	_, seen := utils.SizingFrom(ctx)
+	usages := utils.DirCacheFrom(ctx).DirUsagesParallelFunc(ctx, dirs, seen, func(_ string, u utils.Usage) {
+		measured(ctx, u)
+	})
+	for i := range targets {
+		if targets[i].IsDir {
+			targets[i] = withUsage(ctx, targets[i], usages[targets[i].Path])
+		}
+	}
+
+	if skipped := run.skipped[category]; skipped != nil {
+		return targets, skipped
+	}
+	return targets, nil
+}
+
+// start begins a traversal with the registered detectors and makes it the
+// current one. The traversal keeps ctx's values but not its cancellation.
+// Callers must hold the walker lock.
+func (w *ProjectWalker) start(ctx context.Context) *walkRun {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	run := &walkRun{
+		done:      make(chan struct{}),
+		cancel:    cancel,
+		found:     make(map[string][]Target),
+		skipped:   make(map[string]*GitStatusError),
+		listeners: make(map[string]context.Context),
+		claimed:   make(map[string]bool),
+	}
+	w.current = run
+	detectors := append([]ArtifactDetector(nil), w.detectors...)
+
+	go func() {
+		defer cancel()
+		err := w.walk(ctx, detectors, func(category string, t Target, err error) {
+			w.mu.Lock()
+			if err != nil {
+				if run.skipped[category] == nil {
+					run.skipped[category] = &GitStatusError{Err: err}
+				}
+				run.skipped[category].Paths = append(run.skipped[category].Paths, t.Path)
+				w.mu.Unlock()
+				return
+			}
+			run.found[category] = append(run.found[category], t)
+			listener := run.listeners[category]
+			w.mu.Unlock()
+			if listener != nil {
+				emit(listener, t)
+			}
+		})
+		w.mu.Lock()
+		run.err = err
+		run.finished = time.Now()
+		w.mu.Unlock()
+		close(run.done)
+	}()
+	return run
+}
+
+// expired reports whether a run is too old to hand out, or was abandoned
+// by all its callers. Runs that are still in progress never expire
+// otherwise. Callers must hold the walker lock.
+func (r *walkRun) expired() bool {
+	if r.abandoned {
+		return true
+	}
+	select {
+	case <-r.done:
+		return r.err != nil || time.Since(r.finished) > walkReuseWindow
+	default:
+		return false
+	}
+}
+
+// walk traverses every search path once and passes each detected target
+// to report along with its category, and with the error that made it
+// skip the target, if any.
+func (w *ProjectWalker) walk(ctx context.Context, detectors []ArtifactDetector, report func(category string, t Target, err error)) error {
+	statuses := make(map[string]*gitStatus)
+	now := time.Now()
+	activity := func(dir string, lockfiles []string, fallback time.Time) time.Time {
+		return projectActivity(dir, lockfiles, fallback, func(root, dir string) (time.Time, bool) {
+			return w.lastCommit(ctx, root, dir)
+		})
+	}
+
+	for _, searchPath := range w.searchPaths {
+		if !utils.DirExists(searchPath) {
+			continue
+		}
+
+		err := filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil || !d.IsDir() {
+				return nil // skip inaccessible entries and files
+			}
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+
+			for _, det := range detectors {
+				t, matched := det.Detect(path, d, now, activity)
+				if !matched {
+					continue
+				}
+				if t == nil {
+					return fs.SkipDir
+				}
+				if w.includeDirty {
+					report(det.Category(), *t, nil)
+				} else if dirty, err := w.hasChanges(ctx, t.Path, statuses); err != nil || !dirty {
+					report(det.Category(), *t, err)
+				}
+				return fs.SkipDir
+			}
+			return nil
+		})
+
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Non-context errors during walk are non-fatal; skip this search path.
+	}
+
+	return nil
+}
+
+// gitStatus is the porcelain status of one project.
+type gitStatus struct {
+	entries []string
+	err     error
+}
+
+// hasChanges reports whether the project of artifact, the directory
+// holding it, has uncommitted changes in the git repository containing
+// it. Untracked files inside the artifact itself do not count. statuses
+// caches results per project. An error is returned when the status cannot
+// be read.
+func (w *ProjectWalker) hasChanges(ctx context.Context, artifact string, statuses map[string]*gitStatus) (bool, error) {
+	project := filepath.Dir(artifact)
+	root := findGitRoot(project)
+	if root == "" {
+		return false, nil
+	}
+	st, ok := statuses[project]
+	if !ok {
+		st = w.status(ctx, root, project)
+		statuses[project] = st
+	}
+	if st.err != nil {
+		return false, st.err
+	}
+
+	rel, err := filepath.Rel(root, artifact)
+	if err != nil {
+		return false, fmt.Errorf("failed to locate %s in %s: %w", artifact, root, err)
+	}
+	rel = filepath.ToSlash(rel)
+	for _, entry := range st.entries {
+		if p, ok := strings.CutPrefix(entry, "?? "); ok {
+			p = strings.TrimSuffix(p, "/")
+			if p == rel || strings.HasPrefix(p, rel+"/") {
+				continue
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// status runs git status for project in the repository at root. Paths in
+// the result are relative to root.
+func (w *ProjectWalker) status(ctx context.Context, root, project string) *gitStatus {
+	out, err := w.git(ctx, root, "status", "--porcelain", "-z", "--", pathspec(root, project))
+	if err != nil {
+		return &gitStatus{err: fmt.Errorf("failed to get git status of %s: %w", project, err)}
+	}
+	var entries []string
+	for _, e := range bytes.Split(out, []byte{0}) {
+		if len(e) > 0 {
+			entries = append(entries, string(e))
+		}
+	}
+	return &gitStatus{entries: entries}
+}
+
+// lastCommit returns the time of the last commit touching dir in the
+// repository at root.
+func (w *ProjectWalker) lastCommit(ctx context.Context, root, dir string) (time.Time, bool) {
+	out, err := w.git(ctx, root, "log", "-1", "--format=%ct", "--", pathspec(root, dir))
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// git runs git with args in the repository at root, within gitTimeout.
+func (w *ProjectWalker) git(ctx context.Context, root string, args ...string) ([]byte, error) {
+	if _, err := w.lookPath("git"); err != nil {
+		return nil, fmt.Errorf("failed to find git: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	return w.runCmd(ctx, "git", append([]string{"-C", root}, args...)...)
+}
+
+// pathspec returns dir relative to root, for limiting a git command to
+// dir. The repository root itself is ".".
+func pathspec(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "."
+	}
+	return filepath.ToSlash(rel)
+}
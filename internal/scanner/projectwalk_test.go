@@ -0,0 +1,395 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingDetector wraps a detector and counts how often the walker visits
+// the search root, which happens once per traversal.
+type countingDetector struct {
+	ArtifactDetector
+	root   string
+	visits *atomic.Int32
+}
+
+func (c countingDetector) Detect(path string, d fs.DirEntry, now time.Time, activity ActivityFunc) (*Target, bool) {
+	if path == c.root {
+		c.visits.Add(1)
+	}
+	return c.ArtifactDetector.Detect(path, d, now, activity)
+}
+
+func makeStaleProjects(t *testing.T) (searchDir, nmDir, venvDir, targetDir string) {
+	t.Helper()
+	searchDir = t.TempDir()
+	oldTime := time.Now().Add(-60 * 24 * time.Hour)
+
+	nmDir = filepath.Join(searchDir, "web", "node_modules")
+	venvDir = filepath.Join(searchDir, "api", ".venv")
+	targetDir = filepath.Join(searchDir, "cli", "target")
+	for _, dir := range []string{nmDir, venvDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 512), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(venvDir, "pyvenv.cfg"), []byte("home = /usr/bin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(searchDir, "cli", "Cargo.toml"), []byte("[package]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{nmDir, venvDir, targetDir} {
+		if err := os.Chtimes(dir, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return searchDir, nmDir, venvDir, targetDir
+}
+
+func TestProjectWalker_SharedTraversal(t *testing.T) {
+	searchDir, nmDir, venvDir, targetDir := makeStaleProjects(t)
+	maxAge := 30 * 24 * time.Hour
+
+	var visits atomic.Int32
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(countingDetector{nodeModulesDetector{maxAge: maxAge}, searchDir, &visits})
+	w.Register(venvDetector{maxAge: maxAge})
+	w.Register(cargoTargetDetector{maxAge: maxAge})
+
+	want := map[string]string{
+		"Node.js": nmDir,
+		"Python":  venvDir,
+		"Rust":    targetDir,
+	}
+
+	var wg sync.WaitGroup
+	for category, path := range want {
+		wg.Add(1)
+		go func(category, path string) {
+			defer wg.Done()
+			targets, err := w.Collect(context.Background(), category)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", category, err)
+				return
+			}
+			if len(targets) != 1 || targets[0].Path != path {
+				t.Errorf("%s: expected single target %s, got %+v", category, path, targets)
+				return
+			}
+			if targets[0].Category != category {
+				t.Errorf("%s: expected category %q, got %q", category, category, targets[0].Category)
+			}
+			if targets[0].Size == 0 {
+				t.Errorf("%s: expected non-zero size", category)
+			}
+		}(category, path)
+	}
+	wg.Wait()
+
+	if got := visits.Load(); got != 1 {
+		t.Errorf("expected search paths to be walked once, got %d walks", got)
+	}
+}
+
+func TestProjectWalker_RescanWalksAgain(t *testing.T) {
+	searchDir, nmDir, _, _ := makeStaleProjects(t)
+
+	var visits atomic.Int32
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(countingDetector{nodeModulesDetector{maxAge: 30 * 24 * time.Hour}, searchDir, &visits})
+
+	for i := 0; i < 2; i++ {
+		targets, err := w.Collect(context.Background(), "Node.js")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(targets) != 1 || targets[0].Path != nmDir {
+			t.Fatalf("expected %s, got %+v", nmDir, targets)
+		}
+	}
+
+	if got := visits.Load(); got != 2 {
+		t.Errorf("expected a fresh walk per scan, got %d walks", got)
+	}
+}
+
+func TestProjectWalker_SkipsGitDirs(t *testing.T) {
+	searchDir := t.TempDir()
+	hidden := filepath.Join(searchDir, "repo", ".git", "node_modules")
+	if err := os.MkdirAll(hidden, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(nodeModulesDetector{})
+
+	targets, err := w.Collect(context.Background(), "Node.js")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("expected .git to be skipped, got %+v", targets)
+	}
+}
+
+func TestProjectWalker_ContextCancelled(t *testing.T) {
+	searchDir, _, _, _ := makeStaleProjects(t)
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(nodeModulesDetector{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := w.Collect(ctx, "Node.js"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// A cancelled walk must not be reused by the next caller.
+	targets, err := w.Collect(context.Background(), "Node.js")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 1 {
+		t.Errorf("expected 1 target after retry, got %d", len(targets))
+	}
+}
+
+// blockingDetector wraps a detector and holds the walk at the search root
+// until release is closed.
+type blockingDetector struct {
+	ArtifactDetector
+	root    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingDetector) Detect(path string, d fs.DirEntry, now time.Time, activity ActivityFunc) (*Target, bool) {
+	if path == b.root {
+		close(b.started)
+		<-b.release
+	}
+	return b.ArtifactDetector.Detect(path, d, now, activity)
+}
+
+func TestProjectWalker_CallerCancelDoesNotFailOthers(t *testing.T) {
+	searchDir, _, venvDir, _ := makeStaleProjects(t)
+	maxAge := 30 * 24 * time.Hour
+	started, release := make(chan struct{}), make(chan struct{})
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(blockingDetector{nodeModulesDetector{maxAge: maxAge}, searchDir, started, release})
+	w.Register(venvDetector{maxAge: maxAge})
+
+	// The Node.js scan starts the walk, then times out.
+	ctx, cancel := context.WithCancel(context.Background())
+	nodeErr := make(chan error)
+	go func() {
+		_, err := w.Collect(ctx, "Node.js")
+		nodeErr <- err
+	}()
+	<-started
+
+	type result struct {
+		targets []Target
+		err     error
+	}
+	python := make(chan result)
+	go func() {
+		targets, err := w.Collect(context.Background(), "Python")
+		python <- result{targets, err}
+	}()
+	for {
+		w.mu.Lock()
+		waiting := w.current.waiting
+		w.mu.Unlock()
+		if waiting == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-nodeErr; err != context.Canceled {
+		t.Fatalf("expected context.Canceled for the cancelled caller, got %v", err)
+	}
+	close(release)
+
+	r := <-python
+	if r.err != nil {
+		t.Fatalf("expected the other caller to finish, got %v", r.err)
+	}
+	if len(r.targets) != 1 || r.targets[0].Path != venvDir {
+		t.Errorf("expected %s, got %+v", venvDir, r.targets)
+	}
+}
+
+// chanProgress forwards found targets to a channel, so a test can watch
+// them arrive while a walk is still running.
+type chanProgress struct {
+	found chan Target
+	bytes atomic.Int64
+}
+
+func (c *chanProgress) Found(t Target)       { c.found <- t }
+func (c *chanProgress) Measured(bytes int64) { c.bytes.Add(bytes) }
+
+func TestProjectWalker_ReportsTargetsDuringWalk(t *testing.T) {
+	searchDir, _, venvDir, _ := makeStaleProjects(t)
+	maxAge := 30 * 24 * time.Hour
+	// The walk reaches api/.venv first, then holds at cli/.
+	started, release := make(chan struct{}), make(chan struct{})
+	w := NewProjectWalker([]string{searchDir})
+	w.Register(blockingDetector{venvDetector{maxAge: maxAge}, filepath.Join(searchDir, "cli"), started, release})
+
+	progress := &chanProgress{found: make(chan Target, 1)}
+	ctx := WithProgress(context.Background(), progress)
+	done := make(chan []Target)
+	go func() {
+		targets, _ := w.Collect(ctx, "Python")
+		done <- targets
+	}()
+
+	select {
+	case found := <-progress.found:
+		if found.Path != venvDir {
+			t.Errorf("expected %s to be reported, got %s", venvDir, found.Path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the venv to be reported before the walk finished")
+	}
+	<-started
+	close(release)
+
+	targets := <-done
+	if len(targets) != 1 || targets[0].Size == 0 {
+		t.Fatalf("expected the sized venv, got %+v", targets)
+	}
+	if got := progress.bytes.Load(); got != targets[0].Size {
+		t.Errorf("expected %d bytes measured, got %d", targets[0].Size, got)
+	}
+}
+
+func TestProjectWalker_DirtyRepos(t *testing.T) {
+	searchDir, nmDir, _, _ := makeStaleProjects(t)
+	repo := filepath.Join(searchDir, "web")
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		status       string
+		includeDirty bool
+		want         int
+	}{
+		{"clean", "", false, 1},
+		{"untracked artifact only", "?? node_modules/\x00", false, 1},
+		{"modified file", " M index.js\x00?? node_modules/\x00", false, 0},
+		{"untracked source", "?? src/new.js\x00", false, 0},
+		{"dirty but included", " M index.js\x00", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			w := NewProjectWalker([]string{searchDir})
+			w.SetIncludeDirty(tt.includeDirty)
+			w.lookPath = func(string) (string, error) { return "/usr/bin/git", nil }
+			w.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				if args[2] != "status" {
+					return nil, errors.New("no commits")
+				}
+				calls++
+				if args[1] != repo {
+					t.Errorf("git status run in %s, want %s", args[1], repo)
+				}
+				return []byte(tt.status), nil
+			}
+			w.Register(nodeModulesDetector{maxAge: 30 * 24 * time.Hour})
+
+			targets, err := w.Collect(context.Background(), "Node.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(targets) != tt.want {
+				t.Errorf("expected %d targets, got %+v", tt.want, targets)
+			}
+			if len(targets) == 1 && targets[0].Path != nmDir {
+				t.Errorf("unexpected target %s", targets[0].Path)
+			}
+			if tt.includeDirty && calls != 0 {
+				t.Errorf("git status should not run when dirty projects are included")
+			}
+		})
+	}
+}
+
+func TestProjectWalker_DirtyStatusIsPerProject(t *testing.T) {
+	searchDir := t.TempDir()
+	repo := filepath.Join(searchDir, "mono")
+	oldTime := time.Now().Add(-60 * 24 * time.Hour)
+	for _, app := range []string{"web", "admin"} {
+		nmDir := filepath.Join(repo, app, "node_modules")
+		if err := os.MkdirAll(nmDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(nmDir, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only admin has uncommitted work; git limits status to the pathspec.
+	w := NewProjectWalker([]string{searchDir})
+	w.lookPath = func(string) (string, error) { return "/usr/bin/git", nil }
+	w.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if args[2] != "status" {
+			return nil, errors.New("no commits")
+		}
+		if args[len(args)-1] == "admin" {
+			return []byte(" M admin/index.js\x00"), nil
+		}
+		return nil, nil
+	}
+	w.Register(nodeModulesDetector{maxAge: 30 * 24 * time.Hour})
+
+	targets, err := w.Collect(context.Background(), "Node.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Path != filepath.Join(repo, "web", "node_modules") {
+		t.Errorf("expected only the clean project's node_modules, got %+v", targets)
+	}
+}
+
+func TestProjectWalker_GitStatusFailureIsReported(t *testing.T) {
+	searchDir, _, _, _ := makeStaleProjects(t)
+	nmDir := filepath.Join(searchDir, "web", "node_modules")
+	if err := os.Mkdir(filepath.Join(searchDir, "web", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewProjectWalker([]string{searchDir})
+	w.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	w.Register(nodeModulesDetector{maxAge: 30 * 24 * time.Hour})
+
+	targets, err := w.Collect(context.Background(), "Node.js")
+	var gse *GitStatusError
+	if !errors.As(err, &gse) || len(gse.Paths) != 1 || gse.Paths[0] != nmDir {
+		t.Fatalf("expected the skipped artifact to be reported, got %v", err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("expected artifacts with unknown status to be skipped, got %+v", targets)
+	}
+}
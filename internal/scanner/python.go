@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -11,11 +12,14 @@ import (
 	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
+// condaRoots are the conda distributions whose package caches are scanned.
+var condaRoots = []string{"miniconda3", "anaconda3", "miniforge3"}
+
 // PythonScanner detects pip cache, conda packages, and stale virtualenvs.
 type PythonScanner struct {
-	home        string
-	searchPaths []string
-	maxAge      time.Duration
+	home   string
+	maxAge time.Duration
+	walker *ProjectWalker
 }
 
 // NewPythonScanner returns a new PythonScanner.
@@ -23,11 +27,16 @@ type PythonScanner struct {
 //   - searchPaths: directories to walk looking for stale virtualenvs
 //   - maxAge: threshold after which a virtualenv is considered stale
 func NewPythonScanner(home string, searchPaths []string, maxAge time.Duration) *PythonScanner {
-	return &PythonScanner{
-		home:        home,
-		searchPaths: searchPaths,
-		maxAge:      maxAge,
-	}
+	s := &PythonScanner{home: home, maxAge: maxAge}
+	s.SetWalker(NewProjectWalker(searchPaths))
+	return s
+}
+
+// SetWalker makes the scanner collect stale virtualenvs from a shared
+// ProjectWalker instead of walking its own search paths.
+func (s *PythonScanner) SetWalker(w *ProjectWalker) {
+	w.Register(venvDetector{maxAge: s.maxAge})
+	s.walker = w
 }
 
 func (s *PythonScanner) Name() string { return "Python" }
@@ -36,6 +45,15 @@ func (s *PythonScanner) Description() string {
 }
 func (s *PythonScanner) Risk() RiskLevel { return Safe }
 
+// Roots implements Rooted.
+func (s *PythonScanner) Roots() []string {
+	roots := []string{filepath.Join(s.home, "Library", "Caches", "pip")}
+	for _, condaRoot := range condaRoots {
+		roots = append(roots, filepath.Join(s.home, condaRoot, "pkgs"))
+	}
+	return append(roots, s.walker.searchPaths...)
+}
+
 func (s *PythonScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -46,15 +64,13 @@ func (s *PythonScanner) Scan(ctx context.Context) ([]Target, error) {
 	// --- pip cache ---
 	pipCache := filepath.Join(s.home, "Library", "Caches", "pip")
 	if utils.DirExists(pipCache) {
-		size, _ := utils.DirSize(pipCache)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        pipCache,
-			Size:        size,
 			Category:    "Python",
 			Description: "pip download cache",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, pipCache)))
 	}
 
 	if ctx.Err() != nil {
@@ -62,18 +78,16 @@ func (s *PythonScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	// --- conda package caches ---
-	for _, condaRoot := range []string{"miniconda3", "anaconda3", "miniforge3"} {
+	for _, condaRoot := range condaRoots {
 		pkgsDir := filepath.Join(s.home, condaRoot, "pkgs")
 		if utils.DirExists(pkgsDir) {
-			size, _ := utils.DirSize(pkgsDir)
-			targets = append(targets, Target{
+			targets = append(targets, withUsage(ctx, Target{
 				Path:        pkgsDir,
-				Size:        size,
 				Category:    "Python",
 				Description: fmt.Sprintf("%s package cache", condaRoot),
 				Risk:        Safe,
 				IsDir:       true,
-			})
+			}, measureDir(ctx, pkgsDir)))
 		}
 	}
 
@@ -82,65 +96,50 @@ func (s *PythonScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	// --- stale virtualenvs ---
-	now := time.Now()
-	for _, searchPath := range s.searchPaths {
-		if !utils.DirExists(searchPath) {
-			continue
-		}
+	stale, err := s.walker.Collect(ctx, s.Name())
+	if gse := (*GitStatusError)(nil); err != nil && !errors.As(err, &gse) {
+		return nil, err
+	}
+	// Projects skipped for their git status are reported with the rest.
+	return append(targets, stale...), err
+}
 
-		err := filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			if err != nil {
-				return nil // skip inaccessible entries
-			}
-			if !d.IsDir() {
-				return nil
-			}
-
-			name := d.Name()
-			if name != ".venv" && name != "venv" {
-				return nil
-			}
-
-			// Confirm it's a real virtualenv by checking for pyvenv.cfg.
-			if _, statErr := os.Stat(filepath.Join(path, "pyvenv.cfg")); statErr != nil {
-				return fs.SkipDir
-			}
-
-			info, infoErr := os.Stat(path)
-			if infoErr != nil {
-				return fs.SkipDir
-			}
-
-			age := now.Sub(info.ModTime())
-			if s.maxAge > 0 && age < s.maxAge {
-				return fs.SkipDir
-			}
-
-			size, _ := utils.DirSize(path)
-			targets = append(targets, Target{
-				Path:        path,
-				Size:        size,
-				Category:    "Python",
-				Description: fmt.Sprintf("stale virtualenv (unused for %d days)", int(age.Hours()/24)),
-				Risk:        Moderate,
-				ModTime:     info.ModTime(),
-				IsDir:       true,
-			})
+// venvDetector reports .venv/venv directories containing pyvenv.cfg whose
+// project has had no activity (see projectActivity) within maxAge.
+type venvDetector struct {
+	maxAge time.Duration
+}
 
-			// Don't descend into virtualenv directories regardless of staleness.
-			return fs.SkipDir
-		})
+func (venvDetector) Category() string { return "Python" }
 
-		if err != nil {
-			if ctx.Err() != nil {
-				return nil, ctx.Err()
-			}
-			// Non-context errors during walk are non-fatal; skip this search path.
-		}
+func (d venvDetector) Detect(path string, entry fs.DirEntry, now time.Time, activity ActivityFunc) (*Target, bool) {
+	name := entry.Name()
+	if name != ".venv" && name != "venv" {
+		return nil, false
+	}
+
+	// Confirm it's a real virtualenv by checking for pyvenv.cfg.
+	if _, err := os.Stat(filepath.Join(path, "pyvenv.cfg")); err != nil {
+		return nil, true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, true
+	}
+
+	active := activity(filepath.Dir(path), pythonLockfiles, info.ModTime())
+	age := now.Sub(active)
+	if d.maxAge > 0 && age < d.maxAge {
+		return nil, true
 	}
 
-	return targets, nil
+	return &Target{
+		Path:        path,
+		Category:    "Python",
+		Description: fmt.Sprintf("stale virtualenv (project inactive for %d days)", int(age.Hours()/24)),
+		Risk:        Moderate,
+		ModTime:     active,
+		IsDir:       true,
+	}, true
 }
@@ -0,0 +1,251 @@
+package scanner
+
+import (
+	"strings"
+	"time"
+)
+
+// Profile names a group of built-in scanners selected together, such as
+// --dev or --caches.
+type Profile string
+
+const (
+	// ProfileDev groups the developer-tool caches.
+	ProfileDev Profile = "dev"
+	// ProfileCaches groups general-purpose caches.
+	ProfileCaches Profile = "caches"
+)
+
+// Profiles lists every profile in flag order.
+var Profiles = []Profile{ProfileDev, ProfileCaches}
+
+// Options carries the settings built-in scanners are constructed with.
+type Options struct {
+	Home string
+
+	// DevPaths and DevMinAge configure the Node.js, Python and Rust
+	// scanners. Walker, when set, is shared between them so the search
+	// paths are traversed once.
+	DevPaths  []string
+	DevMinAge time.Duration
+	Walker    *ProjectWalker
+
+	LargeFilePaths   []string
+	LargeFileMinSize int64
+	LargeFileMinAge  time.Duration
+
+	// DockerSocket overrides Docker daemon socket discovery when set.
+	// DockerContainers and DockerVolumes make the Docker scanner report
+	// stopped containers and unused volumes.
+	DockerSocket     string
+	DockerContainers bool
+	DockerVolumes    bool
+}
+
+// Info describes a built-in scanner. The CLI flags, config keys, schedule
+// categories and TUI colors are all derived from the registry.
+type Info struct {
+	// ID is the stable identifier of the scanner.
+	ID string
+	// Name is the display name and the Category of its targets.
+	Name string
+	// Flag is the scan/clean flag (without dashes) and the name accepted
+	// in schedule.categories.
+	Flag string
+	// ConfigKey is the key under "scanners" in the config file.
+	ConfigKey string
+	// Help completes the flag help text, as in "Scan <Help> only".
+	Help string
+	// Profiles lists the profiles that include this scanner.
+	Profiles []Profile
+	// Color is the ANSI-256 color code the TUI draws the category in.
+	Color string
+	// New constructs the scanner.
+	New func(Options) Scanner
+}
+
+// InProfile reports whether the scanner belongs to profile p.
+func (i Info) InProfile(p Profile) bool {
+	for _, q := range i.Profiles {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// registry lists the built-in scanners in display order.
+var registry = []Info{
+	{
+		ID: "system", Name: "System Junk", Flag: "system", ConfigKey: "system",
+		Help: "system junk", Profiles: []Profile{ProfileCaches}, Color: "75",
+		New: func(Options) Scanner { return NewSystemScanner("") },
+	},
+	{
+		ID: "browser", Name: "Browser Cache", Flag: "browser", ConfigKey: "browser",
+		Help: "browser caches", Profiles: []Profile{ProfileCaches}, Color: "214",
+		New: func(Options) Scanner { return NewBrowserScanner("", "") },
+	},
+	{
+		ID: "xcode", Name: "Xcode Junk", Flag: "xcode", ConfigKey: "xcode",
+		Help: "Xcode junk", Color: "141",
+		New: func(Options) Scanner { return NewXcodeScanner("") },
+	},
+	{
+		ID: "large_files", Name: "Large & Old Files", Flag: "large", ConfigKey: "large_files",
+		Help: "large/old files", Color: "223",
+		New: func(o Options) Scanner {
+			return NewLargeFileScanner(o.LargeFilePaths, o.LargeFileMinSize, o.LargeFileMinAge)
+		},
+	},
+	{
+		ID: "docker", Name: "Docker", Flag: "docker", ConfigKey: "docker",
+		Help: "Docker junk", Color: "39",
+		New: func(o Options) Scanner {
+			s := NewDockerScanner()
+			if o.DockerSocket != "" {
+				s.SetSocket(o.DockerSocket)
+			}
+			s.SetIncludeContainers(o.DockerContainers)
+			s.SetIncludeVolumes(o.DockerVolumes)
+			return s
+		},
+	},
+	{
+		ID: "node", Name: "Node.js", Flag: "node", ConfigKey: "node",
+		Help: "Node.js cache", Profiles: []Profile{ProfileDev}, Color: "119",
+		New: func(o Options) Scanner {
+			s := NewNodeScanner(o.Home, o.DevPaths, o.DevMinAge)
+			if o.Walker != nil {
+				s.SetWalker(o.Walker)
+			}
+			return s
+		},
+	},
+	{
+		ID: "homebrew", Name: "Homebrew", Flag: "homebrew", ConfigKey: "homebrew",
+		Help: "Homebrew cache", Profiles: []Profile{ProfileCaches}, Color: "208",
+		New: func(Options) Scanner { return NewHomebrewScanner() },
+	},
+	{
+		ID: "simulator", Name: "iOS Simulators", Flag: "simulator", ConfigKey: "ios_simulators",
+		Help: "iOS Simulator data", Color: "183",
+		New: func(Options) Scanner { return NewSimulatorScanner("") },
+	},
+	{
+		ID: "python", Name: "Python", Flag: "python", ConfigKey: "python",
+		Help: "Python cache", Profiles: []Profile{ProfileDev}, Color: "220",
+		New: func(o Options) Scanner {
+			s := NewPythonScanner(o.Home, o.DevPaths, o.DevMinAge)
+			if o.Walker != nil {
+				s.SetWalker(o.Walker)
+			}
+			return s
+		},
+	},
+	{
+		ID: "rust", Name: "Rust", Flag: "rust", ConfigKey: "rust",
+		Help: "Rust cache", Profiles: []Profile{ProfileDev}, Color: "173",
+		New: func(o Options) Scanner {
+			s := NewRustScanner(o.Home, o.DevPaths, o.DevMinAge)
+			if o.Walker != nil {
+				s.SetWalker(o.Walker)
+			}
+			return s
+		},
+	},
+	{
+		ID: "go", Name: "Go", Flag: "go", ConfigKey: "go",
+		Help: "Go cache", Profiles: []Profile{ProfileDev}, Color: "74",
+		New: func(o Options) Scanner { return NewGoScanner(o.Home) },
+	},
+	{
+		ID: "jetbrains", Name: "JetBrains", Flag: "jetbrains", ConfigKey: "jetbrains",
+		Help: "JetBrains cache", Profiles: []Profile{ProfileDev}, Color: "171",
+		New: func(o Options) Scanner { return NewJetBrainsScanner(o.Home) },
+	},
+	{
+		ID: "maven", Name: "Maven", Flag: "maven", ConfigKey: "maven",
+		Help: "Maven cache", Profiles: []Profile{ProfileDev}, Color: "167",
+		New: func(o Options) Scanner { return NewMavenScanner(o.Home) },
+	},
+	{
+		ID: "gradle", Name: "Gradle", Flag: "gradle", ConfigKey: "gradle",
+		Help: "Gradle cache", Profiles: []Profile{ProfileDev}, Color: "108",
+		New: func(o Options) Scanner { return NewGradleScanner(o.Home) },
+	},
+	{
+		ID: "ruby", Name: "Ruby", Flag: "ruby", ConfigKey: "ruby",
+		Help: "Ruby cache", Profiles: []Profile{ProfileDev}, Color: "161",
+		New: func(o Options) Scanner { return NewRubyScanner(o.Home) },
+	},
+}
+
+// Registry returns the built-in scanners in display order.
+func Registry() []Info {
+	return append([]Info(nil), registry...)
+}
+
+// Lookup returns the built-in scanner with the given ID.
+func Lookup(id string) (Info, bool) {
+	for _, info := range registry {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return Info{}, false
+}
+
+// LookupName returns the built-in scanner whose display name is name,
+// ignoring case.
+func LookupName(name string) (Info, bool) {
+	for _, info := range registry {
+		if strings.EqualFold(info.Name, name) {
+			return info, true
+		}
+	}
+	return Info{}, false
+}
+
+// LookupFlag returns the built-in scanner selected by flag.
+func LookupFlag(flag string) (Info, bool) {
+	for _, info := range registry {
+		if info.Flag == flag {
+			return info, true
+		}
+	}
+	return Info{}, false
+}
+
+// LookupConfigKey returns the built-in scanner configured under key.
+func LookupConfigKey(key string) (Info, bool) {
+	for _, info := range registry {
+		if info.ConfigKey == key {
+			return info, true
+		}
+	}
+	return Info{}, false
+}
+
+// CategoryFlags returns every name accepted as a category selector: the
+// scanner flags, then the profiles, then "all".
+func CategoryFlags() []string {
+	flags := make([]string, 0, len(registry)+len(Profiles)+1)
+	for _, info := range registry {
+		flags = append(flags, info.Flag)
+	}
+	for _, p := range Profiles {
+		flags = append(flags, string(p))
+	}
+	return append(flags, "all")
+}
+
+// IsCategoryFlag reports whether s is one of CategoryFlags.
+func IsCategoryFlag(s string) bool {
+	for _, f := range CategoryFlags() {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package scanner
+
+import "testing"
+
+func TestRegistry_Consistent(t *testing.T) {
+	ids := make(map[string]bool)
+	flags := make(map[string]bool)
+	keys := make(map[string]bool)
+	for _, info := range Registry() {
+		if ids[info.ID] || flags[info.Flag] || keys[info.ConfigKey] {
+			t.Errorf("%s: duplicate ID, flag or config key", info.Name)
+		}
+		ids[info.ID], flags[info.Flag], keys[info.ConfigKey] = true, true, true
+
+		if info.Help == "" || info.Color == "" {
+			t.Errorf("%s: missing help text or color", info.Name)
+		}
+		if got := info.New(Options{Home: t.TempDir()}).Name(); got != info.Name {
+			t.Errorf("registry name %q does not match scanner name %q", info.Name, got)
+		}
+	}
+}
+
+func TestRegistry_Lookups(t *testing.T) {
+	info, ok := LookupFlag("simulator")
+	if !ok || info.Name != "iOS Simulators" || info.ConfigKey != "ios_simulators" {
+		t.Errorf("LookupFlag(simulator) = %+v, %v", info, ok)
+	}
+	if info, ok := LookupName("node.js"); !ok || info.ID != "node" {
+		t.Errorf("LookupName(node.js) = %+v, %v", info, ok)
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Error("expected no scanner with ID nope")
+	}
+	for _, s := range []string{"large", "dev", "caches", "all"} {
+		if !IsCategoryFlag(s) {
+			t.Errorf("expected %q to be a category flag", s)
+		}
+	}
+	if IsCategoryFlag("large_files") {
+		t.Error("config keys are not category flags")
+	}
+}
@@ -21,6 +21,11 @@ func (s *RubyScanner) Name() string        { return "Ruby" }
 func (s *RubyScanner) Description() string { return "Ruby gem and Bundler cache" }
 func (s *RubyScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *RubyScanner) Roots() []string {
+	return []string{filepath.Join(s.home, ".gem"), filepath.Join(s.home, ".bundle")}
+}
+
 func (s *RubyScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -30,15 +35,13 @@ func (s *RubyScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	gemDir := filepath.Join(s.home, ".gem")
 	if utils.DirExists(gemDir) {
-		size, _ := utils.DirSize(gemDir)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        gemDir,
-			Size:        size,
 			Category:    "Ruby",
 			Description: "Ruby gem cache",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, gemDir)))
 	}
 
 	if ctx.Err() != nil {
@@ -47,15 +50,13 @@ func (s *RubyScanner) Scan(ctx context.Context) ([]Target, error) {
 
 	bundleCache := filepath.Join(s.home, ".bundle", "cache")
 	if utils.DirExists(bundleCache) {
-		size, _ := utils.DirSize(bundleCache)
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        bundleCache,
-			Size:        size,
 			Category:    "Ruby",
 			Description: "Bundler cache",
 			Risk:        Safe,
 			IsDir:       true,
-		})
+		}, measureDir(ctx, bundleCache)))
 	}
 
 	return targets, nil
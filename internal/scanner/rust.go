@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -13,9 +14,9 @@ import (
 
 // RustScanner detects cargo registry cache and stale target directories.
 type RustScanner struct {
-	home        string
-	searchPaths []string
-	maxAge      time.Duration
+	home   string
+	maxAge time.Duration
+	walker *ProjectWalker
 }
 
 // NewRustScanner returns a new RustScanner.
@@ -23,7 +24,16 @@ type RustScanner struct {
 //   - searchPaths: directories to walk looking for stale target/ dirs
 //   - maxAge: threshold after which a target/ dir is considered stale
 func NewRustScanner(home string, searchPaths []string, maxAge time.Duration) *RustScanner {
-	return &RustScanner{home: home, searchPaths: searchPaths, maxAge: maxAge}
+	s := &RustScanner{home: home, maxAge: maxAge}
+	s.SetWalker(NewProjectWalker(searchPaths))
+	return s
+}
+
+// SetWalker makes the scanner collect stale target/ directories from a
+// shared ProjectWalker instead of walking its own search paths.
+func (s *RustScanner) SetWalker(w *ProjectWalker) {
+	w.Register(cargoTargetDetector{maxAge: s.maxAge})
+	s.walker = w
 }
 
 func (s *RustScanner) Name() string { return "Rust" }
@@ -32,6 +42,11 @@ func (s *RustScanner) Description() string {
 }
 func (s *RustScanner) Risk() RiskLevel { return Safe }
 
+// Roots implements Rooted.
+func (s *RustScanner) Roots() []string {
+	return append([]string{filepath.Join(s.home, ".cargo")}, s.walker.searchPaths...)
+}
+
 func (s *RustScanner) Scan(ctx context.Context) ([]Target, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -43,15 +58,13 @@ func (s *RustScanner) Scan(ctx context.Context) ([]Target, error) {
 	for _, sub := range []string{"registry/cache", "registry/src"} {
 		dir := filepath.Join(s.home, ".cargo", sub)
 		if utils.DirExists(dir) {
-			size, _ := utils.DirSize(dir)
-			targets = append(targets, Target{
+			targets = append(targets, withUsage(ctx, Target{
 				Path:        dir,
-				Size:        size,
 				Category:    "Rust",
 				Description: fmt.Sprintf("Cargo %s", sub),
 				Risk:        Safe,
 				IsDir:       true,
-			})
+			}, measureDir(ctx, dir)))
 		}
 	}
 
@@ -60,61 +73,49 @@ func (s *RustScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	// --- stale target/ directories ---
-	now := time.Now()
-	for _, searchPath := range s.searchPaths {
-		if !utils.DirExists(searchPath) {
-			continue
-		}
+	stale, err := s.walker.Collect(ctx, s.Name())
+	if gse := (*GitStatusError)(nil); err != nil && !errors.As(err, &gse) {
+		return nil, err
+	}
+	// Projects skipped for their git status are reported with the rest.
+	return append(targets, stale...), err
+}
 
-		err := filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			if err != nil || !d.IsDir() {
-				return nil
-			}
-			if d.Name() != "target" {
-				return nil
-			}
-
-			// Confirm it's a Rust project by checking for Cargo.toml in the parent.
-			parent := filepath.Dir(path)
-			if _, err := os.Stat(filepath.Join(parent, "Cargo.toml")); err != nil {
-				return nil
-			}
-
-			info, err := os.Stat(path)
-			if err != nil {
-				return fs.SkipDir
-			}
-
-			if s.maxAge > 0 {
-				age := now.Sub(info.ModTime())
-				if age < s.maxAge {
-					return fs.SkipDir
-				}
-			}
-
-			size, _ := utils.DirSize(path)
-			targets = append(targets, Target{
-				Path:        path,
-				Size:        size,
-				Category:    "Rust",
-				Description: fmt.Sprintf("Rust build artifacts (%s)", filepath.Base(parent)),
-				Risk:        Moderate,
-				ModTime:     info.ModTime(),
-				IsDir:       true,
-			})
-			return fs.SkipDir
-		})
-
-		if err != nil {
-			if ctx.Err() != nil {
-				return nil, ctx.Err()
-			}
-			// Non-context errors during walk are non-fatal; skip this search path.
-		}
+// cargoTargetDetector reports target/ directories next to a Cargo.toml
+// whose project has had no activity (see projectActivity) within maxAge.
+type cargoTargetDetector struct {
+	maxAge time.Duration
+}
+
+func (cargoTargetDetector) Category() string { return "Rust" }
+
+func (d cargoTargetDetector) Detect(path string, entry fs.DirEntry, now time.Time, activity ActivityFunc) (*Target, bool) {
+	if entry.Name() != "target" {
+		return nil, false
+	}
+
+	// Confirm it's a Rust project by checking for Cargo.toml in the parent.
+	parent := filepath.Dir(path)
+	if _, err := os.Stat(filepath.Join(parent, "Cargo.toml")); err != nil {
+		return nil, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, true
+	}
+
+	active := activity(parent, rustLockfiles, info.ModTime())
+	if d.maxAge > 0 && now.Sub(active) < d.maxAge {
+		return nil, true
 	}
 
-	return targets, nil
+	return &Target{
+		Path:        path,
+		Category:    "Rust",
+		Description: fmt.Sprintf("Rust build artifacts (%s)", filepath.Base(parent)),
+		Risk:        Moderate,
+		ModTime:     active,
+		IsDir:       true,
+	}, true
 }
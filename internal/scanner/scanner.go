@@ -2,7 +2,12 @@ package scanner
 
 import (
 	"context"
+	"fmt"
+	"io/fs"
+	"strings"
 	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
 )
 
 type RiskLevel int
@@ -26,6 +31,74 @@ func (r RiskLevel) String() string {
 	}
 }
 
+// ParseRiskLevel parses "safe", "moderate" or "risky" (case-insensitive).
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "safe":
+		return Safe, nil
+	case "moderate":
+		return Moderate, nil
+	case "risky":
+		return Risky, nil
+	default:
+		return Safe, fmt.Errorf("unknown risk level %q (use safe, moderate or risky)", s)
+	}
+}
+
+// ActionKind identifies how a target is cleaned up.
+type ActionKind int
+
+const (
+	// ActionDelete removes the target's Path from the filesystem.
+	ActionDelete ActionKind = iota
+	// ActionCommand runs an external command such as `docker rmi`.
+	ActionCommand
+)
+
+func (k ActionKind) String() string {
+	switch k {
+	case ActionDelete:
+		return "delete"
+	case ActionCommand:
+		return "command"
+	default:
+		return "unknown"
+	}
+}
+
+// Action describes how to clean up a target that is not a plain file or
+// directory. Targets without an Action are deleted from the filesystem.
+type Action struct {
+	Kind    ActionKind `json:"kind"`
+	Command []string   `json:"command,omitempty"`
+	// Stdin, when set, is written to the command's standard input.
+	Stdin string `json:"stdin,omitempty"`
+	// Timeout, when positive, bounds how long the command may run. It
+	// still applies once cleanup has been cancelled.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// PermanentOnly marks a command that replaces deletion only when
+	// cleaning permanently. The target is otherwise a filesystem target,
+	// guarded and moved to Trash like any other.
+	PermanentOnly bool `json:"permanent_only,omitempty"`
+}
+
+// CommandAction returns an action that runs name with args.
+func CommandAction(name string, args ...string) *Action {
+	return &Action{Kind: ActionCommand, Command: append([]string{name}, args...)}
+}
+
+// String returns the command line for command actions and the kind
+// otherwise.
+func (a *Action) String() string {
+	if a == nil {
+		return ActionDelete.String()
+	}
+	if a.Kind == ActionCommand {
+		return strings.Join(a.Command, " ")
+	}
+	return a.Kind.String()
+}
+
 type Target struct {
 	Path        string    `json:"path"`
 	Size        int64     `json:"size"`
@@ -34,6 +107,60 @@ type Target struct {
 	Risk        RiskLevel `json:"risk"`
 	ModTime     time.Time `json:"mod_time"`
 	IsDir       bool      `json:"is_dir"`
+	Action      *Action   `json:"action,omitempty"`
+	// Usage holds both the apparent and on-disk size when the scanner
+	// measured the filesystem. Size is one of the two, chosen by the
+	// scan's size mode.
+	Usage utils.Usage `json:"usage"`
+	// Contains lists targets of other categories nested inside this one,
+	// set when overlaps are resolved. Their bytes are not counted in Size,
+	// but cleaning this target removes them too, so it is only cleaned
+	// together with all of them.
+	Contains []string `json:"contains,omitempty"`
+}
+
+// SizeIn returns the target's size in mode. Targets whose usage was not
+// measured, such as command targets, report Size either way.
+func (t Target) SizeIn(mode utils.SizeMode) int64 {
+	if t.Usage.IsZero() {
+		return t.Size
+	}
+	return t.Usage.In(mode)
+}
+
+// withUsage records u on t and sets Size according to the size mode in ctx.
+func withUsage(ctx context.Context, t Target, u utils.Usage) Target {
+	mode, _ := utils.SizingFrom(ctx)
+	t.Usage = u
+	t.Size = u.In(mode)
+	return t
+}
+
+// measure returns the usage of path: the whole tree for directories, the
+// file itself otherwise. Hardlinks are counted once per scan using the
+// InodeSet in ctx, and unchanged directories come from ctx's DirCache.
+func measure(ctx context.Context, path string, info fs.FileInfo) utils.Usage {
+	if info.IsDir() {
+		return measureDir(ctx, path)
+	}
+	_, seen := utils.SizingFrom(ctx)
+	u := utils.FileUsage(path, info, seen)
+	measured(ctx, u)
+	return u
+}
+
+// measureDir returns the usage of the directory tree at path.
+func measureDir(ctx context.Context, path string) utils.Usage {
+	_, seen := utils.SizingFrom(ctx)
+	u, _ := utils.DirCacheFrom(ctx).DirUsage(ctx, path, seen)
+	measured(ctx, u)
+	return u
+}
+
+// IsFilesystem reports whether cleaning the target deletes its Path from
+// disk, as opposed to running a cleanup command.
+func (t Target) IsFilesystem() bool {
+	return t.Action == nil || t.Action.Kind == ActionDelete || t.Action.PermanentOnly
 }
 
 type Scanner interface {
@@ -42,3 +169,12 @@ type Scanner interface {
 	Scan(ctx context.Context) ([]Target, error)
 	Risk() RiskLevel
 }
+
+// Rooted is implemented by scanners whose filesystem targets all lie under
+// known directories. Every scanner that reports filesystem targets must
+// implement it: cleanup refuses targets from scanners without roots, and
+// targets that, once symlinks are resolved, fall outside the roots of the
+// scanner that reported them.
+type Rooted interface {
+	Roots() []string
+}
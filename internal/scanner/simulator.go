@@ -41,6 +41,11 @@ func (s *SimulatorScanner) Name() string        { return "iOS Simulators" }
 func (s *SimulatorScanner) Description() string { return "iOS Simulator devices and caches" }
 func (s *SimulatorScanner) Risk() RiskLevel     { return Moderate }
 
+// Roots implements Rooted.
+func (s *SimulatorScanner) Roots() []string {
+	return []string{filepath.Join(s.base(), "Developer", "CoreSimulator")}
+}
+
 func (s *SimulatorScanner) base() string {
 	if s.libraryBase != "" {
 		return s.libraryBase
@@ -63,7 +68,7 @@ func (s *SimulatorScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	devicesDir := filepath.Join(s.base(), "Developer", "CoreSimulator", "Devices")
-	devTargets, err := s.scanDir(devicesDir, "Simulator device data", Moderate)
+	devTargets, err := s.scanDir(ctx, devicesDir, "Simulator device data", Moderate)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +82,7 @@ func (s *SimulatorScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	cachesDir := filepath.Join(s.base(), "Developer", "CoreSimulator", "Caches")
-	cacheTargets, err := s.scanDir(cachesDir, "Simulator cache", Safe)
+	cacheTargets, err := s.scanDir(ctx, cachesDir, "Simulator cache", Safe)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +111,7 @@ func (s *SimulatorScanner) Scan(ctx context.Context) ([]Target, error) {
 
 // scanDir reads entries from a directory and returns targets for each
 // subdirectory with a non-zero size.
-func (s *SimulatorScanner) scanDir(dir, description string, risk RiskLevel) ([]Target, error) {
+func (s *SimulatorScanner) scanDir(ctx context.Context, dir, description string, risk RiskLevel) ([]Target, error) {
 	if !utils.DirExists(dir) {
 		return nil, nil
 	}
@@ -124,26 +129,19 @@ func (s *SimulatorScanner) scanDir(dir, description string, risk RiskLevel) ([]T
 			continue
 		}
 
-		var size int64
-		if info.IsDir() {
-			size, _ = utils.DirSize(entryPath)
-		} else {
-			size = info.Size()
-		}
-
-		if size == 0 {
+		usage := measure(ctx, entryPath, info)
+		if usage.IsZero() {
 			continue
 		}
 
-		targets = append(targets, Target{
+		targets = append(targets, withUsage(ctx, Target{
 			Path:        entryPath,
-			Size:        size,
 			Category:    "iOS Simulators",
 			Description: description,
 			Risk:        risk,
 			ModTime:     info.ModTime(),
 			IsDir:       info.IsDir(),
-		})
+		}, usage))
 	}
 
 	return targets, nil
@@ -182,6 +180,7 @@ func (s *SimulatorScanner) findUnavailableDevices(ctx context.Context) ([]Target
 				Description: fmt.Sprintf("Unavailable simulator: %s (%s)", dev.Name, runtime),
 				Category:    "iOS Simulators",
 				Risk:        Moderate,
+				Action:      CommandAction("xcrun", "simctl", "delete", dev.UDID),
 			})
 		}
 	}
@@ -187,6 +187,10 @@ func TestSimulatorScanner_UnavailableDevices(t *testing.T) {
 		if tgt.Category != "iOS Simulators" {
 			t.Errorf("expected category %q, got %q", "iOS Simulators", tgt.Category)
 		}
+		udid := tgt.Path[len("simulator "):]
+		if want := "xcrun simctl delete " + udid; tgt.Action.String() != want {
+			t.Errorf("expected action %q, got %q", want, tgt.Action.String())
+		}
 	}
 }
 
@@ -13,6 +13,7 @@ type SpaceLensNode struct {
 	Path     string          `json:"path"`
 	Name     string          `json:"name"`
 	Size     int64           `json:"size"`
+	Usage    utils.Usage     `json:"usage"`
 	IsDir    bool            `json:"is_dir"`
 	Children []SpaceLensNode `json:"children,omitempty"`
 	Depth    int             `json:"depth"`
@@ -25,6 +26,7 @@ type SpaceLens struct {
 	root       string
 	maxDepth   int
 	onProgress ProgressFunc
+	sizeMode   utils.SizeMode
 }
 
 func NewSpaceLens(root string, maxDepth int) *SpaceLens {
@@ -36,6 +38,12 @@ func (s *SpaceLens) SetProgress(fn ProgressFunc) {
 	s.onProgress = fn
 }
 
+// SetSizeMode selects whether node sizes are apparent or allocated on disk.
+// Both are always recorded in each node's Usage.
+func (s *SpaceLens) SetSizeMode(mode utils.SizeMode) {
+	s.sizeMode = mode
+}
+
 func (s *SpaceLens) Analyze(ctx context.Context) ([]SpaceLensNode, error) {
 	return s.analyzeDir(ctx, s.root, 0)
 }
@@ -52,6 +60,10 @@ func (s *SpaceLens) analyzeDir(ctx context.Context, dir string, depth int) ([]Sp
 		return nil, err
 	}
 
+	// Siblings share one InodeSet so a file hardlinked into several of
+	// them is counted once in this listing. Children are listed, and so
+	// measured, again with a set of their own.
+	seen := utils.NewInodeSet()
 	var nodes []SpaceLensNode
 	for _, entry := range entries {
 		entryPath := filepath.Join(dir, entry.Name())
@@ -71,15 +83,15 @@ func (s *SpaceLens) analyzeDir(ctx context.Context, dir string, depth int) ([]Sp
 		}
 
 		if info.IsDir() {
-			size, _ := utils.DirSize(entryPath)
-			node.Size = size
+			node.Usage, _ = utils.DirUsage(entryPath, seen)
 			if depth < s.maxDepth {
 				children, _ := s.analyzeDir(ctx, entryPath, depth+1)
 				node.Children = children
 			}
 		} else {
-			node.Size = info.Size()
+			node.Usage = utils.FileUsage(entryPath, info, seen)
 		}
+		node.Size = node.Usage.In(s.sizeMode)
 
 		nodes = append(nodes, node)
 	}
@@ -21,6 +21,11 @@ func (s *SystemScanner) Name() string        { return "System Junk" }
 func (s *SystemScanner) Description() string { return "System caches, logs, and temporary files" }
 func (s *SystemScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (s *SystemScanner) Roots() []string {
+	return []string{filepath.Join(s.base(), "Caches"), filepath.Join(s.base(), "Logs")}
+}
+
 func (s *SystemScanner) base() string {
 	if s.libraryBase != "" {
 		return s.libraryBase
@@ -82,7 +87,8 @@ func (s *SystemScanner) Scan(ctx context.Context) ([]Target, error) {
 	}
 
 	// Compute all directory sizes in parallel
-	sizes := utils.DirSizesParallel(dirPaths)
+	_, seen := utils.SizingFrom(ctx)
+	usages := utils.DirCacheFrom(ctx).DirUsagesParallel(ctx, dirPaths, seen)
 
 	// Build targets using precomputed sizes
 	var mu sync.Mutex
@@ -93,29 +99,29 @@ func (s *SystemScanner) Scan(ctx context.Context) ([]Target, error) {
 		wg.Add(1)
 		go func(e entryInfo) {
 			defer wg.Done()
-			var size int64
+			var usage utils.Usage
 			if e.info.IsDir() {
-				size = sizes[e.path]
+				usage = usages[e.path]
 			} else {
-				size = e.info.Size()
+				usage = utils.FileUsage(e.path, e.info, seen)
 			}
 
-			if size == 0 {
+			if usage.IsZero() {
 				return
 			}
+			measured(ctx, usage)
 
-			t := Target{
+			t := withUsage(ctx, Target{
 				Path:        e.path,
-				Size:        size,
 				Category:    "System Junk",
 				Description: e.description,
 				Risk:        Safe,
 				ModTime:     e.info.ModTime(),
 				IsDir:       e.info.IsDir(),
-			}
+			}, usage)
 
 			mu.Lock()
-			targets = append(targets, t)
+			targets = append(targets, emit(ctx, t))
 			mu.Unlock()
 		}(e)
 	}
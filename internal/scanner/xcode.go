@@ -20,6 +20,11 @@ func (x *XcodeScanner) Name() string        { return "Xcode Junk" }
 func (x *XcodeScanner) Description() string { return "Xcode DerivedData, archives, and device support" }
 func (x *XcodeScanner) Risk() RiskLevel     { return Safe }
 
+// Roots implements Rooted.
+func (x *XcodeScanner) Roots() []string {
+	return []string{filepath.Join(x.base(), "Developer"), filepath.Join(x.base(), "Caches", "com.apple.dt.Xcode")}
+}
+
 func (x *XcodeScanner) base() string {
 	if x.libraryBase != "" {
 		return x.libraryBase
@@ -66,26 +71,19 @@ func (x *XcodeScanner) Scan(ctx context.Context) ([]Target, error) {
 				continue
 			}
 
-			var size int64
-			if info.IsDir() {
-				size, _ = utils.DirSize(entryPath)
-			} else {
-				size = info.Size()
-			}
-
-			if size == 0 {
+			usage := measure(ctx, entryPath, info)
+			if usage.IsZero() {
 				continue
 			}
 
-			targets = append(targets, Target{
+			targets = append(targets, emit(ctx, withUsage(ctx, Target{
 				Path:        entryPath,
-				Size:        size,
 				Category:    "Xcode Junk",
 				Description: d.description,
 				Risk:        Safe,
 				ModTime:     info.ModTime(),
 				IsDir:       info.IsDir(),
-			})
+			}, usage)))
 		}
 	}
 
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"encoding/xml"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+
+	"github.com/lu-zhengda/macbroom/internal/scanner"
 )
 
 const (
@@ -123,17 +126,14 @@ func GeneratePlistWithBinary(timeStr, interval, binary string) string {
 	return GeneratePlistWithCategories(timeStr, interval, binary, nil)
 }
 
-var validCategories = map[string]bool{
-	"system": true, "browser": true, "xcode": true, "large": true,
-	"docker": true, "node": true, "homebrew": true, "simulator": true,
-	"python": true, "rust": true, "go": true, "jetbrains": true,
-	"maven": true, "gradle": true, "ruby": true,
-	"dev": true, "caches": true, "all": true,
-}
+// CustomCategoryPrefix marks a category that names a custom scanner from
+// config rather than a built-in flag.
+const CustomCategoryPrefix = "custom:"
 
 // GeneratePlistWithCategories generates a plist using the specified binary
 // path and optional category flags. Each category name is converted to a
-// CLI flag (e.g., "system" becomes "--system").
+// CLI flag (e.g., "system" becomes "--system"); "custom:NAME" becomes
+// "--custom NAME".
 func GeneratePlistWithCategories(timeStr, interval, binary string, categories []string) string {
 	hour, minute, err := parseTime(timeStr)
 	if err != nil {
@@ -142,7 +142,16 @@ func GeneratePlistWithCategories(timeStr, interval, binary string, categories []
 
 	var extraArgs []string
 	for _, cat := range categories {
-		if !validCategories[cat] {
+		if name, ok := strings.CutPrefix(cat, CustomCategoryPrefix); ok && name != "" {
+			// Custom names are free text; escape them for the plist XML.
+			var esc strings.Builder
+			if err := xml.EscapeText(&esc, []byte(name)); err != nil {
+				return ""
+			}
+			extraArgs = append(extraArgs, "--custom", esc.String())
+			continue
+		}
+		if !scanner.IsCategoryFlag(cat) {
 			return ""
 		}
 		extraArgs = append(extraArgs, "--"+cat)
@@ -323,3 +323,16 @@ func TestGeneratePlistWithCategories_Empty(t *testing.T) {
 		t.Error("empty categories plist should not contain --browser")
 	}
 }
+
+func TestGeneratePlistWithCategories_Custom(t *testing.T) {
+	plist := GeneratePlistWithCategories("10:00", "daily", "/usr/local/bin/macbroom", []string{"system", "custom:Unity & Co"})
+	if plist == "" {
+		t.Fatal("expected non-empty plist")
+	}
+	if !strings.Contains(plist, "<string>--custom</string>") {
+		t.Error("expected --custom flag in plist")
+	}
+	if !strings.Contains(plist, "<string>Unity &amp; Co</string>") {
+		t.Error("expected escaped custom scanner name in plist")
+	}
+}
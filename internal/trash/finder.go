@@ -0,0 +1,110 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Finder moves items to the macOS Trash by asking Finder via osascript, so
+// that "Put Back" works as usual.
+type Finder struct{}
+
+// Trash implements Trasher.
+func (Finder) Trash(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+
+	script := fmt.Sprintf(
+		`tell application "Finder" to return POSIX path of ((delete POSIX file %q) as alias)`,
+		absPath,
+	)
+
+	cmd := exec.Command("osascript", "-e", script)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to trash %s: %w (%s)", path, err, string(out))
+	}
+	return strings.TrimSuffix(strings.TrimSpace(string(out)), "/"), nil
+}
+
+// trashAllScript trashes each path given on the command line and prints,
+// one line per path, either where it went or "error: " and the reason.
+const trashAllScript = `on run argv
+	set out to {}
+	repeat with p in argv
+		try
+			set f to POSIX file (contents of p)
+			tell application "Finder" to set d to delete f
+			set end of out to POSIX path of (d as alias)
+		on error msg
+			set end of out to "error: " & msg
+		end try
+	end repeat
+	set AppleScript's text item delimiters to linefeed
+	return out as text
+end run`
+
+// TrashAll implements BatchTrasher with a single osascript process.
+func (Finder) TrashAll(paths []string) ([]string, []error) {
+	trashed := make([]string, len(paths))
+	errs := make([]error, len(paths))
+	abs := make([]string, len(paths))
+	for i, p := range paths {
+		a, err := filepath.Abs(p)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to resolve path %s: %w", p, err)
+		}
+		abs[i] = a
+	}
+
+	args := []string{"-e", trashAllScript}
+	for i, a := range abs {
+		if errs[i] == nil {
+			args = append(args, a)
+		}
+	}
+	if len(args) == 2 {
+		return trashed, errs
+	}
+	out, runErr := exec.Command("osascript", args...).Output()
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if runErr != nil || len(lines) != len(args)-2 {
+		// Finder may have trashed some of the paths before failing, so
+		// report those that are gone as trashed to an unknown location.
+		for i, p := range paths {
+			if errs[i] != nil {
+				continue
+			}
+			if _, err := os.Lstat(abs[i]); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if runErr != nil {
+				errs[i] = fmt.Errorf("failed to trash %s: %w", p, runErr)
+			} else {
+				errs[i] = fmt.Errorf("failed to trash %s: unexpected osascript output", p)
+			}
+		}
+		return trashed, errs
+	}
+
+	j := 0
+	for i, p := range paths {
+		if errs[i] != nil {
+			continue
+		}
+		line := lines[j]
+		j++
+		if msg, ok := strings.CutPrefix(line, "error: "); ok {
+			errs[i] = fmt.Errorf("failed to trash %s: %s", p, msg)
+			continue
+		}
+		trashed[i] = strings.TrimSuffix(strings.TrimSpace(line), "/")
+	}
+	return trashed, errs
+}
@@ -0,0 +1,18 @@
+package trash
+
+import "golang.org/x/sys/unix"
+
+// clearImmutable clears the user immutable and append-only flags
+// (chflags nouchg,nouappnd) on path, which otherwise block deleting it or
+// its entries. System flags need root and are left alone.
+func clearImmutable(path string) error {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return err
+	}
+	const blocking = unix.UF_IMMUTABLE | unix.UF_APPEND
+	if st.Flags&blocking == 0 {
+		return nil
+	}
+	return unix.Chflags(path, int(st.Flags&^blocking))
+}
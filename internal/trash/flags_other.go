@@ -0,0 +1,6 @@
+//go:build !darwin
+
+package trash
+
+// clearImmutable is a no-op where file flags are not supported.
+func clearImmutable(path string) error { return nil }
@@ -0,0 +1,212 @@
+package trash
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+	"golang.org/x/sys/unix"
+)
+
+// Freedesktop implements the freedesktop.org Trash specification used by
+// Linux desktops: each trash directory holds the trashed items in files/
+// and a matching info/<name>.trashinfo recording the original path and
+// deletion date. Items on the home volume go to $XDG_DATA_HOME/Trash; items
+// on other volumes go to $topdir/.Trash/$uid or $topdir/.Trash-$uid so they
+// are never copied across devices.
+type Freedesktop struct {
+	homeTrash string
+	uid       int
+
+	// now and device are overridable in tests.
+	now    func() time.Time
+	device func(path string) (uint64, error)
+}
+
+// NewFreedesktop returns a Freedesktop trash for the current user.
+func NewFreedesktop() *Freedesktop {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(utils.HomeDir(), ".local", "share")
+	}
+	return &Freedesktop{
+		homeTrash: filepath.Join(dataHome, "Trash"),
+		uid:       os.Getuid(),
+		now:       time.Now,
+		device:    deviceOf,
+	}
+}
+
+// Trash implements Trasher.
+func (f *Freedesktop) Trash(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if _, err := os.Lstat(absPath); err != nil {
+		return "", fmt.Errorf("failed to trash %s: %w", path, err)
+	}
+
+	dir, topdir, err := f.trashDirFor(absPath)
+	if err != nil {
+		return "", err
+	}
+	return f.moveInto(dir, topdir, absPath)
+}
+
+// trashDirFor picks the trash directory on the same device as path. topdir
+// is empty for the home trash, otherwise it is the mount point that
+// .trashinfo paths are relative to.
+func (f *Freedesktop) trashDirFor(path string) (dir, topdir string, err error) {
+	fileDev, err := f.device(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	homeDev, err := f.nearestDevice(f.homeTrash)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat %s: %w", f.homeTrash, err)
+	}
+	if fileDev == homeDev {
+		return f.homeTrash, "", nil
+	}
+
+	topdir = f.mountPoint(path, fileDev)
+	uid := strconv.Itoa(f.uid)
+
+	// An administrator-provided $topdir/.Trash must be a real directory
+	// with the sticky bit set; otherwise the spec says to ignore it.
+	shared := filepath.Join(topdir, ".Trash")
+	if fi, err := os.Lstat(shared); err == nil && fi.IsDir() && fi.Mode()&os.ModeSticky != 0 {
+		dir = filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0o700); err == nil {
+			return dir, topdir, nil
+		}
+	}
+
+	dir = filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", fmt.Errorf("failed to create trash directory %s: %w", dir, err)
+	}
+	if fi, err := os.Lstat(dir); err != nil || !fi.IsDir() {
+		return "", "", fmt.Errorf("trash directory %s is not a directory", dir)
+	}
+	return dir, topdir, nil
+}
+
+// moveInto writes the .trashinfo file and moves path into dir/files under
+// the first free name, returning the trashed location.
+func (f *Freedesktop) moveInto(dir, topdir, path string) (string, error) {
+	filesDir := filepath.Join(dir, "files")
+	infoDir := filepath.Join(dir, "info")
+	for _, d := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return "", fmt.Errorf("failed to create trash directory %s: %w", d, err)
+		}
+	}
+
+	original := path
+	if topdir != "" {
+		rel, err := filepath.Rel(topdir, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s relative to %s: %w", path, topdir, err)
+		}
+		original = rel
+	}
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: original}).EscapedPath(), f.now().Format("2006-01-02T15:04:05"))
+
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = base + "." + strconv.Itoa(i)
+		}
+		dest := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(dest); err == nil {
+			continue
+		}
+
+		// Creating the info file exclusively reserves the name, so two
+		// concurrent trashers never pick the same one.
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		fh, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write trash info for %s: %w", path, err)
+		}
+		_, err = fh.WriteString(info)
+		if cerr := fh.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to write trash info for %s: %w", path, err)
+		}
+
+		if err := os.Rename(path, dest); err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to trash %s: %w", path, err)
+		}
+		return dest, nil
+	}
+}
+
+// nearestDevice returns the device of path, or of its closest existing
+// ancestor when path has not been created yet.
+func (f *Freedesktop) nearestDevice(path string) (uint64, error) {
+	for {
+		dev, err := f.device(path)
+		if err == nil || !os.IsNotExist(err) {
+			return dev, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
+
+// mountPoint returns the topmost ancestor of path that is still on dev.
+func (f *Freedesktop) mountPoint(path string, dev uint64) string {
+	p := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		if d, err := f.device(parent); err != nil || d != dev {
+			return p
+		}
+		p = parent
+	}
+}
+
+// RemoveInfo deletes the freedesktop .trashinfo file belonging to an item
+// that has been moved back out of the Trash. It is a no-op for locations
+// that are not inside a freedesktop trash directory.
+func RemoveInfo(trashed string) error {
+	filesDir := filepath.Dir(trashed)
+	if filepath.Base(filesDir) != "files" {
+		return nil
+	}
+	infoPath := filepath.Join(filepath.Dir(filesDir), "info", filepath.Base(trashed)+".trashinfo")
+	if err := os.Remove(infoPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove trash info %s: %w", infoPath, err)
+	}
+	return nil
+}
+
+func deviceOf(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return 0, &os.PathError{Op: "lstat", Path: path, Err: err}
+	}
+	return uint64(st.Dev), nil
+}
@@ -0,0 +1,200 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFreedesktop returns a Freedesktop whose home trash lives under
+// root and whose clock is fixed.
+func newTestFreedesktop(root string) *Freedesktop {
+	f := NewFreedesktop()
+	f.homeTrash = filepath.Join(root, "data", "Trash")
+	f.uid = 1000
+	f.now = func() time.Time { return time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local) }
+	return f
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFreedesktop_TrashWritesInfo(t *testing.T) {
+	root := t.TempDir()
+	f := newTestFreedesktop(root)
+
+	src := filepath.Join(root, "my files", "report 1.txt")
+	writeFile(t, src, "data")
+
+	trashed, err := f.Trash(src)
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+
+	want := filepath.Join(f.homeTrash, "files", "report 1.txt")
+	if trashed != want {
+		t.Errorf("trashed = %q, want %q", trashed, want)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("expected source to be gone")
+	}
+	if data, _ := os.ReadFile(trashed); string(data) != "data" {
+		t.Errorf("trashed content = %q", data)
+	}
+
+	info, err := os.ReadFile(filepath.Join(f.homeTrash, "info", "report 1.txt.trashinfo"))
+	if err != nil {
+		t.Fatalf("missing trashinfo: %v", err)
+	}
+	wantInfo := "[Trash Info]\nPath=" + strings.ReplaceAll(src, " ", "%20") + "\nDeletionDate=2026-03-04T05:06:07\n"
+	if string(info) != wantInfo {
+		t.Errorf("trashinfo =\n%s\nwant\n%s", info, wantInfo)
+	}
+}
+
+func TestFreedesktop_NameCollision(t *testing.T) {
+	root := t.TempDir()
+	f := newTestFreedesktop(root)
+
+	var got []string
+	for _, dir := range []string{"a", "b", "c"} {
+		src := filepath.Join(root, dir, "node_modules")
+		if err := os.MkdirAll(filepath.Join(src, "pkg"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		trashed, err := f.Trash(src)
+		if err != nil {
+			t.Fatalf("Trash(%s): %v", src, err)
+		}
+		got = append(got, filepath.Base(trashed))
+	}
+
+	want := []string{"node_modules", "node_modules.2", "node_modules.3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trashed names = %v, want %v", got, want)
+			break
+		}
+	}
+	if _, err := os.Stat(filepath.Join(f.homeTrash, "info", "node_modules.3.trashinfo")); err != nil {
+		t.Errorf("expected info for third item: %v", err)
+	}
+}
+
+func TestFreedesktop_OtherVolumeUsesTopdirTrash(t *testing.T) {
+	root := t.TempDir()
+	f := newTestFreedesktop(root)
+
+	// Pretend everything under root/vol is a separate mount.
+	vol := filepath.Join(root, "vol")
+	f.device = func(path string) (uint64, error) {
+		if _, err := os.Lstat(path); err != nil {
+			return 0, err
+		}
+		if path == vol || strings.HasPrefix(path, vol+string(filepath.Separator)) {
+			return 2, nil
+		}
+		return 1, nil
+	}
+
+	src := filepath.Join(vol, "projects", "target")
+	writeFile(t, filepath.Join(src, "debug.bin"), "x")
+
+	trashed, err := f.Trash(src)
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+
+	want := filepath.Join(vol, ".Trash-1000", "files", "target")
+	if trashed != want {
+		t.Errorf("trashed = %q, want %q", trashed, want)
+	}
+	info, err := os.ReadFile(filepath.Join(vol, ".Trash-1000", "info", "target.trashinfo"))
+	if err != nil {
+		t.Fatalf("missing trashinfo: %v", err)
+	}
+	if !strings.Contains(string(info), "\nPath=projects/target\n") {
+		t.Errorf("expected path relative to topdir, got:\n%s", info)
+	}
+	if _, err := os.Stat(f.homeTrash); !os.IsNotExist(err) {
+		t.Error("home trash should not be used for other volumes")
+	}
+}
+
+func TestFreedesktop_StickySharedTrash(t *testing.T) {
+	root := t.TempDir()
+	f := newTestFreedesktop(root)
+
+	vol := filepath.Join(root, "vol")
+	f.device = func(path string) (uint64, error) {
+		if _, err := os.Lstat(path); err != nil {
+			return 0, err
+		}
+		if path == vol || strings.HasPrefix(path, vol+string(filepath.Separator)) {
+			return 2, nil
+		}
+		return 1, nil
+	}
+
+	shared := filepath.Join(vol, ".Trash")
+	if err := os.MkdirAll(shared, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(vol, "old.iso")
+	writeFile(t, src, "x")
+
+	trashed, err := f.Trash(src)
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if want := filepath.Join(shared, "1000", "files", "old.iso"); trashed != want {
+		t.Errorf("trashed = %q, want %q", trashed, want)
+	}
+}
+
+func TestFreedesktop_MissingPath(t *testing.T) {
+	f := newTestFreedesktop(t.TempDir())
+	if _, err := f.Trash(filepath.Join(t.TempDir(), "nope")); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
+
+func TestRemoveInfo(t *testing.T) {
+	root := t.TempDir()
+	f := newTestFreedesktop(root)
+
+	src := filepath.Join(root, "a.txt")
+	writeFile(t, src, "x")
+	trashed, err := f.Trash(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(trashed, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveInfo(trashed); err != nil {
+		t.Fatalf("RemoveInfo: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(f.homeTrash, "info", "a.txt.trashinfo")); !os.IsNotExist(err) {
+		t.Error("expected trashinfo to be removed")
+	}
+
+	// Locations outside a freedesktop trash are ignored.
+	if err := RemoveInfo("/Users/me/.Trash/a.txt"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
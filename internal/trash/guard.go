@@ -0,0 +1,205 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// ErrProtected is matched (via errors.Is) by every refusal from a Guard.
+var ErrProtected = errors.New("protected path")
+
+// Reason explains why a Guard refused a path.
+type Reason string
+
+const (
+	// ReasonSystem covers the built-in denylist: the filesystem and home
+	// roots, system directories, and top-level folders such as
+	// /Applications and ~/Documents.
+	ReasonSystem Reason = "system path"
+	// ReasonGit covers .git directories and their contents.
+	ReasonGit Reason = "git metadata"
+	// ReasonUser covers paths listed under protected_paths in the config.
+	ReasonUser Reason = "protected in config"
+	// ReasonOutsideRoots covers targets that, once symlinks are resolved,
+	// are not under any of the roots their scanner declared.
+	ReasonOutsideRoots Reason = "outside scanner roots"
+	// ReasonNoRoots covers filesystem targets from a scanner that declared
+	// no roots to check them against.
+	ReasonNoRoots Reason = "scanner declared no roots"
+)
+
+// ProtectedError reports a path the guard refused to delete.
+type ProtectedError struct {
+	Path string
+	// Resolved is Path with symlinks in its parent directories resolved.
+	Resolved string
+	Reason   Reason
+	// Rule is the protected path or, for ReasonOutsideRoots, the roots
+	// the target had to be under.
+	Rule string
+}
+
+func (e *ProtectedError) Error() string {
+	if e.Reason == ReasonOutsideRoots {
+		return fmt.Sprintf("refusing to delete %s: resolves to %s, outside %s", e.Path, e.Resolved, e.Rule)
+	}
+	if e.Reason == ReasonNoRoots {
+		return fmt.Sprintf("refusing to delete %s: %s", e.Path, e.Reason)
+	}
+	if e.Resolved != e.Path {
+		return fmt.Sprintf("refusing to delete %s (resolves to %s): %s %s", e.Path, e.Resolved, e.Reason, e.Rule)
+	}
+	return fmt.Sprintf("refusing to delete %s: %s %s", e.Path, e.Reason, e.Rule)
+}
+
+func (e *ProtectedError) Is(target error) bool { return target == ErrProtected }
+
+// protectedPath is one denylist entry. Deleting the path itself or any of
+// its ancestors is refused; with tree set, so is anything inside it.
+type protectedPath struct {
+	path   string
+	tree   bool
+	reason Reason
+}
+
+// Guard decides whether a path may be deleted.
+type Guard struct {
+	protected []protectedPath
+}
+
+// NewGuard returns a Guard with the built-in denylist for home plus the
+// user's protected paths, which must already be absolute (~ expanded).
+// Each user path protects itself and everything inside it.
+func NewGuard(home string, userProtected []string) *Guard {
+	g := &Guard{}
+	for _, p := range []string{"/", "/Applications", "/Library", "/Users", "/Volumes", "/private", "/var", "/tmp"} {
+		g.add(p, false, ReasonSystem)
+	}
+	for _, p := range []string{"/System", "/bin", "/sbin", "/usr", "/etc", "/private/etc"} {
+		g.add(p, true, ReasonSystem)
+	}
+	if home != "" {
+		g.add(home, false, ReasonSystem)
+		for _, sub := range []string{"Applications", "Desktop", "Documents", "Downloads", "Library", "Movies", "Music", "Pictures"} {
+			g.add(filepath.Join(home, sub), false, ReasonSystem)
+		}
+	}
+	for _, p := range userProtected {
+		if p != "" {
+			g.add(p, true, ReasonUser)
+		}
+	}
+	return g
+}
+
+// add registers p both as written and with symlinks resolved, so that
+// /tmp and /private/tmp are protected alike.
+func (g *Guard) add(p string, tree bool, reason Reason) {
+	p = filepath.Clean(p)
+	g.protected = append(g.protected, protectedPath{path: p, tree: tree, reason: reason})
+	if r, err := filepath.EvalSymlinks(p); err == nil && r != p {
+		g.protected = append(g.protected, protectedPath{path: r, tree: tree, reason: reason})
+	}
+}
+
+// Check returns a *ProtectedError if path must not be deleted. When roots
+// is non-empty, path must also lie under one of them once symlinks in its
+// parent directories (and in the roots) are resolved. A symlink at path
+// itself is not followed, because deleting it only removes the link.
+func (g *Guard) Check(path string, roots []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	resolved := resolveParent(abs)
+
+	for _, p := range []string{abs, resolved} {
+		if isGitPath(p) {
+			return &ProtectedError{Path: path, Resolved: resolved, Reason: ReasonGit, Rule: ".git"}
+		}
+		for _, pp := range g.protected {
+			if within(pp.path, p) || (pp.tree && within(p, pp.path)) {
+				return &ProtectedError{Path: path, Resolved: resolved, Reason: pp.reason, Rule: pp.path}
+			}
+		}
+	}
+
+	if len(roots) == 0 {
+		return nil
+	}
+	for _, root := range roots {
+		r := filepath.Clean(root)
+		if er, err := filepath.EvalSymlinks(r); err == nil {
+			r = er
+		}
+		if within(resolved, r) {
+			return nil
+		}
+	}
+	return &ProtectedError{Path: path, Resolved: resolved, Reason: ReasonOutsideRoots, Rule: strings.Join(roots, ", ")}
+}
+
+// resolveParent resolves symlinks in the directories leading to path,
+// keeping its last element as is. Paths whose parent cannot be resolved
+// are returned unchanged.
+func resolveParent(path string) string {
+	dir, base := filepath.Split(path)
+	r, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return path
+	}
+	return filepath.Join(r, base)
+}
+
+// within reports whether path is dir or inside it. Comparison ignores case
+// on macOS, whose default filesystem is case-insensitive.
+func within(path, dir string) bool {
+	if runtime.GOOS == "darwin" {
+		path, dir = strings.ToLower(path), strings.ToLower(dir)
+	}
+	if path == dir {
+		return true
+	}
+	if dir == string(filepath.Separator) {
+		return strings.HasPrefix(path, dir)
+	}
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// isGitPath reports whether path is a .git directory or inside one.
+func isGitPath(path string) bool {
+	for _, part := range strings.Split(path, string(filepath.Separator)) {
+		if part == ".git" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	guardMu      sync.RWMutex
+	defaultGuard = NewGuard(utils.HomeDir(), nil)
+)
+
+// SetProtected replaces the user-protected paths of the guard that Move
+// and PermanentDelete use.
+func SetProtected(paths []string) {
+	g := NewGuard(utils.HomeDir(), paths)
+	guardMu.Lock()
+	defaultGuard = g
+	guardMu.Unlock()
+}
+
+// Check runs the guard that Move and PermanentDelete use. See Guard.Check.
+func Check(path string, roots []string) error {
+	guardMu.RLock()
+	g := defaultGuard
+	guardMu.RUnlock()
+	return g.Check(path, roots)
+}
@@ -0,0 +1,117 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGuard_Check(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	project := filepath.Join(root, "work", "project")
+	g := NewGuard(home, []string{project})
+
+	tests := []struct {
+		name   string
+		path   string
+		reason Reason // "" means allowed
+	}{
+		{"filesystem root", "/", ReasonSystem},
+		{"home root", home, ReasonSystem},
+		{"documents root", filepath.Join(home, "Documents"), ReasonSystem},
+		{"inside documents", filepath.Join(home, "Documents", "old.zip"), ""},
+		{"applications root", "/Applications", ReasonSystem},
+		{"an application", "/Applications/Some.app", ""},
+		{"inside System", "/System/Library/Caches", ReasonSystem},
+		{"git dir", filepath.Join(root, "repo", ".git"), ReasonGit},
+		{"inside git dir", filepath.Join(root, "repo", ".git", "objects"), ReasonGit},
+		{"user protected", project, ReasonUser},
+		{"inside user protected", filepath.Join(project, "node_modules"), ReasonUser},
+		{"ancestor of user protected", filepath.Join(root, "work"), ReasonUser},
+		{"cache", filepath.Join(home, "Library", "Caches", "foo"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.Check(tt.path, nil)
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("expected %s to be allowed, got %v", tt.path, err)
+				}
+				return
+			}
+			var pe *ProtectedError
+			if !errors.As(err, &pe) || pe.Reason != tt.reason {
+				t.Fatalf("expected refusal for %s (%s), got %v", tt.path, tt.reason, err)
+			}
+			if !errors.Is(err, ErrProtected) {
+				t.Error("expected errors.Is(err, ErrProtected)")
+			}
+		})
+	}
+}
+
+func TestGuard_SymlinkedParentLeavesRoots(t *testing.T) {
+	root := t.TempDir()
+	caches := filepath.Join(root, "Caches")
+	elsewhere := filepath.Join(root, "Photos")
+	for _, d := range []string{caches, filepath.Join(elsewhere, "2024")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A cache entry that is really a link to the photo library.
+	if err := os.Symlink(elsewhere, filepath.Join(caches, "app")); err != nil {
+		t.Fatal(err)
+	}
+	g := NewGuard("", nil)
+
+	err := g.Check(filepath.Join(caches, "app", "2024"), []string{caches})
+	var pe *ProtectedError
+	if !errors.As(err, &pe) || pe.Reason != ReasonOutsideRoots {
+		t.Fatalf("expected an outside-roots refusal, got %v", err)
+	}
+	if pe.Resolved != filepath.Join(elsewhere, "2024") {
+		t.Errorf("Resolved = %s", pe.Resolved)
+	}
+
+	// Deleting the link itself only removes the link.
+	if err := g.Check(filepath.Join(caches, "app"), []string{caches}); err != nil {
+		t.Errorf("expected the symlink itself to be deletable, got %v", err)
+	}
+}
+
+func TestGuard_SymlinkIntoProtectedPath(t *testing.T) {
+	root := t.TempDir()
+	protected := filepath.Join(root, "keep")
+	if err := os.MkdirAll(filepath.Join(protected, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(protected, link); err != nil {
+		t.Fatal(err)
+	}
+
+	err := NewGuard("", []string{protected}).Check(filepath.Join(link, "data"), nil)
+	var pe *ProtectedError
+	if !errors.As(err, &pe) || pe.Reason != ReasonUser {
+		t.Fatalf("expected a protected-path refusal through the symlink, got %v", err)
+	}
+}
+
+func TestPermanentDelete_Refused(t *testing.T) {
+	root := t.TempDir()
+	keep := filepath.Join(root, "keep")
+	writeFile(t, filepath.Join(keep, "file"), "x")
+
+	SetProtected([]string{keep})
+	defer SetProtected(nil)
+
+	if err := PermanentDelete(keep); !errors.Is(err, ErrProtected) {
+		t.Fatalf("expected a refusal, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(keep, "file")); err != nil {
+		t.Errorf("protected file was removed: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// PartialError reports a deletion that stopped part-way, leaving some of
+// the tree on disk.
+type PartialError struct {
+	Path string
+	// Remaining is the size of what is still on disk.
+	Remaining utils.Usage
+	Err       error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("partially deleted %s, %s left: %v", e.Path, utils.FormatSize(e.Remaining.Apparent), e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// removeAll is os.RemoveAll for trees that contain read-only directories,
+// such as the Go module cache, or items with the macOS immutable flag.
+// When removal is denied, it makes the rest of the tree writable, clears
+// those flags and tries again. A tree that still cannot be fully removed
+// is reported as a *PartialError.
+func removeAll(path string) error {
+	err := os.RemoveAll(path)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		makeRemovable(path)
+		if err = os.RemoveAll(path); err == nil {
+			return nil
+		}
+	}
+
+	info, statErr := os.Lstat(path)
+	if statErr != nil {
+		return err // gone after all, or unreachable
+	}
+	pe := &PartialError{Path: path, Err: err}
+	if info.IsDir() {
+		pe.Remaining, _ = utils.DirUsage(path, nil)
+	} else {
+		pe.Remaining = utils.FileUsage(path, info, nil)
+	}
+	return pe
+}
+
+// makeRemovable walks the tree at path, adding owner write and search
+// permission to directories and clearing flags that prevent deletion, so
+// that their entries can be unlinked. Errors are ignored: whatever cannot
+// be fixed is reported by the following removal.
+func makeRemovable(path string) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return
+	}
+	_ = clearImmutable(path)
+	if !info.IsDir() {
+		return
+	}
+	if info.Mode().Perm()&0o700 != 0o700 {
+		_ = os.Chmod(path, info.Mode().Perm()|0o700)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		makeRemovable(filepath.Join(path, e.Name()))
+	}
+}
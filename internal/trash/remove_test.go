@@ -0,0 +1,51 @@
+package trash
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+func TestPermanentDelete_ReadOnlyTree(t *testing.T) {
+	// Laid out like the Go module cache: read-only directories of
+	// read-only files.
+	root := filepath.Join(t.TempDir(), "mod")
+	pkg := filepath.Join(root, "example.com", "m@v1.0.0")
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkg, "go.mod"), []byte("module example.com/m\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{pkg, filepath.Dir(pkg), root} {
+		if err := os.Chmod(dir, 0o555); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := PermanentDelete(root); err != nil {
+		t.Fatalf("PermanentDelete: %v", err)
+	}
+	if _, err := os.Lstat(root); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected %s to be gone, got %v", root, err)
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	err := error(&PartialError{
+		Path:      "/cache",
+		Remaining: utils.Usage{Apparent: 2048, Allocated: 4096},
+		Err:       fs.ErrPermission,
+	})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected PartialError to unwrap to its cause")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "/cache") || !strings.Contains(msg, "2.0 KB left") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
@@ -1,30 +1,89 @@
 package trash
 
-import (
-	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-)
+import "runtime"
 
-func MoveToTrash(path string) error {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+// Trasher moves paths to a trash can and reports where each one went, so
+// that it can be put back later.
+type Trasher interface {
+	Trash(path string) (string, error)
+}
+
+// BatchTrasher is a Trasher that can move many paths in one request,
+// which is much faster when each request is expensive, as with Finder.
+type BatchTrasher interface {
+	Trasher
+	// TrashAll trashes each of paths and returns, index for index, where
+	// it went and any error. A trashed path whose location is unknown has
+	// an empty location and a nil error.
+	TrashAll(paths []string) ([]string, []error)
+}
+
+// Default returns the Trasher for the current platform: Finder on macOS and
+// the freedesktop.org Trash everywhere else.
+func Default() Trasher {
+	if runtime.GOOS == "darwin" {
+		return Finder{}
 	}
+	return NewFreedesktop()
+}
 
-	script := fmt.Sprintf(
-		`tell application "Finder" to delete POSIX file %q`,
-		absPath,
-	)
+func MoveToTrash(path string) error {
+	_, err := Move(path)
+	return err
+}
 
-	cmd := exec.Command("osascript", "-e", script)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to trash %s: %w (%s)", path, err, string(out))
+// Move moves path to the platform Trash and returns the location of the
+// item inside the Trash, which is needed to put it back later. Paths the
+// guard protects are refused with a *ProtectedError.
+func Move(path string) (string, error) {
+	if err := Check(path, nil); err != nil {
+		return "", err
 	}
-	return nil
+	return Default().Trash(path)
 }
 
+// PermanentDelete removes path and everything inside it, including
+// read-only directories. Paths the guard protects are refused with a
+// *ProtectedError; a tree that is only partly removed is reported with a
+// *PartialError.
 func PermanentDelete(path string) error {
-	return os.RemoveAll(path)
+	if err := Check(path, nil); err != nil {
+		return err
+	}
+	return removeAll(path)
+}
+
+// MoveAll is Move for many paths, sent to the platform Trash in a single
+// request when it supports batching. It returns, index for index, where
+// each path went and any error.
+func MoveAll(paths []string) ([]string, []error) {
+	trashed := make([]string, len(paths))
+	errs := make([]error, len(paths))
+
+	var allowed []string
+	var idx []int
+	for i, p := range paths {
+		if err := Check(p, nil); err != nil {
+			errs[i] = err
+			continue
+		}
+		allowed = append(allowed, p)
+		idx = append(idx, i)
+	}
+	if len(allowed) == 0 {
+		return trashed, errs
+	}
+
+	t := Default()
+	if bt, ok := t.(BatchTrasher); ok && len(allowed) > 1 {
+		locs, berrs := bt.TrashAll(allowed)
+		for j, i := range idx {
+			trashed[i], errs[i] = locs[j], berrs[j]
+		}
+		return trashed, errs
+	}
+	for j, i := range idx {
+		trashed[i], errs[i] = t.Trash(allowed[j])
+	}
+	return trashed, errs
 }
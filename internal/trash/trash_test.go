@@ -0,0 +1,38 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMoveAll(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses the freedesktop.org Trash")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trashed, errs := MoveAll([]string{a, "/usr", b})
+
+	if !errors.Is(errs[1], ErrProtected) {
+		t.Errorf("expected /usr to be refused, got %v", errs[1])
+	}
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Fatalf("MoveAll[%d]: %v", i, errs[i])
+		}
+		if _, err := os.Stat(trashed[i]); err != nil {
+			t.Errorf("expected trashed item at %s: %v", trashed[i], err)
+		}
+	}
+}
@@ -10,10 +10,13 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/lu-zhengda/macbroom/internal/cleanup"
 	"github.com/lu-zhengda/macbroom/internal/dupes"
 	"github.com/lu-zhengda/macbroom/internal/engine"
 	"github.com/lu-zhengda/macbroom/internal/history"
 	"github.com/lu-zhengda/macbroom/internal/maintain"
+	"github.com/lu-zhengda/macbroom/internal/manifest"
+	"github.com/lu-zhengda/macbroom/internal/scancache"
 	"github.com/lu-zhengda/macbroom/internal/scanner"
 	"github.com/lu-zhengda/macbroom/internal/trash"
 	"github.com/lu-zhengda/macbroom/internal/utils"
@@ -38,13 +41,18 @@ const (
 	viewUninstallInput
 	viewUninstallResults
 	viewUninstallConfirm
+	viewRestore
+	viewRestoreConfirm
+	viewRestoreResult
 )
 
 type scanDoneMsg struct {
+	stream  *engine.ScanStream
 	results []engine.ScanResult
 }
 
 type scanProgressMsg struct {
+	stream   *engine.ScanStream
 	progress engine.ScanProgress
 }
 
@@ -53,12 +61,20 @@ type scannerStatus struct {
 	status engine.ScanStatus
 	count  int
 	size   int64
+	bytes  int64
+	err    error
 }
 
 type cleanDoneMsg struct {
 	cleaned int
 	failed  int
+	skipped int
 	size    int64
+	trashed int64
+	// measured is the growth in free disk space, valid when measuredOK.
+	measured   int64
+	measuredOK bool
+	runID      string
 }
 
 type spaceLensDoneMsg struct {
@@ -79,18 +95,20 @@ type dupesDoneMsg struct {
 }
 
 type dupesProgressMsg struct {
-	path string
+	progress dupes.Progress
 }
 
 type dupesCleanDoneMsg struct {
 	deleted int
 	failed  int
 	freed   int64
+	runID   string
 }
 
 type cleanProgressMsg struct {
 	done  int
 	total int
+	freed int64
 }
 
 type animTickMsg struct{}
@@ -114,6 +132,7 @@ type uninstallCleanDoneMsg struct {
 	deleted int
 	failed  int
 	freed   int64
+	runID   string
 }
 
 // menuItem represents a main menu option.
@@ -128,6 +147,7 @@ var menuItems = []menuItem{
 	{"Maintenance", "Run system maintenance tasks"},
 	{"Duplicates", "Find and remove duplicate files"},
 	{"Uninstall", "Remove apps and all related files"},
+	{"Restore", "Put back items moved to Trash"},
 }
 
 type Model struct {
@@ -139,8 +159,9 @@ type Model struct {
 	selected    map[int]bool
 
 	// Scanning progress state
-	scanStatuses   []scannerStatus
-	scanProgressCh chan engine.ScanProgress
+	scanStatuses []scannerStatus
+	scanStream   *engine.ScanStream
+	scanCancel   context.CancelFunc
 
 	categoryIdx    int
 	categoryCursor int
@@ -149,7 +170,13 @@ type Model struct {
 	// Result view state
 	lastCleaned int
 	lastFailed  int
-	lastSize    int64
+	lastSkipped int
+	// lastTrashed is the part of lastSize moved to Trash; lastMeasured is
+	// the reclaimed disk space as measured, or -1 if unknown.
+	lastTrashed  int64
+	lastMeasured int64
+	lastSize     int64
+	lastRunID    string
 
 	// Space Lens state
 	slPath         string
@@ -169,9 +196,9 @@ type Model struct {
 	dupGroups       []dupes.Group
 	dupLoading      bool
 	dupScanning     string // current file being scanned
-	dupDone         int    // files scanned so far
+	dupProgress     dupes.Progress
 	dupCancel       context.CancelFunc
-	dupProgressCh   chan string
+	dupProgressCh   chan dupes.Progress
 	dupCursor       int
 	dupScrollOffset int
 	dupSelected     map[string]bool // key: "groupIdx:fileIdx", tracks copies to delete
@@ -183,7 +210,9 @@ type Model struct {
 	cleaning      bool
 	cleanDone     int
 	cleanTotal    int
+	cleanFreed    int64
 	cleanDoneCh   chan cleanProgressMsg
+	cleanCancel   context.CancelFunc
 	dupCleaning   bool
 	dupCleanDone  int
 	dupCleanTotal int
@@ -203,6 +232,13 @@ type Model struct {
 	uiFailed          int
 	uiFreed           int64
 
+	// Restore state
+	rsRuns         []*manifest.Manifest
+	rsCursor       int
+	rsScrollOffset int
+	rsOutcomes     []manifest.Outcome
+	rsErr          error
+
 	// Animation state
 	animStart    time.Time
 	animDuration time.Duration
@@ -247,44 +283,45 @@ func (m Model) animatedSize(target int64) int64 {
 }
 
 func (m *Model) startScan() tea.Cmd {
-	ch := make(chan engine.ScanProgress, 20)
-	m.scanProgressCh = ch
-
-	return tea.Batch(
-		m.doScan(ch),
-		waitForScanProgress(ch),
-	)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.scanCancel = cancel
+	m.scanStream = m.engine.Stream(ctx, 4)
+	return waitForScanProgress(m.scanStream)
 }
 
-func (m Model) doScan(ch chan engine.ScanProgress) tea.Cmd {
-	return func() tea.Msg {
-		results := m.engine.ScanGroupedWithProgress(context.Background(), 4, func(p engine.ScanProgress) {
-			select {
-			case ch <- p:
-			default:
+// stopScan cancels a running scan and drains its remaining events so the
+// scanners can finish.
+func (m *Model) stopScan() {
+	if m.scanCancel != nil {
+		m.scanCancel()
+	}
+	if st := m.scanStream; st != nil {
+		go func() {
+			for range st.Events {
 			}
-		})
-		close(ch)
-		return scanDoneMsg{results: results}
+		}()
 	}
+	m.scanStream = nil
+	m.scanCancel = nil
 }
 
-func waitForScanProgress(ch chan engine.ScanProgress) tea.Cmd {
+func waitForScanProgress(st *engine.ScanStream) tea.Cmd {
 	return func() tea.Msg {
-		p, ok := <-ch
+		p, ok := <-st.Events
 		if !ok {
-			return nil
+			return scanDoneMsg{stream: st, results: st.Results()}
 		}
-		return scanProgressMsg{progress: p}
+		return scanProgressMsg{stream: st, progress: p}
 	}
 }
 
-func startSpaceLens(path string) (context.CancelFunc, chan string, tea.Cmd) {
+func startSpaceLens(path string, mode utils.SizeMode) (context.CancelFunc, chan string, tea.Cmd) {
 	ctx, cancel := context.WithCancel(context.Background())
 	ch := make(chan string, 1)
 
 	analyzeCmd := func() tea.Msg {
 		sl := scanner.NewSpaceLens(path, 1)
+		sl.SetSizeMode(mode)
 		sl.SetProgress(func(name string) {
 			select {
 			case ch <- name:
@@ -332,25 +369,41 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case scanProgressMsg:
+		if msg.stream != m.scanStream {
+			return m, nil
+		}
+		p := msg.progress
 		for i, ss := range m.scanStatuses {
-			if ss.name == msg.progress.Name {
-				m.scanStatuses[i].status = msg.progress.Status
-				if msg.progress.Status == engine.ScanDone {
-					m.scanStatuses[i].count = len(msg.progress.Targets)
-					var size int64
-					for _, t := range msg.progress.Targets {
-						size += t.Size
-					}
-					m.scanStatuses[i].size = size
+			if ss.name == p.Name {
+				switch p.Status {
+				case engine.ScanFound, engine.ScanMeasured:
+					m.scanStatuses[i].status = engine.ScanStarted
+				case engine.ScanResolved:
+					// Final totals, with bytes other categories own removed.
+					m.scanStatuses[i].count = p.Items
+					m.scanStatuses[i].size = p.Size
+					return m, waitForScanProgress(m.scanStream)
+				default:
+					m.scanStatuses[i].status = p.Status
+				}
+				m.scanStatuses[i].count = p.Items
+				m.scanStatuses[i].size = p.Size
+				m.scanStatuses[i].bytes = p.Bytes
+				if p.Status == engine.ScanDone {
+					m.scanStatuses[i].err = p.Error
 				}
 				break
 			}
 		}
-		return m, waitForScanProgress(m.scanProgressCh)
+		return m, waitForScanProgress(m.scanStream)
 
 	case scanDoneMsg:
+		if msg.stream != m.scanStream {
+			return m, nil
+		}
 		m.scanning = false
-		m.scanProgressCh = nil
+		m.scanStream = nil
+		m.scanCancel = nil
 		m.results = msg.results
 
 		// Sort categories by total size descending.
@@ -380,6 +433,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case cleanProgressMsg:
 		m.cleanDone = msg.done
 		m.cleanTotal = msg.total
+		m.cleanFreed = msg.freed
 		if m.cleanDoneCh != nil {
 			return m, listenCleanProgress(m.cleanDoneCh)
 		}
@@ -388,26 +442,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case cleanDoneMsg:
 		m.cleaning = false
 		m.cleanDoneCh = nil
+		m.cleanCancel = nil
 		m.lastCleaned = msg.cleaned
 		m.lastFailed = msg.failed
+		m.lastSkipped = msg.skipped
+		m.lastTrashed = msg.trashed
+		m.lastMeasured = -1
+		if msg.measuredOK {
+			m.lastMeasured = max(msg.measured, 0)
+		}
 		m.lastSize = msg.size
+		m.lastRunID = msg.runID
 		m.currentView = viewResult
 		m.animStart = time.Now()
 		m.animDuration = 500 * time.Millisecond
 		m.animating = true
 
-		// Record cleanup history.
-		if msg.cleaned > 0 && m.categoryIdx < len(m.results) {
-			h := history.New(history.DefaultPath())
-			_ = h.Record(history.Entry{
-				Timestamp:  time.Now(),
-				Category:   m.results[m.categoryIdx].Category,
-				Items:      msg.cleaned,
-				BytesFreed: msg.size,
-				Method:     "trash",
-			})
-		}
-
 		return m, animTick()
 
 	case spaceLensProgressMsg:
@@ -433,8 +483,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case dupesProgressMsg:
-		m.dupDone++
-		m.dupScanning = msg.path
+		m.dupProgress = msg.progress
+		m.dupScanning = msg.progress.Path
 		if m.dupProgressCh != nil {
 			return m, listenDupesProgress(m.dupProgressCh)
 		}
@@ -471,6 +521,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.dupDeleted = msg.deleted
 		m.dupFailed = msg.failed
 		m.dupFreed = msg.freed
+		m.lastRunID = msg.runID
 		m.currentView = viewDupesResult
 		return m, nil
 
@@ -493,7 +544,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Re-use viewResult for the uninstall result display.
 		m.lastCleaned = msg.deleted
 		m.lastFailed = msg.failed
+		m.lastSkipped = 0
+		m.lastTrashed = 0
+		m.lastMeasured = -1
 		m.lastSize = msg.freed
+		m.lastRunID = msg.runID
 		m.currentView = viewResult
 		return m, nil
 
@@ -508,6 +563,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		// While cleaning, ctrl+c and esc stop before the next deletion and
+		// the result view reports what was left; other keys are ignored.
+		if m.cleaning {
+			if (msg.String() == "ctrl+c" || msg.String() == "esc") && m.cleanCancel != nil {
+				m.cleanCancel()
+			}
+			return m, nil
+		}
+
 		// Global quit.
 		if msg.String() == "ctrl+c" || msg.String() == "q" {
 			return m, tea.Quit
@@ -546,6 +610,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateUninstallResults(msg)
 		case viewUninstallConfirm:
 			return m.updateUninstallConfirm(msg)
+		case viewRestore:
+			return m.updateRestore(msg)
+		case viewRestoreConfirm:
+			return m.updateRestoreConfirm(msg)
+		case viewRestoreResult:
+			return m.updateRestoreResult(msg)
 		}
 	}
 
@@ -580,7 +650,7 @@ func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.slScrollOffset = 0
 			m.slPath = "/"
 			m.currentView = viewSpaceLens
-			cancel, ch, cmd := startSpaceLens(m.slPath)
+			cancel, ch, cmd := startSpaceLens(m.slPath, m.engine.SizeMode())
 			m.slCancel = cancel
 			m.slProgressCh = ch
 			return m, tea.Batch(cmd, m.spinner.Tick)
@@ -589,7 +659,7 @@ func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(m.doMaintain(), m.spinner.Tick)
 		case 3: // Duplicates
 			m.dupLoading = true
-			m.dupDone = 0
+			m.dupProgress = dupes.Progress{}
 			m.dupCursor = 0
 			m.dupScrollOffset = 0
 			m.currentView = viewDupes
@@ -606,6 +676,9 @@ func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.uiTargets = nil
 			m.uiSelected = make(map[int]bool)
 			m.currentView = viewUninstallInput
+		case 5: // Restore
+			m.loadRestoreRuns()
+			m.currentView = viewRestore
 		}
 	}
 	return m, nil
@@ -737,6 +810,10 @@ func (m Model) updateResult(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cursor = 0
 		m.currentView = viewScanning
 		return m, tea.Batch(m.spinner.Tick, m.startScan())
+	case "u":
+		if m.lastRunID != "" {
+			m.openRestoreRun(m.lastRunID)
+		}
 	case "esc", "backspace":
 		m.currentView = viewMenu
 		m.cursor = 0
@@ -776,7 +853,7 @@ func (m Model) updateSpaceLens(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.slLoading = true
 			m.slCursor = 0
 			m.slScrollOffset = 0
-			cancel, ch, cmd := startSpaceLens(m.slPath)
+			cancel, ch, cmd := startSpaceLens(m.slPath, m.engine.SizeMode())
 			m.slCancel = cancel
 			m.slProgressCh = ch
 			return m, tea.Batch(cmd, m.spinner.Tick)
@@ -794,7 +871,7 @@ func (m Model) updateSpaceLens(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.slLoading = true
 			m.slCursor = 0
 			m.slScrollOffset = 0
-			cancel, ch, cmd := startSpaceLens(m.slPath)
+			cancel, ch, cmd := startSpaceLens(m.slPath, m.engine.SizeMode())
 			m.slCancel = cancel
 			m.slProgressCh = ch
 			return m, tea.Batch(cmd, m.spinner.Tick)
@@ -816,7 +893,7 @@ func (m Model) updateSpaceLensConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.slCursor = 0
 			m.slScrollOffset = 0
 			m.currentView = viewSpaceLens
-			cancel, ch, cmd := startSpaceLens(m.slPath)
+			cancel, ch, cmd := startSpaceLens(m.slPath, m.engine.SizeMode())
 			m.slCancel = cancel
 			m.slProgressCh = ch
 			return m, tea.Batch(cmd, m.spinner.Tick)
@@ -838,9 +915,9 @@ func (m Model) updateMaintainResult(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func startDupesScan() (context.CancelFunc, chan string, tea.Cmd) {
+func startDupesScan() (context.CancelFunc, chan dupes.Progress, tea.Cmd) {
 	ctx, cancel := context.WithCancel(context.Background())
-	ch := make(chan string, 1)
+	ch := make(chan dupes.Progress, 1)
 
 	home := utils.HomeDir()
 	dirs := []string{
@@ -850,12 +927,14 @@ func startDupesScan() (context.CancelFunc, chan string, tea.Cmd) {
 	}
 
 	scanCmd := func() tea.Msg {
-		groups, _ := dupes.FindWithProgress(ctx, dirs, 0, func(path string) {
+		cache, _ := dupes.LoadHashCache(scancache.HashCachePath())
+		groups, _ := dupes.FindWithProgress(dupes.WithHashCache(ctx, cache), dirs, 0, func(p dupes.Progress) {
 			select {
-			case ch <- path:
+			case ch <- p:
 			default:
 			}
 		})
+		_ = cache.Save()
 		close(ch)
 		return dupesDoneMsg{groups: groups}
 	}
@@ -863,13 +942,13 @@ func startDupesScan() (context.CancelFunc, chan string, tea.Cmd) {
 	return cancel, ch, tea.Batch(scanCmd, listenDupesProgress(ch))
 }
 
-func listenDupesProgress(ch chan string) tea.Cmd {
+func listenDupesProgress(ch chan dupes.Progress) tea.Cmd {
 	return func() tea.Msg {
-		path, ok := <-ch
+		p, ok := <-ch
 		if !ok {
 			return nil
 		}
-		return dupesProgressMsg{path: path}
+		return dupesProgressMsg{progress: p}
 	}
 }
 
@@ -882,6 +961,9 @@ type dupesEntry struct {
 	hash     string
 	isKeep   bool
 	isHeader bool
+	// files is the number of files in each directory of a directory
+	// group, zero for file groups.
+	files int
 }
 
 func (m Model) dupesFlatList() []dupesEntry {
@@ -893,8 +975,12 @@ func (m Model) dupesFlatList() []dupesEntry {
 			isHeader: true,
 			size:     wasted,
 			hash:     g.Hash,
+			files:    g.FileCount,
 		})
 		for fi, f := range g.Files {
+			if g.Dir {
+				f += string(filepath.Separator)
+			}
 			entries = append(entries, dupesEntry{
 				groupIdx: gi,
 				fileIdx:  fi,
@@ -996,7 +1082,7 @@ func (m Model) updateDupesResult(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "r":
 		// Re-scan.
 		m.dupLoading = true
-		m.dupDone = 0
+		m.dupProgress = dupes.Progress{}
 		m.dupCursor = 0
 		m.dupScrollOffset = 0
 		m.currentView = viewDupes
@@ -1004,6 +1090,10 @@ func (m Model) updateDupesResult(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.dupCancel = cancel
 		m.dupProgressCh = ch
 		return m, tea.Batch(cmd, m.spinner.Tick)
+	case "u":
+		if m.lastRunID != "" {
+			m.openRestoreRun(m.lastRunID)
+		}
 	case "esc", "backspace", "enter":
 		m.currentView = viewMenu
 		m.cursor = 3
@@ -1034,15 +1124,17 @@ func (m *Model) doDupesClean() tea.Cmd {
 		var deleted, failed int
 		var freed int64
 		var done int
+		run := manifest.New("dupes")
 		for gi, g := range m.dupGroups {
 			for fi, f := range g.Files {
 				key := fmt.Sprintf("%d:%d", gi, fi)
 				if !m.dupSelected[key] {
 					continue
 				}
-				if err := trash.MoveToTrash(f); err != nil {
+				if trashed, err := trash.Move(f); err != nil {
 					failed++
 				} else {
+					run.Add(f, trashed, g.Size, "")
 					deleted++
 					freed += g.Size
 				}
@@ -1054,7 +1146,7 @@ func (m *Model) doDupesClean() tea.Cmd {
 			}
 		}
 		close(ch)
-		return dupesCleanDoneMsg{deleted: deleted, failed: failed, freed: freed}
+		return dupesCleanDoneMsg{deleted: deleted, failed: failed, freed: freed, runID: manifest.SaveRun(run)}
 	}
 
 	return tea.Batch(cleanCmd, listenDupesCleanProgress(ch))
@@ -1188,18 +1280,20 @@ func (m Model) doUninstallClean() tea.Cmd {
 	return func() tea.Msg {
 		var deleted, failed int
 		var freed int64
+		run := manifest.New("uninstall")
 		for i, t := range m.uiTargets {
 			if !m.uiSelected[i] {
 				continue
 			}
-			if err := trash.MoveToTrash(t.Path); err != nil {
+			if trashed, err := trash.Move(t.Path); err != nil {
 				failed++
 			} else {
+				run.Add(t.Path, trashed, t.Size, t.Category)
 				deleted++
 				freed += t.Size
 			}
 		}
-		return uninstallCleanDoneMsg{deleted: deleted, failed: failed, freed: freed}
+		return uninstallCleanDoneMsg{deleted: deleted, failed: failed, freed: freed, runID: manifest.SaveRun(run)}
 	}
 }
 
@@ -1217,44 +1311,100 @@ func (m *Model) doClean() tea.Cmd {
 	if m.categoryIdx >= len(m.results) {
 		return func() tea.Msg { return cleanDoneMsg{} }
 	}
-	targets := m.results[m.categoryIdx].Targets
-
-	// Count selected items for progress total.
-	var total int
-	for i := range targets {
+	var targets []scanner.Target
+	for i, t := range m.results[m.categoryIdx].Targets {
 		if m.selected[i] {
-			total++
+			targets = append(targets, t)
 		}
 	}
 
 	ch := make(chan cleanProgressMsg, 1)
+	ctx, cancel := context.WithCancel(context.Background())
 	m.cleaning = true
 	m.cleanDone = 0
-	m.cleanTotal = total
+	m.cleanTotal = len(targets)
+	m.cleanFreed = 0
 	m.cleanDoneCh = ch
+	m.cleanCancel = cancel
+
+	executor := cleanup.NewExecutor(false)
+	executor.SetRoots(m.engine.Roots())
+	var paths []string
+	for _, t := range targets {
+		if t.IsFilesystem() {
+			paths = append(paths, t.Path)
+		}
+	}
+	vols := utils.Volumes(append(paths, utils.HomeDir()))
 
 	cleanCmd := func() tea.Msg {
-		var cleaned, failed int
-		var totalSize int64
-		var done int
-		for i, t := range targets {
-			if !m.selected[i] {
-				continue
+		defer cancel()
+		freeBefore := utils.FreeOn(vols)
+		results := executor.ExecuteAllWithProgress(ctx, targets, func(p cleanup.Progress) {
+			select {
+			case ch <- cleanProgressMsg{done: p.Done, total: p.Total, freed: p.Freed}:
+			default:
 			}
-			if err := trash.MoveToTrash(t.Path); err != nil {
+		})
+		freeAfter := utils.FreeOn(vols)
+		close(ch)
+
+		var cleaned, failed, skipped int
+		var totalSize, trashed int64
+		run := manifest.New("clean")
+		hrun := history.Run{Timestamp: run.Timestamp, Command: "clean"}
+		byMethod := make(map[string]*history.Entry)
+		for _, r := range results {
+			t := r.Target
+			if !r.Skipped {
+				hrun.Items = append(hrun.Items, r.HistoryItem())
+			}
+			switch {
+			case r.Skipped:
+				skipped++
+			case r.Err != nil:
 				failed++
-			} else {
+			default:
+				e := byMethod[r.Method]
+				if e == nil {
+					e = &history.Entry{Category: t.Category, Method: r.Method}
+					byMethod[r.Method] = e
+				}
+				e.Items++
+				e.BytesFreed += t.Size
+				if r.Method == cleanup.MethodTrash {
+					run.Add(t.Path, r.TrashedPath, t.Size, t.Category)
+					trashed += t.Size
+					e.BytesTrashed += t.Size
+				}
 				cleaned++
 				totalSize += t.Size
 			}
-			done++
-			select {
-			case ch <- cleanProgressMsg{done: done, total: total}:
-			default:
+		}
+		runID := manifest.SaveRun(run)
+		measured := utils.Reclaimed(freeBefore, freeAfter)
+
+		if len(hrun.Items) > 0 {
+			hrun.ID = runID
+			hrun.BytesMeasured = measured
+			for _, method := range []string{cleanup.MethodTrash, cleanup.MethodPermanent, cleanup.MethodCommand} {
+				if e := byMethod[method]; e != nil {
+					hrun.Entries = append(hrun.Entries, *e)
+				}
 			}
+			_ = history.New(history.DefaultPath()).RecordRun(hrun)
+		}
+
+		return cleanDoneMsg{
+			cleaned:    cleaned,
+			failed:     failed,
+			skipped:    skipped,
+			size:       totalSize,
+			trashed:    trashed,
+			measured:   measured,
+			measuredOK: len(freeAfter) > 0,
+			runID:      runID,
 		}
-		close(ch)
-		return cleanDoneMsg{cleaned: cleaned, failed: failed, size: totalSize}
 	}
 
 	return tea.Batch(cleanCmd, listenCleanProgress(ch))
@@ -1296,6 +1446,12 @@ func (m Model) View() string {
 		return m.viewUninstallResults()
 	case viewUninstallConfirm:
 		return m.viewUninstallConfirm()
+	case viewRestore:
+		return m.viewRestore()
+	case viewRestoreConfirm:
+		return m.viewRestoreConfirm()
+	case viewRestoreResult:
+		return m.viewRestoreResult()
 	default:
 		return m.viewMenu()
 	}
@@ -1320,7 +1476,7 @@ func (m Model) updateScanning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.String() == "esc" || msg.String() == "backspace" {
 		m.scanning = false
 		m.scanStatuses = nil
-		m.scanProgressCh = nil
+		m.stopScan()
 		m.currentView = viewMenu
 		m.cursor = 0
 	}
@@ -1330,7 +1486,12 @@ func (m Model) updateScanning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m Model) viewScanProgress() string {
 	s := renderHeader("Clean")
 
+	var totalCount int
+	var totalSize int64
 	for _, ss := range m.scanStatuses {
+		totalCount += ss.count
+		totalSize += ss.size
+
 		var icon, detail string
 		switch ss.status {
 		case engine.ScanWaiting:
@@ -1339,13 +1500,27 @@ func (m Model) viewScanProgress() string {
 		case engine.ScanStarted:
 			icon = m.spinner.View()
 			detail = "scanning..."
+			if ss.count > 0 {
+				detail = fmt.Sprintf("%d items   %s", ss.count, utils.FormatSize(ss.size))
+			}
+			if ss.bytes > 0 {
+				detail += dimStyle.Render(fmt.Sprintf("   (%s checked)", utils.FormatSize(ss.bytes)))
+			}
 		case engine.ScanDone:
 			icon = successStyle.Render("✓")
 			detail = fmt.Sprintf("%d items   %s", ss.count, utils.FormatSize(ss.size))
+			if errs := engine.AsScanErrors(ss.err); len(errs) > 0 {
+				icon = failStyle.Render("✗")
+				detail = failStyle.Render("failed")
+				if errs[0].TimedOut() {
+					detail = failStyle.Render(fmt.Sprintf("timed out after %s", errs[0].Timeout))
+				}
+			}
 		}
 		line := fmt.Sprintf("  %s %-20s %s", icon, ss.name, detail)
 		s += line + "\n"
 	}
+	s += fmt.Sprintf("\n  Found so far: %d items   %s\n", totalCount, utils.FormatSize(totalSize))
 
 	s += renderFooter("esc cancel | q quit")
 	return s
@@ -1449,6 +1624,9 @@ func (m Model) viewCategory() string {
 
 		line := fmt.Sprintf("%s%s %-35s %10s",
 			cursor, check, truncPath(t.Path, 35), utils.FormatSize(t.Size))
+		if n := len(t.Contains); n > 0 {
+			line += fmt.Sprintf("  +%d nested", n)
+		}
 
 		if i == m.categoryCursor {
 			s += selectedStyle.Render(line) + "\n"
@@ -1489,15 +1667,16 @@ func (m Model) viewConfirm() string {
 		s += "\n" + m.spinner.View() + " Cleaning...\n"
 		if m.cleanTotal > 0 {
 			ratio := float64(m.cleanDone) / float64(m.cleanTotal)
-			s += fmt.Sprintf("  %s %d/%d items\n", renderProgressBar(ratio, 30), m.cleanDone, m.cleanTotal)
+			s += fmt.Sprintf("  %s %d/%d items · %s freed\n", renderProgressBar(ratio, 30), m.cleanDone, m.cleanTotal, utils.FormatSize(m.cleanFreed))
 		}
+		s += renderFooter("esc stop")
 		return s
 	}
 
 	s += dangerBannerStyle.Render(" CONFIRM DELETION ") + "\n\n"
 
 	var selectedSize int64
-	var selectedCount int
+	var selectedCount, commandCount int
 	var hasRisky bool
 	for i, t := range r.Targets {
 		if m.selected[i] {
@@ -1512,6 +1691,13 @@ func (m Model) viewConfirm() string {
 				riskLabel = fmt.Sprintf(" [%s]", t.Risk)
 			}
 			s += fmt.Sprintf("  %s (%s)%s\n", truncPath(t.Path, 45), utils.FormatSize(t.Size), riskLabel)
+			for _, p := range t.Contains {
+				s += dimStyle.Render("    also removes "+truncPath(p, 40)+"; skipped unless that is cleaned too") + "\n"
+			}
+			if !t.IsFilesystem() {
+				commandCount++
+				s += dimStyle.Render("    runs: "+t.Action.String()) + "\n"
+			}
 		}
 	}
 
@@ -1521,6 +1707,9 @@ func (m Model) viewConfirm() string {
 	}
 
 	s += fmt.Sprintf("  %d items | %s | will be moved to Trash (recoverable)\n", selectedCount, utils.FormatSize(selectedSize))
+	if commandCount > 0 {
+		s += warnStyle.Render(fmt.Sprintf("  %d items are removed by running a cleanup command (not recoverable)", commandCount)) + "\n"
+	}
 	s += renderFooter("y confirm | n cancel | q quit")
 	return s
 }
@@ -1533,7 +1722,21 @@ func (m Model) viewResult() string {
 	if m.lastFailed > 0 {
 		s += failStyle.Render(fmt.Sprintf("  Failed:  %d items", m.lastFailed)) + "\n"
 	}
+	if m.lastSkipped > 0 {
+		s += warnStyle.Render(fmt.Sprintf("  Stopped: %d items not cleaned", m.lastSkipped)) + "\n"
+	}
+	if m.lastTrashed > 0 {
+		s += dimStyle.Render(fmt.Sprintf("  Moved to Trash: %s (freed once the Trash is emptied)", utils.FormatSize(m.lastTrashed))) + "\n"
+	}
+	if m.lastMeasured >= 0 {
+		s += dimStyle.Render(fmt.Sprintf("  Disk space reclaimed: %s measured", utils.FormatSize(m.lastMeasured))) + "\n"
+	}
 
+	if m.lastRunID != "" {
+		s += dimStyle.Render("  Saved as run "+m.lastRunID) + "\n"
+		s += renderFooter("r re-scan | u undo | esc menu | q quit")
+		return s
+	}
 	s += renderFooter("r re-scan | esc menu | q quit")
 	return s
 }
@@ -1596,7 +1799,12 @@ func (m Model) viewDupes() string {
 
 	if m.dupLoading {
 		s += "\n"
-		s += m.spinner.View() + fmt.Sprintf(" Scanning... %d files checked", m.dupDone) + "\n"
+		if p := m.dupProgress; p.Phase == dupes.PhaseWalk {
+			s += m.spinner.View() + fmt.Sprintf(" Scanning... %d files checked", p.Files) + "\n"
+		} else {
+			s += m.spinner.View() + fmt.Sprintf(" Hashing... %s / %s (%d/%d files)",
+				utils.FormatSize(p.Bytes), utils.FormatSize(p.TotalBytes), p.Files, p.TotalFiles) + "\n"
+		}
 		if m.dupScanning != "" {
 			name := m.dupScanning
 			if len(name) > 50 {
@@ -1637,8 +1845,12 @@ func (m Model) viewDupes() string {
 			if len(hashShort) > 12 {
 				hashShort = hashShort[:12]
 			}
-			s += dimStyle.Render(fmt.Sprintf("  --- Group %d: %s wasted (hash: %s...) ---",
-				e.groupIdx+1, utils.FormatSize(e.size), hashShort)) + "\n"
+			kind := ""
+			if e.files > 0 {
+				kind = fmt.Sprintf("directories of %d files, ", e.files)
+			}
+			s += dimStyle.Render(fmt.Sprintf("  --- Group %d: %s%s wasted (hash: %s...) ---",
+				e.groupIdx+1, kind, utils.FormatSize(e.size), hashShort)) + "\n"
 			continue
 		}
 
@@ -1735,6 +1947,11 @@ func (m Model) viewDupesResult() string {
 		s += failStyle.Render(fmt.Sprintf("  Failed:  %d items", m.dupFailed)) + "\n"
 	}
 
+	if m.lastRunID != "" {
+		s += dimStyle.Render("  Saved as run "+m.lastRunID) + "\n"
+		s += renderFooter("r re-scan | u undo | esc menu | q quit")
+		return s
+	}
 	s += renderFooter("r re-scan | esc menu | q quit")
 	return s
 }
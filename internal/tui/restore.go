@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lu-zhengda/macbroom/internal/manifest"
+	"github.com/lu-zhengda/macbroom/internal/utils"
+)
+
+// restoreMenuIdx is the position of "Restore" in menuItems.
+const restoreMenuIdx = 5
+
+func (m *Model) loadRestoreRuns() {
+	m.rsRuns, m.rsErr = manifest.NewStore(manifest.DefaultDir()).List()
+	m.rsCursor = 0
+	m.rsScrollOffset = 0
+	m.rsOutcomes = nil
+}
+
+// openRestoreRun jumps straight to the confirmation for run id, used by the
+// "u" (undo) key on result screens.
+func (m *Model) openRestoreRun(id string) {
+	m.loadRestoreRuns()
+	for i, r := range m.rsRuns {
+		if r.ID == id {
+			m.rsCursor = i
+			m.currentView = viewRestoreConfirm
+			return
+		}
+	}
+	m.currentView = viewRestore
+}
+
+func (m Model) selectedRun() *manifest.Manifest {
+	if m.rsCursor < len(m.rsRuns) {
+		return m.rsRuns[m.rsCursor]
+	}
+	return nil
+}
+
+func (m Model) updateRestore(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.rsCursor > 0 {
+			m.rsCursor--
+			m.rsEnsureCursorVisible()
+		}
+	case "down", "j":
+		if m.rsCursor < len(m.rsRuns)-1 {
+			m.rsCursor++
+			m.rsEnsureCursorVisible()
+		}
+	case "enter", "d":
+		if run := m.selectedRun(); run != nil && run.Pending() > 0 {
+			m.currentView = viewRestoreConfirm
+		}
+	case "esc", "backspace":
+		m.currentView = viewMenu
+		m.cursor = restoreMenuIdx
+	}
+	return m, nil
+}
+
+func (m *Model) rsEnsureCursorVisible() {
+	visible := m.visibleItemCount()
+	if m.rsCursor < m.rsScrollOffset {
+		m.rsScrollOffset = m.rsCursor
+	}
+	if m.rsCursor >= m.rsScrollOffset+visible {
+		m.rsScrollOffset = m.rsCursor - visible + 1
+	}
+}
+
+func (m Model) updateRestoreConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y":
+		run := m.selectedRun()
+		if run == nil {
+			return m, nil
+		}
+		m.rsOutcomes = manifest.Restore(run)
+		m.rsErr = manifest.NewStore(manifest.DefaultDir()).Save(run)
+		m.currentView = viewRestoreResult
+	case "n", "esc", "backspace":
+		m.currentView = viewRestore
+	}
+	return m, nil
+}
+
+func (m Model) updateRestoreResult(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "backspace", "enter":
+		m.loadRestoreRuns()
+		m.currentView = viewRestore
+	}
+	return m, nil
+}
+
+func (m Model) viewRestore() string {
+	s := renderHeader("Restore")
+
+	if m.rsErr != nil {
+		s += failStyle.Render("  "+m.rsErr.Error()) + "\n"
+	}
+	if len(m.rsRuns) == 0 {
+		s += "No runs to restore.\n"
+		s += renderFooter("esc back | q quit")
+		return s
+	}
+
+	visible := m.visibleItemCount()
+	end := m.rsScrollOffset + visible
+	if end > len(m.rsRuns) {
+		end = len(m.rsRuns)
+	}
+	for i := m.rsScrollOffset; i < end; i++ {
+		r := m.rsRuns[i]
+		line := fmt.Sprintf("%s  %-10s %3d items  %10s",
+			r.Timestamp.Local().Format("2006-01-02 15:04"), r.Command, len(r.Items), utils.FormatSize(r.TotalSize()))
+		if r.Pending() == 0 {
+			line += "  (restored)"
+		}
+		if i == m.rsCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else if r.Pending() == 0 {
+			s += dimStyle.Render("  "+line) + "\n"
+		} else {
+			s += "  " + line + "\n"
+		}
+	}
+
+	s += renderFooter("j/k navigate | enter restore | esc back | q quit")
+	return s
+}
+
+func (m Model) viewRestoreConfirm() string {
+	run := m.selectedRun()
+	if run == nil {
+		return "Nothing to restore"
+	}
+
+	s := renderHeader("Restore", run.ID, "Confirm")
+
+	var pendingSize int64
+	for _, it := range run.Items {
+		if it.RestoredTo != "" {
+			continue
+		}
+		pendingSize += it.Size
+		s += fmt.Sprintf("  %s (%s)\n", truncPath(it.Original, 60), utils.FormatSize(it.Size))
+	}
+
+	s += fmt.Sprintf("\n  %d items | %s | will be moved back from Trash\n", run.Pending(), utils.FormatSize(pendingSize))
+	s += dimStyle.Render("  Items whose original path is taken are restored as \"name (restored)\".") + "\n"
+	s += renderFooter("y confirm | n cancel | q quit")
+	return s
+}
+
+func (m Model) viewRestoreResult() string {
+	s := renderHeader("Restore", "Complete")
+
+	var restored, missing, failed int
+	for _, o := range m.rsOutcomes {
+		switch o.Status {
+		case manifest.Restored:
+			restored++
+		case manifest.Renamed:
+			restored++
+			s += dimStyle.Render("  Restored as "+truncPath(o.Dest, 60)) + "\n"
+		case manifest.Missing:
+			missing++
+			s += warnStyle.Render("  No longer in Trash: "+truncPath(o.Item.Original, 50)) + "\n"
+		case manifest.Failed:
+			failed++
+			s += failStyle.Render(fmt.Sprintf("  Failed: %s (%v)", truncPath(o.Item.Original, 40), o.Err)) + "\n"
+		}
+	}
+
+	s += successStyle.Render(fmt.Sprintf("  Restored: %d items", restored)) + "\n"
+	if missing > 0 {
+		s += warnStyle.Render(fmt.Sprintf("  Missing:  %d items (Trash was emptied)", missing)) + "\n"
+	}
+	if failed > 0 {
+		s += failStyle.Render(fmt.Sprintf("  Failed:   %d items", failed)) + "\n"
+	}
+	if m.rsErr != nil {
+		s += failStyle.Render("  "+m.rsErr.Error()) + "\n"
+	}
+
+	s += renderFooter("esc back | q quit")
+	return s
+}
@@ -12,6 +12,7 @@ import (
 // SpaceLensModel is the standalone Space Lens TUI (used by `spacelens -i`).
 type SpaceLensModel struct {
 	path         string
+	sizeMode     utils.SizeMode
 	nodes        []scanner.SpaceLensNode
 	cursor       int
 	scrollOffset int
@@ -20,8 +21,8 @@ type SpaceLensModel struct {
 	height       int
 }
 
-func NewSpaceLensModel(path string) SpaceLensModel {
-	return SpaceLensModel{path: path, loading: true}
+func NewSpaceLensModel(path string, mode utils.SizeMode) SpaceLensModel {
+	return SpaceLensModel{path: path, sizeMode: mode, loading: true}
 }
 
 func (m SpaceLensModel) Init() tea.Cmd {
@@ -29,9 +30,10 @@ func (m SpaceLensModel) Init() tea.Cmd {
 }
 
 func (m SpaceLensModel) doAnalyze() tea.Cmd {
-	path := m.path
+	path, mode := m.path, m.sizeMode
 	return func() tea.Msg {
 		sl := scanner.NewSpaceLens(path, 1)
+		sl.SetSizeMode(mode)
 		nodes, _ := sl.Analyze(context.Background())
 		return spaceLensDoneMsg{nodes: nodes, path: path}
 	}
@@ -1,6 +1,9 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lu-zhengda/macbroom/internal/scanner"
+)
 
 // ---------------------------------------------------------------------------
 // Color palette -- single source of truth for all TUI colors.
@@ -21,26 +24,17 @@ var (
 )
 
 // ---------------------------------------------------------------------------
-// Category colors -- used in the dashboard view.
+// Category colors -- used in the dashboard view, taken from the scanner
+// registry.
 // ---------------------------------------------------------------------------
 
-var categoryColors = map[string]lipgloss.Color{
-	"System Junk":       lipgloss.Color("75"),
-	"Browser Cache":     lipgloss.Color("214"),
-	"Xcode Junk":        lipgloss.Color("141"),
-	"Large & Old Files": lipgloss.Color("223"),
-	"Docker":            lipgloss.Color("39"),
-	"Node.js":           lipgloss.Color("119"),
-	"Homebrew":          lipgloss.Color("208"),
-	"iOS Simulators":    lipgloss.Color("183"),
-	"Python":            lipgloss.Color("220"),
-	"Rust":              lipgloss.Color("173"),
-	"Go":                lipgloss.Color("74"),
-	"JetBrains":         lipgloss.Color("171"),
-	"Maven":             lipgloss.Color("167"),
-	"Gradle":            lipgloss.Color("108"),
-	"Ruby":              lipgloss.Color("161"),
-}
+var categoryColors = func() map[string]lipgloss.Color {
+	colors := make(map[string]lipgloss.Color)
+	for _, info := range scanner.Registry() {
+		colors[info.Name] = lipgloss.Color(info.Color)
+	}
+	return colors
+}()
 
 // CategoryColor returns the theme color for a scan category.
 // Unknown categories fall back to colorPrimary.
@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// dirCacheVersion is bumped whenever the on-disk record layout changes;
+// files with another version are discarded.
+const dirCacheVersion = 2
+
+// dirCacheTTL bounds how long a record is trusted, long enough to carry
+// sizes across daily and scheduled scans. Records are validated by the
+// directory's inode and mtime, which only change when entries are added,
+// removed or renamed; a file rewritten in place keeps its old size until
+// the record expires or a scan runs with --rescan.
+const dirCacheTTL = 7 * 24 * time.Hour
+
+// dirCacheSettle skips caching directories modified this recently, since a
+// change landing within the same mtime tick would go unnoticed.
+const dirCacheSettle = 2 * time.Second
+
+// DirCache persists the usage of individual directories between runs. Each
+// record holds the sizes of the files directly inside one directory and the
+// names of its subdirectories, keyed by path and validated against the
+// directory's device, inode and mtime. Unchanged directories are not read
+// again; changed ones are re-read without invalidating their subtrees.
+//
+// Hardlinked files are stored individually so the per-scan InodeSet still
+// counts each inode once on a cache hit. A nil *DirCache is valid and
+// measures everything from scratch. DirCache is safe for concurrent use.
+type DirCache struct {
+	path string
+	now  func() time.Time
+
+	refresh atomic.Bool
+	hits    atomic.Int64
+	misses  atomic.Int64
+
+	mu      sync.Mutex
+	records map[string]dirRecord
+	dirty   bool
+}
+
+type dirRecord struct {
+	Dev      uint64       `json:"dev"`
+	Ino      uint64       `json:"ino"`
+	MTime    int64        `json:"mtime"`
+	Computed int64        `json:"computed"`
+	Files    Usage        `json:"files"`
+	Links    []linkRecord `json:"links,omitempty"`
+	Subdirs  []string     `json:"subdirs,omitempty"`
+}
+
+type linkRecord struct {
+	Name  string `json:"name"`
+	Dev   uint64 `json:"dev"`
+	Ino   uint64 `json:"ino"`
+	Usage Usage  `json:"usage"`
+}
+
+type dirCacheFile struct {
+	Version int                  `json:"version"`
+	Records map[string]dirRecord `json:"records"`
+}
+
+// DirCacheStats counts directories served from the cache (Hits) and read
+// from disk (Misses).
+type DirCacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// HitRate returns the fraction of directories served from the cache.
+func (s DirCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewDirCache returns an empty cache that Save writes to path.
+func NewDirCache(path string) *DirCache {
+	return &DirCache{path: path, now: time.Now, records: make(map[string]dirRecord)}
+}
+
+// LoadDirCache reads the cache stored at path. A missing file yields an
+// empty cache. An unreadable or outdated file also yields an empty cache,
+// along with the error, so callers can carry on without it.
+func LoadDirCache(path string) (*DirCache, error) {
+	c := NewDirCache(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read dir size cache: %w", err)
+	}
+	var f dirCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return c, fmt.Errorf("failed to parse dir size cache: %w", err)
+	}
+	if f.Version != dirCacheVersion {
+		return c, nil
+	}
+	if f.Records != nil {
+		c.records = f.Records
+	}
+	return c, nil
+}
+
+// Save writes the cache back to its path if anything changed, dropping
+// expired records. The file is replaced atomically.
+func (c *DirCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	cutoff := c.now().Add(-dirCacheTTL).UnixNano()
+	for p, r := range c.records {
+		if r.Computed < cutoff {
+			delete(c.records, p)
+		}
+	}
+
+	data, err := json.Marshal(dirCacheFile{Version: dirCacheVersion, Records: c.records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal dir size cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create dir size cache directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write dir size cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write dir size cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// SetRefresh makes the cache ignore stored records, so every directory is
+// read from disk. Fresh records are still stored for the next run.
+func (c *DirCache) SetRefresh(refresh bool) {
+	if c != nil {
+		c.refresh.Store(refresh)
+	}
+}
+
+// Stats returns the hit and miss counts since the cache was loaded.
+func (c *DirCache) Stats() DirCacheStats {
+	if c == nil {
+		return DirCacheStats{}
+	}
+	return DirCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// DirUsage is like the package-level DirUsage but reuses cached records
+// for unchanged directories. It stops when ctx is done, returning the usage
+// counted so far with ctx's error.
+func (c *DirCache) DirUsage(ctx context.Context, path string, seen *InodeSet) (Usage, error) {
+	if seen == nil {
+		seen = NewInodeSet()
+	}
+	var u Usage
+	err := c.walk(ctx, path, seen, &u)
+	return u, err
+}
+
+// DirUsagesParallel is like the package-level DirUsagesParallel but reuses
+// cached records for unchanged directories.
+func (c *DirCache) DirUsagesParallel(ctx context.Context, paths []string, seen *InodeSet) map[string]Usage {
+	return c.DirUsagesParallelFunc(ctx, paths, seen, nil)
+}
+
+// DirUsagesParallelFunc is like DirUsagesParallel, and also calls fn, if
+// non-nil, with each path's usage as soon as it is known. Calls to fn are
+// serialized. Paths not measured in full before ctx is done are left out.
+func (c *DirCache) DirUsagesParallelFunc(ctx context.Context, paths []string, seen *InodeSet, fn func(path string, u Usage)) map[string]Usage {
+	if seen == nil {
+		seen = NewInodeSet()
+	}
+	result := make(map[string]Usage, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// Limit concurrency to avoid overwhelming the filesystem
+	sem := make(chan struct{}, 8)
+
+	for _, p := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			u, err := c.DirUsage(ctx, path, seen)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			result[path] = u
+			if fn != nil {
+				fn(path, u)
+			}
+			mu.Unlock()
+		}(p)
+	}
+
+	wg.Wait()
+	return result
+}
+
+// walk adds the usage of path to total. Like filepath.WalkDir it does not
+// follow symlinks and silently skips entries it cannot read. It checks ctx
+// before each directory and returns ctx's error once it is done.
+func (c *DirCache) walk(ctx context.Context, path string, seen *InodeSet, total *Usage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		total.Add(FileUsage(path, info, seen))
+		return nil
+	}
+
+	rec, ok := c.lookup(path, info)
+	if !ok {
+		var complete bool
+		rec, complete = readDirRecord(path, info)
+		c.store(path, info, rec, complete)
+	}
+
+	total.Add(rec.Files)
+	for _, l := range rec.Links {
+		if seen.claimID(fileID{dev: l.Dev, ino: l.Ino}, filepath.Join(path, l.Name)) {
+			total.Add(l.Usage)
+		}
+	}
+	for _, name := range rec.Subdirs {
+		if err := c.walk(ctx, filepath.Join(path, name), seen, total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the record for path if it is still valid for info.
+func (c *DirCache) lookup(path string, info fs.FileInfo) (dirRecord, bool) {
+	if c == nil {
+		return dirRecord{}, false
+	}
+	if c.refresh.Load() {
+		c.misses.Add(1)
+		return dirRecord{}, false
+	}
+	dev, ino := devIno(info)
+	c.mu.Lock()
+	rec, ok := c.records[path]
+	c.mu.Unlock()
+	if !ok || rec.Dev != dev || rec.Ino != ino || rec.MTime != info.ModTime().UnixNano() ||
+		c.now().Sub(time.Unix(0, rec.Computed)) > dirCacheTTL {
+		c.misses.Add(1)
+		return dirRecord{}, false
+	}
+	c.hits.Add(1)
+	return rec, true
+}
+
+// store records rec for path unless the listing was incomplete or the
+// directory changed too recently to trust its mtime.
+func (c *DirCache) store(path string, info fs.FileInfo, rec dirRecord, complete bool) {
+	if c == nil {
+		return
+	}
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !complete || now.Sub(info.ModTime()) < dirCacheSettle {
+		if _, ok := c.records[path]; ok {
+			delete(c.records, path)
+			c.dirty = true
+		}
+		return
+	}
+	rec.Dev, rec.Ino = devIno(info)
+	rec.MTime = info.ModTime().UnixNano()
+	rec.Computed = now.UnixNano()
+	c.records[path] = rec
+	c.dirty = true
+}
+
+// readDirRecord lists dir and sums the files directly inside it. It reports
+// false if the listing could not be read in full.
+func readDirRecord(dir string, info fs.FileInfo) (dirRecord, bool) {
+	var rec dirRecord
+	entries, err := os.ReadDir(dir)
+	complete := err == nil
+	for _, e := range entries {
+		if e.IsDir() {
+			rec.Subdirs = append(rec.Subdirs, e.Name())
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			complete = false
+			continue
+		}
+		if id, linked := hardlinkID(fi); linked {
+			rec.Links = append(rec.Links, linkRecord{Name: e.Name(), Dev: id.dev, Ino: id.ino, Usage: fileUsage(fi)})
+			continue
+		}
+		rec.Files.Add(fileUsage(fi))
+	}
+	return rec, complete
+}
+
+func devIno(info fs.FileInfo) (uint64, uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// settledCache returns a cache whose clock runs ahead of the filesystem so
+// freshly written test directories are old enough to be cached.
+func settledCache(t *testing.T, offset time.Duration) *DirCache {
+	t.Helper()
+	c := NewDirCache(filepath.Join(t.TempDir(), "dir-sizes.json"))
+	c.now = func() time.Time { return time.Now().Add(offset) }
+	return c
+}
+
+func TestDirCache_ReusesUnchangedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0o755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(sub, "b.txt"), make([]byte, 200), 0o644)
+
+	c := settledCache(t, time.Minute)
+	u, _ := c.DirUsage(context.Background(), dir, nil)
+	if u.Apparent != 300 {
+		t.Fatalf("first pass = %d, want 300", u.Apparent)
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 2 {
+		t.Errorf("first pass stats = %+v, want 0 hits, 2 misses", s)
+	}
+
+	u, _ = c.DirUsage(context.Background(), dir, nil)
+	if u.Apparent != 300 {
+		t.Errorf("second pass = %d, want 300", u.Apparent)
+	}
+	if s := c.Stats(); s.Hits != 2 {
+		t.Errorf("second pass stats = %+v, want 2 hits", s)
+	}
+}
+
+func TestDirCache_ChangedDirectoryIsReread(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0o755)
+	os.WriteFile(filepath.Join(sub, "b.txt"), make([]byte, 200), 0o644)
+
+	c := settledCache(t, time.Minute)
+	c.DirUsage(context.Background(), dir, nil)
+
+	os.WriteFile(filepath.Join(sub, "c.txt"), make([]byte, 50), 0o644)
+	// Make sure the mtime moves even on filesystems with coarse timestamps.
+	later := time.Now().Add(-10 * time.Second)
+	os.Chtimes(sub, later, later)
+
+	u, _ := c.DirUsage(context.Background(), dir, nil)
+	if u.Apparent != 250 {
+		t.Errorf("after change = %d, want 250", u.Apparent)
+	}
+}
+
+func TestDirCache_RecentDirectoriesNotCached(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	c := settledCache(t, 0)
+	c.DirUsage(context.Background(), dir, nil)
+	c.DirUsage(context.Background(), dir, nil)
+	if s := c.Stats(); s.Hits != 0 {
+		t.Errorf("directory modified just now must not be served from cache, stats = %+v", s)
+	}
+}
+
+func TestDirCache_Refresh(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	c := settledCache(t, time.Minute)
+	c.DirUsage(context.Background(), dir, nil)
+	c.SetRefresh(true)
+	c.DirUsage(context.Background(), dir, nil)
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 2 {
+		t.Errorf("refresh must bypass stored records, stats = %+v", s)
+	}
+}
+
+func TestDirCache_HardlinksOnHit(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	os.Mkdir(a, 0o755)
+	os.Mkdir(b, 0o755)
+	os.WriteFile(filepath.Join(a, "f.bin"), make([]byte, 1000), 0o644)
+	if err := os.Link(filepath.Join(a, "f.bin"), filepath.Join(b, "f.bin")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	c := settledCache(t, time.Minute)
+	c.DirUsage(context.Background(), dir, nil)
+	u, _ := c.DirUsage(context.Background(), dir, nil)
+	if c.Stats().Hits == 0 {
+		t.Fatal("expected the second pass to hit the cache")
+	}
+	if u.Apparent != 1000 {
+		t.Errorf("cached hardlinks counted %d bytes, want 1000", u.Apparent)
+	}
+}
+
+func TestDirCache_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	c := settledCache(t, time.Minute)
+	c.DirUsage(context.Background(), dir, nil)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadDirCache(c.path)
+	if err != nil {
+		t.Fatalf("LoadDirCache: %v", err)
+	}
+	loaded.now = c.now
+	u, _ := loaded.DirUsage(context.Background(), dir, nil)
+	if u.Apparent != 10 || loaded.Stats().Hits != 1 {
+		t.Errorf("loaded cache: usage %d, stats %+v", u.Apparent, loaded.Stats())
+	}
+}
+
+func TestDirCache_ExpiredRecordsDropped(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	c := settledCache(t, time.Minute)
+	c.DirUsage(context.Background(), dir, nil)
+	c.now = func() time.Time { return time.Now().Add(dirCacheTTL + time.Hour) }
+	c.DirUsage(context.Background(), dir, nil)
+	if c.Stats().Hits != 0 {
+		t.Error("expired record must not be reused")
+	}
+}
+
+func TestDirCache_StopsWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := settledCache(t, time.Minute)
+	if _, err := c.DirUsage(ctx, dir, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("DirUsage error = %v, want context.Canceled", err)
+	}
+	if got := c.DirUsagesParallel(ctx, []string{dir}, nil); len(got) != 0 {
+		t.Errorf("cancelled DirUsagesParallel = %v, want no usages", got)
+	}
+	if s := c.Stats(); s.Misses != 0 {
+		t.Errorf("stats = %+v, want no directories read", s)
+	}
+}
+
+func TestLoadDirCache_Missing(t *testing.T) {
+	c, err := LoadDirCache(filepath.Join(t.TempDir(), "nope.json"))
+	if err != nil || c == nil {
+		t.Fatalf("missing cache file should yield an empty cache, got %v, %v", c, err)
+	}
+}
+
+func TestLoadDirCache_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir-sizes.json")
+	os.WriteFile(path, []byte("{"), 0o644)
+	c, err := LoadDirCache(path)
+	if err == nil {
+		t.Error("expected an error for a corrupt cache file")
+	}
+	if c == nil {
+		t.Error("expected a usable empty cache alongside the error")
+	}
+}
+
+func TestDirCache_Nil(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), make([]byte, 10), 0o644)
+
+	var c *DirCache
+	u, _ := c.DirUsage(context.Background(), dir, nil)
+	if u.Apparent != 10 {
+		t.Errorf("nil cache usage = %d, want 10", u.Apparent)
+	}
+	if err := c.Save(); err != nil {
+		t.Errorf("nil cache Save: %v", err)
+	}
+}
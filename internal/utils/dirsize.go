@@ -1,60 +1,43 @@
 package utils
 
 import (
-	"io/fs"
-	"path/filepath"
-	"sync"
+	"context"
 	"sync/atomic"
 )
 
 // DirSize calculates the total size of all files in a directory tree.
-// Uses filepath.WalkDir for performance (avoids redundant stat calls).
+// Hardlinked files are counted once.
 func DirSize(path string) (int64, error) {
-	var size int64
-	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // skip inaccessible files
-		}
-		if !d.IsDir() {
-			info, err := d.Info()
-			if err != nil {
-				return nil
-			}
-			size += info.Size()
-		}
-		return nil
-	})
-	return size, err
+	u, err := DirUsage(path, nil)
+	return u.Apparent, err
+}
+
+// DirUsage calculates both the apparent and the allocated size of a
+// directory tree. Each hardlinked inode is counted once per seen set; when
+// seen is nil a fresh set is used for this tree.
+func DirUsage(path string, seen *InodeSet) (Usage, error) {
+	var c *DirCache
+	return c.DirUsage(context.Background(), path, seen)
 }
 
 // DirSizesParallel computes sizes for multiple paths concurrently.
 // Returns a map of path -> size.
 func DirSizesParallel(paths []string) map[string]int64 {
-	result := make(map[string]int64, len(paths))
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Limit concurrency to avoid overwhelming the filesystem
-	sem := make(chan struct{}, 8)
-
-	for _, p := range paths {
-		wg.Add(1)
-		go func(path string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			size, _ := DirSize(path)
-			mu.Lock()
-			result[path] = size
-			mu.Unlock()
-		}(p)
+	usages := DirUsagesParallel(paths, nil)
+	result := make(map[string]int64, len(usages))
+	for p, u := range usages {
+		result[p] = u.Apparent
 	}
-
-	wg.Wait()
 	return result
 }
 
+// DirUsagesParallel computes usages for multiple paths concurrently,
+// sharing seen across all of them. Returns a map of path -> usage.
+func DirUsagesParallel(paths []string, seen *InodeSet) map[string]Usage {
+	var c *DirCache
+	return c.DirUsagesParallel(context.Background(), paths, seen)
+}
+
 // DirSizeAtomic is a concurrent-safe version for use in goroutines.
 func DirSizeAtomic(path string, total *atomic.Int64) {
 	size, _ := DirSize(path)
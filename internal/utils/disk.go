@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// DiskFree returns the space available to unprivileged users on the volume
+// holding path.
+func DiskFree(path string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem: %w", err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// Volumes returns one directory on each distinct volume holding one of
+// paths, keyed by device. A path that does not exist is looked up through
+// its nearest existing ancestor.
+func Volumes(paths []string) map[uint64]string {
+	vols := make(map[uint64]string)
+	for _, p := range paths {
+		dir := filepath.Dir(filepath.Clean(p))
+		for {
+			var st unix.Stat_t
+			if err := unix.Stat(dir, &st); err == nil {
+				if _, ok := vols[uint64(st.Dev)]; !ok {
+					vols[uint64(st.Dev)] = dir
+				}
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return vols
+}
+
+// FreeOn returns DiskFree for each of vols, as returned by Volumes.
+// Volumes that cannot be measured are left out.
+func FreeOn(vols map[uint64]string) map[uint64]int64 {
+	free := make(map[uint64]int64, len(vols))
+	for dev, dir := range vols {
+		if n, err := DiskFree(dir); err == nil {
+			free[dev] = n
+		}
+	}
+	return free
+}
+
+// Reclaimed returns how much free space grew from before to after, two
+// FreeOn results, over the volumes measured both times.
+func Reclaimed(before, after map[uint64]int64) int64 {
+	var n int64
+	for dev, b := range before {
+		if a, ok := after[dev]; ok {
+			n += a - b
+		}
+	}
+	return n
+}
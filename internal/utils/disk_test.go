@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskFree(t *testing.T) {
+	free, err := DiskFree(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free <= 0 {
+		t.Errorf("expected free space, got %d", free)
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	dir := t.TempDir()
+	vols := Volumes([]string{
+		filepath.Join(dir, "a"),
+		filepath.Join(dir, "missing", "deeper", "b"), // resolved via dir
+	})
+	if len(vols) != 1 {
+		t.Fatalf("expected one volume, got %v", vols)
+	}
+	for _, v := range vols {
+		if v != dir {
+			t.Errorf("expected %s to represent the volume, got %s", dir, v)
+		}
+	}
+}
+
+func TestReclaimed(t *testing.T) {
+	before := map[uint64]int64{1: 100, 2: 500, 3: 10}
+	after := map[uint64]int64{1: 150, 2: 520}
+	if got := Reclaimed(before, after); got != 70 {
+		t.Errorf("Reclaimed() = %d, want 70 (volume 3 was not measured twice)", got)
+	}
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// SizeMode selects which of a file's two sizes is reported as its size.
+type SizeMode int
+
+const (
+	// SizeApparent is the file length (st_size), as shown by ls and Finder.
+	SizeApparent SizeMode = iota
+	// SizeAllocated is the space the file occupies on disk (st_blocks),
+	// which is what df and du account for. Sparse files such as Docker.raw
+	// allocate far less than their length.
+	SizeAllocated
+)
+
+func (m SizeMode) String() string {
+	if m == SizeAllocated {
+		return "allocated"
+	}
+	return "apparent"
+}
+
+// ParseSizeMode parses "apparent" or "allocated". An empty string means
+// apparent.
+func ParseSizeMode(s string) (SizeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "apparent":
+		return SizeApparent, nil
+	case "allocated", "disk":
+		return SizeAllocated, nil
+	default:
+		return SizeApparent, fmt.Errorf("unknown size mode %q (use apparent or allocated)", s)
+	}
+}
+
+// Usage is the size of a file or tree measured both ways.
+type Usage struct {
+	Apparent  int64 `json:"apparent"`
+	Allocated int64 `json:"allocated"`
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.Apparent += o.Apparent
+	u.Allocated += o.Allocated
+}
+
+// In returns the size selected by mode.
+func (u Usage) In(mode SizeMode) int64 {
+	if mode == SizeAllocated {
+		return u.Allocated
+	}
+	return u.Apparent
+}
+
+// IsZero reports whether the usage has no bytes either way.
+func (u Usage) IsZero() bool {
+	return u.Apparent == 0 && u.Allocated == 0
+}
+
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// InodeSet remembers hardlinked files that have already been counted so
+// each inode contributes its size once, no matter how many links to it
+// are walked. Files with a single link are never recorded. The link that
+// first claims an inode counts it every time it is walked, exactly like a
+// single-link file, so nested trees measured separately agree on its
+// bytes. It is safe for concurrent use.
+//
+// APFS clones are separate inodes that share blocks; stat cannot tell
+// them apart from ordinary copies, so each clone is counted in full.
+type InodeSet struct {
+	mu   sync.Mutex
+	seen map[fileID]string // inode -> path of the link that claimed it
+}
+
+// NewInodeSet returns an empty InodeSet.
+func NewInodeSet() *InodeSet {
+	return &InodeSet{seen: make(map[fileID]string)}
+}
+
+// claim reports whether the file at path described by info should be
+// counted: always for single-link files, and otherwise only through the
+// link that was seen first.
+func (s *InodeSet) claim(path string, info fs.FileInfo) bool {
+	id, linked := hardlinkID(info)
+	if !linked {
+		return true
+	}
+	return s.claimID(id, path)
+}
+
+// claimID records path as the link counting id unless another link got
+// there first, and reports whether path counts id. A nil set claims
+// everything.
+func (s *InodeSet) claimID(id fileID, path string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner, dup := s.seen[id]; dup {
+		return owner == path
+	}
+	s.seen[id] = path
+	return true
+}
+
+// hardlinkID returns the device and inode of a file with more than one
+// link. Single-link files report false.
+func hardlinkID(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink <= 1 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// FileUsage returns the usage of the single non-directory file at path.
+// Hardlinks already counted in seen through another link are reported as
+// zero. seen may be nil.
+func FileUsage(path string, info fs.FileInfo, seen *InodeSet) Usage {
+	if !seen.claim(path, info) {
+		return Usage{}
+	}
+	return fileUsage(info)
+}
+
+// fileUsage returns the usage of info without hardlink accounting.
+func fileUsage(info fs.FileInfo) Usage {
+	u := Usage{Apparent: info.Size(), Allocated: info.Size()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		u.Allocated = int64(st.Blocks) * 512
+	}
+	return u
+}
+
+type sizingKey struct{}
+
+type sizing struct {
+	mode SizeMode
+	seen *InodeSet
+}
+
+// WithSizing returns a context that tells scanners which size to report
+// and which InodeSet to share, so that a hardlinked file reachable from
+// several targets is counted once per scan.
+func WithSizing(ctx context.Context, mode SizeMode, seen *InodeSet) context.Context {
+	return context.WithValue(ctx, sizingKey{}, sizing{mode: mode, seen: seen})
+}
+
+// SizingFrom returns the size mode and InodeSet stored by WithSizing. A
+// context without sizing yields SizeApparent and a nil set, which
+// de-duplicates hardlinks only within each measured tree.
+func SizingFrom(ctx context.Context) (SizeMode, *InodeSet) {
+	if s, ok := ctx.Value(sizingKey{}).(sizing); ok {
+		return s.mode, s.seen
+	}
+	return SizeApparent, nil
+}
+
+type dirCacheKey struct{}
+
+// WithDirCache returns a context whose directory measurements go through
+// cache.
+func WithDirCache(ctx context.Context, cache *DirCache) context.Context {
+	return context.WithValue(ctx, dirCacheKey{}, cache)
+}
+
+// DirCacheFrom returns the cache stored by WithDirCache, or nil. A nil
+// *DirCache measures every directory from scratch.
+func DirCacheFrom(ctx context.Context) *DirCache {
+	c, _ := ctx.Value(dirCacheKey{}).(*DirCache)
+	return c
+}
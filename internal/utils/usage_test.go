@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSizeMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SizeMode
+		wantErr bool
+	}{
+		{"", SizeApparent, false},
+		{"apparent", SizeApparent, false},
+		{"Allocated", SizeAllocated, false},
+		{"disk", SizeAllocated, false},
+		{"blocks", SizeApparent, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSizeMode(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSizeMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseSizeMode(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDirUsage_HardlinksCountedOnce(t *testing.T) {
+	dir := t.TempDir()
+	orig := filepath.Join(dir, "a.bin")
+	os.WriteFile(orig, make([]byte, 4096), 0o644)
+	if err := os.Link(orig, filepath.Join(dir, "b.bin")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	u, err := DirUsage(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Apparent != 4096 {
+		t.Errorf("Apparent = %d, want 4096", u.Apparent)
+	}
+}
+
+func TestDirUsage_SharedSetAcrossTrees(t *testing.T) {
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+	orig := filepath.Join(dir1, "a.bin")
+	os.WriteFile(orig, make([]byte, 1000), 0o644)
+	if err := os.Link(orig, filepath.Join(dir2, "a.bin")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	seen := NewInodeSet()
+	u1, _ := DirUsage(dir1, seen)
+	u2, _ := DirUsage(dir2, seen)
+	if u1.Apparent+u2.Apparent != 1000 {
+		t.Errorf("shared set total = %d, want 1000", u1.Apparent+u2.Apparent)
+	}
+
+	// Without a shared set each tree counts the link on its own.
+	u2, _ = DirUsage(dir2, nil)
+	if u2.Apparent != 1000 {
+		t.Errorf("independent tree = %d, want 1000", u2.Apparent)
+	}
+}
+
+func TestDirUsage_SharedSetAcrossNestedTrees(t *testing.T) {
+	outer := t.TempDir()
+	inner := filepath.Join(outer, "inner")
+	os.Mkdir(inner, 0o755)
+	orig := filepath.Join(inner, "a.bin")
+	os.WriteFile(orig, make([]byte, 1000), 0o644)
+	if err := os.Link(orig, filepath.Join(t.TempDir(), "a.bin")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+
+	// Both trees reach the link through the same path, so both count it and
+	// subtracting the inner tree from the outer one leaves no bytes behind.
+	seen := NewInodeSet()
+	u1, _ := DirUsage(inner, seen)
+	u2, _ := DirUsage(outer, seen)
+	if u1.Apparent != 1000 || u2.Apparent != 1000 {
+		t.Errorf("inner = %d, outer = %d, want 1000 each", u1.Apparent, u2.Apparent)
+	}
+}
+
+func TestDirUsage_SparseFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "sparse.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const length = 64 << 20
+	if err := f.Truncate(length); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	u, err := DirUsage(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Apparent != length {
+		t.Errorf("Apparent = %d, want %d", u.Apparent, length)
+	}
+	if u.Allocated >= u.Apparent {
+		t.Errorf("Allocated = %d, expected far less than apparent %d", u.Allocated, u.Apparent)
+	}
+}
+
+func TestSizingFrom(t *testing.T) {
+	mode, seen := SizingFrom(context.Background())
+	if mode != SizeApparent || seen != nil {
+		t.Errorf("default sizing = %v, %v", mode, seen)
+	}
+
+	set := NewInodeSet()
+	mode, seen = SizingFrom(WithSizing(context.Background(), SizeAllocated, set))
+	if mode != SizeAllocated || seen != set {
+		t.Errorf("sizing = %v, %v", mode, seen)
+	}
+}